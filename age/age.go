@@ -0,0 +1,389 @@
+// age/age.go
+package age
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "math/rand"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/s3upload"
+)
+
+// Bucket is the outcome of the reads of the objects of one age range.
+type Bucket struct {
+    Label        string
+    MinAge       time.Duration
+    MaxAge       time.Duration // Zero for the last, open-ended bucket.
+    Objects      int64         // Listed objects of this age.
+    Sampled      int           // Objects kept to be read.
+    Requests     int64
+    Errors       int64
+    Bytes        int64
+    FromMetadata int64           // Reads whose age came from the upload time metadata rather than LastModified.
+    Latencies    stats.Latencies // Times of the successful reads.
+    FirstBytes   stats.Latencies // Times to the first byte of the successful reads.
+}
+
+// Percentile returns the p-th fraction (0-1) of the read times.
+func (b *Bucket) Percentile(q float64) time.Duration {
+    return b.Latencies.Percentile(q)
+}
+
+// FirstBytePercentile returns the p-th fraction (0-1) of the times to the first byte.
+func (b *Bucket) FirstBytePercentile(q float64) time.Duration {
+    return b.FirstBytes.Percentile(q)
+}
+
+// Average returns the mean read time.
+func (b *Bucket) Average() time.Duration {
+    return b.Latencies.Average()
+}
+
+// Result is the outcome of an object age read benchmark.
+type Result struct {
+    RunID   string
+    Prefix  string
+    Listed  int64
+    Buckets []Bucket // From the youngest to the oldest.
+    Elapsed time.Duration
+}
+
+// Failures returns the failed reads over all buckets.
+func (r Result) Failures() int64 {
+    var failures int64
+    for _, b := range r.Buckets {
+        failures += b.Errors
+    }
+    return failures
+}
+
+// object is a listed object that may be read.
+type object struct {
+    key          string
+    lastModified time.Time
+}
+
+// newBuckets returns the empty age buckets bounded by ageBucketMinutes.
+func newBuckets(bounds []int) []Bucket {
+    buckets := make([]Bucket, 0, len(bounds)+1)
+    var lower time.Duration
+    for _, m := range bounds {
+        upper := time.Duration(m) * time.Minute
+        label := fmt.Sprintf("%s-%s", formatAge(lower), formatAge(upper))
+        if lower == 0 {
+            label = "<" + formatAge(upper)
+        }
+        buckets = append(buckets, Bucket{Label: label, MinAge: lower, MaxAge: upper})
+        lower = upper
+    }
+    return append(buckets, Bucket{Label: ">=" + formatAge(lower), MinAge: lower})
+}
+
+// formatAge formats an age bound in the largest whole unit: days, hours or minutes.
+func formatAge(d time.Duration) string {
+    switch {
+    case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+        return fmt.Sprintf("%dd", d/(24*time.Hour))
+    case d >= time.Hour && d%time.Hour == 0:
+        return fmt.Sprintf("%dh", d/time.Hour)
+    default:
+        return fmt.Sprintf("%dm", d/time.Minute)
+    }
+}
+
+// bucketIndex returns the index of the bucket holding objects of age age.
+func bucketIndex(buckets []Bucket, age time.Duration) int {
+    for i, b := range buckets {
+        if b.MaxAge == 0 || age < b.MaxAge {
+            return i
+        }
+    }
+    return len(buckets) - 1
+}
+
+// Run lists the objects below prefix, groups them by age, then reads random objects of every
+// age bucket in turn for ageReadSeconds, so each bucket sees the same load over the same
+// period. The age of a read object is its upload time metadata when the object carries it
+// (see uploadTimeMetadata), else its LastModified time.
+func Run(cfg *config.Config, s3Clients []*s3.S3, prefix string) (Result, error) {
+    result := Result{RunID: cfg.NewRunID(), Prefix: prefix, Buckets: newBuckets(cfg.AgeBucketMinutes)}
+
+    samples, err := list(cfg, s3Clients[0], prefix, &result)
+    if err != nil {
+        return result, err
+    }
+    if result.Listed == 0 {
+        return result, fmt.Errorf("no objects found under %q", prefix)
+    }
+
+    var active []int
+    for i, keys := range samples {
+        result.Buckets[i].Sampled = len(keys)
+        if len(keys) > 0 {
+            active = append(active, i)
+        }
+    }
+
+    start := time.Now()
+    read(cfg, s3Clients, samples, active, &result)
+    result.Elapsed = time.Since(start)
+    return result, nil
+}
+
+// list lists the objects below prefix and returns up to ageMaxKeys objects per age bucket,
+// sampled evenly over the listing.
+func list(cfg *config.Config, s3Client *s3.S3, prefix string, result *Result) ([][]object, error) {
+    samples := make([][]object, len(result.Buckets))
+    now := time.Now()
+
+    task := progress.Begin("Listing objects", "objects", 0)
+    defer task.Done()
+    input := &s3.ListObjectsV2Input{
+        Bucket: aws.String(cfg.BucketName),
+        Prefix: aws.String(prefix),
+    }
+    for {
+        if err := monitor.RunContext().Err(); err != nil {
+            return samples, err
+        }
+
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := s3Client.ListObjectsV2WithContext(ctx, input)
+        cancel()
+        if err != nil {
+            return samples, fmt.Errorf("error listing bucket %s: %w", cfg.BucketName, err)
+        }
+
+        for _, obj := range page.Contents {
+            result.Listed++
+            o := object{key: aws.StringValue(obj.Key), lastModified: aws.TimeValue(obj.LastModified)}
+            i := bucketIndex(result.Buckets, now.Sub(o.lastModified))
+            result.Buckets[i].Objects++
+
+            // Reservoir sampling keeps every listed object of a bucket with the same probability.
+            if len(samples[i]) < cfg.AgeMaxKeys {
+                samples[i] = append(samples[i], o)
+            } else if j := rand.Int63n(result.Buckets[i].Objects); j < int64(cfg.AgeMaxKeys) {
+                samples[i][j] = o
+            }
+        }
+        task.Add(int64(len(page.Contents)))
+
+        if !aws.BoolValue(page.IsTruncated) {
+            return samples, nil
+        }
+        input.ContinuationToken = page.NextContinuationToken
+    }
+}
+
+// read sends GETs with ageConcurrency workers for ageReadSeconds. Each request reads a random
+// object of the next active bucket, round-robin.
+func read(cfg *config.Config, s3Clients []*s3.S3, samples [][]object, active []int, result *Result) {
+    task := progress.Begin("Reading objects by age", "requests", 0)
+    defer task.Done()
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    deadline := time.Now().Add(time.Duration(cfg.AgeReadSeconds) * time.Second)
+
+    for w := 0; w < cfg.AgeConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(w)))
+
+            for time.Now().Before(deadline) && monitor.RunContext().Err() == nil {
+                keys := samples[active[int(atomic.AddInt64(&next, 1)-1)%len(active)]]
+                obj := keys[rng.Intn(len(keys))]
+
+                n, latency, firstByte, uploadedAt, err := get(cfg, client, obj.key)
+                age, fromMetadata := time.Since(obj.lastModified), false
+                if !uploadedAt.IsZero() {
+                    age, fromMetadata = time.Since(uploadedAt), true
+                }
+
+                mu.Lock()
+                b := &result.Buckets[bucketIndex(result.Buckets, age)]
+                b.Requests++
+                if fromMetadata {
+                    b.FromMetadata++
+                }
+                if err != nil {
+                    b.Errors++
+                } else {
+                    b.Bytes += n
+                    b.Latencies.Add(latency)
+                    b.FirstBytes.Add(firstByte)
+                }
+                mu.Unlock()
+
+                if err != nil {
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error reading %s: %v\n", obj.key, err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further read errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+}
+
+// get reads an object and returns its size, the time of the whole read and to the first byte,
+// and the upload time from its metadata, if it has one.
+func get(cfg *config.Config, client *s3.S3, key string) (int64, time.Duration, time.Duration, time.Time, error) {
+    ctx, cancel := cfg.OperationContext(config.OperationGet)
+    defer cancel()
+
+    start := time.Now()
+    output, err := client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+        Bucket: aws.String(cfg.BucketName),
+        Key:    aws.String(key),
+    })
+    if err != nil {
+        return 0, 0, 0, time.Time{}, err
+    }
+    defer output.Body.Close()
+
+    var firstByte time.Duration
+    buf := make([]byte, 32*1024)
+    var n int64
+    for {
+        chunk, err := output.Body.Read(buf)
+        if chunk > 0 && n == 0 {
+            firstByte = time.Since(start)
+        }
+        n += int64(chunk)
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return n, 0, 0, time.Time{}, err
+        }
+    }
+    latency := time.Since(start)
+    if n == 0 {
+        firstByte = latency
+    }
+    return n, latency, firstByte, uploadTime(output.Metadata), nil
+}
+
+// uploadTime returns the upload time stored in the metadata of an object, or the zero time.
+// The SDK canonicalizes metadata keys, so the key is matched case-insensitively.
+func uploadTime(metadata map[string]*string) time.Time {
+    for k, v := range metadata {
+        if strings.EqualFold(k, s3upload.UploadTimeMetadataKey) {
+            t, err := time.Parse(time.RFC3339Nano, aws.StringValue(v))
+            if err == nil {
+                return t
+            }
+        }
+    }
+    return time.Time{}
+}
+
+// PrintReport prints the read times of every age bucket, and how the oldest objects compare
+// with the youngest ones.
+func PrintReport(r Result) {
+    fmt.Println("\nObject Age Read Report:")
+    fmt.Println("=======================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Prefix: %s\n", r.Prefix)
+    fmt.Printf("Objects Listed: %d\n", r.Listed)
+    fmt.Printf("Duration: %v\n", r.Elapsed.Round(time.Millisecond))
+
+    var fromMetadata, requests int64
+    fmt.Printf("\n%-10s %10s %10s %8s %10s %10s %10s %10s %10s %10s\n", "Age", "Objects", "Requests", "Errors", "Avg", "P50", "P90", "P99", "TTFB P50", "MB/s")
+    for _, b := range r.Buckets {
+        fromMetadata += b.FromMetadata
+        requests += b.Requests
+        mbps := 0.0
+        if r.Elapsed > 0 {
+            mbps = float64(b.Bytes) / (1024 * 1024) / r.Elapsed.Seconds()
+        }
+        fmt.Printf("%-10s %10d %10d %8d %10v %10v %10v %10v %10v %10.2f\n", b.Label, b.Objects, b.Requests, b.Errors,
+            b.Average().Round(time.Microsecond), b.Percentile(0.50).Round(time.Microsecond), b.Percentile(0.90).Round(time.Microsecond),
+            b.Percentile(0.99).Round(time.Microsecond), b.FirstBytePercentile(0.50).Round(time.Microsecond), mbps)
+    }
+    fmt.Printf("\nAges From Upload Time Metadata: %d of %d reads (the rest use LastModified)\n", fromMetadata, requests)
+
+    // Only buckets with successful reads are compared.
+    var measured []Bucket
+    for _, b := range r.Buckets {
+        if b.Latencies.Count > 0 {
+            measured = append(measured, b)
+        }
+    }
+    if len(measured) > 1 {
+        youngest, oldest := measured[0], measured[len(measured)-1]
+        if p50 := youngest.Percentile(0.50); p50 > 0 {
+            fmt.Printf("P50 of %s Objects vs %s Objects: %.2fx\n", oldest.Label, youngest.Label, float64(oldest.Percentile(0.50))/float64(p50))
+        }
+    }
+    fmt.Println("=======================")
+}
+
+// WriteCSV writes the read times of every age bucket to a CSV file.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating age report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Age", "MinAgeMinutes", "MaxAgeMinutes", "Objects", "Sampled", "Requests", "Errors", "FromMetadata", "AvgMs", "P50Ms", "P90Ms", "P99Ms", "FirstByteP50Ms", "MBPerSec"})
+    for _, b := range r.Buckets {
+        maxAge := ""
+        if b.MaxAge > 0 {
+            maxAge = strconv.FormatInt(int64(b.MaxAge/time.Minute), 10)
+        }
+        mbps := 0.0
+        if r.Elapsed > 0 {
+            mbps = float64(b.Bytes) / (1024 * 1024) / r.Elapsed.Seconds()
+        }
+        writer.Write([]string{
+            b.Label,
+            strconv.FormatInt(int64(b.MinAge/time.Minute), 10),
+            maxAge,
+            strconv.FormatInt(b.Objects, 10),
+            strconv.Itoa(b.Sampled),
+            strconv.FormatInt(b.Requests, 10),
+            strconv.FormatInt(b.Errors, 10),
+            strconv.FormatInt(b.FromMetadata, 10),
+            stats.Milliseconds(b.Average()),
+            stats.Milliseconds(b.Percentile(0.50)),
+            stats.Milliseconds(b.Percentile(0.90)),
+            stats.Milliseconds(b.Percentile(0.99)),
+            stats.Milliseconds(b.FirstBytePercentile(0.50)),
+            fmt.Sprintf("%.3f", mbps),
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing age report %s: %w", filePath, err)
+    }
+    return nil
+}
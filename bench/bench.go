@@ -0,0 +1,78 @@
+// bench/bench.go
+
+// Package bench is the stable API for programs that compose pieces of the benchmark instead of
+// running the s3-benchmark binary. It names the extension points; the packages behind them do
+// the work:
+//
+//   - Storage is the object store the workloads run against, see package storage. Besides
+//     implementing it, storage.NewS3 and the backends of s3upload.InitializeBackends wrap the
+//     built-in clients.
+//   - Workload decides the benchmark phases of a benchmark.Runner.
+//   - Reporter receives the result of a benchmark.Runner.
+//   - ProgressSink receives the progress of uploads and benchmark phases.
+//   - KeyNamer names the uploaded objects, see s3upload.WithKeyNamer.
+//   - PayloadGenerator writes the content of the base files, see filegen.GenerateBaseFiles.
+//   - Hooks run custom logic around the work: s3upload.WithBeforeUpload and
+//     s3upload.WithAfterUpload around each upload, benchmark.WithOperationHook after each
+//     benchmark operation, benchmark.WithPhaseHook before each benchmark phase and
+//     monitor.OnPhaseChange on every run phase change.
+//
+// The Uploader and the benchmark Runner are configured with functional options, so new
+// settings can be added without breaking callers; the package examples show both, with a
+// KeyNamer and the hooks. A custom Storage implements Endpoint, which names it in statistics
+// and reports, and the four object calls, as the Storage example does.
+//
+// Statistics are collected by package monitor for the whole process, so only one run should
+// be in progress at a time; call monitor.ResetRun before each.
+package bench
+
+import (
+    "scale_s3_benchmark/benchmark"
+    "scale_s3_benchmark/filegen"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/s3upload"
+    "scale_s3_benchmark/storage"
+)
+
+// Storage is an object store the upload and benchmark workloads run against.
+type Storage = storage.Backend
+
+// Workload decides which benchmark phases a benchmark.Runner runs; benchmark.Phases is a
+// Workload of fixed phases.
+type Workload = benchmark.Workload
+
+// Reporter receives the result of a benchmark.Runner; benchmark.ReporterFunc adapts a function
+// and benchmark.ConsoleReporter prints the final report.
+type Reporter = benchmark.Reporter
+
+// ProgressSink starts a progress.Counter for each task, such as an upload or a benchmark phase;
+// progress.StatusLine prints them on the status line and progress.Discard drops them.
+type ProgressSink = progress.Sink
+
+// ProgressCounter receives the progress of one task.
+type ProgressCounter = progress.Counter
+
+// KeyNamer names the objects an s3upload.Uploader uploads; s3upload.KeyNamerFunc adapts a
+// function.
+type KeyNamer = s3upload.KeyNamer
+
+// PayloadGenerator writes the content of the base files; filegen.TextPayload is the default.
+type PayloadGenerator = filegen.PayloadGenerator
+
+// UploadInfo describes a finished upload, passed to the s3upload.WithAfterUpload hooks.
+type UploadInfo = s3upload.UploadInfo
+
+// OperationInfo describes a finished benchmark operation, passed to the
+// benchmark.WithOperationHook hooks.
+type OperationInfo = benchmark.OperationInfo
+
+// Compile-time checks that the built-in implementations satisfy the interfaces.
+var (
+    _ Storage          = (*storage.S3Backend)(nil)
+    _ Workload         = benchmark.Phases(nil)
+    _ Reporter         = benchmark.ConsoleReporter
+    _ ProgressSink     = progress.StatusLine
+    _ ProgressCounter  = (*progress.Task)(nil)
+    _ KeyNamer         = s3upload.KeyNamerFunc(nil)
+    _ PayloadGenerator = filegen.TextPayload
+)
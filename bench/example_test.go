@@ -0,0 +1,181 @@
+// bench/example_test.go
+package bench_test
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "hash/crc32"
+    "io"
+    "strings"
+    "sync"
+    "time"
+
+    "scale_s3_benchmark/bench"
+    "scale_s3_benchmark/benchmark"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/s3upload"
+    "scale_s3_benchmark/storage"
+)
+
+// The Uploader is configured with functional options. This one places the objects after a
+// production hashing scheme, below the run prefix so cleanup and verification still find them,
+// and collects the uploaded keys in a store of the caller.
+func Example_newUploader() {
+    cfg, err := config.LoadConfig("config.json")
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    backends, err := s3upload.InitializeBackends(cfg)
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    keys := keystore.New(cfg.KeyStoreDir, cfg.KeyStoreMemoryLimit)
+    defer keys.Close()
+
+    var mu sync.Mutex
+    var uploaded int64
+    uploader := s3upload.NewUploader(cfg, backends, time.Now(),
+        s3upload.WithRunID(cfg.NewRunID()),
+        s3upload.WithKeyStore(keys),
+        s3upload.WithKeyNamer(s3upload.KeyNamerFunc(func(spec s3upload.KeySpec) string {
+            name := fmt.Sprintf("%s/%d", spec.Subfolder, spec.Index)
+            return fmt.Sprintf("%s/%08x/%s", spec.RunPrefix, crc32.ChecksumIEEE([]byte(name)), name)
+        })),
+        s3upload.WithAfterUpload(func(info s3upload.UploadInfo) {
+            if info.Err == nil {
+                mu.Lock()
+                uploaded += info.Size
+                mu.Unlock()
+            }
+        }),
+    )
+    uploader.UploadFiles("folder-0", 100, func(i int64) string { return "file_base_0.txt" })
+    fmt.Printf("%d keys, %d bytes uploaded\n", keys.Len(), uploaded)
+}
+
+// A benchmark Runner runs a Workload on the keys an Uploader collected, with hooks and
+// reporters to take the results elsewhere.
+func Example_run() {
+    cfg, err := config.LoadConfig("config.json")
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    backends, err := s3upload.InitializeBackends(cfg)
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+
+    sink := progress.StatusLine
+    uploader := s3upload.NewUploader(cfg, backends, time.Now(),
+        s3upload.WithRunID(cfg.NewRunID()),
+        s3upload.WithProgress(sink.Begin("upload", "files", 100)),
+    )
+    defer uploader.UploadedS3Files.Close()
+    uploader.UploadFiles("folder-0", 100, func(i int64) string { return "file_base_0.txt" })
+
+    var mu sync.Mutex
+    slowest := make(map[benchmark.OperationType]time.Duration)
+    runner := benchmark.NewRunner(cfg, backends[0], uploader.UploadedS3Files,
+        benchmark.WithWorkload(benchmark.Phases{
+            {Operations: []string{"GET", "STAT"}, DurationSeconds: 60},
+        }),
+        benchmark.WithProgressSink(sink),
+        benchmark.WithOperationHook(func(op benchmark.OperationInfo) {
+            mu.Lock()
+            defer mu.Unlock()
+            if op.Err == nil && op.Latency > slowest[op.Operation] {
+                slowest[op.Operation] = op.Latency
+            }
+        }),
+        benchmark.WithReporter(benchmark.ReporterFunc(func(r benchmark.BenchmarkResult) error {
+            get := r.Metrics[benchmark.OperationGet]
+            if get == nil {
+                return errors.New("no GET operations were run")
+            }
+            fmt.Printf("GET p99: %v\n", get.Percentile(0.99))
+            return nil
+        })),
+    )
+    if _, err := runner.Run(); err != nil {
+        fmt.Println(err)
+    }
+}
+
+// memoryStorage keeps objects in memory.
+type memoryStorage struct {
+    mu      sync.Mutex
+    objects map[string][]byte
+}
+
+func (m *memoryStorage) Endpoint() string { return "memory" }
+
+func (m *memoryStorage) PutObject(ctx context.Context, key string, body io.ReadSeeker, size int64) (string, error) {
+    data, err := io.ReadAll(body)
+    if err != nil {
+        return "", err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.objects[key] = data
+    return "", nil
+}
+
+func (m *memoryStorage) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    data, ok := m.objects[key]
+    if !ok {
+        return nil, storage.ErrNotFound
+    }
+    return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memoryStorage) HeadObject(ctx context.Context, key string) (int64, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    data, ok := m.objects[key]
+    if !ok {
+        return 0, storage.ErrNotFound
+    }
+    return int64(len(data)), nil
+}
+
+func (m *memoryStorage) DeleteObject(ctx context.Context, key string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    delete(m.objects, key)
+    return nil
+}
+
+// A custom Storage implements the endpoint name used in statistics and reports, and the four
+// object calls. GetObject and HeadObject return storage.ErrNotFound for missing keys.
+func ExampleStorage() {
+    var store bench.Storage = &memoryStorage{objects: make(map[string][]byte)}
+    ctx := context.Background()
+
+    body := strings.NewReader("hello")
+    if _, err := store.PutObject(ctx, "run/folder-0/file_0.txt", body, body.Size()); err != nil {
+        fmt.Println(err)
+        return
+    }
+    size, err := store.HeadObject(ctx, "run/folder-0/file_0.txt")
+    fmt.Println(store.Endpoint(), size, err)
+
+    if err := store.DeleteObject(ctx, "run/folder-0/file_0.txt"); err != nil {
+        fmt.Println(err)
+        return
+    }
+    _, err = store.GetObject(ctx, "run/folder-0/file_0.txt")
+    fmt.Println(errors.Is(err, storage.ErrNotFound))
+    // Output:
+    // memory 5 <nil>
+    // true
+}
@@ -0,0 +1,59 @@
+// benchmark/access.go
+package benchmark
+
+import (
+    "math/rand"
+
+    "scale_s3_benchmark/config"
+)
+
+// keyPicker chooses the positions of the keys a benchmark worker operates on, following an
+// access distribution. The hottest keys are the first ones of the key set, i.e. the first
+// uploaded or listed. Each worker has its own keyPicker, so it is not safe for concurrent use.
+type keyPicker struct {
+    cfg          *config.Config
+    distribution string
+    rng          *rand.Rand
+
+    // The zipf generator is bound to a key count and rebuilt when keys are added.
+    zipf  *rand.Zipf
+    zipfN int
+}
+
+// newKeyPicker returns a keyPicker for opType. DELETE always picks uniformly: concentrating
+// deletes would only empty the hot set.
+func newKeyPicker(cfg *config.Config, opType OperationType, seed int64) *keyPicker {
+    distribution := cfg.AccessDistribution
+    if opType == OperationDelete {
+        distribution = config.AccessUniform
+    }
+    return &keyPicker{cfg: cfg, distribution: distribution, rng: rand.New(rand.NewSource(seed))}
+}
+
+// pick returns the position of the next key in a key set of n keys. n must be positive.
+func (p *keyPicker) pick(n int) int {
+    switch p.distribution {
+    case config.AccessZipf:
+        if n == 1 {
+            return 0
+        }
+        if p.zipf == nil || p.zipfN != n {
+            p.zipf = rand.NewZipf(p.rng, p.cfg.ZipfExponent, 1, uint64(n-1))
+            p.zipfN = n
+        }
+        return int(p.zipf.Uint64())
+    case config.AccessHotSet:
+        hot := int(float64(n) * p.cfg.HotSetPercent / 100)
+        if hot < 1 {
+            hot = 1
+        }
+        if hot >= n {
+            return p.rng.Intn(n)
+        }
+        if p.rng.Float64()*100 < p.cfg.HotSetTrafficPercent {
+            return p.rng.Intn(hot)
+        }
+        return hot + p.rng.Intn(n-hot)
+    }
+    return p.rng.Intn(n)
+}
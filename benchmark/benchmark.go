@@ -3,8 +3,11 @@ package benchmark
 
 import (
     "context"
+    "errors"
     "fmt"
+    "io"
     "math/rand"
+    "strings"
     "sync"
     "time"
 
@@ -12,8 +15,31 @@ import (
     "github.com/aws/aws-sdk-go/service/s3"
 
     "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/latencylog"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/s3upload"
+    "scale_s3_benchmark/storage"
 )
 
+// maxKeyErrors is the number of consecutive failures to read a key from the key store after
+// which a benchmark worker aborts the run, since the store is then unreadable.
+const maxKeyErrors = 10
+
+// bodyBufferSize is the size of the pooled buffers GET response bodies are read into.
+const bodyBufferSize = 256 * 1024
+
+// bodyBuffers recycles the read buffers of GET operations so that allocation does not
+// dominate the measurement at high request rates.
+var bodyBuffers = sync.Pool{
+    New: func() interface{} {
+        buf := make([]byte, bodyBufferSize)
+        return &buf
+    },
+}
+
 // PerformanceMetrics holds the metrics for benchmarking operations.
 type PerformanceMetrics struct {
     TotalOperations int64
@@ -21,6 +47,54 @@ type PerformanceMetrics struct {
     MinTime         time.Duration
     MaxTime         time.Duration
     ErrorCount      int64
+    TotalBytes      int64                 // Response body bytes read, for GET operations.
+    Elapsed         time.Duration         // Wall-clock time the operation was run for.
+    Threads         int                   // Workers that ran the operation.
+    TargetRate      float64               // Operations per second the workers were limited to, 0 if not limited.
+    ByDepth         map[int]*DepthMetrics // Operations per prefix depth of their keys, with latencyByDepth.
+    Latency         stats.Histogram       // Latencies of all operations, for percentiles.
+}
+
+// record adds one operation to the metrics.
+func (m *PerformanceMetrics) record(duration time.Duration, bytes int64, failed bool) {
+    m.TotalOperations++
+    m.TotalTime += duration
+    m.TotalBytes += bytes
+    if m.MinTime == 0 || duration < m.MinTime {
+        m.MinTime = duration
+    }
+    if duration > m.MaxTime {
+        m.MaxTime = duration
+    }
+    if failed {
+        m.ErrorCount++
+    }
+    m.Latency.Add(duration)
+}
+
+// merge adds the operations recorded in other to the metrics.
+func (m *PerformanceMetrics) merge(other PerformanceMetrics) {
+    if other.TotalOperations == 0 {
+        return
+    }
+    m.TotalOperations += other.TotalOperations
+    m.TotalTime += other.TotalTime
+    m.TotalBytes += other.TotalBytes
+    if m.MinTime == 0 || (other.MinTime > 0 && other.MinTime < m.MinTime) {
+        m.MinTime = other.MinTime
+    }
+    if other.MaxTime > m.MaxTime {
+        m.MaxTime = other.MaxTime
+    }
+    m.ErrorCount += other.ErrorCount
+    m.Latency.Merge(other.Latency)
+    m.mergeDepths(other)
+}
+
+// Percentile returns the latency below which the p-th fraction (0-1) of the operations completed,
+// to the resolution of the latency histogram and never above the slowest operation.
+func (m *PerformanceMetrics) Percentile(p float64) time.Duration {
+    return min(m.Latency.Percentile(p), m.MaxTime)
 }
 
 // OperationType defines the type of S3 operation.
@@ -30,134 +104,393 @@ const (
     OperationGet    OperationType = "GET"
     OperationDelete OperationType = "DELETE"
     OperationStat   OperationType = "STAT"
+
+    // OperationRetention retrieves the Object Lock retention settings of an object.
+    OperationRetention OperationType = "RETENTION"
+
+    // OperationGetAccelerated is a GET sent through the S3 Transfer Acceleration endpoint.
+    OperationGetAccelerated OperationType = "GET_ACCELERATED"
+
+    // OperationGetDuringUpload is a GET sent while the upload phase is still writing to the bucket.
+    OperationGetDuringUpload OperationType = "GET_DURING_UPLOAD"
+
+    // OperationList lists one page of the directory that holds the selected key.
+    OperationList OperationType = "LIST"
+
+    // OperationRename moves an object to a new key with CopyObject and DeleteObject.
+    OperationRename OperationType = "RENAME"
+
+    // OperationPutTagging replaces the tag set of an object with benchmarkTagCount tags.
+    OperationPutTagging OperationType = "PUT_TAGGING"
+
+    // OperationGetTagging reads the tag set of an object.
+    OperationGetTagging OperationType = "GET_TAGGING"
+
+    // OperationPutACL sets the ACL of an object.
+    OperationPutACL OperationType = "PUT_ACL"
+
+    // OperationGetACL reads the ACL of an object.
+    OperationGetACL OperationType = "GET_ACL"
+
+    // OperationPutPolicy writes the bucket policy, see putPolicy.
+    OperationPutPolicy OperationType = "PUT_POLICY"
+
+    // OperationGetPolicy reads the bucket policy.
+    OperationGetPolicy OperationType = "GET_POLICY"
+
+    // OperationPutCORS writes the CORS configuration of the bucket, see putCORS.
+    OperationPutCORS OperationType = "PUT_CORS"
+
+    // OperationGetCORS reads the CORS configuration of the bucket.
+    OperationGetCORS OperationType = "GET_CORS"
 )
 
 // BenchmarkResult holds the results of the benchmarking.
 type BenchmarkResult struct {
-    Metrics  map[OperationType]*PerformanceMetrics
-    Duration time.Duration
+    Metrics   map[OperationType]*PerformanceMetrics
+    Duration  time.Duration
+    GetTiming string // What the GET times cover, config.GetTimingFull or config.GetTimingFirstByte.
+    Access    string // How the reads picked their keys, see config.AccessDescription.
+    Phases    string // The phases that were run, e.g. "GET+STAT (1m0s), DELETE (1m0s)".
 }
 
-// PerformBenchmarkOperations performs GET, STAT, and DELETE operations for benchmarking.
-func PerformBenchmarkOperations(cfg *config.Config, s3Client *s3.S3, uploadedS3Files []string, startTime time.Time) BenchmarkResult {
-    fmt.Println("\nPerforming benchmarking operations...")
-
-    // Prepare metrics storage
-    metrics := map[OperationType]*PerformanceMetrics{
-        OperationGet:    &PerformanceMetrics{},
-        OperationStat:   &PerformanceMetrics{},
-        OperationDelete: &PerformanceMetrics{},
-    }
-
-    // Start time for benchmarking duration
-    benchmarkStartTime := time.Now()
-    benchmarkDuration := time.Duration(cfg.BenchmarkDurationSeconds) * time.Second
-
-    // Define a context with timeout for benchmarking duration
-    ctx, cancel := context.WithTimeout(context.Background(), benchmarkDuration)
-    defer cancel()
+// runState is the state the workers of one run of a Runner share, so runs in the same process
+// do not see each other's.
+type runState struct {
+    renames  *renameTracker  // Objects moved by RENAME.
+    controls *bucketControls // Bucket settings saved before PUT_POLICY and PUT_CORS, restored after.
+}
 
-    // Perform GET and STAT operations first
-    var wg sync.WaitGroup
-    operations := []OperationType{OperationGet, OperationStat}
+func newRunState() *runState {
+    return &runState{renames: newRenameTracker()}
+}
 
-    for _, opType := range operations {
-        wg.Add(1)
-        go func(opType OperationType) {
-            defer wg.Done()
-            performOperation(ctx, cfg, s3Client, opType, metrics[opType], uploadedS3Files, cfg.MaxBenchmarkThreads)
-        }(opType)
+// PerformBenchmarkOperations runs the benchmark phases of the configuration, by default GET and
+// STAT followed by DELETE, on the uploaded keys.
+func PerformBenchmarkOperations(cfg *config.Config, backend storage.Backend, uploadedS3Files *keystore.Store, startTime time.Time) BenchmarkResult {
+    fmt.Println("\nPerforming benchmarking operations...")
+    result, err := NewRunner(cfg, backend, uploadedS3Files).Run()
+    if err != nil {
+        fmt.Printf("Error running benchmark: %v\n", err)
     }
+    return result
+}
 
-    wg.Wait()
+// runPhases runs benchmark phases one after the other. The operations of a phase run at the
+// same time, each with its own workers, until the phase duration has passed, counted in a task
+// of the sink of r. Once the run is aborted the remaining phases are skipped.
+func (r *Runner) runPhases(phases []config.BenchmarkPhase) BenchmarkResult {
+    cfg, backend, keys := r.cfg, r.backend, r.keys
+    metrics := make(map[OperationType]*PerformanceMetrics)
+    var ran []string
+    state := newRunState()
 
-    fmt.Println("\nGET and STAT operations completed. Starting DELETE operations...")
+    // PUT_POLICY and PUT_CORS rewrite bucket-wide settings, which are put back once the phases end.
+    var putsPolicy, putsCORS bool
+    for _, phase := range phases {
+        for _, op := range phase.Operations {
+            putsPolicy = putsPolicy || OperationType(op) == OperationPutPolicy
+            putsCORS = putsCORS || OperationType(op) == OperationPutCORS
+        }
+    }
+    if s3Backend, ok := backend.(*storage.S3Backend); ok && (putsPolicy || putsCORS) {
+        s3Client := s3Backend.Client
+        saved, err := saveBucketControls(s3Client, cfg.BucketName)
+        if err != nil {
+            fmt.Printf("Error saving bucket settings, skipping the benchmark: %v\n", err)
+            return BenchmarkResult{Metrics: metrics, GetTiming: cfg.GetTiming, Access: cfg.AccessDescription()}
+        }
+        state.controls = saved
+        defer func() {
+            if err := saved.restore(s3Client, cfg.BucketName, putsPolicy, putsCORS); err != nil {
+                fmt.Printf("Error: %v\n", err)
+            }
+        }()
+    }
 
-    // Reset the context for DELETE operations
-    ctx, cancel = context.WithTimeout(context.Background(), benchmarkDuration)
-    defer cancel()
+    benchmarkStartTime := time.Now()
+    for i, phase := range phases {
+        if monitor.RunContext().Err() != nil {
+            break
+        }
 
-    wg.Add(1)
-    go func() {
-        defer wg.Done()
-        performOperation(ctx, cfg, s3Client, OperationDelete, metrics[OperationDelete], uploadedS3Files, cfg.MaxBenchmarkThreads)
-    }()
+        var operations []OperationType
+        var names []string
+        clients := make(map[OperationType]storage.Backend)
+        for _, op := range phase.Operations {
+            opType := OperationType(op)
+            client := backend
+            if opType == OperationGetAccelerated {
+                // GETs through the accelerated endpoint, to compare them with the standard ones.
+                acceleratedClient, err := s3upload.NewAcceleratedClient(cfg)
+                if err != nil {
+                    fmt.Printf("Error creating accelerated client, skipping %s: %v\n", opType, err)
+                    continue
+                }
+                client = storage.NewS3(acceleratedClient, cfg.BucketName)
+            }
+            operations = append(operations, opType)
+            names = append(names, op)
+            clients[opType] = client
+            metrics[opType] = &PerformanceMetrics{}
+        }
+        if len(operations) == 0 {
+            continue
+        }
 
-    wg.Wait()
+        duration := cfg.PhaseDuration(phase)
+        name := strings.Join(names, "+")
+        fmt.Printf("Benchmark phase %d of %d: %s for %v\n", i+1, len(phases), name, duration)
+        ran = append(ran, fmt.Sprintf("%s (%v)", name, duration))
+        r.beforePhase(PhaseInfo{Index: i, Count: len(phases), Operations: names, Duration: duration})
 
-    // Calculate actual benchmarking duration
-    actualBenchmarkDuration := time.Since(benchmarkStartTime)
+        ctx, cancel := context.WithTimeout(monitor.RunContext(), duration)
+        task := r.sink.Begin("Benchmarking "+strings.Join(names, ", "), "ops", 0)
+        var wg sync.WaitGroup
+        for _, opType := range operations {
+            wg.Add(1)
+            go func(opType OperationType) {
+                defer wg.Done()
+                performOperation(ctx, cfg, clients[opType], opType, metrics[opType], keys, cfg.BenchmarkThreadsFor(string(opType)), task, r.afterOperation, state)
+            }(opType)
+        }
+        wg.Wait()
+        task.Done()
+        cancel()
+    }
 
-    // Return benchmark results
     return BenchmarkResult{
-        Metrics:  metrics,
-        Duration: actualBenchmarkDuration,
+        Metrics:   metrics,
+        Duration:  time.Since(benchmarkStartTime),
+        GetTiming: cfg.GetTiming,
+        Access:    cfg.AccessDescription(),
+        Phases:    strings.Join(ran, ", "),
     }
 }
 
 // performOperation performs a specific S3 operation for the specified duration and collects metrics.
-func performOperation(ctx context.Context, cfg *config.Config, s3Client *s3.S3, opType OperationType, metrics *PerformanceMetrics, uploadedS3Files []string, maxBenchmarkThreads int) {
+// A fixed pool of threads workers each issue one operation at a time until ctx is done, together
+// at most benchmarkRates operations per second. Keys are picked following accessDistribution,
+// see keyPicker. Every worker records into its own metrics shard, merged into metrics when it
+// stops, so workers never contend on a shared lock. Completed and failed operations are also
+// counted on task and passed to afterOp, if not nil. state is shared with the other operations
+// of the run.
+func performOperation(ctx context.Context, cfg *config.Config, backend storage.Backend, opType OperationType, metrics *PerformanceMetrics, uploadedS3Files *keystore.Store, threads int, task progress.Counter, afterOp func(OperationInfo), state *runState) {
     var mu sync.Mutex
     var wg sync.WaitGroup
-    semaphore := make(chan struct{}, maxBenchmarkThreads)
 
-    fileCount := len(uploadedS3Files)
-    if fileCount == 0 {
+    if uploadedS3Files.Len() == 0 {
         fmt.Println("No uploaded S3 files available for benchmarking.")
         return
     }
 
-    for {
-        select {
-        case <-ctx.Done():
-            wg.Wait()
-            return
-        default:
-            wg.Add(1)
-            semaphore <- struct{}{}
-            go func() {
-                defer wg.Done()
-                s3Key := uploadedS3Files[rand.Intn(fileCount)]
-                start := time.Now()
-                var err error
-
-                switch opType {
-                case OperationGet:
-                    _, err = s3Client.GetObject(&s3.GetObjectInput{
-                        Bucket: aws.String(cfg.BucketName),
-                        Key:    aws.String(s3Key),
-                    })
-                case OperationDelete:
-                    _, err = s3Client.DeleteObject(&s3.DeleteObjectInput{
-                        Bucket: aws.String(cfg.BucketName),
-                        Key:    aws.String(s3Key),
-                    })
-                case OperationStat:
-                    _, err = s3Client.HeadObject(&s3.HeadObjectInput{
-                        Bucket: aws.String(cfg.BucketName),
-                        Key:    aws.String(s3Key),
-                    })
-                }
+    metrics.Threads = threads
+    metrics.TargetRate = cfg.BenchmarkRateFor(string(opType))
+    pacer := newOpPacer(metrics.TargetRate)
 
-                duration := time.Since(start)
+    start := time.Now()
+    defer func() {
+        metrics.Elapsed = time.Since(start)
+    }()
+
+    for w := 0; w < threads; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
 
+            picker := newKeyPicker(cfg, opType, cfg.SeedFor(fmt.Sprintf("benchmark/%s/%d", opType, w)))
+            runID, endpoint := monitor.RunID(), backend.Endpoint()
+            var shard PerformanceMetrics
+            defer func() {
                 mu.Lock()
-                metrics.TotalOperations++
-                metrics.TotalTime += duration
-                if metrics.MinTime == 0 || duration < metrics.MinTime {
-                    metrics.MinTime = duration
+                metrics.merge(shard)
+                mu.Unlock()
+            }()
+
+            keyErrors := 0
+            for {
+                monitor.WaitIfPaused(ctx)
+                pacer.wait(ctx)
+                if ctx.Err() != nil {
+                    return
+                }
+
+                s3Key, err := uploadedS3Files.Get(picker.pick(uploadedS3Files.Len()))
+                if err != nil {
+                    progress.Printf("Error selecting key for benchmarking: %v\n", err)
+                    if keyErrors++; keyErrors >= maxKeyErrors {
+                        monitor.Abort(fmt.Sprintf("%d consecutive errors selecting keys for %s: %v", keyErrors, opType, err))
+                        return
+                    }
+                    continue
+                }
+                keyErrors = 0
+
+                start := time.Now()
+                bytes, firstByte, err := runOperation(cfg, backend, state, opType, s3Key, picker.rng)
+                if err == errRenameBusy {
+                    continue
                 }
-                if duration > metrics.MaxTime {
-                    metrics.MaxTime = duration
+                duration := time.Since(start)
+                if !firstByte.IsZero() {
+                    duration = firstByte.Sub(start)
+                }
+
+                shard.record(duration, bytes, err != nil)
+                latencylog.Add(latencylog.Entry{
+                    Time:      start,
+                    RunID:     runID,
+                    Operation: string(opType),
+                    Endpoint:  endpoint,
+                    Key:       s3Key,
+                    Size:      bytes,
+                    Latency:   duration,
+                    Failed:    err != nil,
+                })
+                if cfg.LatencyByDepth {
+                    shard.recordDepth(keyDepth(s3Key), duration, err != nil)
                 }
+                monitor.RecordOutcome(err == nil)
                 if err != nil {
-                    metrics.ErrorCount++
+                    task.Fail(1)
+                } else {
+                    task.Add(1)
                 }
-                mu.Unlock()
+                if afterOp != nil {
+                    afterOp(OperationInfo{Operation: opType, Key: s3Key, Endpoint: endpoint, Bytes: bytes, Latency: duration, Err: err})
+                }
+            }
+        }(w)
+    }
 
-                <-semaphore
-            }()
+    wg.Wait()
+}
+
+// runOperation issues a single benchmark operation on s3Key and returns the number of
+// response body bytes read. With first-byte GET timing it also returns when the first body
+// byte arrived, which then ends the measured time instead of the completed operation. The
+// values the write operations store are drawn from rng. The operations that call the S3 API
+// directly fail without a request on other backends.
+func runOperation(cfg *config.Config, backend storage.Backend, state *runState, opType OperationType, s3Key string, rng *rand.Rand) (int64, time.Time, error) {
+    opCtx, opCancel := cfg.OperationContext(requestType(opType))
+    defer opCancel()
+
+    var s3Client *s3.S3
+    if config.S3OnlyOperation(string(opType)) {
+        s3Backend, ok := backend.(*storage.S3Backend)
+        if !ok {
+            return 0, time.Time{}, fmt.Errorf("benchmark operation %s needs an S3 backend, got %T", opType, backend)
+        }
+        s3Client = s3Backend.Client
+    }
+
+    switch opType {
+    case OperationGet, OperationGetAccelerated, OperationGetDuringUpload:
+        // The SDK retries requests that stall before the response; a body that stalls halfway
+        // is read again from the start, up to maxRetries times.
+        for attempt := 1; ; attempt++ {
+            body, err := backend.GetObject(opCtx, s3Key)
+            if err != nil {
+                return 0, time.Time{}, err
+            }
+            bytes, firstByte, err := readBody(body, cfg.GetTiming == config.GetTimingFirstByte)
+            if !errors.Is(err, s3upload.ErrStalled) || attempt >= cfg.MaxRetries || opCtx.Err() != nil {
+                return bytes, firstByte, err
+            }
+        }
+    case OperationDelete:
+        return 0, time.Time{}, backend.DeleteObject(opCtx, s3Key)
+    case OperationStat:
+        _, err := backend.HeadObject(opCtx, s3Key)
+        return 0, time.Time{}, err
+    case OperationRetention:
+        // Object Lock is only allowed with the S3 storage backend.
+        _, err := s3Client.GetObjectRetentionWithContext(opCtx, &s3.GetObjectRetentionInput{
+            Bucket: aws.String(cfg.BucketName),
+            Key:    aws.String(s3Key),
+        })
+        return 0, time.Time{}, err
+    case OperationList:
+        // LIST is only allowed with the S3 storage backend.
+        prefix := ""
+        if i := strings.LastIndex(s3Key, "/"); i >= 0 {
+            prefix = s3Key[:i+1]
         }
+        _, err := s3Client.ListObjectsV2WithContext(opCtx, &s3.ListObjectsV2Input{
+            Bucket: aws.String(cfg.BucketName),
+            Prefix: aws.String(prefix),
+        })
+        return 0, time.Time{}, err
+    case OperationRename:
+        // RENAME is only allowed with the S3 storage backend.
+        return 0, time.Time{}, renameObject(opCtx, s3Client, state.renames, cfg.BucketName, s3Key)
+    case OperationPutTagging:
+        // Tagging is only allowed with the S3 storage backend.
+        return 0, time.Time{}, putTagging(opCtx, s3Client, cfg.BucketName, s3Key, cfg.BenchmarkTagCount, rng)
+    case OperationGetTagging:
+        return 0, time.Time{}, getTagging(opCtx, s3Client, cfg.BucketName, s3Key)
+    case OperationPutACL:
+        // ACLs are only available with the S3 storage backend.
+        return 0, time.Time{}, putACL(opCtx, s3Client, cfg.BucketName, s3Key)
+    case OperationGetACL:
+        return 0, time.Time{}, getACL(opCtx, s3Client, cfg.BucketName, s3Key)
+    case OperationPutPolicy:
+        // Bucket policies and CORS are only available with the S3 storage backend. These
+        // operations act on the bucket, so s3Key only paces them like the object operations.
+        return 0, time.Time{}, putPolicy(opCtx, s3Client, state.controls, cfg, rng)
+    case OperationGetPolicy:
+        return 0, time.Time{}, getPolicy(opCtx, s3Client, cfg.BucketName)
+    case OperationPutCORS:
+        return 0, time.Time{}, putCORS(opCtx, s3Client, state.controls, cfg.BucketName, rng)
+    case OperationGetCORS:
+        return 0, time.Time{}, getCORS(opCtx, s3Client, cfg.BucketName)
     }
+    return 0, time.Time{}, fmt.Errorf("unknown benchmark operation %s", opType)
 }
 
+// readBody reads a response body to the end into a pooled buffer and closes it, so the
+// connection can be reused. It returns the bytes read and, if firstByte is set, the time the
+// first byte arrived; the rest of the body is then only drained.
+func readBody(body io.ReadCloser, firstByte bool) (int64, time.Time, error) {
+    defer body.Close()
+
+    bufPtr := bodyBuffers.Get().(*[]byte)
+    defer bodyBuffers.Put(bufPtr)
+    buf := *bufPtr
+
+    var total int64
+    var firstByteAt time.Time
+    for {
+        n, err := body.Read(buf)
+        if n > 0 && firstByte && firstByteAt.IsZero() {
+            firstByteAt = time.Now()
+        }
+        total += int64(n)
+        if err == io.EOF {
+            if firstByte && firstByteAt.IsZero() {
+                // An empty object has no first byte; its headers are all there is to time.
+                firstByteAt = time.Now()
+            }
+            return total, firstByteAt, nil
+        }
+        if err != nil {
+            return total, firstByteAt, fmt.Errorf("error reading response body: %w", err)
+        }
+    }
+}
+
+// requestType maps a benchmark operation to the HTTP request type used to select its timeout.
+func requestType(opType OperationType) string {
+    switch opType {
+    case OperationDelete:
+        return config.OperationDelete
+    case OperationStat:
+        return config.OperationHead
+    case OperationList:
+        return config.OperationList
+    case OperationRename, OperationPutTagging, OperationPutACL, OperationPutPolicy, OperationPutCORS:
+        return config.OperationPut
+    default:
+        return config.OperationGet
+    }
+}
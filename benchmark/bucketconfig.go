@@ -0,0 +1,158 @@
+// benchmark/bucketconfig.go
+package benchmark
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "math/rand"
+    "path"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+)
+
+// bucketControls holds the bucket policy and CORS configuration found before the benchmark.
+// PUT_POLICY and PUT_CORS write them back with one extra probe entry, so the existing grants
+// and rules stay in force during the benchmark, and restore them when the phases end.
+type bucketControls struct {
+    policy    map[string]interface{} // Existing policy document; nil if the bucket has none.
+    rawPolicy string
+    corsRules []*s3.CORSRule // Existing CORS rules; nil if the bucket has none.
+}
+
+// isMissing reports whether err is the answer for a bucket without the configuration, which
+// is a complete control-plane response rather than a failure.
+func isMissing(err error) bool {
+    aerr, ok := err.(awserr.Error)
+    return ok && (aerr.Code() == "NoSuchBucketPolicy" || aerr.Code() == "NoSuchCORSConfiguration")
+}
+
+// saveBucketControls reads the policy and CORS configuration of the bucket.
+func saveBucketControls(s3Client *s3.S3, bucket string) (*bucketControls, error) {
+    c := &bucketControls{}
+
+    policy, err := s3Client.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: aws.String(bucket)})
+    if err != nil && !isMissing(err) {
+        return nil, fmt.Errorf("error reading the policy of bucket %s: %w", bucket, err)
+    }
+    if err == nil {
+        c.rawPolicy = aws.StringValue(policy.Policy)
+        if err := json.Unmarshal([]byte(c.rawPolicy), &c.policy); err != nil {
+            return nil, fmt.Errorf("error parsing the policy of bucket %s: %w", bucket, err)
+        }
+    }
+
+    cors, err := s3Client.GetBucketCors(&s3.GetBucketCorsInput{Bucket: aws.String(bucket)})
+    if err != nil && !isMissing(err) {
+        return nil, fmt.Errorf("error reading the CORS configuration of bucket %s: %w", bucket, err)
+    }
+    if err == nil {
+        c.corsRules = cors.CORSRules
+    }
+    return c, nil
+}
+
+// restore writes back the policy and CORS configuration found by saveBucketControls, or
+// removes them if the bucket had none. Only the configurations the benchmark wrote are touched.
+func (c *bucketControls) restore(s3Client *s3.S3, bucket string, policy, cors bool) error {
+    if policy {
+        var err error
+        if c.policy == nil {
+            _, err = s3Client.DeleteBucketPolicy(&s3.DeleteBucketPolicyInput{Bucket: aws.String(bucket)})
+        } else {
+            _, err = s3Client.PutBucketPolicy(&s3.PutBucketPolicyInput{Bucket: aws.String(bucket), Policy: aws.String(c.rawPolicy)})
+        }
+        if err != nil {
+            return fmt.Errorf("error restoring the policy of bucket %s: %w", bucket, err)
+        }
+    }
+    if cors {
+        var err error
+        if c.corsRules == nil {
+            _, err = s3Client.DeleteBucketCors(&s3.DeleteBucketCorsInput{Bucket: aws.String(bucket)})
+        } else {
+            _, err = s3Client.PutBucketCors(&s3.PutBucketCorsInput{
+                Bucket:            aws.String(bucket),
+                CORSConfiguration: &s3.CORSConfiguration{CORSRules: c.corsRules},
+            })
+        }
+        if err != nil {
+            return fmt.Errorf("error restoring the CORS configuration of bucket %s: %w", bucket, err)
+        }
+    }
+    return nil
+}
+
+// putPolicy writes the existing bucket policy, as saved in controls, plus a probe statement that denies reads below a
+// prefix no object uses. The Sid is drawn from rng, so every request changes the stored policy.
+func putPolicy(ctx context.Context, s3Client *s3.S3, controls *bucketControls, cfg *config.Config, rng *rand.Rand) error {
+    statements := []interface{}{}
+    document := map[string]interface{}{"Version": "2012-10-17"}
+    if controls.policy != nil {
+        for k, v := range controls.policy {
+            document[k] = v
+        }
+        switch existing := controls.policy["Statement"].(type) {
+        case []interface{}:
+            statements = append(statements, existing...)
+        case map[string]interface{}:
+            statements = append(statements, existing)
+        }
+    }
+    statements = append(statements, map[string]interface{}{
+        "Sid":       fmt.Sprintf("S3BenchmarkProbe%08x", rng.Uint32()),
+        "Effect":    "Deny",
+        "Principal": "*",
+        "Action":    "s3:GetObject",
+        "Resource":  "arn:aws:s3:::" + path.Join(cfg.BucketName, cfg.S3Folder, ".s3-benchmark-policy-probe") + "/*",
+    })
+    document["Statement"] = statements
+
+    policy, err := json.Marshal(document)
+    if err != nil {
+        return err
+    }
+    _, err = s3Client.PutBucketPolicyWithContext(ctx, &s3.PutBucketPolicyInput{
+        Bucket: aws.String(cfg.BucketName),
+        Policy: aws.String(string(policy)),
+    })
+    return err
+}
+
+// getPolicy reads the bucket policy. A bucket without a policy is a valid answer.
+func getPolicy(ctx context.Context, s3Client *s3.S3, bucket string) error {
+    _, err := s3Client.GetBucketPolicyWithContext(ctx, &s3.GetBucketPolicyInput{Bucket: aws.String(bucket)})
+    if isMissing(err) {
+        return nil
+    }
+    return err
+}
+
+// putCORS writes the existing CORS rules, as saved in controls, plus a probe rule for an origin that cannot exist. Its
+// max age is drawn from rng, so every request changes the stored configuration.
+func putCORS(ctx context.Context, s3Client *s3.S3, controls *bucketControls, bucket string, rng *rand.Rand) error {
+    rules := append([]*s3.CORSRule{}, controls.corsRules...)
+    rules = append(rules, &s3.CORSRule{
+        AllowedMethods: aws.StringSlice([]string{"GET"}),
+        AllowedOrigins: aws.StringSlice([]string{"https://s3-benchmark-probe.invalid"}),
+        MaxAgeSeconds:  aws.Int64(int64(rng.Intn(3600) + 1)),
+    })
+    _, err := s3Client.PutBucketCorsWithContext(ctx, &s3.PutBucketCorsInput{
+        Bucket:            aws.String(bucket),
+        CORSConfiguration: &s3.CORSConfiguration{CORSRules: rules},
+    })
+    return err
+}
+
+// getCORS reads the CORS configuration of the bucket. A bucket without one is a valid answer.
+func getCORS(ctx context.Context, s3Client *s3.S3, bucket string) error {
+    _, err := s3Client.GetBucketCorsWithContext(ctx, &s3.GetBucketCorsInput{Bucket: aws.String(bucket)})
+    if isMissing(err) {
+        return nil
+    }
+    return err
+}
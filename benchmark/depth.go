@@ -0,0 +1,106 @@
+// benchmark/depth.go
+package benchmark
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "scale_s3_benchmark/internal/stats"
+)
+
+// LatencyCounts returns the counts of the latency histogram buckets. The counts of several runs
+// can be summed and passed to LatencyPercentile to get the percentiles of all of them.
+func (m *PerformanceMetrics) LatencyCounts() []int64 {
+    return append([]int64(nil), m.Latency[:]...)
+}
+
+// LatencyPercentile returns the p-th fraction (0-1) of the latencies counted by LatencyCounts.
+// It reports false if counts were not produced with the buckets of this version.
+func LatencyPercentile(counts []int64, p float64) (time.Duration, bool) {
+    var h stats.Histogram
+    if len(counts) != len(h) {
+        return 0, false
+    }
+    copy(h[:], counts)
+    return h.Percentile(p), true
+}
+
+// DepthMetrics holds the operations on keys at one prefix depth, for latencyByDepth.
+type DepthMetrics struct {
+    Operations int64
+    Errors     int64
+    Latency    stats.Histogram
+}
+
+// keyDepth returns the prefix depth of a key: the number of directories it is in.
+func keyDepth(key string) int {
+    return strings.Count(key, "/")
+}
+
+// recordDepth adds one operation on a key at the given depth.
+func (m *PerformanceMetrics) recordDepth(depth int, duration time.Duration, failed bool) {
+    if m.ByDepth == nil {
+        m.ByDepth = make(map[int]*DepthMetrics)
+    }
+    d, ok := m.ByDepth[depth]
+    if !ok {
+        d = &DepthMetrics{}
+        m.ByDepth[depth] = d
+    }
+    d.Operations++
+    if failed {
+        d.Errors++
+    }
+    d.Latency.Add(duration)
+}
+
+// mergeDepths adds the per-depth operations of other to the metrics.
+func (m *PerformanceMetrics) mergeDepths(other PerformanceMetrics) {
+    for depth, o := range other.ByDepth {
+        if m.ByDepth == nil {
+            m.ByDepth = make(map[int]*DepthMetrics)
+        }
+        d, ok := m.ByDepth[depth]
+        if !ok {
+            d = &DepthMetrics{}
+            m.ByDepth[depth] = d
+        }
+        d.Operations += o.Operations
+        d.Errors += o.Errors
+        d.Latency.Merge(o.Latency)
+    }
+}
+
+// printDepthReport prints the latency percentiles of every operation per prefix depth, for
+// the operations that recorded them.
+func printDepthReport(result BenchmarkResult) {
+    var opTypes []string
+    for opType, metrics := range result.Metrics {
+        if len(metrics.ByDepth) > 0 {
+            opTypes = append(opTypes, string(opType))
+        }
+    }
+    if len(opTypes) == 0 {
+        return
+    }
+    sort.Strings(opTypes)
+
+    fmt.Println("\nLatency by Prefix Depth:")
+    fmt.Printf("%-18s %6s %12s %8s %10s %10s %10s %10s\n", "Operation", "Depth", "Operations", "Errors", "P50", "P90", "P99", "P99.9")
+    for _, opType := range opTypes {
+        byDepth := result.Metrics[OperationType(opType)].ByDepth
+        depths := make([]int, 0, len(byDepth))
+        for depth := range byDepth {
+            depths = append(depths, depth)
+        }
+        sort.Ints(depths)
+
+        for _, depth := range depths {
+            d := byDepth[depth]
+            fmt.Printf("%-18s %6d %12d %8d %10v %10v %10v %10v\n", opType, depth, d.Operations, d.Errors,
+                d.Latency.Percentile(0.50), d.Latency.Percentile(0.90), d.Latency.Percentile(0.99), d.Latency.Percentile(0.999))
+        }
+    }
+}
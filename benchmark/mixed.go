@@ -0,0 +1,62 @@
+// benchmark/mixed.go
+package benchmark
+
+import (
+    "context"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/storage"
+)
+
+// keyPollInterval is how often the mixed readers check for the first uploaded key.
+const keyPollInterval = 100 * time.Millisecond
+
+// MixedReads is a GET workload running against the bucket while the upload phase writes to it,
+// reproducing an ingest and query workload. It keeps its own metrics, separate from the
+// benchmark phase that follows the uploads.
+type MixedReads struct {
+    cancel  context.CancelFunc
+    done    chan struct{}
+    metrics PerformanceMetrics
+}
+
+// StartMixedReads starts MixedReadThreads GET workers reading random keys of uploadedS3Files.
+// Workers wait for the first uploads to be recorded, then run until Stop is called.
+func StartMixedReads(cfg *config.Config, backend storage.Backend, uploadedS3Files *keystore.Store) *MixedReads {
+    ctx, cancel := context.WithCancel(monitor.RunContext())
+    m := &MixedReads{cancel: cancel, done: make(chan struct{})}
+
+    go func() {
+        defer close(m.done)
+
+        // Keys are recorded as workers finish their subfolders.
+        for uploadedS3Files.Len() == 0 {
+            select {
+            case <-ctx.Done():
+                return
+            case <-time.After(keyPollInterval):
+            }
+        }
+
+        task := progress.Begin("Reads during uploads", "ops", 0)
+        defer task.Done()
+
+        performOperation(ctx, cfg, backend, OperationGetDuringUpload, &m.metrics, uploadedS3Files, cfg.MixedReadThreads, task, nil, newRunState())
+    }()
+    return m
+}
+
+// Stop ends the mixed reads and returns their metrics. It is safe to call on a nil MixedReads,
+// which returns nil.
+func (m *MixedReads) Stop() *PerformanceMetrics {
+    if m == nil {
+        return nil
+    }
+    m.cancel()
+    <-m.done
+    return &m.metrics
+}
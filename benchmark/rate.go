@@ -0,0 +1,50 @@
+// benchmark/rate.go
+package benchmark
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// opPacer spaces the operations of all workers of one operation type to a target rate.
+// A nil opPacer does not limit the rate.
+type opPacer struct {
+    mu       sync.Mutex
+    interval time.Duration
+    next     time.Time // Start time of the next operation.
+}
+
+// newOpPacer returns a pacer for rate operations per second, or nil if rate is not positive.
+func newOpPacer(rate float64) *opPacer {
+    if rate <= 0 {
+        return nil
+    }
+    return &opPacer{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait blocks until the next operation may start or ctx is done. Time lost to slow operations
+// is not made up with a burst: the schedule restarts from now.
+func (p *opPacer) wait(ctx context.Context) {
+    if p == nil {
+        return
+    }
+
+    p.mu.Lock()
+    now := time.Now()
+    if p.next.Before(now) {
+        p.next = now
+    }
+    at := p.next
+    p.next = p.next.Add(p.interval)
+    p.mu.Unlock()
+
+    if d := time.Until(at); d > 0 {
+        timer := time.NewTimer(d)
+        defer timer.Stop()
+        select {
+        case <-ctx.Done():
+        case <-timer.C:
+        }
+    }
+}
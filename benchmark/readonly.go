@@ -0,0 +1,87 @@
+// benchmark/readonly.go
+package benchmark
+
+import (
+    "fmt"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/storage"
+)
+
+// ListKeys adds the keys under prefix to keys with paged LIST requests and returns the number
+// of keys added. With keyListingSampleRate below 1 each listed key is kept with that
+// probability, and listing stops early once keyListingMaxKeys keys are kept, so a sample of a
+// huge bucket fits in the key store without listing all of it.
+func ListKeys(cfg *config.Config, s3Client *s3.S3, prefix string, keys *keystore.Store) (int64, error) {
+    var listed, kept int64
+    defer func() {
+        if kept != listed {
+            fmt.Printf("Kept %d of %d listed keys.\n", kept, listed)
+        }
+    }()
+
+    rng := cfg.Rand("listing")
+    task := progress.Begin("Listing existing objects", "objects", 0)
+    defer task.Done()
+    input := &s3.ListObjectsV2Input{
+        Bucket: aws.String(cfg.BucketName),
+        Prefix: aws.String(prefix),
+    }
+    for {
+        if err := monitor.RunContext().Err(); err != nil {
+            return kept, err
+        }
+
+        // Each page request gets its own LIST deadline.
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := s3Client.ListObjectsV2WithContext(ctx, input)
+        cancel()
+        if err != nil {
+            return kept, fmt.Errorf("error listing bucket %s: %w", cfg.BucketName, err)
+        }
+
+        batch := make([]string, 0, len(page.Contents))
+        for _, obj := range page.Contents {
+            if cfg.KeyListingMaxKeys > 0 && kept+int64(len(batch)) >= cfg.KeyListingMaxKeys {
+                break
+            }
+            listed++
+            if cfg.KeyListingSampleRate < 1 && rng.Float64() >= cfg.KeyListingSampleRate {
+                continue
+            }
+            batch = append(batch, aws.StringValue(obj.Key))
+        }
+        if err := keys.AddBatch(batch); err != nil {
+            return kept, fmt.Errorf("error storing listed keys: %w", err)
+        }
+        kept += int64(len(batch))
+        task.Add(int64(len(batch)))
+
+        if !aws.BoolValue(page.IsTruncated) || (cfg.KeyListingMaxKeys > 0 && kept >= cfg.KeyListingMaxKeys) {
+            return kept, nil
+        }
+        input.ContinuationToken = page.NextContinuationToken
+    }
+}
+
+// PerformReadOnlyOperations runs the benchmark phases of the configuration on existing keys, by
+// default GET, STAT and LIST at the same time. The caller must make sure no phase deletes.
+func PerformReadOnlyOperations(cfg *config.Config, backend storage.Backend, keys *keystore.Store) BenchmarkResult {
+    fmt.Println("\nPerforming read-only benchmarking operations...")
+
+    phases := cfg.BenchmarkPhases
+    if len(phases) == 0 {
+        phases = []config.BenchmarkPhase{{Operations: []string{string(OperationGet), string(OperationStat), string(OperationList)}}}
+    }
+    result, err := NewRunner(cfg, backend, keys, WithWorkload(Phases(phases))).Run()
+    if err != nil {
+        fmt.Printf("Error running benchmark: %v\n", err)
+    }
+    return result
+}
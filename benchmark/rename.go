@@ -0,0 +1,105 @@
+// benchmark/rename.go
+package benchmark
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/url"
+    "strings"
+    "sync"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+)
+
+// renamedSuffix is appended to a key by RENAME; the next RENAME of the key moves the object back.
+const renamedSuffix = ".renamed"
+
+// renameTracker remembers which objects RENAME has moved, so the next rename of a key starts
+// from the object's current name, and keeps two workers from renaming the same object at once.
+type renameTracker struct {
+    mu      sync.Mutex
+    renamed map[string]bool // Keys whose object is at key+renamedSuffix.
+    busy    map[string]bool // Keys being renamed.
+}
+
+// errRenameBusy is returned when another worker is renaming the object. The operation is then
+// skipped without being recorded.
+var errRenameBusy = errors.New("object is already being renamed")
+
+func newRenameTracker() *renameTracker {
+    return &renameTracker{renamed: make(map[string]bool), busy: make(map[string]bool)}
+}
+
+// begin reserves key for a rename and returns the current and the new name of its object.
+// ok is false if another worker is renaming it.
+func (t *renameTracker) begin(key string) (from, to string, ok bool) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    if t.busy[key] {
+        return "", "", false
+    }
+    t.busy[key] = true
+    if t.renamed[key] {
+        return key + renamedSuffix, key, true
+    }
+    return key, key + renamedSuffix, true
+}
+
+// end releases key. moved reports whether the object was moved to its new name.
+func (t *renameTracker) end(key string, moved bool) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+
+    delete(t.busy, key)
+    if moved {
+        if t.renamed[key] {
+            delete(t.renamed, key)
+        } else {
+            t.renamed[key] = true
+        }
+    }
+}
+
+// renameObject simulates a rename the way S3 clients do it: a server-side CopyObject to the new
+// key followed by a DeleteObject of the old one. Both requests count in the measured time.
+func renameObject(ctx context.Context, s3Client *s3.S3, renames *renameTracker, bucket, key string) error {
+    from, to, ok := renames.begin(key)
+    if !ok {
+        return errRenameBusy
+    }
+    moved := false
+    defer func() { renames.end(key, moved) }()
+
+    _, err := s3Client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+        Bucket:     aws.String(bucket),
+        Key:        aws.String(to),
+        CopySource: aws.String(copySource(bucket, from)),
+    })
+    if err != nil {
+        return fmt.Errorf("error copying %s to %s: %w", from, to, err)
+    }
+
+    _, err = s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+        Bucket: aws.String(bucket),
+        Key:    aws.String(from),
+    })
+    if err != nil {
+        // The copy exists under the new name; later renames start from there.
+        moved = true
+        return fmt.Errorf("error deleting %s after copying it: %w", from, err)
+    }
+    moved = true
+    return nil
+}
+
+// copySource returns the URL-encoded "bucket/key" form of the x-amz-copy-source header.
+func copySource(bucket, key string) string {
+    segments := strings.Split(key, "/")
+    for i, segment := range segments {
+        segments[i] = url.PathEscape(segment)
+    }
+    return bucket + "/" + strings.Join(segments, "/")
+}
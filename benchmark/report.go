@@ -3,14 +3,69 @@ package benchmark
 
 import (
     "fmt"
+    "math"
+    "runtime"
     "time" // Added import for time
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
 )
 
+// maxErrorSignatures is the number of error signatures printed in the report.
+const maxErrorSignatures = 10
+
+// saturatedCPUPercent is the host CPU use at which the report warns that the load generator,
+// not the storage, may have been the bottleneck.
+const saturatedCPUPercent = 90.0
+
 // GenerateFinalReport generates a summary report of the benchmarking operations.
 func GenerateFinalReport(result BenchmarkResult) {
     fmt.Println("\nBenchmarking Report:")
     fmt.Println("====================")
+    fmt.Printf("Run ID: %s\n", monitor.RunID())
+
+    if reason := monitor.AbortReason(); reason != "" {
+        fmt.Printf("\nPARTIAL REPORT - run aborted: %s\n", reason)
+    }
+    if reason := monitor.TruncateReason(); reason != "" {
+        printTruncation(reason, result)
+    }
+
+    printOperationReport(result)
+    printAccelerationReport(result)
+    printUploadReport()
+    printFolderReport()
+    printConnectionReport()
+    printCircuitReport()
+    printErrorReport()
+    printMultipartReport()
+    printIntegrityReport()
+    printFaultReport()
+    printStallReport()
+    printNotificationReport()
+    printReplicationReport()
+    printResourceReport()
+    fmt.Println("====================")
+}
+
+// GenerateReadOnlyReport prints the report of the readonly command: the benchmark operations
+// run against the keys listed under prefix, and the health of the endpoints.
+func GenerateReadOnlyReport(result BenchmarkResult, prefix string, keys int64) {
+    fmt.Println("\nRead-Only Benchmarking Report:")
+    fmt.Println("==============================")
+    fmt.Printf("Prefix: %s\n", prefix)
+    fmt.Printf("Keys Listed: %d\n", keys)
+
+    printOperationReport(result)
+    printConnectionReport()
+    printCircuitReport()
+    printStallReport()
+    printErrorReport()
+    fmt.Println("==============================")
+}
 
+// printOperationReport prints the metrics of every benchmark operation and their totals.
+func printOperationReport(result BenchmarkResult) {
     totalOperations := int64(0)
     totalErrors := int64(0)
 
@@ -23,18 +78,312 @@ func GenerateFinalReport(result BenchmarkResult) {
         totalErrors += metrics.ErrorCount
 
         fmt.Printf("\nOperation: %s\n", opType)
+        if (opType == OperationGet || opType == OperationGetAccelerated || opType == OperationGetDuringUpload) && result.GetTiming == config.GetTimingFirstByte {
+            fmt.Println("Times: time to first byte; bodies are drained untimed")
+        }
         fmt.Printf("Total Operations: %d\n", metrics.TotalOperations)
+        if metrics.Threads > 0 {
+            fmt.Printf("Threads: %d\n", metrics.Threads)
+        }
+        if metrics.Elapsed > 0 {
+            rate := float64(metrics.TotalOperations) / metrics.Elapsed.Seconds()
+            if metrics.TargetRate > 0 {
+                fmt.Printf("Rate: %.2f ops/sec (target %.2f)\n", rate, metrics.TargetRate)
+            } else {
+                fmt.Printf("Rate: %.2f ops/sec\n", rate)
+            }
+        }
         fmt.Printf("Successes: %d\n", metrics.TotalOperations-metrics.ErrorCount)
         fmt.Printf("Errors: %d\n", metrics.ErrorCount)
         fmt.Printf("Min Time: %v\n", metrics.MinTime)
         fmt.Printf("Max Time: %v\n", metrics.MaxTime)
         fmt.Printf("Avg Time: %v\n", avgTime)
+        if metrics.TotalBytes > 0 {
+            mb := float64(metrics.TotalBytes) / (1024 * 1024)
+            fmt.Printf("Bytes Read: %.2f MB\n", mb)
+            if metrics.Elapsed > 0 {
+                fmt.Printf("Throughput: %.2f MB/sec\n", mb/metrics.Elapsed.Seconds())
+            }
+        }
     }
 
     fmt.Println("\nOverall Benchmark Summary:")
     fmt.Printf("Total Operations: %d\n", totalOperations)
     fmt.Printf("Total Errors: %d\n", totalErrors)
     fmt.Printf("Benchmarking Duration: %v\n", result.Duration)
-    fmt.Println("====================")
+    if result.Phases != "" {
+        fmt.Printf("Phases: %s\n", result.Phases)
+    }
+    if result.Access != "" {
+        fmt.Printf("Key Access: %s\n", result.Access)
+    }
+
+    printDepthReport(result)
 }
 
+// printAccelerationReport prints the average GET latency delta between the standard
+// and the transfer-accelerated endpoint, if the comparison was run.
+func printAccelerationReport(result BenchmarkResult) {
+    standard, ok := result.Metrics[OperationGet]
+    if !ok || standard.TotalOperations == 0 {
+        return
+    }
+    accelerated, ok := result.Metrics[OperationGetAccelerated]
+    if !ok || accelerated.TotalOperations == 0 {
+        return
+    }
+
+    standardAvg := time.Duration(int64(standard.TotalTime) / standard.TotalOperations)
+    acceleratedAvg := time.Duration(int64(accelerated.TotalTime) / accelerated.TotalOperations)
+    delta := acceleratedAvg - standardAvg
+
+    fmt.Println("\nTransfer Acceleration Comparison:")
+    fmt.Printf("Standard GET Avg Time: %v\n", standardAvg)
+    fmt.Printf("Accelerated GET Avg Time: %v\n", acceleratedAvg)
+    fmt.Printf("Delta: %v (%.2f%%)\n", delta, float64(delta)/float64(standardAvg)*100)
+}
+
+// printTruncation marks the report of a run stopped by its time limit, with how much of the
+// work was completed before the remaining work was cancelled.
+func printTruncation(reason string, result BenchmarkResult) {
+    stats := monitor.GetStats()
+    var operations int64
+    for _, metrics := range result.Metrics {
+        operations += metrics.TotalOperations
+    }
+
+    fmt.Printf("\nTRUNCATED REPORT - %s\n", reason)
+    fmt.Printf("Completed before the limit: %d uploads (%d succeeded, %d failed, %d skipped), %d benchmark operations in %v\n",
+        stats.TotalUploads+stats.Skipped, stats.Successes, stats.Failures, stats.Skipped, operations, result.Duration.Round(time.Second))
+}
+
+// printUploadReport prints the totals of the upload phase, including the data written.
+func printUploadReport() {
+    stats := monitor.GetStats()
+    if stats.TotalUploads == 0 && stats.Skipped == 0 {
+        return
+    }
+
+    fmt.Println("\nUpload Summary:")
+    fmt.Printf("Uploads: %d succeeded, %d failed, %d skipped\n", stats.Successes, stats.Failures, stats.Skipped)
+    fmt.Printf("Data Written: %s\n", config.FormatSize(stats.Bytes))
+}
+
+// printFolderReport prints a table with the upload statistics of each subfolder,
+// in upload order, so degradation as the folder count grows is visible.
+func printFolderReport() {
+    folders := monitor.GetFolderStats()
+    if len(folders) == 0 {
+        return
+    }
+
+    fmt.Println("\nPer-Subfolder Upload Statistics:")
+    fmt.Printf("%-6s %-40s %10s %10s %10s %14s %14s\n", "Index", "Subfolder", "Files", "Successes", "Failures", "Duration", "Files/sec")
+    for _, f := range folders {
+        fmt.Printf("%-6d %-40s %10d %10d %10d %14v %14.2f\n",
+            f.Index, f.Name, f.Files, f.Successes, f.Failures, f.Duration.Round(time.Millisecond), f.Throughput())
+    }
+}
+
+// printConnectionReport prints the HTTP transport metrics collected for each endpoint.
+func printConnectionReport() {
+    connStats := monitor.GetConnStats()
+    if len(connStats) == 0 {
+        return
+    }
+
+    fmt.Println("\nConnection Statistics:")
+    for _, cs := range connStats {
+        reuseRatio := 0.0
+        if cs.Requests > 0 {
+            reuseRatio = float64(cs.ReusedConns) / float64(cs.Requests) * 100
+        }
+        fmt.Printf("\nEndpoint: %s\n", cs.Endpoint)
+        fmt.Printf("Requests: %d\n", cs.Requests)
+        fmt.Printf("New Connections: %d\n", cs.NewConns)
+        fmt.Printf("Reused Connections: %d (%.2f%%)\n", cs.ReusedConns, reuseRatio)
+        fmt.Printf("TLS Handshakes: %d (total %v)\n", cs.TLSHandshakes, cs.TLSTime)
+        fmt.Printf("DNS Lookups: %d (total %v)\n", cs.DNSLookups, cs.DNSTime)
+        fmt.Printf("Dial Time: %v\n", cs.DialTime)
+    }
+}
+
+
+// printCircuitReport prints every circuit breaker state change, if any occurred.
+func printCircuitReport() {
+    events := monitor.GetCircuitEvents()
+    if len(events) == 0 {
+        return
+    }
+
+    fmt.Println("\nCircuit Breaker Events:")
+    for _, e := range events {
+        fmt.Printf("%s  %-30s %s\n", e.Time.Format(time.RFC3339), e.Endpoint, e.State)
+    }
+}
+
+// printErrorReport prints the most frequent failed request signatures, with the request IDs
+// of their latest occurrence, if any request failed.
+func printErrorReport() {
+    signatures := monitor.GetErrorSignatures()
+    if len(signatures) == 0 {
+        return
+    }
+
+    fmt.Println("\nTop Error Signatures:")
+    for i, sig := range signatures {
+        if i == maxErrorSignatures {
+            fmt.Printf("... and %d more signatures\n", len(signatures)-i)
+            break
+        }
+        fmt.Printf("\n%s %d %s: %d failures\n", sig.Operation, sig.StatusCode, sig.Code, sig.Count)
+        if sig.Last.Message != "" {
+            fmt.Printf("Message: %s\n", sig.Last.Message)
+        }
+        fmt.Printf("Last: %s on %s, x-amz-request-id %q, x-amz-id-2 %q\n", sig.Last.Time.Format(time.RFC3339), sig.Last.Endpoint, sig.Last.RequestID, sig.Last.HostID)
+    }
+}
+
+// printResourceReport prints the average and peak use of the load generator host over the run,
+// and warns when its CPU was saturated, which makes the tool rather than the storage the limit.
+func printResourceReport() {
+    samples := monitor.GetResourceSamples()
+    if len(samples) == 0 {
+        return
+    }
+
+    var sum, peak monitor.ResourceSample
+    for _, s := range samples {
+        sum.CPUPercent += s.CPUPercent
+        sum.HostCPUPercent += s.HostCPUPercent
+        sum.RSSBytes += s.RSSBytes
+        sum.HostMemPercent += s.HostMemPercent
+        sum.NetRxBytesPerSec += s.NetRxBytesPerSec
+        sum.NetTxBytesPerSec += s.NetTxBytesPerSec
+        sum.OpenFDs += s.OpenFDs
+        peak.CPUPercent = math.Max(peak.CPUPercent, s.CPUPercent)
+        peak.HostCPUPercent = math.Max(peak.HostCPUPercent, s.HostCPUPercent)
+        peak.RSSBytes = max(peak.RSSBytes, s.RSSBytes)
+        peak.HostMemPercent = math.Max(peak.HostMemPercent, s.HostMemPercent)
+        peak.NetRxBytesPerSec = math.Max(peak.NetRxBytesPerSec, s.NetRxBytesPerSec)
+        peak.NetTxBytesPerSec = math.Max(peak.NetTxBytesPerSec, s.NetTxBytesPerSec)
+        peak.OpenFDs = max(peak.OpenFDs, s.OpenFDs)
+    }
+    n := float64(len(samples))
+    rate := func(bytesPerSec float64) string { return config.FormatSize(int64(bytesPerSec)) + "/s" }
+
+    fmt.Printf("\nLoad Generator Resources (%d samples):\n", len(samples))
+    fmt.Printf("Process CPU: avg %.1f%%, max %.1f%% of one core (%d CPUs)\n", sum.CPUPercent/n, peak.CPUPercent, runtime.NumCPU())
+    fmt.Printf("Host CPU: avg %.1f%%, max %.1f%%\n", sum.HostCPUPercent/n, peak.HostCPUPercent)
+    fmt.Printf("Process Memory: avg %s, max %s\n", config.FormatSize(int64(float64(sum.RSSBytes)/n)), config.FormatSize(peak.RSSBytes))
+    fmt.Printf("Host Memory: avg %.1f%%, max %.1f%%\n", sum.HostMemPercent/n, peak.HostMemPercent)
+    fmt.Printf("Network Received: avg %s, max %s\n", rate(sum.NetRxBytesPerSec/n), rate(peak.NetRxBytesPerSec))
+    fmt.Printf("Network Sent: avg %s, max %s\n", rate(sum.NetTxBytesPerSec/n), rate(peak.NetTxBytesPerSec))
+    fmt.Printf("Open File Descriptors: avg %.0f, max %d\n", float64(sum.OpenFDs)/n, peak.OpenFDs)
+    if peak.HostCPUPercent >= saturatedCPUPercent {
+        fmt.Printf("Warning: the host CPU reached %.1f%%; the load generator may have limited the results.\n", peak.HostCPUPercent)
+    }
+}
+
+// printMultipartReport prints multipart upload statistics, if any multipart uploads were made.
+func printMultipartReport() {
+    mp := monitor.GetMultipartStats()
+    if mp.Uploads == 0 && mp.Abandoned == 0 {
+        return
+    }
+
+    fmt.Println("\nMultipart Upload Statistics:")
+    fmt.Printf("Objects: %d\n", mp.Uploads)
+    fmt.Printf("Parts: %d\n", mp.Parts)
+    if mp.Uploads > 0 {
+        fmt.Printf("Avg Endpoints per Object: %.2f\n", float64(mp.EndpointsPerObj)/float64(mp.Uploads))
+        fmt.Printf("Avg Time per Object: %v\n", time.Duration(int64(mp.TotalTime)/mp.Uploads))
+        fmt.Printf("Max Time per Object: %v\n", mp.MaxTime)
+    }
+    if mp.Abandoned > 0 {
+        fmt.Printf("Abandoned Uploads: %d (%d parts, %d bytes left for the orphans command)\n", mp.Abandoned, mp.AbandonedParts, mp.AbandonedBytes)
+    }
+}
+
+// printIntegrityReport prints the ETag verification results, if verification was enabled.
+func printIntegrityReport() {
+    integrity := monitor.GetIntegrityStats()
+    if integrity.Checked == 0 {
+        return
+    }
+
+    fmt.Println("\nIntegrity Verification:")
+    fmt.Printf("ETags Checked: %d\n", integrity.Checked)
+    fmt.Printf("Integrity Failures: %d\n", integrity.Mismatches)
+}
+
+// printFaultReport prints the number of injected faults, if fault injection was active.
+func printFaultReport() {
+    faults := monitor.GetFaultStats()
+    if faults.Errors == 0 && faults.Latency == 0 && faults.Truncated == 0 {
+        return
+    }
+
+    fmt.Println("\nInjected Faults:")
+    fmt.Printf("Errors: %d\n", faults.Errors)
+    fmt.Printf("Delayed Requests: %d\n", faults.Latency)
+    fmt.Printf("Truncated Responses: %d\n", faults.Truncated)
+}
+
+// printStallReport prints the number of transfers cancelled by the stall watchdog, if any stalled.
+func printStallReport() {
+    stalls := monitor.GetStallStats()
+    if stalls.Total() == 0 {
+        return
+    }
+
+    fmt.Println("\nStalled Transfers:")
+    fmt.Printf("While Sending: %d\n", stalls.Sending)
+    fmt.Printf("While Receiving: %d\n", stalls.Receiving)
+    for _, e := range stalls.Endpoints {
+        fmt.Printf("%-30s %d\n", e.Endpoint, e.Stalls)
+    }
+}
+
+// printNotificationReport prints the bucket notification delay statistics, if notifications were measured.
+func printNotificationReport() {
+    n := monitor.GetNotificationStats()
+    if n.Expected == 0 && n.Unmatched == 0 {
+        return
+    }
+
+    fmt.Println("\nBucket Notification Latency:")
+    fmt.Printf("Objects Uploaded: %d\n", n.Expected)
+    fmt.Printf("Notifications Received: %d\n", n.Received)
+    fmt.Printf("Missing Notifications: %d\n", n.Missing)
+    fmt.Printf("Unmatched Notifications: %d\n", n.Unmatched)
+    if n.Received > 0 {
+        fmt.Printf("Min Delay: %v\n", n.MinDelay)
+        fmt.Printf("Max Delay: %v\n", n.MaxDelay)
+        fmt.Printf("Avg Delay: %v\n", time.Duration(int64(n.TotalDelay)/n.Received))
+        fmt.Printf("P50 Delay: %v\n", n.P50)
+        fmt.Printf("P99 Delay: %v\n", n.P99)
+    }
+}
+
+// printReplicationReport prints the replication lag distribution, if replication was measured.
+func printReplicationReport() {
+    r := monitor.GetReplicationStats()
+    if r.Objects == 0 {
+        return
+    }
+
+    fmt.Println("\nReplication Lag:")
+    fmt.Printf("Objects Checked: %d\n", r.Objects)
+    fmt.Printf("Replicated: %d\n", r.Replicated)
+    fmt.Printf("Timed Out: %d\n", r.TimedOut)
+    fmt.Printf("Poll Errors: %d\n", r.PollErrors)
+    if r.Replicated > 0 {
+        fmt.Printf("Min Lag: %v\n", r.MinLag)
+        fmt.Printf("Max Lag: %v\n", r.MaxLag)
+        fmt.Printf("Avg Lag: %v\n", time.Duration(int64(r.TotalLag)/r.Replicated))
+        fmt.Printf("P50 Lag: %v\n", r.P50)
+        fmt.Printf("P90 Lag: %v\n", r.P90)
+        fmt.Printf("P99 Lag: %v\n", r.P99)
+    }
+}
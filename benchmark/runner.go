@@ -0,0 +1,176 @@
+// benchmark/runner.go
+package benchmark
+
+import (
+    "errors"
+    "fmt"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/storage"
+)
+
+// Workload decides which benchmark phases a Runner runs.
+type Workload interface {
+    Phases() []config.BenchmarkPhase
+}
+
+// Phases is a Workload of fixed phases.
+type Phases []config.BenchmarkPhase
+
+// Phases implements Workload.
+func (p Phases) Phases() []config.BenchmarkPhase {
+    return p
+}
+
+// configWorkload is the Workload of benchmarkPhases, or the default GET and STAT then DELETE.
+type configWorkload struct {
+    cfg *config.Config
+}
+
+func (w configWorkload) Phases() []config.BenchmarkPhase {
+    return w.cfg.BenchmarkPlan()
+}
+
+// Reporter receives the result of a Runner once its phases have ended.
+type Reporter interface {
+    Report(result BenchmarkResult) error
+}
+
+// ReporterFunc adapts a function to a Reporter.
+type ReporterFunc func(result BenchmarkResult) error
+
+// Report implements Reporter.
+func (f ReporterFunc) Report(result BenchmarkResult) error {
+    return f(result)
+}
+
+// ConsoleReporter prints the final report of the run, with every statistic the monitor collected.
+var ConsoleReporter Reporter = ReporterFunc(func(result BenchmarkResult) error {
+    GenerateFinalReport(result)
+    return nil
+})
+
+// OperationInfo describes a finished benchmark operation, passed to the operation hooks.
+type OperationInfo struct {
+    Operation OperationType
+    Key       string
+    Endpoint  string
+    Bytes     int64         // Response body bytes read.
+    Latency   time.Duration // The measured time, up to the first byte with first-byte GET timing.
+    Err       error         // Why the operation failed; nil on success.
+}
+
+// PhaseInfo describes a benchmark phase about to start, passed to the phase hooks.
+type PhaseInfo struct {
+    Index      int // From 0.
+    Count      int // Phases of the workload.
+    Operations []string
+    Duration   time.Duration
+}
+
+// Runner runs the phases of a Workload on a set of keys of one storage backend.
+type Runner struct {
+    cfg        *config.Config
+    backend    storage.Backend
+    keys       *keystore.Store
+    workload   Workload
+    reporters  []Reporter
+    sink       progress.Sink
+    opHooks    []func(OperationInfo)
+    phaseHooks []func(PhaseInfo)
+}
+
+// RunnerOption configures a Runner created by NewRunner.
+type RunnerOption func(*Runner)
+
+// WithWorkload runs the phases of w instead of those of the configuration.
+func WithWorkload(w Workload) RunnerOption {
+    return func(r *Runner) { r.workload = w }
+}
+
+// WithReporter passes the result to rep once the phases have ended. Reporters are called in
+// the order they were added; without any the result is only returned.
+func WithReporter(rep Reporter) RunnerOption {
+    return func(r *Runner) { r.reporters = append(r.reporters, rep) }
+}
+
+// WithProgressSink counts the operations of every phase in a task of s instead of the status line.
+func WithProgressSink(s progress.Sink) RunnerOption {
+    return func(r *Runner) { r.sink = s }
+}
+
+// WithOperationHook calls hook after every benchmark operation. Hooks run on the benchmark
+// workers, in the order they were added, so they must be safe for concurrent use and slow ones
+// lower the measured throughput.
+func WithOperationHook(hook func(OperationInfo)) RunnerOption {
+    return func(r *Runner) { r.opHooks = append(r.opHooks, hook) }
+}
+
+// WithPhaseHook calls hook before each benchmark phase starts. Run phases such as uploading and
+// benchmarking are reported by monitor.OnPhaseChange instead.
+func WithPhaseHook(hook func(PhaseInfo)) RunnerOption {
+    return func(r *Runner) { r.phaseHooks = append(r.phaseHooks, hook) }
+}
+
+// NewRunner creates a Runner for the keys of backend. By default it runs the phases of the
+// configuration, shows them on the status line and reports nothing.
+func NewRunner(cfg *config.Config, backend storage.Backend, keys *keystore.Store, opts ...RunnerOption) *Runner {
+    r := &Runner{
+        cfg:      cfg,
+        backend:  backend,
+        keys:     keys,
+        workload: configWorkload{cfg},
+        sink:     progress.StatusLine,
+    }
+    for _, opt := range opts {
+        opt(r)
+    }
+    return r
+}
+
+// Run checks the phases of the workload and runs them, then passes the result to every
+// reporter. A failing reporter does not stop the others; their errors are returned together
+// with the result.
+func (r *Runner) Run() (BenchmarkResult, error) {
+    phases := r.workload.Phases()
+    if err := r.cfg.CheckBenchmarkPhases(phases); err != nil {
+        return BenchmarkResult{}, fmt.Errorf("error in workload: %w", err)
+    }
+    // The configuration may name the S3 backend while a custom Storage was passed in.
+    if _, ok := r.backend.(*storage.S3Backend); !ok {
+        for _, phase := range phases {
+            for _, op := range phase.Operations {
+                if config.S3OnlyOperation(op) {
+                    return BenchmarkResult{}, fmt.Errorf("error in workload: benchmark operation %s needs an S3 backend, got %T", op, r.backend)
+                }
+            }
+        }
+    }
+
+    result := r.runPhases(phases)
+
+    var errs []error
+    for _, rep := range r.reporters {
+        if err := rep.Report(result); err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return result, errors.Join(errs...)
+}
+
+// afterOperation passes info to the operation hooks.
+func (r *Runner) afterOperation(info OperationInfo) {
+    for _, hook := range r.opHooks {
+        hook(info)
+    }
+}
+
+// beforePhase passes info to the phase hooks.
+func (r *Runner) beforePhase(info PhaseInfo) {
+    for _, hook := range r.phaseHooks {
+        hook(info)
+    }
+}
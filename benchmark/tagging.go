@@ -0,0 +1,38 @@
+// benchmark/tagging.go
+package benchmark
+
+import (
+    "context"
+    "fmt"
+    "math/rand"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+)
+
+// putTagging replaces the tag set of an object with count tags, tag1 to tag<count>. Values are
+// drawn from rng so every request changes the stored tags.
+func putTagging(ctx context.Context, s3Client *s3.S3, bucket, key string, count int, rng *rand.Rand) error {
+    tags := make([]*s3.Tag, count)
+    for i := range tags {
+        tags[i] = &s3.Tag{
+            Key:   aws.String(fmt.Sprintf("tag%d", i+1)),
+            Value: aws.String(fmt.Sprintf("%08x", rng.Uint32())),
+        }
+    }
+    _, err := s3Client.PutObjectTaggingWithContext(ctx, &s3.PutObjectTaggingInput{
+        Bucket:  aws.String(bucket),
+        Key:     aws.String(key),
+        Tagging: &s3.Tagging{TagSet: tags},
+    })
+    return err
+}
+
+// getTagging reads the tag set of an object.
+func getTagging(ctx context.Context, s3Client *s3.S3, bucket, key string) error {
+    _, err := s3Client.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+        Bucket: aws.String(bucket),
+        Key:    aws.String(key),
+    })
+    return err
+}
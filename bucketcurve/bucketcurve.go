@@ -0,0 +1,400 @@
+// bucketcurve/bucketcurve.go
+package bucketcurve
+
+import (
+    "encoding/csv"
+    "fmt"
+    "math/rand"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// Checkpoint is the outcome of the timed requests at one bucket count.
+type Checkpoint struct {
+    Buckets    int             // Buckets provisioned by the run so far, or listed with bucketCurveExistingOnly.
+    Listed     int             // Buckets returned by the last ListBuckets, all of the account's.
+    ListErrors int64           // Failed ListBuckets requests.
+    HeadErrors int64           // Failed HeadBucket requests.
+    Listings   stats.Latencies // Time of every ListBuckets request.
+    Heads      stats.Latencies // Time of every HeadBucket request.
+}
+
+// ListAvg returns the average ListBuckets latency.
+func (c *Checkpoint) ListAvg() time.Duration {
+    return c.Listings.Average()
+}
+
+// ListP50 returns the median ListBuckets latency.
+func (c *Checkpoint) ListP50() time.Duration {
+    return c.Listings.Percentile(0.50)
+}
+
+// ListP99 returns the 99th percentile ListBuckets latency.
+func (c *Checkpoint) ListP99() time.Duration {
+    return c.Listings.Percentile(0.99)
+}
+
+// HeadP50 returns the median HeadBucket latency.
+func (c *Checkpoint) HeadP50() time.Duration {
+    return c.Heads.Percentile(0.50)
+}
+
+// HeadP99 returns the 99th percentile HeadBucket latency.
+func (c *Checkpoint) HeadP99() time.Duration {
+    return c.Heads.Percentile(0.99)
+}
+
+// Bucket is a bucket provisioned by the run.
+type Bucket struct {
+    Name    string
+    Created bool // Created by the run; false when it already existed.
+}
+
+// Result is the outcome of a buckets run.
+type Result struct {
+    RunID             string
+    Checkpoints       []Checkpoint
+    Buckets           []Bucket        // Buckets provisioned, created or already owned.
+    CreateFailed      int64           // CreateBucket requests that failed.
+    CreateTimes       stats.Latencies // Time of every successful CreateBucket request.
+    ProvisionDuration time.Duration   // Wall-clock time spent creating buckets.
+    Deleted           int64
+    DeleteFailed      int64
+    StoppedEarly      bool // No bucket of a step could be created, so the remaining checkpoints were skipped.
+}
+
+// Failures returns the failed bucket creations, requests and deletes.
+func (r Result) Failures() int64 {
+    failures := r.CreateFailed + r.DeleteFailed
+    for _, c := range r.Checkpoints {
+        failures += c.ListErrors + c.HeadErrors
+    }
+    return failures
+}
+
+// BucketName returns the name of the n-th bucket from bucketCurveTemplate. The number is
+// zero-padded to the width of the largest one, so the buckets sort in creation order.
+func BucketName(cfg *config.Config, runID string, n int) string {
+    number := fmt.Sprintf("%0*d", len(strconv.Itoa(cfg.BucketCurveCount-1)), n)
+    return strings.NewReplacer("{bucket}", cfg.BucketName, "{run}", runID, "{n}", number).Replace(cfg.BucketCurveTemplate)
+}
+
+// Run creates BucketCurveCount buckets, bucketCurveStep at a time, and after each step times
+// bucketCurveListings ListBuckets and bucketCurveHeads HeadBucket requests. With
+// bucketCurveExistingOnly it creates nothing and times the requests once against the buckets
+// the account already has. The buckets created are deleted again with bucketCurveDeleteBuckets.
+func Run(cfg *config.Config, s3Clients []*s3.S3) Result {
+    runID := cfg.NewRunID()
+    result := Result{RunID: runID}
+    rng := cfg.Rand("bucketcurve/" + runID)
+
+    if cfg.BucketCurveExistingOnly {
+        fmt.Println("\nListing the buckets of the account...")
+        names, err := listBuckets(cfg, s3Clients[0])
+        if err != nil {
+            fmt.Printf("Error listing buckets: %v\n", err)
+            result.Checkpoints = append(result.Checkpoints, Checkpoint{ListErrors: 1})
+            return result
+        }
+        fmt.Printf("Timing %d ListBuckets and %d HeadBucket requests against %d buckets...\n", cfg.BucketCurveListings, cfg.BucketCurveHeads, len(names))
+        result.Checkpoints = append(result.Checkpoints, measure(cfg, s3Clients[0], names, rng))
+        return result
+    }
+
+    var names []string
+    for next := 0; next < cfg.BucketCurveCount; {
+        end := min(next+cfg.BucketCurveStep, cfg.BucketCurveCount)
+        fmt.Printf("\nCreating buckets %d to %d with %d workers...\n", next, end-1, cfg.BucketCurveConcurrency)
+        start := time.Now()
+        buckets := provision(cfg, s3Clients, runID, next, end, &result)
+        result.ProvisionDuration += time.Since(start)
+        next = end
+        if len(buckets) == 0 {
+            fmt.Println("No bucket of the step could be created; stopping.")
+            result.StoppedEarly = true
+            break
+        }
+        result.Buckets = append(result.Buckets, buckets...)
+        for _, b := range buckets {
+            names = append(names, b.Name)
+        }
+
+        fmt.Printf("Timing %d ListBuckets and %d HeadBucket requests at %d buckets...\n", cfg.BucketCurveListings, cfg.BucketCurveHeads, len(names))
+        result.Checkpoints = append(result.Checkpoints, measure(cfg, s3Clients[0], names, rng))
+    }
+
+    if cfg.BucketCurveDeleteBuckets {
+        result.Deleted, result.DeleteFailed = deleteBuckets(cfg, s3Clients, result.Buckets)
+    }
+    return result
+}
+
+// provision creates the buckets with the numbers from to end-1, bucketCurveConcurrency at a time,
+// and returns those that could be provisioned. A bucket the credentials already own is used as
+// it is. The time of every successful CreateBucket request is added to the result.
+func provision(cfg *config.Config, s3Clients []*s3.S3, runID string, from, end int, result *Result) []Bucket {
+    task := progress.Begin("Creating buckets", "buckets", int64(end-from))
+    defer task.Done()
+
+    var mu sync.Mutex
+    var buckets []Bucket
+    var wg sync.WaitGroup
+    var printedErrors int64
+    next := int64(from)
+    for w := 0; w < cfg.BucketCurveConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := int(atomic.AddInt64(&next, 1) - 1)
+                if i >= end {
+                    return
+                }
+                b := Bucket{Name: BucketName(cfg, runID, i)}
+
+                input := &s3.CreateBucketInput{Bucket: aws.String(b.Name)}
+                // us-east-1 is the default location and must not be sent as a constraint.
+                if cfg.Region != "us-east-1" {
+                    input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+                        LocationConstraint: aws.String(cfg.Region),
+                    }
+                }
+
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                opStart := time.Now()
+                _, err := client.CreateBucketWithContext(ctx, input)
+                latency := time.Since(opStart)
+                cancel()
+
+                if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeBucketAlreadyOwnedByYou {
+                    err = nil
+                } else if err == nil {
+                    b.Created = true
+                }
+                if err != nil {
+                    atomic.AddInt64(&result.CreateFailed, 1)
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error creating bucket %s: %v\n", b.Name, err)
+                    }
+                    continue
+                }
+
+                mu.Lock()
+                buckets = append(buckets, b)
+                if b.Created {
+                    result.CreateTimes.Add(latency)
+                }
+                mu.Unlock()
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further bucket errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+    return buckets
+}
+
+// listBuckets returns the names of the buckets of the account.
+func listBuckets(cfg *config.Config, s3Client *s3.S3) ([]string, error) {
+    ctx, cancel := cfg.OperationContext(config.OperationList)
+    defer cancel()
+    output, err := s3Client.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+    if err != nil {
+        return nil, err
+    }
+    names := make([]string, 0, len(output.Buckets))
+    for _, b := range output.Buckets {
+        names = append(names, aws.StringValue(b.Name))
+    }
+    return names, nil
+}
+
+// measure times bucketCurveListings ListBuckets requests and bucketCurveHeads HeadBucket
+// requests on buckets drawn from names, one after the other, so the latencies are not skewed
+// by concurrent requests.
+func measure(cfg *config.Config, s3Client *s3.S3, names []string, rng *rand.Rand) Checkpoint {
+    c := Checkpoint{Buckets: len(names)}
+    for n := 0; n < cfg.BucketCurveListings; n++ {
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        start := time.Now()
+        output, err := s3Client.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+        latency := time.Since(start)
+        cancel()
+        if err != nil {
+            c.ListErrors++
+            if c.ListErrors <= stats.MaxPrintedErrors {
+                fmt.Printf("Error listing buckets: %v\n", err)
+            }
+            continue
+        }
+        c.Listings.Add(latency)
+        c.Listed = len(output.Buckets)
+    }
+
+    for n := 0; n < cfg.BucketCurveHeads && len(names) > 0; n++ {
+        name := names[rng.Intn(len(names))]
+        ctx, cancel := cfg.OperationContext(config.OperationHead)
+        start := time.Now()
+        _, err := s3Client.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(name)})
+        latency := time.Since(start)
+        cancel()
+        if err != nil {
+            c.HeadErrors++
+            if c.HeadErrors <= stats.MaxPrintedErrors {
+                fmt.Printf("Error reading bucket %s: %v\n", name, err)
+            }
+            continue
+        }
+        c.Heads.Add(latency)
+    }
+
+    return c
+}
+
+// deleteBuckets deletes the buckets created by the run, bucketCurveConcurrency at a time. Buckets
+// that already existed are kept. It returns how many were deleted and how many could not be.
+func deleteBuckets(cfg *config.Config, s3Clients []*s3.S3, buckets []Bucket) (deleted, failed int64) {
+    var created []string
+    for _, b := range buckets {
+        if b.Created {
+            created = append(created, b.Name)
+        }
+    }
+    fmt.Printf("\nDeleting the %d buckets created by the run...\n", len(created))
+    task := progress.Begin("Deleting buckets", "buckets", int64(len(created)))
+    defer task.Done()
+
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    for w := 0; w < cfg.BucketCurveConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := atomic.AddInt64(&next, 1) - 1
+                if i >= int64(len(created)) {
+                    return
+                }
+
+                ctx, cancel := cfg.OperationContext(config.OperationDelete)
+                _, err := client.DeleteBucketWithContext(ctx, &s3.DeleteBucketInput{Bucket: aws.String(created[i])})
+                cancel()
+                if err != nil {
+                    atomic.AddInt64(&failed, 1)
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error deleting bucket %s: %v\n", created[i], err)
+                    }
+                    continue
+                }
+                atomic.AddInt64(&deleted, 1)
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further delete errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    return deleted, failed
+}
+
+// PrintReport prints the ListBuckets and HeadBucket latencies at every checkpoint and how they
+// grew from the first to the last checkpoint.
+func PrintReport(r Result) {
+    fmt.Println("\nListBuckets Latency vs Bucket Count Report:")
+    fmt.Println("===========================================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+
+    if len(r.Buckets) > 0 || r.CreateFailed > 0 {
+        created := 0
+        for _, b := range r.Buckets {
+            if b.Created {
+                created++
+            }
+        }
+        fmt.Printf("Buckets: %d (%d created, %d already existed, %d failed)\n", len(r.Buckets)+int(r.CreateFailed), created, len(r.Buckets)-created, r.CreateFailed)
+        fmt.Printf("Provisioning Duration: %v\n", r.ProvisionDuration.Round(time.Millisecond))
+        if r.CreateTimes.Count > 0 {
+            fmt.Printf("CreateBucket Latency: avg %v, p50 %v, p99 %v\n", r.CreateTimes.Average().Round(time.Microsecond),
+                r.CreateTimes.Percentile(0.50).Round(time.Microsecond), r.CreateTimes.Percentile(0.99).Round(time.Microsecond))
+        }
+    }
+    fmt.Println()
+
+    fmt.Printf("%10s %10s %12s %12s %12s %12s %12s %8s\n", "Buckets", "Listed", "List Avg", "List P50", "List P99", "Head P50", "Head P99", "Errors")
+    for _, c := range r.Checkpoints {
+        fmt.Printf("%10d %10d %12v %12v %12v %12v %12v %8d\n", c.Buckets, c.Listed,
+            c.ListAvg().Round(time.Microsecond), c.ListP50().Round(time.Microsecond), c.ListP99().Round(time.Microsecond),
+            c.HeadP50().Round(time.Microsecond), c.HeadP99().Round(time.Microsecond), c.ListErrors+c.HeadErrors)
+    }
+
+    if len(r.Checkpoints) > 1 {
+        first, last := r.Checkpoints[0], r.Checkpoints[len(r.Checkpoints)-1]
+        if first.ListP50() > 0 && first.HeadP50() > 0 {
+            fmt.Printf("\nFrom %d to %d buckets: ListBuckets P50 x%.2f, HeadBucket P50 x%.2f\n", first.Buckets, last.Buckets,
+                float64(last.ListP50())/float64(first.ListP50()), float64(last.HeadP50())/float64(first.HeadP50()))
+        }
+    }
+    if r.StoppedEarly {
+        fmt.Println("The run stopped early because no bucket of a step could be created.")
+    }
+
+    if r.Deleted > 0 || r.DeleteFailed > 0 {
+        fmt.Printf("\nBuckets Deleted: %d\n", r.Deleted)
+        fmt.Printf("Buckets Not Deleted: %d\n", r.DeleteFailed)
+    }
+    fmt.Println("===========================================")
+}
+
+// WriteCSV writes one row per checkpoint, the curve of ListBuckets and HeadBucket latency over
+// the bucket count.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating bucket curve report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Buckets", "Listed", "ListAvgMs", "ListP50Ms", "ListP99Ms", "HeadP50Ms", "HeadP99Ms", "ListErrors", "HeadErrors"})
+    for _, c := range r.Checkpoints {
+        writer.Write([]string{
+            strconv.Itoa(c.Buckets),
+            strconv.Itoa(c.Listed),
+            stats.Milliseconds(c.ListAvg()),
+            stats.Milliseconds(c.ListP50()),
+            stats.Milliseconds(c.ListP99()),
+            stats.Milliseconds(c.HeadP50()),
+            stats.Milliseconds(c.HeadP99()),
+            strconv.FormatInt(c.ListErrors, 10),
+            strconv.FormatInt(c.HeadErrors, 10),
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing bucket curve report %s: %w", filePath, err)
+    }
+    return nil
+}
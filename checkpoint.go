@@ -0,0 +1,76 @@
+// checkpoint.go
+package main
+
+import (
+    "fmt"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// startCheckpoints writes a checkpoint of the run to checkpointPath every checkpointSeconds,
+// after syncing the manifest and the failures file, so they list at least every upload the
+// checkpoint counts. The returned function writes a final checkpoint and stops.
+func startCheckpoints(cfg *config.Config) func() {
+    if cfg.CheckpointSeconds < 0 {
+        return func() {}
+    }
+    return monitor.StartCheckpoints(cfg.CheckpointPath, time.Duration(cfg.CheckpointSeconds)*time.Second, syncRunFiles)
+}
+
+// syncRunFiles syncs the manifest and the failures file of the run to disk, while they are open.
+func syncRunFiles() error {
+    runArtifacts.Lock()
+    defer runArtifacts.Unlock()
+
+    if runArtifacts.manifest != nil {
+        if err := runArtifacts.manifest.Sync(); err != nil {
+            return fmt.Errorf("error syncing manifest: %w", err)
+        }
+    }
+    if runArtifacts.uploader != nil && runArtifacts.uploader.Failures != nil {
+        if err := runArtifacts.uploader.Failures.Sync(); err != nil {
+            return fmt.Errorf("error syncing failures file: %w", err)
+        }
+    }
+    return nil
+}
+
+// printCheckpoint prints what a run had completed when the checkpoint at path was written.
+func printCheckpoint(path string, c monitor.Checkpoint) {
+    fmt.Println("\nCheckpoint Report:")
+    fmt.Println("==================")
+    fmt.Printf("Checkpoint: %s (#%d, written %s, %v ago)\n", path, c.Sequence, c.Time.UTC().Format(time.RFC3339), time.Since(c.Time).Round(time.Second))
+    fmt.Printf("Run ID: %s\n", c.RunID)
+    fmt.Printf("Phase: %s\n", c.Phase)
+    if c.Reason != "final" {
+        fmt.Println("Not the final checkpoint: the run was still going when it was written, or it never finished.")
+    }
+    if c.AbortReason != "" {
+        fmt.Printf("Abort Reason: %s\n", c.AbortReason)
+    }
+    if !c.Stats.StartTime.IsZero() {
+        fmt.Printf("Run Started: %s (%v before the checkpoint)\n", c.Stats.StartTime.UTC().Format(time.RFC3339), c.Time.Sub(c.Stats.StartTime).Round(time.Second))
+    }
+    fmt.Printf("Uploads: %d succeeded, %d failed, %d skipped\n", c.Stats.Successes, c.Stats.Failures, c.Stats.Skipped)
+    fmt.Printf("Data Written: %s\n", config.FormatSize(c.Stats.Bytes))
+
+    fmt.Printf("\nCompleted Subfolders: %d\n", len(c.Folders))
+    if len(c.Folders) > 0 {
+        fmt.Printf("%-6s %-40s %10s %10s %10s\n", "Index", "Subfolder", "Files", "Successes", "Failures")
+        for _, f := range c.Folders {
+            fmt.Printf("%-6d %-40s %10d %10d %10d\n", f.Index, f.Name, f.Files, f.Successes, f.Failures)
+        }
+    }
+
+    if len(c.Cursors) > 0 {
+        fmt.Printf("\nSubfolders in Progress: %d\n", len(c.Cursors))
+        fmt.Printf("%-40s %10s %10s %10s\n", "Subfolder", "Files", "Scheduled", "Done")
+        for _, cursor := range c.Cursors {
+            fmt.Printf("%-40s %10d %10d %10d\n", cursor.Name, cursor.Files, cursor.Scheduled, cursor.Done)
+        }
+    }
+    fmt.Println("\nThe manifest lists every object uploaded up to the checkpoint; the counters above may trail it by the uploads in flight.")
+    fmt.Println("==================")
+}
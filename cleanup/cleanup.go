@@ -0,0 +1,170 @@
+// cleanup/cleanup.go
+package cleanup
+
+import (
+    "fmt"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// Result is the outcome of deleting the objects of one run.
+type Result struct {
+    RunID    string
+    Prefix   string
+    Deleted  int64
+    Failed   int64
+    Duration time.Duration
+}
+
+// DeleteRun deletes every object below the prefix of a run, s3Folder/<runID>/. Each LIST page
+// of up to 1000 keys is removed with a single DeleteObjects request. Keys that could not be
+// deleted, for example because Object Lock retains them, are counted as failed.
+func DeleteRun(cfg *config.Config, s3Client *s3.S3, runID string) (Result, error) {
+    if !config.ValidRunID(runID) {
+        // An empty or malformed ID could widen the prefix to other runs.
+        return Result{}, fmt.Errorf("invalid run ID %q", runID)
+    }
+
+    result := Result{RunID: runID, Prefix: cfg.RunPrefix(runID) + "/"}
+    start := time.Now()
+
+    task := progress.Begin("Deleting objects of run "+runID, "objects", 0)
+    defer task.Done()
+
+    input := &s3.ListObjectsV2Input{
+        Bucket: aws.String(cfg.BucketName),
+        Prefix: aws.String(result.Prefix),
+    }
+    printedErrors := 0
+    for {
+        // Each page request gets its own LIST deadline.
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := s3Client.ListObjectsV2WithContext(ctx, input)
+        cancel()
+        if err != nil {
+            result.Duration = time.Since(start)
+            return result, fmt.Errorf("error listing bucket %s: %w", cfg.BucketName, err)
+        }
+
+        if len(page.Contents) > 0 {
+            objects := make([]*s3.ObjectIdentifier, len(page.Contents))
+            for i, obj := range page.Contents {
+                objects[i] = &s3.ObjectIdentifier{Key: obj.Key}
+            }
+            if err := deleteObjects(cfg, s3Client, objects, &result, task, &printedErrors); err != nil {
+                result.Duration = time.Since(start)
+                return result, err
+            }
+        }
+
+        if !aws.BoolValue(page.IsTruncated) {
+            break
+        }
+        input.ContinuationToken = page.NextContinuationToken
+    }
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further delete errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    result.Duration = time.Since(start)
+    return result, nil
+}
+
+// DeleteRunVersions deletes every version and delete marker below the prefix of a run,
+// s3Folder/<runID>/, for runs written to a versioned bucket, where DeleteRun would only add
+// delete markers. Each page of up to 1000 versions is removed with a single DeleteObjects request.
+func DeleteRunVersions(cfg *config.Config, s3Client *s3.S3, runID string) (Result, error) {
+    if !config.ValidRunID(runID) {
+        // An empty or malformed ID could widen the prefix to other runs.
+        return Result{}, fmt.Errorf("invalid run ID %q", runID)
+    }
+
+    result := Result{RunID: runID, Prefix: cfg.RunPrefix(runID) + "/"}
+    start := time.Now()
+
+    task := progress.Begin("Deleting object versions of run "+runID, "versions", 0)
+    defer task.Done()
+
+    input := &s3.ListObjectVersionsInput{
+        Bucket: aws.String(cfg.BucketName),
+        Prefix: aws.String(result.Prefix),
+    }
+    printedErrors := 0
+    for {
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := s3Client.ListObjectVersionsWithContext(ctx, input)
+        cancel()
+        if err != nil {
+            result.Duration = time.Since(start)
+            return result, fmt.Errorf("error listing object versions of bucket %s: %w", cfg.BucketName, err)
+        }
+
+        var objects []*s3.ObjectIdentifier
+        for _, v := range page.Versions {
+            objects = append(objects, &s3.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+        }
+        for _, m := range page.DeleteMarkers {
+            objects = append(objects, &s3.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+        }
+        if len(objects) > 0 {
+            if err := deleteObjects(cfg, s3Client, objects, &result, task, &printedErrors); err != nil {
+                result.Duration = time.Since(start)
+                return result, err
+            }
+        }
+
+        if !aws.BoolValue(page.IsTruncated) {
+            break
+        }
+        input.KeyMarker, input.VersionIdMarker = page.NextKeyMarker, page.NextVersionIdMarker
+    }
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further delete errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    result.Duration = time.Since(start)
+    return result, nil
+}
+
+// deleteObjects removes up to 1000 objects with a single DeleteObjects request and adds the
+// outcome to result.
+func deleteObjects(cfg *config.Config, s3Client *s3.S3, objects []*s3.ObjectIdentifier, result *Result, task *progress.Task, printedErrors *int) error {
+    ctx, cancel := cfg.OperationContext(config.OperationDelete)
+    output, err := s3Client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+        Bucket: aws.String(cfg.BucketName),
+        Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+    })
+    cancel()
+    if err != nil {
+        return fmt.Errorf("error deleting objects: %w", err)
+    }
+
+    // In quiet mode only the keys that failed are returned.
+    for _, e := range output.Errors {
+        if *printedErrors++; *printedErrors <= stats.MaxPrintedErrors {
+            progress.Printf("Error deleting %s: %s\n", aws.StringValue(e.Key), aws.StringValue(e.Message))
+        }
+    }
+    failed := int64(len(output.Errors))
+    result.Failed += failed
+    result.Deleted += int64(len(objects)) - failed
+    task.Add(int64(len(objects)) - failed)
+    task.Fail(failed)
+    return nil
+}
+
+// PrintReport prints the number of objects deleted for each run.
+func PrintReport(results []Result) {
+    fmt.Println("\nCleanup Report:")
+    fmt.Println("===============")
+    for _, r := range results {
+        fmt.Printf("Run %s (%s): %d deleted, %d failed in %v\n", r.RunID, r.Prefix, r.Deleted, r.Failed, r.Duration.Round(time.Millisecond))
+    }
+    fmt.Println("===============")
+}
@@ -0,0 +1,913 @@
+// commands.go
+package main
+
+import (
+    "fmt"
+    "math/rand"
+    "os"
+    "os/signal"
+    "path/filepath"
+    "syscall"
+    "time"
+
+    "scale_s3_benchmark/age"
+    "scale_s3_benchmark/benchmark"
+    "scale_s3_benchmark/bucketcurve"
+    "scale_s3_benchmark/cleanup"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/deletesweep"
+    "scale_s3_benchmark/depth"
+    "scale_s3_benchmark/fanout"
+    "scale_s3_benchmark/firehose"
+    "scale_s3_benchmark/hugeobject"
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/latencylog"
+    "scale_s3_benchmark/lifecycle"
+    "scale_s3_benchmark/listcurve"
+    "scale_s3_benchmark/manifest"
+    "scale_s3_benchmark/migrate"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/orphans"
+    "scale_s3_benchmark/quota"
+    "scale_s3_benchmark/redrive"
+    "scale_s3_benchmark/restore"
+    "scale_s3_benchmark/results"
+    "scale_s3_benchmark/s3upload"
+    "scale_s3_benchmark/script"
+    "scale_s3_benchmark/storage"
+    "scale_s3_benchmark/tenants"
+    "scale_s3_benchmark/versions"
+    "scale_s3_benchmark/verify"
+)
+
+// runCommand runs a named subcommand and returns the process exit code.
+func runCommand(cfg *config.Config, name string, args []string) int {
+    switch name {
+    case "verify":
+        return runVerify(cfg, args)
+    case "serve":
+        return runServe(cfg)
+    case "daemon":
+        return runDaemon(cfg)
+    case "migrate":
+        return runMigrate(cfg)
+    case "restore":
+        return runRestore(cfg)
+    case "huge":
+        return runHuge(cfg)
+    case "firehose":
+        return runFirehose(cfg)
+    case "cleanup":
+        return runCleanup(cfg, args)
+    case "readonly":
+        return runReadOnly(cfg, args)
+    case "deletesweep":
+        return runDeleteSweep(cfg)
+    case "tenants":
+        return runTenants(cfg)
+    case "quota":
+        return runQuota(cfg)
+    case "buckets":
+        return runBuckets(cfg)
+    case "listcurve":
+        return runListCurve(cfg)
+    case "depth":
+        return runDepth(cfg)
+    case "fanout":
+        return runFanOut(cfg)
+    case "versions":
+        return runVersions(cfg)
+    case "lifecycle":
+        return runLifecycle(cfg)
+    case "orphans":
+        return runOrphans(cfg, args)
+    case "redrive":
+        return runRedrive(cfg, args)
+    case "checkpoint":
+        return runCheckpoint(cfg, args)
+    case "age":
+        return runAge(cfg, args)
+    case "report":
+        return runReport(cfg, args)
+    case "script":
+        return runScript(cfg)
+    default:
+        fmt.Printf("Unknown command %q. Available commands: verify, serve, daemon, migrate, restore, huge, firehose, cleanup, readonly, deletesweep, tenants, quota, buckets, listcurve, depth, fanout, versions, lifecycle, orphans, redrive, checkpoint, age, report, script\n", name)
+        return 2
+    }
+}
+
+// runServe starts the web server with the control API, plus the gRPC control plane when
+// configured, and waits for runs to be started remotely. It only returns on start-up errors.
+func runServe(cfg *config.Config) int {
+    monitor.StartPeriodicReporting("plot/stats_report.csv", time.Minute, logRotation(cfg))
+    if cfg.ResourceSampleSeconds > 0 {
+        monitor.StartResourceSampling(time.Duration(cfg.ResourceSampleSeconds) * time.Second)
+    }
+    rand.Seed(time.Now().UnixNano())
+
+    if err := startWebServer(cfg); err != nil {
+        fmt.Println(err)
+        return 1
+    }
+    if cfg.GrpcListenAddress != "" {
+        if err := startGRPCServer(cfg); err != nil {
+            fmt.Println(err)
+            return 1
+        }
+    }
+    select {}
+}
+
+// runDaemon serves like runServe and also accepts benchmark jobs on /api/jobs, which are
+// queued and run one after another.
+func runDaemon(cfg *config.Config) int {
+    if err := startJobQueue(cfg); err != nil {
+        fmt.Println(err)
+        return 1
+    }
+    registerJobRoutes()
+    return runServe(cfg)
+}
+
+// runVerify reconciles the bucket contents against the upload manifest.
+// An optional argument overrides the manifest path from the configuration.
+func runVerify(cfg *config.Config, args []string) int {
+    manifestPath := cfg.ManifestPath
+    if len(args) > 0 {
+        manifestPath = args[0]
+    }
+
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The verify command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    entries, err := manifest.Read(manifestPath)
+    if err != nil {
+        fmt.Printf("Error reading manifest: %v\n", err)
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result, err := verify.Run(cfg, s3Clients[0], entries)
+    if err != nil {
+        fmt.Printf("Error verifying bucket: %v\n", err)
+        return 1
+    }
+
+    verify.PrintReport(result)
+    if !result.OK() {
+        return 1
+    }
+    return 0
+}
+
+// runMigrate copies objects from the migration source to the configured storage and
+// reports the end-to-end migration throughput.
+func runMigrate(cfg *config.Config) int {
+    if cfg.MigrationSourceEndpointURL == "" {
+        fmt.Println("migrationSourceEndpointURL must be set to run the migrate command.")
+        return 1
+    }
+
+    source, err := s3upload.NewClient(cfg, cfg.MigrationSourceEndpointURL, cfg.MigrationSourceAccessKey, cfg.MigrationSourceSecretKey)
+    if err != nil {
+        fmt.Printf("Error initializing migration source: %v\n", err)
+        return 1
+    }
+
+    targets, err := s3upload.InitializeBackends(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing storage backends: %v\n", err)
+        return 1
+    }
+
+    result, err := migrate.Run(cfg, source, targets)
+    migrate.PrintReport(result)
+    if err != nil {
+        fmt.Printf("Error migrating objects: %v\n", err)
+        return 1
+    }
+    if result.GetErrors > 0 || result.PutErrors > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runRestore restores archived objects and reports the time to restore and the GET
+// latency of the restored objects.
+func runRestore(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The restore command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result, err := restore.Run(cfg, s3Clients[0])
+    if err != nil {
+        fmt.Printf("Error restoring objects: %v\n", err)
+        return 1
+    }
+
+    restore.PrintReport(result)
+    if result.RequestErrors > 0 || result.TimedOut > 0 || result.GetErrors > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runHuge uploads a few very large objects through streamed multipart uploads and
+// reports per-part and aggregate throughput.
+func runHuge(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The huge command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result, err := hugeobject.Run(cfg, s3Clients)
+    if err != nil {
+        fmt.Printf("Error uploading huge objects: %v\n", err)
+        return 1
+    }
+
+    hugeobject.PrintReport(result)
+    for _, o := range result.Objects {
+        if o.Err != nil {
+            return 1
+        }
+    }
+    return 0
+}
+
+// runFirehose uploads millions of tiny objects as fast as possible and reports the rate and latency.
+func runFirehose(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The firehose command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    // Keep an idle connection for every worker so requests never wait for a new connection.
+    firehoseCfg := *cfg
+    firehoseCfg.DisableKeepAlives = false
+    if firehoseCfg.MaxIdleConnsPerHost < cfg.FirehoseConcurrency {
+        firehoseCfg.MaxIdleConnsPerHost = cfg.FirehoseConcurrency
+    }
+    if firehoseCfg.MaxIdleConns != 0 && firehoseCfg.MaxIdleConns < cfg.FirehoseConcurrency {
+        firehoseCfg.MaxIdleConns = cfg.FirehoseConcurrency
+    }
+
+    s3Clients, err := s3upload.InitializeUntracedS3Clients(&firehoseCfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := firehose.Run(&firehoseCfg, s3Clients)
+    firehose.PrintReport(result)
+    if result.Failed > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runCleanup deletes the objects of the runs given as arguments, or of runID from the
+// configuration when no argument is given.
+func runCleanup(cfg *config.Config, args []string) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The cleanup command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    runIDs := args
+    if len(runIDs) == 0 && cfg.RunID != "" {
+        runIDs = []string{cfg.RunID}
+    }
+    if len(runIDs) == 0 {
+        fmt.Println("Give the IDs of the runs to clean up as arguments, or set runID.")
+        return 2
+    }
+    for _, runID := range runIDs {
+        if !config.ValidRunID(runID) {
+            fmt.Printf("Invalid run ID %q.\n", runID)
+            return 2
+        }
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    var results []cleanup.Result
+    code := 0
+    for _, runID := range runIDs {
+        result, err := cleanup.DeleteRun(cfg, s3Clients[0], runID)
+        results = append(results, result)
+        if err != nil {
+            fmt.Printf("Error cleaning up run %s: %v\n", runID, err)
+            code = 1
+        }
+        if result.Failed > 0 {
+            code = 1
+        }
+    }
+
+    cleanup.PrintReport(results)
+    return code
+}
+
+// runReadOnly benchmarks GET, STAT and LIST against the objects already under readOnlyPrefix,
+// or under the prefix given as argument, without generating, uploading or deleting anything.
+func runReadOnly(cfg *config.Config, args []string) int {
+    prefix := cfg.ReadOnlyPrefix
+    if len(args) > 0 {
+        prefix = args[0]
+    }
+
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The readonly command is only supported with the s3 storage backend.")
+        return 1
+    }
+    for _, phase := range cfg.BenchmarkPhases {
+        for _, op := range phase.Operations {
+            switch benchmark.OperationType(op) {
+            case benchmark.OperationDelete, benchmark.OperationRename, benchmark.OperationPutTagging, benchmark.OperationPutACL, benchmark.OperationPutPolicy, benchmark.OperationPutCORS:
+                fmt.Printf("The readonly command never modifies the bucket or its objects; remove %s from benchmarkPhases.\n", op)
+                return 1
+            }
+        }
+    }
+
+    backends, err := s3upload.InitializeBackends(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing storage backends: %v\n", err)
+        return 1
+    }
+
+    keys := keystore.New(cfg.KeyStoreDir, cfg.KeyStoreMemoryLimit)
+    defer func() {
+        if err := keys.Close(); err != nil {
+            fmt.Printf("Error closing key store: %v\n", err)
+        }
+    }()
+
+    fmt.Printf("Listing objects under %q...\n", prefix)
+    listed, err := benchmark.ListKeys(cfg, backends[0].(*storage.S3Backend).Client, prefix, keys)
+    if err != nil {
+        fmt.Printf("Error listing objects: %v\n", err)
+        return 1
+    }
+    if listed == 0 {
+        fmt.Printf("No objects found under %q.\n", prefix)
+        return 1
+    }
+    fmt.Printf("Found %d objects.\n", listed)
+
+    if cfg.LatencyLogPath != "" {
+        if err := latencylog.Open(cfg.LatencyLogPath, cfg.LatencyLogFormat, logRotation(cfg)); err != nil {
+            fmt.Printf("Error opening latency log: %v\n", err)
+            return 1
+        }
+        defer func() {
+            if err := latencylog.Close(); err != nil {
+                fmt.Println(err)
+            }
+        }()
+    }
+
+    result := benchmark.PerformReadOnlyOperations(cfg, backends[0], keys)
+    benchmark.GenerateReadOnlyReport(result, prefix, listed)
+    return 0
+}
+
+// runDeleteSweep creates and deletes objects with DeleteObjects requests of several batch sizes
+// and reports the request and per-key latency of each.
+func runDeleteSweep(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The deletesweep command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := deletesweep.Run(cfg, s3Clients)
+    deletesweep.PrintReport(result)
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runTenants creates one bucket per simulated tenant, spreads uploads across them and reports
+// the provisioning time and the throughput of every bucket.
+func runTenants(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The tenants command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := tenants.Run(cfg, s3Clients)
+    tenants.PrintReport(result)
+
+    writeCommandReport(cfg, result.RunID+"-tenants.csv", "Per-bucket report", func(reportPath string) error {
+        return tenants.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runQuota uploads until the endpoint refuses writes and reports the object count and bytes
+// at which throttling and denials began.
+func runQuota(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The quota command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := quota.Run(cfg, s3Clients)
+    quota.PrintReport(result)
+
+    writeCommandReport(cfg, result.RunID+"-quota.csv", "Throughput windows", func(reportPath string) error {
+        return quota.WriteCSV(reportPath, result)
+    })
+
+    if result.Failed() {
+        return 1
+    }
+    return 0
+}
+
+// runListCurve fills a single prefix step by step and times complete listings of it at every
+// step, showing how LIST latency grows with the prefix size.
+func runListCurve(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The listcurve command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := listcurve.Run(cfg, s3Clients)
+    listcurve.PrintReport(result)
+    writeCommandReport(cfg, result.RunID+"-listcurve.csv", "LIST latency curve", func(reportPath string) error {
+        return listcurve.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 || result.StoppedEarly {
+        return 1
+    }
+    return 0
+}
+
+// runBuckets provisions buckets step by step and reports the ListBuckets and HeadBucket latency
+// at every bucket count.
+func runBuckets(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The buckets command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := bucketcurve.Run(cfg, s3Clients)
+    bucketcurve.PrintReport(result)
+    writeCommandReport(cfg, result.RunID+"-buckets.csv", "ListBuckets latency curve", func(reportPath string) error {
+        return bucketcurve.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 || result.StoppedEarly {
+        return 1
+    }
+    return 0
+}
+
+// runDepth builds namespaces of several directory depths and reports the PUT, HEAD and LIST
+// latency of each.
+func runDepth(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The depth command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := depth.Run(cfg, s3Clients)
+    depth.PrintReport(result)
+    writeCommandReport(cfg, result.RunID+"-depth.csv", "Latency by depth", func(reportPath string) error {
+        return depth.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runFanOut sweeps combinations of prefix count and objects per prefix and reports the PUT
+// and GET throughput of each as a matrix.
+func runFanOut(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The fanout command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := fanout.Run(cfg, s3Clients)
+    fanout.PrintReport(result)
+    writeCommandReport(cfg, result.RunID+"-fanout.csv", "Fan-out matrix", func(reportPath string) error {
+        return fanout.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runVersions writes keys with many versions each on a versioned bucket and reports how
+// version listing, latest-version reads and version deletes scale with the versions per key.
+func runVersions(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The versions command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result, err := versions.Run(cfg, s3Clients)
+    if err != nil {
+        fmt.Printf("Error running the version stress test: %v\n", err)
+        return 1
+    }
+
+    versions.PrintReport(result)
+    writeCommandReport(cfg, result.RunID+"-versions.csv", "Latency by versions per key", func(reportPath string) error {
+        return versions.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runLifecycle uploads objects for a lifecycle rule to expire and reports how long after their
+// due time they disappeared. The observation can last days; an interrupt ends it early and
+// still prints the report.
+func runLifecycle(cfg *config.Config) int {
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The lifecycle command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    // The first interrupt stops the observation; a second one kills the process as usual.
+    interrupts := make(chan os.Signal, 1)
+    signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+    go func() {
+        <-interrupts
+        signal.Stop(interrupts)
+        monitor.Abort("interrupted")
+    }()
+    defer signal.Stop(interrupts)
+
+    result, err := lifecycle.Run(cfg, s3Clients)
+    if err != nil {
+        fmt.Printf("Error observing lifecycle expiration: %v\n", err)
+        if result.RuleError != nil {
+            fmt.Printf("Rule Error: %v\n", result.RuleError)
+        }
+        return 1
+    }
+
+    lifecycle.PrintReport(result)
+    writeCommandReport(cfg, result.RunID+"-lifecycle.csv", "Per-object expiration report", func(reportPath string) error {
+        return lifecycle.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runOrphans aborts the incomplete multipart uploads below orphanPrefix, or the prefix given as
+// argument, and reports the parts they left behind and the latency of cleaning them up.
+func runOrphans(cfg *config.Config, args []string) int {
+    prefix := cfg.OrphanPrefix
+    if len(args) > 0 {
+        prefix = args[0]
+    }
+
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The orphans command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result := orphans.Run(cfg, s3Clients, prefix)
+    orphans.PrintReport(result)
+    writeCommandReport(cfg, result.RunID+"-orphans.csv", "Incomplete upload report", func(reportPath string) error {
+        return orphans.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runRedrive uploads the failed uploads recorded in failuresPath, or the file given as argument,
+// again and keeps only those that still fail in the file.
+func runRedrive(cfg *config.Config, args []string) int {
+    failuresPath := cfg.FailuresPath
+    if len(args) > 0 {
+        failuresPath = args[0]
+    }
+
+    backends, err := s3upload.InitializeBackends(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing storage backends: %v\n", err)
+        return 1
+    }
+
+    result, err := redrive.Run(cfg, backends, failuresPath)
+    redrive.PrintReport(result)
+    if err != nil {
+        fmt.Printf("Error re-driving failed uploads: %v\n", err)
+        return 1
+    }
+    if result.Failed > 0 || result.MissingFiles > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runCheckpoint reports what a run had completed at its last checkpoint, read from
+// checkpointPath or the file given as argument.
+func runCheckpoint(cfg *config.Config, args []string) int {
+    checkpointPath := cfg.CheckpointPath
+    if len(args) > 0 {
+        checkpointPath = args[0]
+    }
+
+    checkpoint, err := monitor.ReadCheckpoint(checkpointPath)
+    if err != nil {
+        fmt.Println(err)
+        return 1
+    }
+    printCheckpoint(checkpointPath, checkpoint)
+    return 0
+}
+
+// runAge reads objects of different ages below agePrefix, or the prefix given as an argument,
+// and reports GET latency per age bucket, exposing tiering or cache eviction in the backend.
+func runAge(cfg *config.Config, args []string) int {
+    prefix := cfg.AgePrefix
+    if len(args) > 0 {
+        prefix = args[0]
+    }
+
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The age command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result, err := age.Run(cfg, s3Clients, prefix)
+    if err != nil {
+        fmt.Printf("Error running the object age benchmark: %v\n", err)
+        return 1
+    }
+    age.PrintReport(result)
+    writeCommandReport(cfg, result.RunID+"-age.csv", "Object age report", func(reportPath string) error {
+        return age.WriteCSV(reportPath, result)
+    })
+
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runScript runs the scripted workload of workloadScript and reports every operation type the
+// script issued.
+func runScript(cfg *config.Config) int {
+    if cfg.WorkloadScript == "" {
+        fmt.Println("The script command needs workloadScript to be set.")
+        return 2
+    }
+    if cfg.StorageBackend != config.StorageBackendS3 {
+        fmt.Println("The script command is only supported with the s3 storage backend.")
+        return 1
+    }
+
+    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error initializing S3 clients: %v\n", err)
+        return 1
+    }
+
+    result, err := script.Run(cfg, s3Clients)
+    if len(result.Operations) > 0 {
+        script.PrintReport(result)
+        writeCommandReport(cfg, result.RunID+"-script.csv", "Scripted workload report", func(reportPath string) error {
+            return script.WriteCSV(reportPath, result)
+        })
+    }
+    if err != nil {
+        fmt.Printf("Error running the workload script: %v\n", err)
+        return 1
+    }
+
+    if result.Failures() > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runReport handles the report subcommands: "report merge" and "report diff".
+func runReport(cfg *config.Config, args []string) int {
+    switch {
+    case len(args) >= 3 && args[0] == "merge":
+        return runReportMerge(cfg, args[1:])
+    case len(args) == 3 && args[0] == "diff":
+        return runReportDiff(cfg, args[1], args[2])
+    case (len(args) == 2 || len(args) == 3) && args[0] == "junit":
+        return runReportJUnit(cfg, args[1:])
+    default:
+        fmt.Println("Usage: report merge <report.json> <report.json> [<report.json>...]")
+        fmt.Println("       report diff <a.json> <b.json>")
+        fmt.Println("       report junit <report.json> [<junit.xml>]")
+        return 2
+    }
+}
+
+// runReportMerge combines the JSON reports of workers that ran side by side into one report,
+// stored in resultsDir like a run of its own.
+func runReportMerge(cfg *config.Config, paths []string) int {
+    var records []results.Record
+    for _, path := range paths {
+        rec, err := results.ReadFile(path)
+        if err != nil {
+            fmt.Println(err)
+            return 1
+        }
+        records = append(records, rec)
+    }
+
+    merged, warnings, err := results.Merge("merged-"+time.Now().Format("20060102-150405"), records)
+    if err != nil {
+        fmt.Printf("Error merging reports: %v\n", err)
+        return 1
+    }
+    results.PrintMerged(merged, records, warnings)
+
+    if err := results.Save(cfg.ResultsDir, merged); err != nil {
+        fmt.Println(err)
+        return 1
+    }
+    fmt.Printf("Merged report written to %s\n", filepath.Join(cfg.ResultsDir, merged.ID+".json"))
+    return 0
+}
+
+// runReportDiff compares two JSON reports, e.g. of two clusters or two software versions, and
+// exits with a non-zero status if b regressed from a by more than reportDiffThreshold.
+func runReportDiff(cfg *config.Config, pathA, pathB string) int {
+    a, err := results.ReadFile(pathA)
+    if err != nil {
+        fmt.Println(err)
+        return 1
+    }
+    b, err := results.ReadFile(pathB)
+    if err != nil {
+        fmt.Println(err)
+        return 1
+    }
+
+    rows := results.Diff(a, b, cfg.ReportDiffThreshold)
+    if results.PrintDiff(a, b, rows, cfg.ReportDiffThreshold) > 0 {
+        return 1
+    }
+    return 0
+}
+
+// runReportJUnit checks a JSON report against slaMaxErrorPercent and slaAssertions and writes
+// the checks as JUnit XML to the given file, or to standard output. It exits with a non-zero
+// status if any check failed.
+func runReportJUnit(cfg *config.Config, args []string) int {
+    rec, err := results.ReadFile(args[0])
+    if err != nil {
+        fmt.Println(err)
+        return 1
+    }
+
+    checks := results.CheckSLA(cfg, rec)
+    if len(args) == 1 {
+        if err := results.WriteJUnit(os.Stdout, rec, checks); err != nil {
+            fmt.Println(err)
+            return 1
+        }
+    } else {
+        results.PrintSLA(checks)
+        if err := results.SaveJUnit(args[1], rec, checks); err != nil {
+            fmt.Println(err)
+            return 1
+        }
+        fmt.Printf("JUnit report written to %s\n", args[1])
+    }
+    for _, c := range checks {
+        if !c.Passed() {
+            return 1
+        }
+    }
+    return 0
+}
+
+// writeCommandReport writes a command's CSV report to fileName in resultsDir with write.
+// Errors are printed, since the report on screen is already complete.
+func writeCommandReport(cfg *config.Config, fileName, description string, write func(reportPath string) error) {
+    if err := os.MkdirAll(cfg.ResultsDir, 0755); err != nil {
+        fmt.Printf("Error creating results directory %s: %v\n", cfg.ResultsDir, err)
+        return
+    }
+    reportPath := filepath.Join(cfg.ResultsDir, fileName)
+    if err := write(reportPath); err != nil {
+        fmt.Println(err)
+        return
+    }
+    fmt.Printf("%s written to %s\n", description, reportPath)
+}
@@ -0,0 +1,44 @@
+// config/config_test.go
+package config
+
+import "testing"
+
+func TestMultipartPartSizeFor(t *testing.T) {
+    const mib = 1 << 20
+    tests := []struct {
+        name     string
+        partSize int64
+        fileSize int64
+        want     int64
+        wantErr  bool
+    }{
+        {name: "configured size fits", partSize: 8 * mib, fileSize: 1 << 30, want: 8 * mib},
+        {name: "below the minimum", partSize: 1 * mib, fileSize: 100 * mib, want: 5 * mib},
+        {name: "exactly 10000 minimum parts", partSize: 5 * mib, fileSize: 10000 * 5 * mib, want: 5 * mib},
+        {name: "one byte over 10000 parts", partSize: 5 * mib, fileSize: 10000*5*mib + 1, want: 6 * mib},
+        {name: "100 GiB", partSize: 5 * mib, fileSize: 100 << 30, want: 11 * mib},
+        {name: "largest object", partSize: 5 * mib, fileSize: 10000 * maxMultipartPartSize, want: maxMultipartPartSize},
+        {name: "too large", partSize: 5 * mib, fileSize: 10000*maxMultipartPartSize + 1, wantErr: true},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            cfg := &Config{MultipartPartSize: tt.partSize}
+            got, err := cfg.MultipartPartSizeFor(tt.fileSize)
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatalf("MultipartPartSizeFor(%d) = %d, want an error", tt.fileSize, got)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("MultipartPartSizeFor(%d): %v", tt.fileSize, err)
+            }
+            if got != tt.want {
+                t.Errorf("MultipartPartSizeFor(%d) = %d, want %d", tt.fileSize, got, tt.want)
+            }
+            if parts := (tt.fileSize + got - 1) / got; parts > maxMultipartPartCount {
+                t.Errorf("MultipartPartSizeFor(%d) = %d needs %d parts", tt.fileSize, got, parts)
+            }
+        })
+    }
+}
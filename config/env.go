@@ -0,0 +1,158 @@
+// config/env.go
+package config
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "reflect"
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// EnvPrefix starts the name of every environment variable that sets a configuration setting,
+// e.g. S3BENCH_BUCKET_NAME for bucketName.
+const EnvPrefix = "S3BENCH_"
+
+// EnvName returns the environment variable that sets the setting with the given JSON name:
+// EnvPrefix followed by the name in upper case, with an underscore at each word boundary. A word
+// starts at an upper-case letter after a lower-case letter or digit, and at the last letter of
+// an acronym of two or more letters followed by a word, e.g. S3BENCH_WEB_TLS_CERT_FILE for
+// webTLSCertFile but S3BENCH_VERIFY_ETAG for verifyETag.
+func EnvName(setting string) string {
+    var b strings.Builder
+    b.WriteString(EnvPrefix)
+    runes := []rune(setting)
+    for i, r := range runes {
+        if i > 0 && unicode.IsUpper(r) {
+            prev := runes[i-1]
+            if unicode.IsLower(prev) || unicode.IsDigit(prev) || (i > 1 && unicode.IsUpper(prev) && unicode.IsUpper(runes[i-2]) && startsWord(runes[i+1:])) {
+                b.WriteByte('_')
+            }
+        }
+        b.WriteRune(unicode.ToUpper(r))
+    }
+    return b.String()
+}
+
+// startsWord reports whether the letters after an upper-case letter that follows another make
+// it the start of a word, as the C of TLSCert, rather than the end of an acronym with a plural
+// or per-second suffix, as the L of URLs or the B of KBps.
+func startsWord(rest []rune) bool {
+    n := 0
+    for n < len(rest) && unicode.IsLower(rest[n]) {
+        n++
+    }
+    suffix := string(rest[:n])
+    return n > 0 && suffix != "s" && suffix != "ps"
+}
+
+// settingTypes returns the type of every setting by its JSON name.
+func settingTypes() map[string]reflect.Type {
+    types := make(map[string]reflect.Type)
+    t := reflect.TypeOf(Config{})
+    for i := 0; i < t.NumField(); i++ {
+        name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+        if name != "" && name != "-" {
+            types[name] = t.Field(i).Type
+        }
+    }
+    return types
+}
+
+// SettingsFromEnv returns the settings set by environment variables, by JSON name.
+func SettingsFromEnv() map[string]string {
+    settings := make(map[string]string)
+    for name := range settingTypes() {
+        if value, ok := os.LookupEnv(EnvName(name)); ok {
+            settings[name] = value
+        }
+    }
+    return settings
+}
+
+// LoadConfigWithSettings loads configuration like LoadConfig, with settings, by JSON name,
+// overriding those of the file. An empty configPath starts from no file at all, so every
+// setting comes from settings.
+func LoadConfigWithSettings(configPath string, settings map[string]string) (*Config, error) {
+    data := []byte("{}")
+    if configPath != "" {
+        var err error
+        data, err = os.ReadFile(configPath)
+        if err != nil {
+            return nil, fmt.Errorf("error reading config file: %w", err)
+        }
+    }
+
+    data, err := applySettings(data, settings)
+    if err != nil {
+        return nil, err
+    }
+    return ParseConfig(data)
+}
+
+// applySettings returns the JSON configuration data with settings replacing its values.
+func applySettings(data []byte, settings map[string]string) ([]byte, error) {
+    if len(settings) == 0 {
+        return data, nil
+    }
+
+    var fields map[string]json.RawMessage
+    if err := json.Unmarshal(data, &fields); err != nil {
+        return nil, fmt.Errorf("error decoding config file: %w", err)
+    }
+    if fields == nil {
+        fields = make(map[string]json.RawMessage)
+    }
+
+    types := settingTypes()
+    names := make([]string, 0, len(settings))
+    for name := range settings {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        typ, ok := types[name]
+        if !ok {
+            return nil, fmt.Errorf("unknown setting %q", name)
+        }
+        value, err := settingJSON(typ, settings[name])
+        if err != nil {
+            return nil, fmt.Errorf("invalid value for setting %s: %w", name, err)
+        }
+        fields[name] = value
+    }
+    return json.Marshal(fields)
+}
+
+// settingJSON converts the text value of a setting of type typ to JSON. Strings are taken as
+// they are, lists of strings and numbers may be given comma-separated, and everything else is
+// given in JSON, as in the config file.
+func settingJSON(typ reflect.Type, value string) (json.RawMessage, error) {
+    var raw json.RawMessage
+    switch {
+    case typ.Kind() == reflect.String:
+        raw, _ = json.Marshal(value)
+    case typ.Kind() == reflect.Slice && !strings.HasPrefix(strings.TrimSpace(value), "["):
+        elems := []json.RawMessage{}
+        if strings.TrimSpace(value) != "" {
+            for _, elem := range strings.Split(value, ",") {
+                elemJSON, err := settingJSON(typ.Elem(), strings.TrimSpace(elem))
+                if err != nil {
+                    return nil, err
+                }
+                elems = append(elems, elemJSON)
+            }
+        }
+        raw, _ = json.Marshal(elems)
+    default:
+        raw = json.RawMessage(strings.TrimSpace(value))
+    }
+
+    // Decode the value once, so a bad value is reported with the setting it was given for.
+    if err := json.Unmarshal(raw, reflect.New(typ).Interface()); err != nil {
+        return nil, err
+    }
+    return raw, nil
+}
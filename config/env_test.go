@@ -0,0 +1,32 @@
+// config/env_test.go
+package config
+
+import "testing"
+
+func TestEnvName(t *testing.T) {
+    tests := []struct {
+        name string
+        want string
+    }{
+        {"bucketName", "S3BENCH_BUCKET_NAME"},
+        {"s3Folder", "S3BENCH_S3_FOLDER"},
+        {"workDir", "S3BENCH_WORK_DIR"},
+        {"runID", "S3BENCH_RUN_ID"},
+        {"verifyETag", "S3BENCH_VERIFY_ETAG"},
+        {"endpointURLs", "S3BENCH_ENDPOINT_URLS"},
+        {"enableHTTP2", "S3BENCH_ENABLE_HTTP2"},
+        {"throttleBandwidthKBps", "S3BENCH_THROTTLE_BANDWIDTH_KBPS"},
+        {"logRotateMB", "S3BENCH_LOG_ROTATE_MB"},
+        {"webTLSCertFile", "S3BENCH_WEB_TLS_CERT_FILE"},
+        {"webTLSKeyFile", "S3BENCH_WEB_TLS_KEY_FILE"},
+        {"notificationAMQPQueue", "S3BENCH_NOTIFICATION_AMQP_QUEUE"},
+        {"notificationQueueURL", "S3BENCH_NOTIFICATION_QUEUE_URL"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := EnvName(tt.name); got != tt.want {
+                t.Errorf("EnvName(%q) = %q, want %q", tt.name, got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,92 @@
+// config/mc.go
+package config
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// mcAlias is one alias entry of a MinIO client (mc) configuration file.
+type mcAlias struct {
+    URL       string `json:"url"`
+    AccessKey string `json:"accessKey"`
+    SecretKey string `json:"secretKey"`
+}
+
+// mcConfig is the MinIO client configuration file. Releases before version 10 named
+// the alias map "hosts".
+type mcConfig struct {
+    Aliases map[string]mcAlias `json:"aliases"`
+    Hosts   map[string]mcAlias `json:"hosts"`
+}
+
+// defaultMCConfigPath returns ~/.mc/config.json.
+func defaultMCConfigPath() (string, error) {
+    home, err := os.UserHomeDir()
+    if err != nil {
+        return "", fmt.Errorf("error locating home directory: %w", err)
+    }
+    return filepath.Join(home, ".mc", "config.json"), nil
+}
+
+// loadMCAlias reads an alias from a MinIO client configuration file.
+func loadMCAlias(path, name string) (mcAlias, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return mcAlias{}, fmt.Errorf("error reading mc config %s: %w", path, err)
+    }
+
+    var mc mcConfig
+    if err := json.Unmarshal(data, &mc); err != nil {
+        return mcAlias{}, fmt.Errorf("error decoding mc config %s: %w", path, err)
+    }
+
+    alias, ok := mc.Aliases[name]
+    if !ok {
+        alias, ok = mc.Hosts[name]
+    }
+    if !ok {
+        return mcAlias{}, fmt.Errorf("alias %q not found in mc config %s", name, path)
+    }
+    if alias.URL == "" {
+        return mcAlias{}, fmt.Errorf("alias %q in mc config %s has no url", name, path)
+    }
+    return alias, nil
+}
+
+// applyMCAlias fills the endpoint and credentials from the configured mc alias.
+// Values set explicitly in the configuration take precedence over the alias.
+func applyMCAlias(cfg *Config) error {
+    path := cfg.MCConfigPath
+    if path == "" {
+        var err error
+        if path, err = defaultMCConfigPath(); err != nil {
+            return err
+        }
+    } else if strings.HasPrefix(path, "~/") {
+        home, err := os.UserHomeDir()
+        if err != nil {
+            return fmt.Errorf("error locating home directory: %w", err)
+        }
+        path = filepath.Join(home, path[2:])
+    }
+
+    alias, err := loadMCAlias(path, cfg.MCAlias)
+    if err != nil {
+        return err
+    }
+
+    if len(cfg.EndpointURLs) == 0 {
+        cfg.EndpointURLs = []string{alias.URL}
+    }
+    if cfg.AccessKey == "" {
+        cfg.AccessKey = alias.AccessKey
+    }
+    if cfg.SecretKey == "" {
+        cfg.SecretKey = alias.SecretKey
+    }
+    return nil
+}
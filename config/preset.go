@@ -0,0 +1,64 @@
+// config/preset.go
+package config
+
+import "fmt"
+
+// Workload presets selectable with WorkloadPreset.
+const (
+    // WorkloadMetadata targets the metadata service: HEADs and LISTs across deep prefixes with
+    // a few GETs, and latency percentiles per prefix depth.
+    WorkloadMetadata = "metadata"
+)
+
+// Defaults of the metadata workload preset.
+var (
+    metadataFanOut     = []int{16, 16, 16} // 4096 leaf prefixes, three levels below each subfolder.
+    metadataStatFactor = 4                 // STAT threads per maxBenchmarkThreads.
+    metadataGetThreads = 1                 // GET threads.
+    metadataGetRate    = 10.0              // GETs per second.
+)
+
+// applyWorkloadPreset fills in the settings of WorkloadPreset that the configuration leaves
+// unset. Settings given explicitly always win over the preset.
+func applyWorkloadPreset(cfg *Config) error {
+    switch cfg.WorkloadPreset {
+    case "":
+        return nil
+    case WorkloadMetadata:
+    default:
+        return fmt.Errorf("workloadPreset must be %q or empty, current: %q", WorkloadMetadata, cfg.WorkloadPreset)
+    }
+
+    if len(cfg.HierarchyFanOut) == 0 {
+        cfg.HierarchyFanOut = metadataFanOut
+    }
+
+    if len(cfg.BenchmarkPhases) == 0 {
+        // LIST needs the S3 API; the other backends get HEADs and GETs only.
+        operations := []string{"STAT", "GET"}
+        if cfg.StorageBackend == "" || cfg.StorageBackend == StorageBackendS3 {
+            operations = []string{"STAT", "LIST", "GET"}
+        }
+        cfg.BenchmarkPhases = []BenchmarkPhase{{Operations: operations}}
+    }
+
+    if cfg.BenchmarkThreads == nil {
+        cfg.BenchmarkThreads = make(map[string]int)
+    }
+    if _, ok := cfg.BenchmarkThreads["STAT"]; !ok && cfg.MaxBenchmarkThreads > 0 {
+        cfg.BenchmarkThreads["STAT"] = cfg.MaxBenchmarkThreads * metadataStatFactor
+    }
+    if _, ok := cfg.BenchmarkThreads["GET"]; !ok {
+        cfg.BenchmarkThreads["GET"] = metadataGetThreads
+    }
+
+    if cfg.BenchmarkRates == nil {
+        cfg.BenchmarkRates = make(map[string]float64)
+    }
+    if _, ok := cfg.BenchmarkRates["GET"]; !ok {
+        cfg.BenchmarkRates["GET"] = metadataGetRate
+    }
+
+    cfg.LatencyByDepth = true
+    return nil
+}
@@ -0,0 +1,33 @@
+// config/runid.go
+package config
+
+import (
+    "fmt"
+    "math/rand"
+    "path"
+    "regexp"
+    "time"
+)
+
+// runIDPattern restricts run IDs to characters that are safe in S3 keys and file names.
+var runIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// ValidRunID reports whether id is a usable run ID: not empty and made only of letters,
+// digits, '.', '_' and '-'.
+func ValidRunID(id string) bool {
+    return runIDPattern.MatchString(id)
+}
+
+// NewRunID returns the configured RunID, or a new one made of the current time and a random
+// suffix, such as 20240131-154502-3f9c, so runs started in the same second still differ.
+func (c *Config) NewRunID() string {
+    if c.RunID != "" {
+        return c.RunID
+    }
+    return fmt.Sprintf("%s-%04x", time.Now().Format("20060102-150405"), rand.Intn(1<<16))
+}
+
+// RunPrefix returns the prefix below s3Folder that holds the objects of a run.
+func (c *Config) RunPrefix(runID string) string {
+    return path.Join(c.S3Folder, runID)
+}
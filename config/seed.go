@@ -0,0 +1,34 @@
+// config/seed.go
+package config
+
+import (
+    "hash/fnv"
+    "math/rand"
+    "time"
+)
+
+// SeedFor returns the seed of the random stream named stream. With a Seed the stream is
+// derived from it and the name only, so every part of the workload draws the same numbers on
+// each run, whatever order the parts run in. Without a Seed it changes from run to run.
+func (c *Config) SeedFor(stream string) int64 {
+    h := fnv.New64a()
+    h.Write([]byte(stream))
+    if c.Seed == 0 {
+        return time.Now().UnixNano() ^ int64(h.Sum64())
+    }
+    return int64(mixSeed(uint64(c.Seed) ^ h.Sum64()))
+}
+
+// Rand returns a random number generator for the stream named stream, see SeedFor. It is not
+// safe for concurrent use.
+func (c *Config) Rand(stream string) *rand.Rand {
+    return rand.New(rand.NewSource(c.SeedFor(stream)))
+}
+
+// mixSeed is the splitmix64 finalizer, so related seeds and names give unrelated streams.
+func mixSeed(x uint64) uint64 {
+    x += 0x9e3779b97f4a7c15
+    x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+    x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+    return x ^ (x >> 31)
+}
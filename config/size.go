@@ -0,0 +1,62 @@
+// config/size.go
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// sizeUnits maps the accepted size suffixes to their multipliers. Decimal (KB, MB, ...) and
+// binary (KiB, MiB, ...) units are both accepted.
+var sizeUnits = map[string]int64{
+    "":    1,
+    "B":   1,
+    "KB":  1000,
+    "MB":  1000 * 1000,
+    "GB":  1000 * 1000 * 1000,
+    "TB":  1000 * 1000 * 1000 * 1000,
+    "PB":  1000 * 1000 * 1000 * 1000 * 1000,
+    "KIB": 1 << 10,
+    "MIB": 1 << 20,
+    "GIB": 1 << 30,
+    "TIB": 1 << 40,
+    "PIB": 1 << 50,
+}
+
+// ParseSize parses a data size such as "50TiB", "1.5 TB" or "1048576" into bytes.
+func ParseSize(s string) (int64, error) {
+    s = strings.TrimSpace(s)
+    i := strings.IndexFunc(s, func(r rune) bool {
+        return (r < '0' || r > '9') && r != '.'
+    })
+    if i < 0 {
+        i = len(s)
+    }
+
+    number, unit := s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+    multiplier, ok := sizeUnits[unit]
+    if !ok {
+        return 0, fmt.Errorf("invalid size %q: unknown unit %q", s, s[i:])
+    }
+    value, err := strconv.ParseFloat(number, 64)
+    if err != nil {
+        return 0, fmt.Errorf("invalid size %q: %w", s, err)
+    }
+    return int64(value * float64(multiplier)), nil
+}
+
+// FormatSize formats a byte count with the largest binary unit that keeps it at or above 1.
+func FormatSize(n int64) string {
+    units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+    value := float64(n)
+    u := 0
+    for value >= 1024 && u < len(units)-1 {
+        value /= 1024
+        u++
+    }
+    if u == 0 {
+        return fmt.Sprintf("%d B", n)
+    }
+    return fmt.Sprintf("%.2f %s", value, units[u])
+}
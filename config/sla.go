@@ -0,0 +1,87 @@
+// config/sla.go
+package config
+
+import "fmt"
+
+// SLAUploads is the operation of SLA assertions on the uploads rather than a benchmark operation.
+const SLAUploads = "UPLOAD"
+
+// Metrics an SLA assertion can limit. Latencies are in milliseconds.
+const (
+    SLAMetricAvg          = "avg"
+    SLAMetricP50          = "p50"
+    SLAMetricP90          = "p90"
+    SLAMetricP99          = "p99"
+    SLAMetricMax          = "max"
+    SLAMetricOpsPerSec    = "opsPerSec"
+    SLAMetricErrorPercent = "errorPercent"
+)
+
+// SLAAssertion is a limit on one metric of the uploads or of a benchmark operation, checked
+// when the run ends.
+type SLAAssertion struct {
+    Operation string  `json:"operation"` // Benchmark operation, e.g. "GET", or "UPLOAD" for the uploads.
+    Metric    string  `json:"metric"`    // "avg", "p50", "p90", "p99" or "max" latency in milliseconds, "opsPerSec" or "errorPercent".
+    Max       float64 `json:"max"`       // Highest passing value (0 = no upper limit).
+    Min       float64 `json:"min"`       // Lowest passing value (0 = no lower limit).
+}
+
+// IsLatency reports whether the assertion limits a latency.
+func (a SLAAssertion) IsLatency() bool {
+    switch a.Metric {
+    case SLAMetricAvg, SLAMetricP50, SLAMetricP90, SLAMetricP99, SLAMetricMax:
+        return true
+    }
+    return false
+}
+
+// Name describes the assertion, e.g. "p99 <= 200ms" or "opsPerSec >= 1000".
+func (a SLAAssertion) Name() string {
+    unit := ""
+    if a.IsLatency() {
+        unit = "ms"
+    } else if a.Metric == SLAMetricErrorPercent {
+        unit = "%"
+    }
+    switch {
+    case a.Min > 0 && a.Max > 0:
+        return fmt.Sprintf("%g%s <= %s <= %g%s", a.Min, unit, a.Metric, a.Max, unit)
+    case a.Min > 0:
+        return fmt.Sprintf("%s >= %g%s", a.Metric, a.Min, unit)
+    default:
+        return fmt.Sprintf("%s <= %g%s", a.Metric, a.Max, unit)
+    }
+}
+
+// validateSLA checks the SLA assertions and the error-rate limit.
+func validateSLA(cfg *Config) error {
+    if cfg.SLAMaxErrorPercent < 0 || cfg.SLAMaxErrorPercent > 100 {
+        return fmt.Errorf("slaMaxErrorPercent must be between 0 and 100, current: %g", cfg.SLAMaxErrorPercent)
+    }
+
+    for i, a := range cfg.SLAAssertions {
+        if a.Operation == "" {
+            return fmt.Errorf("slaAssertions[%d]: operation is required", i)
+        }
+        switch {
+        case a.IsLatency():
+            if a.Operation == SLAUploads {
+                return fmt.Errorf("slaAssertions[%d]: metric %q is not measured for %s, only %q and %q", i, a.Metric, SLAUploads, SLAMetricOpsPerSec, SLAMetricErrorPercent)
+            }
+        case a.Metric == SLAMetricOpsPerSec, a.Metric == SLAMetricErrorPercent:
+        default:
+            return fmt.Errorf("slaAssertions[%d]: metric must be %q, %q, %q, %q, %q, %q or %q, current: %q", i,
+                SLAMetricAvg, SLAMetricP50, SLAMetricP90, SLAMetricP99, SLAMetricMax, SLAMetricOpsPerSec, SLAMetricErrorPercent, a.Metric)
+        }
+        if a.Min < 0 || a.Max < 0 {
+            return fmt.Errorf("slaAssertions[%d]: min and max must not be negative, current: %g and %g", i, a.Min, a.Max)
+        }
+        if a.Min == 0 && a.Max == 0 {
+            return fmt.Errorf("slaAssertions[%d]: min or max is required", i)
+        }
+        if a.Max > 0 && a.Min > a.Max {
+            return fmt.Errorf("slaAssertions[%d]: min must not be above max, current: %g and %g", i, a.Min, a.Max)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,78 @@
+// config/timestamp.go
+package config
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Named subfolder timestamp formats selectable with FolderTimeFormat. Any other value is
+// used as a Go time layout.
+const (
+    FolderTimeLegacy      = "legacy"       // 31012024154502 (DDMMYYYYHHMMSS), the default.
+    FolderTimeSortable    = "sortable"     // 20240131154502, sorts in time order.
+    FolderTimeISOBasic    = "iso-basic"    // 20240131T154502Z, ISO 8601 basic format with the offset.
+    FolderTimeRFC3339Safe = "rfc3339-safe" // 2024-01-31T15-45-02Z, RFC 3339 with the colons replaced.
+    FolderTimeUnix        = "unix"         // 1706715902, seconds since the epoch.
+)
+
+// folderTimeLayouts maps the named formats to Go time layouts.
+var folderTimeLayouts = map[string]string{
+    FolderTimeLegacy:      "02012006150405",
+    FolderTimeSortable:    "20060102150405",
+    FolderTimeISOBasic:    "20060102T150405Z0700",
+    FolderTimeRFC3339Safe: "2006-01-02T15-04-05Z0700",
+}
+
+// parseFolderTime validates FolderTimeFormat and FolderTimeZone and resolves them into
+// FolderTimeLayout and FolderLocation.
+func parseFolderTime(cfg *Config) error {
+    if cfg.FolderTimeFormat == "" {
+        cfg.FolderTimeFormat = FolderTimeLegacy
+    }
+    cfg.FolderTimeLayout = cfg.FolderTimeFormat
+    if layout, ok := folderTimeLayouts[cfg.FolderTimeFormat]; ok {
+        cfg.FolderTimeLayout = layout
+    }
+
+    if cfg.FolderTimeFormat != FolderTimeUnix {
+        // A layout without time fields formats to itself, whatever the time.
+        a := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC).Format(cfg.FolderTimeLayout)
+        b := time.Date(2012, 11, 10, 9, 8, 7, 0, time.UTC).Format(cfg.FolderTimeLayout)
+        if a == b {
+            return fmt.Errorf("folderTimeFormat must be a named format or a Go time layout, current: %q", cfg.FolderTimeFormat)
+        }
+        if strings.Contains(a, "/") {
+            return fmt.Errorf("folderTimeFormat must not produce '/', which would add a key level, current: %q", cfg.FolderTimeFormat)
+        }
+    }
+
+    switch cfg.FolderTimeZone {
+    case "", "Local":
+        cfg.FolderLocation = time.Local
+    default:
+        location, err := time.LoadLocation(cfg.FolderTimeZone)
+        if err != nil {
+            return fmt.Errorf("invalid folderTimeZone: %w", err)
+        }
+        cfg.FolderLocation = location
+    }
+    return nil
+}
+
+// FolderTimestamp formats t for a subfolder name with FolderTimeFormat in FolderTimeZone.
+func (c *Config) FolderTimestamp(t time.Time) string {
+    if c.FolderTimeFormat == FolderTimeUnix {
+        return strconv.FormatInt(t.Unix(), 10)
+    }
+    if c.FolderLocation != nil {
+        t = t.In(c.FolderLocation)
+    }
+    layout := c.FolderTimeLayout
+    if layout == "" {
+        layout = folderTimeLayouts[FolderTimeLegacy]
+    }
+    return t.Format(layout)
+}
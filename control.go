@@ -0,0 +1,168 @@
+// control.go
+package main
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "sync"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// activeRun tracks whether a run is in progress: one started through the control API, a job of
+// the daemon, or the run of the command line, which holds it until the process exits.
+var activeRun struct {
+    sync.Mutex
+    running bool
+}
+
+// runStatus is the JSON document returned by GET /api/run.
+type runStatus struct {
+    Running        bool          `json:"Running"`
+    RunID          string        `json:"RunID"`
+    Phase          string        `json:"Phase"`
+    Paused         bool          `json:"Paused"`
+    AbortReason    string        `json:"AbortReason,omitempty"`
+    TruncateReason string        `json:"TruncateReason,omitempty"`
+    Concurrency    int           `json:"Concurrency,omitempty"`
+    Stats          monitor.Stats `json:"Stats"`
+}
+
+// startRun launches runBenchmark in the background. It fails if a run is already in progress.
+func startRun(cfg *config.Config) error {
+    activeRun.Lock()
+    defer activeRun.Unlock()
+
+    if activeRun.running {
+        return fmt.Errorf("a run is already in progress")
+    }
+    activeRun.running = true
+
+    go func() {
+        defer func() {
+            activeRun.Lock()
+            activeRun.running = false
+            activeRun.Unlock()
+        }()
+        runBenchmark(cfg)
+    }()
+    return nil
+}
+
+// currentStatus collects the status of the current or last run.
+func currentStatus() runStatus {
+    activeRun.Lock()
+    running := activeRun.running
+    activeRun.Unlock()
+
+    status := runStatus{
+        Running:        running,
+        RunID:          monitor.RunID(),
+        Phase:          monitor.Phase(),
+        Paused:         monitor.Paused(),
+        AbortReason:    monitor.AbortReason(),
+        TruncateReason: monitor.TruncateReason(),
+        Stats:          monitor.GetStats(),
+    }
+
+    runArtifacts.Lock()
+    if runArtifacts.uploader != nil {
+        status.Concurrency = runArtifacts.uploader.Concurrency()
+    }
+    runArtifacts.Unlock()
+
+    return status
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(code)
+    json.NewEncoder(w).Encode(v)
+}
+
+// runHandler starts a run from a posted configuration (POST) or returns the run status (GET).
+func runHandler(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        writeJSON(w, http.StatusOK, currentStatus())
+    case http.MethodPost:
+        body, err := io.ReadAll(r.Body)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        cfg, err := config.ParseConfig(body)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if err := createOutputDirs(cfg); err != nil {
+            http.Error(w, fmt.Sprintf("error preparing output directories: %v", err), http.StatusInternalServerError)
+            return
+        }
+        if err := startRun(cfg); err != nil {
+            http.Error(w, err.Error(), http.StatusConflict)
+            return
+        }
+        writeJSON(w, http.StatusAccepted, currentStatus())
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// runActionHandler handles POST /api/run/{pause,resume,stop}.
+func runActionHandler(action func()) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodPost {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        action()
+        writeJSON(w, http.StatusOK, currentStatus())
+    }
+}
+
+// concurrencyHandler adjusts the number of concurrent uploads per subfolder of the active run.
+// The body is {"maxConcurrentUploads": n}.
+func concurrencyHandler(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodPost {
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+        return
+    }
+
+    var req struct {
+        MaxConcurrentUploads int `json:"maxConcurrentUploads"`
+    }
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if req.MaxConcurrentUploads <= 0 {
+        http.Error(w, "maxConcurrentUploads must be a positive number", http.StatusBadRequest)
+        return
+    }
+
+    runArtifacts.Lock()
+    uploader := runArtifacts.uploader
+    runArtifacts.Unlock()
+    if uploader == nil {
+        http.Error(w, "no upload phase in progress", http.StatusConflict)
+        return
+    }
+
+    uploader.SetConcurrency(req.MaxConcurrentUploads)
+    writeJSON(w, http.StatusOK, currentStatus())
+}
+
+// registerControlRoutes adds the run control API to the default mux.
+func registerControlRoutes() {
+    http.HandleFunc("/api/run", runHandler)
+    http.HandleFunc("/api/run/pause", runActionHandler(monitor.Pause))
+    http.HandleFunc("/api/run/resume", runActionHandler(monitor.Resume))
+    http.HandleFunc("/api/run/stop", runActionHandler(func() { monitor.Abort("stopped via control API") }))
+    http.HandleFunc("/api/run/concurrency", concurrencyHandler)
+}
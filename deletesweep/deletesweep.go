@@ -0,0 +1,257 @@
+// deletesweep/deletesweep.go
+package deletesweep
+
+import (
+    "bytes"
+    "fmt"
+    "math/rand"
+    "path"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// SizeResult is the outcome of deleting objects with one batch size.
+type SizeResult struct {
+    BatchSize     int
+    Created       int64           // Objects uploaded for the batch size.
+    Requests      int64           // DeleteObjects requests sent.
+    RequestErrors int64           // Requests that failed as a whole.
+    Deleted       int64           // Keys deleted.
+    Failed        int64           // Keys not deleted, by failed requests or per-key errors.
+    Latencies     stats.Latencies // Times of the successful requests.
+    Duration      time.Duration   // Wall-clock time of the deletes.
+}
+
+// Result is the outcome of a sweep over all batch sizes.
+type Result struct {
+    RunID  string
+    Prefix string
+    Sizes  []SizeResult
+}
+
+// Run creates DeleteSweepObjects objects for each batch size of DeleteSweepBatchSizes and deletes
+// them with DeleteObjects requests of that many keys, DeleteSweepConcurrency requests at a time.
+// Objects are written below <deleteSweepPrefix>/<runID>/DELETESWEEP/<batch size>/.
+func Run(cfg *config.Config, s3Clients []*s3.S3) Result {
+    runID := cfg.NewRunID()
+    result := Result{RunID: runID, Prefix: path.Join(cfg.DeleteSweepPrefix, runID, "DELETESWEEP")}
+
+    payload := make([]byte, cfg.DeleteSweepObjectSize)
+    rand.Read(payload)
+
+    for _, batchSize := range cfg.DeleteSweepBatchSizes {
+        keys := make([]string, cfg.DeleteSweepObjects)
+        for i := range keys {
+            keys[i] = fmt.Sprintf("%s/%d/%x", result.Prefix, batchSize, i)
+        }
+
+        fmt.Printf("\nBatch size %d: creating %d objects...\n", batchSize, len(keys))
+        created := createObjects(cfg, s3Clients, keys, payload)
+
+        fmt.Printf("Batch size %d: deleting %d objects...\n", batchSize, len(created))
+        sr := deleteObjects(cfg, s3Clients, batchSize, created)
+        sr.Created = int64(len(created))
+        result.Sizes = append(result.Sizes, sr)
+    }
+    return result
+}
+
+// createObjects uploads keys with maxConcurrentUploads workers and returns the keys that were
+// uploaded.
+func createObjects(cfg *config.Config, s3Clients []*s3.S3, keys []string, payload []byte) []string {
+    task := progress.Begin("Creating objects", "objects", int64(len(keys)))
+    defer task.Done()
+
+    uploaded := make([]bool, len(keys))
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    for w := 0; w < cfg.MaxConcurrentUploads; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := atomic.AddInt64(&next, 1) - 1
+                if i >= int64(len(keys)) {
+                    return
+                }
+
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                _, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+                    Bucket:        aws.String(cfg.BucketName),
+                    Key:           aws.String(keys[i]),
+                    Body:          bytes.NewReader(payload),
+                    ContentLength: aws.Int64(int64(len(payload))),
+                })
+                cancel()
+                if err != nil {
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error uploading %s: %v\n", keys[i], err)
+                    }
+                    continue
+                }
+                uploaded[i] = true
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    created := make([]string, 0, len(keys))
+    for i, ok := range uploaded {
+        if ok {
+            created = append(created, keys[i])
+        }
+    }
+    return created
+}
+
+// deleteObjects deletes keys with DeleteObjects requests of batchSize keys and times each request.
+func deleteObjects(cfg *config.Config, s3Clients []*s3.S3, batchSize int, keys []string) SizeResult {
+    result := SizeResult{BatchSize: batchSize}
+    task := progress.Begin(fmt.Sprintf("Deleting in batches of %d", batchSize), "objects", int64(len(keys)))
+    defer task.Done()
+
+    batches := make(chan []string)
+    go func() {
+        for start := 0; start < len(keys); start += batchSize {
+            end := start + batchSize
+            if end > len(keys) {
+                end = len(keys)
+            }
+            batches <- keys[start:end]
+        }
+        close(batches)
+    }()
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var printedErrors int64
+    start := time.Now()
+    for w := 0; w < cfg.DeleteSweepConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for batch := range batches {
+                objects := make([]*s3.ObjectIdentifier, len(batch))
+                for i, key := range batch {
+                    objects[i] = &s3.ObjectIdentifier{Key: aws.String(key)}
+                }
+
+                ctx, cancel := cfg.OperationContext(config.OperationDelete)
+                opStart := time.Now()
+                output, err := client.DeleteObjectsWithContext(ctx, &s3.DeleteObjectsInput{
+                    Bucket: aws.String(cfg.BucketName),
+                    Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+                })
+                duration := time.Since(opStart)
+                cancel()
+
+                mu.Lock()
+                result.Requests++
+                if err != nil {
+                    result.RequestErrors++
+                    result.Failed += int64(len(batch))
+                    mu.Unlock()
+                    task.Fail(int64(len(batch)))
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error deleting a batch of %d keys: %v\n", len(batch), err)
+                    }
+                    continue
+                }
+                // In quiet mode only the keys that failed are returned.
+                failed := int64(len(output.Errors))
+                result.Failed += failed
+                result.Deleted += int64(len(batch)) - failed
+                result.Latencies.Add(duration)
+                mu.Unlock()
+
+                task.Add(int64(len(batch)) - failed)
+                task.Fail(failed)
+                for _, e := range output.Errors {
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error deleting %s: %s\n", aws.StringValue(e.Key), aws.StringValue(e.Message))
+                    }
+                }
+            }
+        }(w)
+    }
+    wg.Wait()
+    result.Duration = time.Since(start)
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    return result
+}
+
+// AvgLatency returns the average time of a successful request.
+func (r *SizeResult) AvgLatency() time.Duration {
+    return r.Latencies.Average()
+}
+
+// Percentile returns the p-th fraction (0-1) of the request times.
+func (r *SizeResult) Percentile(p float64) time.Duration {
+    return r.Latencies.Percentile(p)
+}
+
+// PerKeyLatency returns the average request time divided by the average number of keys per
+// request: the time each key effectively costs. The last batch may hold fewer keys.
+func (r SizeResult) PerKeyLatency() time.Duration {
+    if r.Requests == 0 {
+        return 0
+    }
+    keysPerRequest := float64(r.Deleted+r.Failed) / float64(r.Requests)
+    return time.Duration(float64(r.AvgLatency()) / keysPerRequest)
+}
+
+// KeysPerSecond returns the keys deleted per second of wall-clock time.
+func (r SizeResult) KeysPerSecond() float64 {
+    if r.Duration <= 0 {
+        return 0
+    }
+    return float64(r.Deleted) / r.Duration.Seconds()
+}
+
+// Failures returns the number of keys that could not be deleted over all batch sizes.
+func (r Result) Failures() int64 {
+    var failures int64
+    for _, sr := range r.Sizes {
+        failures += sr.Failed
+    }
+    return failures
+}
+
+// PrintReport prints the request and per-key latencies of every batch size and the batch size
+// with the highest delete rate.
+func PrintReport(r Result) {
+    fmt.Println("\nMulti-Object Delete Report:")
+    fmt.Println("===========================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Prefix: %s\n\n", r.Prefix)
+
+    fmt.Printf("%10s %10s %10s %8s %12s %12s %12s %12s %12s\n", "Batch Size", "Requests", "Deleted", "Failed", "Req Avg", "Req P50", "Req P99", "Per Key", "Keys/sec")
+    best := -1
+    for i, sr := range r.Sizes {
+        fmt.Printf("%10d %10d %10d %8d %12v %12v %12v %12v %12.2f\n", sr.BatchSize, sr.Requests, sr.Deleted, sr.Failed,
+            sr.AvgLatency().Round(time.Microsecond), sr.Percentile(0.50).Round(time.Microsecond), sr.Percentile(0.99).Round(time.Microsecond),
+            sr.PerKeyLatency().Round(time.Microsecond), sr.KeysPerSecond())
+        if sr.Deleted > 0 && (best < 0 || sr.KeysPerSecond() > r.Sizes[best].KeysPerSecond()) {
+            best = i
+        }
+    }
+    if best >= 0 {
+        fmt.Printf("\nHighest delete rate: batch size %d (%.2f keys/sec)\n", r.Sizes[best].BatchSize, r.Sizes[best].KeysPerSecond())
+    }
+    fmt.Println("===========================")
+}
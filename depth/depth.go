@@ -0,0 +1,294 @@
+// depth/depth.go
+package depth
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "math/rand"
+    "os"
+    "path"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/cleanup"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// branches is the number of directories at the first level of every namespace. Objects are
+// spread over them, so each namespace is a small tree rather than a single chain.
+const branches = 10
+
+// Operations measured at every depth, in the order they run.
+var Operations = []string{config.OperationPut, config.OperationHead, config.OperationList}
+
+// OperationResult holds the requests of one operation at one depth.
+type OperationResult struct {
+    Requests  int64
+    Errors    int64
+    Latencies stats.Latencies // Times of the successful requests.
+}
+
+// Average returns the average time of a successful request.
+func (o *OperationResult) Average() time.Duration {
+    return o.Latencies.Average()
+}
+
+// Percentile returns the p-th fraction (0-1) of the request times.
+func (o *OperationResult) Percentile(p float64) time.Duration {
+    return o.Latencies.Percentile(p)
+}
+
+// LevelResult is the outcome of the namespace with one number of directory levels.
+type LevelResult struct {
+    Levels     int
+    KeyLength  int // Length of the longest key of the namespace.
+    Operations map[string]*OperationResult
+}
+
+// Result is the outcome of a depth study.
+type Result struct {
+    RunID        string
+    Prefix       string
+    Levels       []LevelResult
+    Deleted      int64
+    DeleteFailed int64
+    DeleteError  error
+}
+
+// Failures returns the failed requests and deletes over all depths.
+func (r Result) Failures() int64 {
+    failures := r.DeleteFailed
+    for _, lr := range r.Levels {
+        for _, op := range lr.Operations {
+            failures += op.Errors
+        }
+    }
+    if r.DeleteError != nil {
+        failures++
+    }
+    return failures
+}
+
+// namespace builds the keys of the namespace with a number of directory levels.
+type namespace struct {
+    root   string
+    levels int
+}
+
+// dir returns the deepest directory of a branch, without a trailing slash. The first level
+// holds the branch and the further levels are numbered.
+func (n namespace) dir(branch int) string {
+    dirs := []string{n.root, fmt.Sprintf("b%02d", branch)}
+    for level := 2; level <= n.levels; level++ {
+        dirs = append(dirs, fmt.Sprintf("l%02d", level))
+    }
+    return path.Join(dirs...)
+}
+
+// key returns the key of the i-th object.
+func (n namespace) key(i int) string {
+    return path.Join(n.dir(i%branches), fmt.Sprintf("%08d", i))
+}
+
+// Run writes DepthObjects objects into a namespace for each number of depthLevels, reads them
+// back with HEAD and lists the deepest directories, timing every request. The namespace with
+// n levels is written below <depthPrefix>/<runID>/DEPTH/<n>/.
+func Run(cfg *config.Config, s3Clients []*s3.S3) Result {
+    runID := cfg.NewRunID()
+    result := Result{RunID: runID, Prefix: path.Join(cfg.DepthPrefix, runID, "DEPTH")}
+
+    payload := make([]byte, cfg.DepthObjectSize)
+    rand.Read(payload)
+
+    for _, levels := range cfg.DepthLevels {
+        ns := namespace{root: path.Join(result.Prefix, strconv.Itoa(levels)), levels: levels}
+        lr := LevelResult{Levels: levels, KeyLength: len(ns.key(cfg.DepthObjects - 1)), Operations: make(map[string]*OperationResult)}
+        fmt.Printf("\n%d levels: %d objects, keys of up to %d bytes\n", levels, cfg.DepthObjects, lr.KeyLength)
+
+        lr.Operations[config.OperationPut] = measure(cfg, s3Clients, fmt.Sprintf("PUT at %d levels", levels), cfg.DepthObjects,
+            func(client *s3.S3, i int) error {
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                defer cancel()
+                _, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+                    Bucket:        aws.String(cfg.BucketName),
+                    Key:           aws.String(ns.key(i)),
+                    Body:          bytes.NewReader(payload),
+                    ContentLength: aws.Int64(int64(len(payload))),
+                })
+                return err
+            })
+
+        lr.Operations[config.OperationHead] = measure(cfg, s3Clients, fmt.Sprintf("HEAD at %d levels", levels), cfg.DepthObjects,
+            func(client *s3.S3, i int) error {
+                ctx, cancel := cfg.OperationContext(config.OperationHead)
+                defer cancel()
+                _, err := client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+                    Bucket: aws.String(cfg.BucketName),
+                    Key:    aws.String(ns.key(i)),
+                })
+                return err
+            })
+
+        lr.Operations[config.OperationList] = measure(cfg, s3Clients, fmt.Sprintf("LIST at %d levels", levels), cfg.DepthListings,
+            func(client *s3.S3, i int) error {
+                ctx, cancel := cfg.OperationContext(config.OperationList)
+                defer cancel()
+                _, err := client.ListObjectsV2WithContext(ctx, &s3.ListObjectsV2Input{
+                    Bucket:    aws.String(cfg.BucketName),
+                    Prefix:    aws.String(ns.dir(i%branches) + "/"),
+                    Delimiter: aws.String("/"),
+                })
+                return err
+            })
+
+        result.Levels = append(result.Levels, lr)
+    }
+
+    if cfg.DepthDeleteObjects {
+        fmt.Println("\nDeleting the objects of the run...")
+        depthCfg := *cfg
+        depthCfg.S3Folder = cfg.DepthPrefix
+        deleted, err := cleanup.DeleteRun(&depthCfg, s3Clients[0], runID)
+        result.Deleted, result.DeleteFailed, result.DeleteError = deleted.Deleted, deleted.Failed, err
+    }
+    return result
+}
+
+// measure sends count requests with request, depthConcurrency at a time, and times each one.
+func measure(cfg *config.Config, s3Clients []*s3.S3, name string, count int, request func(client *s3.S3, i int) error) *OperationResult {
+    result := &OperationResult{}
+    task := progress.Begin(name, "requests", int64(count))
+    defer task.Done()
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    for w := 0; w < cfg.DepthConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := int(atomic.AddInt64(&next, 1) - 1)
+                if i >= count {
+                    return
+                }
+
+                start := time.Now()
+                err := request(client, i)
+                duration := time.Since(start)
+
+                mu.Lock()
+                result.Requests++
+                if err != nil {
+                    result.Errors++
+                } else {
+                    result.Latencies.Add(duration)
+                }
+                mu.Unlock()
+
+                if err != nil {
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error in %s: %v\n", name, err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    return result
+}
+
+// PrintReport prints the latency of every operation per depth and how the median latency
+// at the deepest namespace compares with the shallowest.
+func PrintReport(r Result) {
+    fmt.Println("\nPrefix Nesting Depth Report:")
+    fmt.Println("============================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Prefix: %s/\n", r.Prefix)
+
+    for _, op := range Operations {
+        fmt.Printf("\n%s Latency:\n", op)
+        fmt.Printf("%8s %10s %10s %8s %12s %12s %12s\n", "Levels", "Key Bytes", "Requests", "Errors", "Avg", "P50", "P99")
+        for _, lr := range r.Levels {
+            o := lr.Operations[op]
+            fmt.Printf("%8d %10d %10d %8d %12v %12v %12v\n", lr.Levels, lr.KeyLength, o.Requests, o.Errors,
+                o.Average().Round(time.Microsecond), o.Percentile(0.50).Round(time.Microsecond), o.Percentile(0.99).Round(time.Microsecond))
+        }
+    }
+
+    if len(r.Levels) > 1 {
+        shallow, deep := r.Levels[0], r.Levels[0]
+        for _, lr := range r.Levels {
+            if lr.Levels < shallow.Levels {
+                shallow = lr
+            }
+            if lr.Levels > deep.Levels {
+                deep = lr
+            }
+        }
+        fmt.Printf("\nMedian latency at %d levels relative to %d levels:", deep.Levels, shallow.Levels)
+        for _, op := range Operations {
+            if base := shallow.Operations[op].Percentile(0.50); base > 0 {
+                fmt.Printf(" %s x%.2f", op, float64(deep.Operations[op].Percentile(0.50))/float64(base))
+            }
+        }
+        fmt.Println()
+    }
+
+    if r.Deleted > 0 || r.DeleteFailed > 0 || r.DeleteError != nil {
+        fmt.Printf("\nObjects Deleted: %d\n", r.Deleted)
+        fmt.Printf("Objects Not Deleted: %d\n", r.DeleteFailed)
+        if r.DeleteError != nil {
+            fmt.Printf("Delete Error: %v\n", r.DeleteError)
+        }
+    }
+    fmt.Println("============================")
+}
+
+// WriteCSV writes one row per depth and operation.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating depth report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Levels", "KeyBytes", "Operation", "Requests", "Errors", "AvgMs", "P50Ms", "P99Ms"})
+    for _, lr := range r.Levels {
+        for _, op := range Operations {
+            o := lr.Operations[op]
+            writer.Write([]string{
+                strconv.Itoa(lr.Levels),
+                strconv.Itoa(lr.KeyLength),
+                op,
+                strconv.FormatInt(o.Requests, 10),
+                strconv.FormatInt(o.Errors, 10),
+                stats.Milliseconds(o.Average()),
+                stats.Milliseconds(o.Percentile(0.50)),
+                stats.Milliseconds(o.Percentile(0.99)),
+            })
+        }
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing depth report %s: %w", filePath, err)
+    }
+    return nil
+}
@@ -0,0 +1,387 @@
+// fanout/fanout.go
+package fanout
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "io"
+    "math/rand"
+    "os"
+    "path"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/cleanup"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// heatRamp shades the matrix cells from the lowest to the highest throughput.
+const heatRamp = " .:-=+*#"
+
+// Phase is the outcome of one operation in one cell of the sweep.
+type Phase struct {
+    Requests  int64
+    Errors    int64
+    Bytes     int64
+    Elapsed   time.Duration
+    Latencies stats.Latencies // Times of the successful requests.
+}
+
+// OpsPerSecond returns the successful requests per second.
+func (p Phase) OpsPerSecond() float64 {
+    if p.Elapsed <= 0 {
+        return 0
+    }
+    return float64(p.Requests-p.Errors) / p.Elapsed.Seconds()
+}
+
+// MBPerSecond returns the MiB transferred per second.
+func (p Phase) MBPerSecond() float64 {
+    if p.Elapsed <= 0 {
+        return 0
+    }
+    return float64(p.Bytes) / (1024 * 1024) / p.Elapsed.Seconds()
+}
+
+// Percentile returns the p-th fraction (0-1) of the request times.
+func (p *Phase) Percentile(q float64) time.Duration {
+    return p.Latencies.Percentile(q)
+}
+
+// Cell is the outcome of one combination of prefix count and objects per prefix.
+type Cell struct {
+    Prefixes         int
+    ObjectsPerPrefix int
+    Put              Phase
+    Get              Phase
+}
+
+// Result is the outcome of a fan-out sweep.
+type Result struct {
+    RunID        string
+    Prefix       string
+    Cells        []Cell // In sweep order: every objects per prefix for the first prefix count, and so on.
+    Deleted      int64
+    DeleteFailed int64
+    DeleteError  error
+}
+
+// Failures returns the failed requests and deletes over all cells.
+func (r Result) Failures() int64 {
+    failures := r.DeleteFailed
+    for _, c := range r.Cells {
+        failures += c.Put.Errors + c.Get.Errors
+    }
+    if r.DeleteError != nil {
+        failures++
+    }
+    return failures
+}
+
+// cellLayout builds the keys of one cell. The i-th object goes to prefix i%prefixes, so
+// consecutive uploads are spread over all the prefixes of the cell.
+type cellLayout struct {
+    root     string
+    prefixes int
+}
+
+// key returns the key of the i-th object.
+func (l cellLayout) key(i int) string {
+    return path.Join(l.root, fmt.Sprintf("p%05d", i%l.prefixes), fmt.Sprintf("%08d", i/l.prefixes))
+}
+
+// Run measures every combination of fanOutPrefixCounts and fanOutObjectsPerPrefix. Each cell
+// uploads its objects, spread over its prefixes, then reads random objects back for
+// fanOutReadSeconds. The cell with p prefixes of n objects is written below
+// <fanOutPrefix>/<runID>/FANOUT/<p>x<n>/.
+func Run(cfg *config.Config, s3Clients []*s3.S3) Result {
+    runID := cfg.NewRunID()
+    result := Result{RunID: runID, Prefix: path.Join(cfg.FanOutPrefix, runID, "FANOUT")}
+
+    payload := make([]byte, cfg.FanOutObjectSize)
+    rand.Read(payload)
+
+    for _, prefixes := range cfg.FanOutPrefixCounts {
+        for _, perPrefix := range cfg.FanOutObjectsPerPrefix {
+            cell := Cell{Prefixes: prefixes, ObjectsPerPrefix: perPrefix}
+            layout := cellLayout{root: path.Join(result.Prefix, fmt.Sprintf("%dx%d", prefixes, perPrefix)), prefixes: prefixes}
+            objects := prefixes * perPrefix
+            fmt.Printf("\n%d prefixes x %d objects: %d objects below %s/\n", prefixes, perPrefix, objects, layout.root)
+
+            var stored []bool
+            cell.Put, stored = put(cfg, s3Clients, layout, objects, payload)
+            cell.Get = get(cfg, s3Clients, layout, stored)
+            result.Cells = append(result.Cells, cell)
+
+            // Each cell is deleted before the next one, so a cell is not measured against the
+            // objects of the earlier ones.
+            if cfg.FanOutDeleteObjects {
+                fanOutCfg := *cfg
+                fanOutCfg.S3Folder = cfg.FanOutPrefix
+                deleted, err := cleanup.DeleteRun(&fanOutCfg, s3Clients[0], runID)
+                result.Deleted += deleted.Deleted
+                result.DeleteFailed += deleted.Failed
+                if err != nil && result.DeleteError == nil {
+                    result.DeleteError = err
+                }
+            }
+        }
+    }
+    return result
+}
+
+// put uploads the objects of a cell with fanOutConcurrency workers and returns which of them
+// were stored.
+func put(cfg *config.Config, s3Clients []*s3.S3, layout cellLayout, objects int, payload []byte) (Phase, []bool) {
+    stored := make([]bool, objects)
+    task := progress.Begin("Uploading cell", "objects", int64(objects))
+    defer task.Done()
+
+    var next int64
+    phase := run(cfg, s3Clients, config.OperationPut, task, func(client *s3.S3, rng *rand.Rand) (int64, bool, error) {
+        i := int(atomic.AddInt64(&next, 1) - 1)
+        if i >= objects {
+            return 0, false, nil
+        }
+        ctx, cancel := cfg.OperationContext(config.OperationPut)
+        defer cancel()
+        _, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+            Bucket:        aws.String(cfg.BucketName),
+            Key:           aws.String(layout.key(i)),
+            Body:          bytes.NewReader(payload),
+            ContentLength: aws.Int64(int64(len(payload))),
+        })
+        if err != nil {
+            return 0, true, err
+        }
+        // Every worker writes a different index, so no lock is needed.
+        stored[i] = true
+        return int64(len(payload)), true, nil
+    })
+    return phase, stored
+}
+
+// get reads random stored objects of a cell with fanOutConcurrency workers for fanOutReadSeconds.
+func get(cfg *config.Config, s3Clients []*s3.S3, layout cellLayout, stored []bool) Phase {
+    var keys []int
+    for i, ok := range stored {
+        if ok {
+            keys = append(keys, i)
+        }
+    }
+    if len(keys) == 0 {
+        fmt.Println("No object of the cell was stored; skipping the reads.")
+        return Phase{}
+    }
+
+    duration := time.Duration(cfg.FanOutReadSeconds) * time.Second
+    task := progress.Begin("Reading cell", "requests", 0)
+    defer task.Done()
+
+    deadline := time.Now().Add(duration)
+    return run(cfg, s3Clients, config.OperationGet, task, func(client *s3.S3, rng *rand.Rand) (int64, bool, error) {
+        if !time.Now().Before(deadline) {
+            return 0, false, nil
+        }
+        ctx, cancel := cfg.OperationContext(config.OperationGet)
+        defer cancel()
+        output, err := client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+            Bucket: aws.String(cfg.BucketName),
+            Key:    aws.String(layout.key(keys[rng.Intn(len(keys))])),
+        })
+        if err != nil {
+            return 0, true, err
+        }
+        defer output.Body.Close()
+        n, err := io.Copy(io.Discard, output.Body)
+        return n, true, err
+    })
+}
+
+// run sends requests with fanOutConcurrency workers until request reports that there is no
+// more work, and times each one. request returns the bytes transferred, whether a request was
+// sent at all and its error.
+func run(cfg *config.Config, s3Clients []*s3.S3, name string, task *progress.Task, request func(client *s3.S3, rng *rand.Rand) (int64, bool, error)) Phase {
+    var phase Phase
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var printedErrors int64
+
+    start := time.Now()
+    for w := 0; w < cfg.FanOutConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(w)))
+            for {
+                requestStart := time.Now()
+                n, sent, err := request(client, rng)
+                if !sent {
+                    return
+                }
+                latency := time.Since(requestStart)
+
+                mu.Lock()
+                phase.Requests++
+                if err != nil {
+                    phase.Errors++
+                } else {
+                    phase.Bytes += n
+                    phase.Latencies.Add(latency)
+                }
+                mu.Unlock()
+
+                if err != nil {
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error in %s: %v\n", name, err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+    phase.Elapsed = time.Since(start)
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further %s errors were not printed.\n", printedErrors-stats.MaxPrintedErrors, name)
+    }
+    return phase
+}
+
+// PrintReport prints the throughput of every cell as a matrix per operation, with the prefix
+// counts as rows and the objects per prefix as columns, and names the best cell.
+func PrintReport(r Result) {
+    fmt.Println("\nPrefix Fan-Out Sweep Report:")
+    fmt.Println("============================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Prefix: %s/\n", r.Prefix)
+
+    printMatrix(r, config.OperationPut, func(c Cell) Phase { return c.Put })
+    printMatrix(r, config.OperationGet, func(c Cell) Phase { return c.Get })
+
+    fmt.Printf("\nCell Details:\n")
+    fmt.Printf("%10s %12s %10s %12s %8s %12s %10s %12s %8s\n", "Prefixes", "Obj/Prefix", "PUT ops/s", "PUT P99", "PUT Err", "GET ops/s", "GET MB/s", "GET P99", "GET Err")
+    for _, c := range r.Cells {
+        fmt.Printf("%10d %12d %10.1f %12v %8d %12.1f %10.2f %12v %8d\n", c.Prefixes, c.ObjectsPerPrefix,
+            c.Put.OpsPerSecond(), c.Put.Percentile(0.99).Round(time.Microsecond), c.Put.Errors,
+            c.Get.OpsPerSecond(), c.Get.MBPerSecond(), c.Get.Percentile(0.99).Round(time.Microsecond), c.Get.Errors)
+    }
+
+    if r.Deleted > 0 || r.DeleteFailed > 0 || r.DeleteError != nil {
+        fmt.Printf("\nObjects Deleted: %d\n", r.Deleted)
+        fmt.Printf("Objects Not Deleted: %d\n", r.DeleteFailed)
+        if r.DeleteError != nil {
+            fmt.Printf("Delete Error: %v\n", r.DeleteError)
+        }
+    }
+    fmt.Println("============================")
+}
+
+// printMatrix prints the ops/sec of one operation as a heat table. Each value is followed by
+// a shade from heatRamp relative to the best cell, which is marked with "<".
+func printMatrix(r Result, op string, phase func(Cell) Phase) {
+    var prefixCounts, perPrefixCounts []int
+    values := make(map[[2]int]float64)
+    var best Cell
+    var bestValue float64
+    for _, c := range r.Cells {
+        if !containsInt(prefixCounts, c.Prefixes) {
+            prefixCounts = append(prefixCounts, c.Prefixes)
+        }
+        if !containsInt(perPrefixCounts, c.ObjectsPerPrefix) {
+            perPrefixCounts = append(perPrefixCounts, c.ObjectsPerPrefix)
+        }
+        v := phase(c).OpsPerSecond()
+        values[[2]int{c.Prefixes, c.ObjectsPerPrefix}] = v
+        if v > bestValue {
+            best, bestValue = c, v
+        }
+    }
+
+    fmt.Printf("\n%s ops/sec (rows: prefixes, columns: objects per prefix):\n", op)
+    fmt.Printf("%10s", "")
+    for _, n := range perPrefixCounts {
+        fmt.Printf(" %13d", n)
+    }
+    fmt.Println()
+    for _, p := range prefixCounts {
+        fmt.Printf("%10d", p)
+        for _, n := range perPrefixCounts {
+            v, ok := values[[2]int{p, n}]
+            if !ok {
+                fmt.Printf(" %13s", "-")
+                continue
+            }
+            shade := heatRamp[0]
+            if bestValue > 0 {
+                shade = heatRamp[min(int(v/bestValue*float64(len(heatRamp))), len(heatRamp)-1)]
+            }
+            mark := " "
+            if p == best.Prefixes && n == best.ObjectsPerPrefix {
+                mark = "<"
+            }
+            fmt.Printf(" %10.1f %s%s", v, string(shade), mark)
+        }
+        fmt.Println()
+    }
+    if bestValue > 0 {
+        fmt.Printf("Best %s: %d prefixes x %d objects per prefix at %.1f ops/sec\n", op, best.Prefixes, best.ObjectsPerPrefix, bestValue)
+    }
+}
+
+// containsInt reports whether values holds v.
+func containsInt(values []int, v int) bool {
+    for _, x := range values {
+        if x == v {
+            return true
+        }
+    }
+    return false
+}
+
+// WriteCSV writes one row per cell of the sweep.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating fan-out report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Prefixes", "ObjectsPerPrefix", "Operation", "Requests", "Errors", "OpsPerSec", "MBPerSec", "P50Ms", "P99Ms"})
+    for _, c := range r.Cells {
+        for _, op := range []struct {
+            name  string
+            phase Phase
+        }{{config.OperationPut, c.Put}, {config.OperationGet, c.Get}} {
+            writer.Write([]string{
+                strconv.Itoa(c.Prefixes),
+                strconv.Itoa(c.ObjectsPerPrefix),
+                op.name,
+                strconv.FormatInt(op.phase.Requests, 10),
+                strconv.FormatInt(op.phase.Errors, 10),
+                fmt.Sprintf("%.1f", op.phase.OpsPerSecond()),
+                fmt.Sprintf("%.3f", op.phase.MBPerSecond()),
+                stats.Milliseconds(op.phase.Percentile(0.50)),
+                stats.Milliseconds(op.phase.Percentile(0.99)),
+            })
+        }
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing fan-out report %s: %w", filePath, err)
+    }
+    return nil
+}
@@ -6,9 +6,10 @@ import (
     "math/rand"
     "os"
     "path/filepath"
-    "time"
 
     "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
 )
 
 // PrepareBaseDirectory prepares the base directory by creating it if it doesn't exist.
@@ -17,34 +18,57 @@ func PrepareBaseDirectory(baseDir string) error {
 }
 
 // GenerateAllBaseFiles generates a specified number of base files with random content.
-// It skips generating files that already exist.
+// It skips generating files that already exist, unless a seed is set: then every file is
+// generated again from the seed, so it has the same size and content on every run.
 func GenerateAllBaseFiles(cfg *config.Config) {
-    startTime := time.Now()
+    GenerateBaseFiles(cfg, TextPayload)
+}
+
+// GenerateBaseFiles generates the base files like GenerateAllBaseFiles, with the content written
+// by gen. It stops early when the run is cancelled.
+func GenerateBaseFiles(cfg *config.Config, gen PayloadGenerator) {
+    task := progress.Begin("Generating base files", "files", int64(cfg.BaseFileCount))
+    runCtx := monitor.RunContext()
     for i := 0; i < cfg.BaseFileCount; i++ {
+        if runCtx.Err() != nil {
+            task.Done()
+            fmt.Printf("Base file generation stopped after %d of %d files.\n", i, cfg.BaseFileCount)
+            return
+        }
         filename := filepath.Join(cfg.BaseDirectory, fmt.Sprintf("file_base_%d.txt", i))
 
         // Check if the file already exists.
-        if _, err := os.Stat(filename); os.IsNotExist(err) {
-            if err := GenerateTextFile(filename, cfg.MinSize, cfg.MaxSize); err != nil {
-                fmt.Printf("Error generating base file %s: %v\n", filename, err)
+        if _, err := os.Stat(filename); cfg.Seed != 0 || os.IsNotExist(err) {
+            rng := cfg.Rand(fmt.Sprintf("base-file/%d", i))
+            if err := generateFile(filename, cfg.MinSize, cfg.MaxSize, gen, rng); err != nil {
+                progress.Printf("Error generating base file %s: %v\n", filename, err)
+                task.Fail(1)
+                continue
             }
         }
-
-        elapsedTime := time.Since(startTime).Seconds()
-        rate := float64(i+1) / elapsedTime
-        progress := float64(i+1) / float64(cfg.BaseFileCount) * 100
-        fmt.Printf("Generating base files: %d/%d (%.2f%%) | Rate: %.2f files/sec\r", i+1, cfg.BaseFileCount, progress, rate)
+        task.Add(1)
     }
+    task.Done()
     fmt.Printf("100%% completed - %d base files generated.\n", cfg.BaseFileCount)
 }
 
 // GenerateTextFile creates a text file with random alphabetical content of a specified size.
 func GenerateTextFile(filename string, minSize, maxSize int) error {
-    size := rand.Intn(maxSize-minSize+1) + minSize
-    content := make([]byte, size)
-    for i := range content {
-        content[i] = byte('a' + rand.Intn(26))
+    return generateFile(filename, minSize, maxSize, TextPayload, rand.New(rand.NewSource(rand.Int63())))
+}
+
+// generateFile creates a file of a size between minSize and maxSize drawn from rng, with the
+// content written by gen.
+func generateFile(filename string, minSize, maxSize int, gen PayloadGenerator, rng *rand.Rand) error {
+    size := rng.Intn(maxSize-minSize+1) + minSize
+    f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+    if err != nil {
+        return err
+    }
+    if err := gen.Generate(f, size, rng); err != nil {
+        f.Close()
+        return err
     }
-    return os.WriteFile(filename, content, 0644)
+    return f.Close()
 }
 
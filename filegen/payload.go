@@ -0,0 +1,36 @@
+// filegen/payload.go
+package filegen
+
+import (
+    "bufio"
+    "io"
+    "math/rand"
+)
+
+// PayloadGenerator writes the content of the base files, which every uploaded object is a copy
+// of. Custom generators can produce data shaped like production objects, such as compressible
+// logs or media that defeats deduplication.
+type PayloadGenerator interface {
+    // Generate writes exactly size bytes of content to w, taking any random choices from rng,
+    // which is derived from the seed when one is set.
+    Generate(w io.Writer, size int, rng *rand.Rand) error
+}
+
+// PayloadGeneratorFunc adapts a function to a PayloadGenerator.
+type PayloadGeneratorFunc func(w io.Writer, size int, rng *rand.Rand) error
+
+// Generate implements PayloadGenerator.
+func (f PayloadGeneratorFunc) Generate(w io.Writer, size int, rng *rand.Rand) error {
+    return f(w, size, rng)
+}
+
+// TextPayload is the default PayloadGenerator: random lower-case letters.
+var TextPayload PayloadGenerator = PayloadGeneratorFunc(func(w io.Writer, size int, rng *rand.Rand) error {
+    bw := bufio.NewWriter(w)
+    for i := 0; i < size; i++ {
+        if err := bw.WriteByte(byte('a' + rng.Intn(26))); err != nil {
+            return err
+        }
+    }
+    return bw.Flush()
+})
@@ -12,16 +12,20 @@ import (
     "time"
 
     "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
 )
 
 // ReplicateFilesWithReflinkInParallel replicates files using reflink in parallel.
-// It returns the list of replicated file paths and any error encountered.
+// It returns the list of replicated file paths in replica order, whatever order the workers
+// finish in, and any error encountered.
 func ReplicateFilesWithReflinkInParallel(cfg *config.Config) ([]string, error) {
     fmt.Println("Starting file replication with reflink in parallel.")
-    startTime := time.Now()
+    task := progress.Begin("Replicating files", "files", int64(cfg.MaxLocalFiles))
 
-    var replicatedFiles []string
-    var mu sync.Mutex
+    // Each worker writes the path of a replica at its index, so the list does not depend on
+    // scheduling and seeded runs select the same files.
+    replicas := make([]string, cfg.MaxLocalFiles)
     var replicationWG sync.WaitGroup
 
     jobs := make(chan int, 1000) // Adjusted buffer size
@@ -32,29 +36,30 @@ func ReplicateFilesWithReflinkInParallel(cfg *config.Config) ([]string, error) {
     // Error channel to collect errors
     errorChan := make(chan error, 1000)
 
+    // No more replicas are made once the run is cancelled.
+    runCtx := monitor.RunContext()
+
     replicationWG.Add(cfg.MaxConcurrentReplicas)
     for w := 0; w < cfg.MaxConcurrentReplicas; w++ {
         go func() {
             defer replicationWG.Done()
             for currentCount := range jobs {
+                if runCtx.Err() != nil {
+                    continue
+                }
                 baseFileIndex := currentCount % cfg.BaseFileCount
                 src := filepath.Join(cfg.BaseDirectory, fmt.Sprintf("file_base_%d.txt", baseFileIndex))
                 dst := filepath.Join(folderPath, fmt.Sprintf("file_%d.txt", currentCount))
 
                 if err := CopyFileReflink(src, dst); err != nil {
-                    fmt.Printf("\nError replicating file %s to %s: %v\n", src, dst, err)
+                    progress.Printf("Error replicating file %s to %s: %v\n", src, dst, err)
+                    task.Fail(1)
                     errorChan <- err
                     continue
                 }
 
-                mu.Lock()
-                replicatedFiles = append(replicatedFiles, dst)
-                mu.Unlock()
-
-                elapsedTime := time.Since(startTime).Seconds()
-                rate := float64(len(replicatedFiles)) / elapsedTime
-                progress := float64(len(replicatedFiles)) / float64(cfg.MaxLocalFiles) * 100
-                fmt.Printf("Replicating files: %d/%d (%.2f%%) | Rate: %.2f files/sec\r", len(replicatedFiles), cfg.MaxLocalFiles, progress, rate)
+                replicas[currentCount] = dst
+                task.Add(1)
             }
         }()
     }
@@ -63,21 +68,34 @@ func ReplicateFilesWithReflinkInParallel(cfg *config.Config) ([]string, error) {
     go func() {
         defer close(jobs)
         for currentCount := 0; currentCount < cfg.MaxLocalFiles; currentCount++ {
-            jobs <- currentCount
+            select {
+            case jobs <- currentCount:
+            case <-runCtx.Done():
+                return
+            }
         }
     }()
 
     replicationWG.Wait()
     close(errorChan)
+    task.Done()
 
     // Check for replication errors
     errorCount := len(errorChan)
+    replicatedFiles := make([]string, 0, len(replicas))
+    for _, replica := range replicas {
+        if replica != "" {
+            replicatedFiles = append(replicatedFiles, replica)
+        }
+    }
+
     if errorCount > 0 {
-        fmt.Printf("\n%d errors occurred during file replication.\n", errorCount)
+        fmt.Printf("%d errors occurred during file replication.\n", errorCount)
+    } else if runCtx.Err() != nil {
+        fmt.Printf("File replication stopped after %d of %d files.\n", len(replicatedFiles), cfg.MaxLocalFiles)
     } else {
-        fmt.Println("\nFile replication completed successfully.")
+        fmt.Println("File replication completed successfully.")
     }
-
     return replicatedFiles, nil
 }
 
@@ -0,0 +1,47 @@
+// filegen/replication_test.go
+package filegen
+
+import (
+    "fmt"
+    "os"
+    "path/filepath"
+    "slices"
+    "testing"
+
+    "scale_s3_benchmark/config"
+)
+
+func TestReplicateFilesWithReflinkInParallelOrder(t *testing.T) {
+    cfg := &config.Config{
+        BaseDirectory:         t.TempDir(),
+        BaseFileCount:         3,
+        MaxLocalFiles:         50,
+        MaxConcurrentReplicas: 8,
+    }
+    for i := 0; i < cfg.BaseFileCount; i++ {
+        path := filepath.Join(cfg.BaseDirectory, fmt.Sprintf("file_base_%d.txt", i))
+        if err := os.WriteFile(path, []byte("base"), 0644); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    want := make([]string, cfg.MaxLocalFiles)
+    for i := range want {
+        want[i] = filepath.Join(cfg.BaseDirectory, fmt.Sprintf("file_%d.txt", i))
+    }
+    var first []string
+    for run := 0; run < 2; run++ {
+        got, err := ReplicateFilesWithReflinkInParallel(cfg)
+        if err != nil {
+            t.Fatalf("run %d: %v", run, err)
+        }
+        if !slices.Equal(got, want) {
+            t.Fatalf("run %d returned the replicas out of order: %v", run, got)
+        }
+        if run == 0 {
+            first = got
+        } else if !slices.Equal(got, first) {
+            t.Fatalf("the runs returned different orders:\n%v\n%v", first, got)
+        }
+    }
+}
@@ -0,0 +1,177 @@
+// firehose/firehose.go
+package firehose
+
+import (
+    "bytes"
+    "fmt"
+    "math/rand"
+    "path"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// progressBatch is the number of uploads a worker completes before updating the status line.
+const progressBatch = 256
+
+// Result holds the outcome of a firehose run.
+type Result struct {
+    RunID     string
+    Uploaded  int64
+    Failed    int64
+    Bytes     int64
+    MinTime   time.Duration
+    MaxTime   time.Duration
+    TotalTime time.Duration
+    Latency   stats.Histogram
+    Duration  time.Duration
+}
+
+// merge adds the measurements of one worker to the result.
+func (r *Result) merge(w *Result) {
+    r.Uploaded += w.Uploaded
+    r.Failed += w.Failed
+    r.Bytes += w.Bytes
+    r.TotalTime += w.TotalTime
+    if r.MinTime == 0 || (w.MinTime > 0 && w.MinTime < r.MinTime) {
+        r.MinTime = w.MinTime
+    }
+    if w.MaxTime > r.MaxTime {
+        r.MaxTime = w.MaxTime
+    }
+    r.Latency.Merge(w.Latency)
+}
+
+// Run uploads FirehoseObjects small objects as fast as possible. Payloads are slices of one
+// random buffer held in memory and all keys are generated before the clock starts, so the
+// measured time is spent on requests only. Each worker uses one client, keeps its own
+// statistics and prints nothing per object.
+func Run(cfg *config.Config, s3Clients []*s3.S3) Result {
+    fmt.Printf("Generating %d keys...\n", cfg.FirehoseObjects)
+    runID := cfg.NewRunID()
+    prefix := path.Join(cfg.FirehosePrefix, runID, "FIREHOSE")
+    keys := make([]string, cfg.FirehoseObjects)
+    for i := range keys {
+        keys[i] = fmt.Sprintf("%s/%x", prefix, i)
+    }
+
+    // Objects are windows of random size at random offsets into a single payload buffer.
+    payload := make([]byte, 2*cfg.FirehoseMaxSize)
+    rand.Read(payload)
+
+    fmt.Printf("Uploading %d objects of %d-%d bytes with %d workers...\n", cfg.FirehoseObjects, cfg.FirehoseMinSize, cfg.FirehoseMaxSize, cfg.FirehoseConcurrency)
+    task := progress.Begin("Firehose uploads", "objects", cfg.FirehoseObjects)
+
+    result := Result{RunID: runID}
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    start := time.Now()
+
+    for w := 0; w < cfg.FirehoseConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+
+            client := s3Clients[w%len(s3Clients)]
+            rng := rand.New(rand.NewSource(int64(w)))
+            var local Result
+            var unreported int64
+            defer func() {
+                task.Add(unreported)
+                mu.Lock()
+                result.merge(&local)
+                mu.Unlock()
+            }()
+
+            for {
+                i := atomic.AddInt64(&next, 1) - 1
+                if i >= cfg.FirehoseObjects {
+                    return
+                }
+
+                size := cfg.FirehoseMinSize + rng.Intn(cfg.FirehoseMaxSize-cfg.FirehoseMinSize+1)
+                offset := rng.Intn(len(payload) - size + 1)
+
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                opStart := time.Now()
+                _, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+                    Bucket:        aws.String(cfg.BucketName),
+                    Key:           aws.String(keys[i]),
+                    Body:          bytes.NewReader(payload[offset : offset+size]),
+                    ContentLength: aws.Int64(int64(size)),
+                })
+                duration := time.Since(opStart)
+                cancel()
+
+                if err != nil {
+                    local.Failed++
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error uploading %s: %v\n", keys[i], err)
+                    }
+                    continue
+                }
+
+                local.Uploaded++
+                local.Bytes += int64(size)
+                local.TotalTime += duration
+                if local.MinTime == 0 || duration < local.MinTime {
+                    local.MinTime = duration
+                }
+                if duration > local.MaxTime {
+                    local.MaxTime = duration
+                }
+                local.Latency.Add(duration)
+
+                if unreported++; unreported == progressBatch {
+                    task.Add(unreported)
+                    unreported = 0
+                }
+            }
+        }(w)
+    }
+
+    wg.Wait()
+    result.Duration = time.Since(start)
+    task.Done()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further upload errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    return result
+}
+
+// PrintReport prints the rate, throughput and latency distribution of a firehose run.
+func PrintReport(r Result) {
+    fmt.Println("\nTiny Object Firehose Report:")
+    fmt.Println("============================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Objects Uploaded: %d\n", r.Uploaded)
+    fmt.Printf("Objects Failed: %d\n", r.Failed)
+    fmt.Printf("Data Written: %.2f MB\n", float64(r.Bytes)/(1024*1024))
+    fmt.Printf("Duration: %v\n", r.Duration.Round(time.Millisecond))
+    if seconds := r.Duration.Seconds(); seconds > 0 {
+        fmt.Printf("Rate: %.2f objects/sec\n", float64(r.Uploaded)/seconds)
+        fmt.Printf("Throughput: %.2f MB/sec\n", float64(r.Bytes)/(1024*1024)/seconds)
+    }
+
+    if r.Uploaded > 0 {
+        fmt.Println("\nPUT Latency:")
+        fmt.Printf("Min: %v\n", r.MinTime)
+        fmt.Printf("Avg: %v\n", r.TotalTime/time.Duration(r.Uploaded))
+        fmt.Printf("P50: %v\n", r.Latency.Percentile(0.50))
+        fmt.Printf("P90: %v\n", r.Latency.Percentile(0.90))
+        fmt.Printf("P99: %v\n", r.Latency.Percentile(0.99))
+        fmt.Printf("P99.9: %v\n", r.Latency.Percentile(0.999))
+        fmt.Printf("Max: %v\n", r.MaxTime)
+    }
+    fmt.Println("============================")
+}
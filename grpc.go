@@ -0,0 +1,182 @@
+// grpc.go
+package main
+
+import (
+    "context"
+    "crypto/subtle"
+    "encoding/base64"
+    "fmt"
+    "net"
+    "strings"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/controlpb"
+    "scale_s3_benchmark/monitor"
+)
+
+// controlServer implements the gRPC control plane on top of the same run control as the HTTP API.
+type controlServer struct {
+    controlpb.UnimplementedControlServer
+}
+
+// statusMessage converts the current run status to its protobuf form.
+func statusMessage() *controlpb.RunStatus {
+    st := currentStatus()
+
+    msg := &controlpb.RunStatus{
+        Running:     st.Running,
+        Phase:       st.Phase,
+        Paused:      st.Paused,
+        AbortReason: st.AbortReason,
+        Concurrency: int32(st.Concurrency),
+        Stats: &controlpb.UploadStats{
+            TotalUploads:      st.Stats.TotalUploads,
+            Successes:         st.Stats.Successes,
+            Failures:          st.Stats.Failures,
+            Skipped:           st.Stats.Skipped,
+            StartTimeUnixNano: st.Stats.StartTime.UnixNano(),
+        },
+    }
+    for _, ep := range monitor.GetEndpointLive() {
+        msg.Endpoints = append(msg.Endpoints, &controlpb.EndpointStats{
+            Endpoint:     ep.Endpoint,
+            Rate:         ep.Rate,
+            ErrorRate:    ep.ErrorRate,
+            P99Nanos:     int64(ep.P99),
+            CircuitState: ep.CircuitState,
+        })
+    }
+    return msg
+}
+
+// StartRun starts a run from the posted configuration.
+func (s *controlServer) StartRun(ctx context.Context, req *controlpb.StartRunRequest) (*controlpb.RunStatus, error) {
+    cfg, err := config.ParseConfig(req.ConfigJson)
+    if err != nil {
+        return nil, status.Error(codes.InvalidArgument, err.Error())
+    }
+    if err := createOutputDirs(cfg); err != nil {
+        return nil, status.Errorf(codes.Internal, "error preparing output directories: %v", err)
+    }
+    if err := startRun(cfg); err != nil {
+        return nil, status.Error(codes.FailedPrecondition, err.Error())
+    }
+    return statusMessage(), nil
+}
+
+// GetStatus returns the status of the current or last run.
+func (s *controlServer) GetStatus(ctx context.Context, req *controlpb.GetStatusRequest) (*controlpb.RunStatus, error) {
+    return statusMessage(), nil
+}
+
+// StreamStats sends the run status every interval until the client goes away.
+func (s *controlServer) StreamStats(req *controlpb.StreamStatsRequest, stream controlpb.Control_StreamStatsServer) error {
+    interval := time.Duration(req.IntervalSeconds) * time.Second
+    if interval <= 0 {
+        interval = 5 * time.Second
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        if err := stream.Send(statusMessage()); err != nil {
+            return err
+        }
+        select {
+        case <-stream.Context().Done():
+            return nil
+        case <-ticker.C:
+        }
+    }
+}
+
+// StopRun gracefully stops the run in progress.
+func (s *controlServer) StopRun(ctx context.Context, req *controlpb.StopRunRequest) (*controlpb.RunStatus, error) {
+    reason := req.Reason
+    if reason == "" {
+        reason = "stopped via gRPC"
+    }
+    monitor.Abort(reason)
+    return statusMessage(), nil
+}
+
+// grpcAuthorized reports whether the call carries the web server's credentials in its
+// "authorization" metadata: the bearer token, or basic-auth credentials, as checked by
+// requireAuth. Without either every call is allowed.
+func grpcAuthorized(ctx context.Context, cfg *config.Config) bool {
+    if cfg.WebAuthToken == "" && cfg.WebAuthUsername == "" {
+        return true
+    }
+    md, _ := metadata.FromIncomingContext(ctx)
+    for _, auth := range md.Get("authorization") {
+        if cfg.WebAuthToken != "" && strings.HasPrefix(auth, "Bearer ") &&
+            subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(cfg.WebAuthToken)) == 1 {
+            return true
+        }
+        if cfg.WebAuthUsername != "" && strings.HasPrefix(auth, "Basic ") {
+            decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+            if err != nil {
+                continue
+            }
+            user, pass, ok := strings.Cut(string(decoded), ":")
+            if ok && subtle.ConstantTimeCompare([]byte(user), []byte(cfg.WebAuthUsername)) == 1 &&
+                subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.WebAuthPassword)) == 1 {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// startGRPCServer serves the control plane on cfg.GrpcListenAddress in the background.
+// It uses the web server's bearer token or basic-auth credentials and TLS certificate, when
+// configured.
+func startGRPCServer(cfg *config.Config) error {
+    unauthenticated := status.Error(codes.Unauthenticated, "missing or invalid credentials")
+
+    opts := []grpc.ServerOption{
+        grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+            if !grpcAuthorized(ctx, cfg) {
+                return nil, unauthenticated
+            }
+            return handler(ctx, req)
+        }),
+        grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+            if !grpcAuthorized(ss.Context(), cfg) {
+                return unauthenticated
+            }
+            return handler(srv, ss)
+        }),
+    }
+    if cfg.WebTLSCertFile != "" {
+        creds, err := credentials.NewServerTLSFromFile(cfg.WebTLSCertFile, cfg.WebTLSKeyFile)
+        if err != nil {
+            return fmt.Errorf("error loading gRPC TLS certificate: %w", err)
+        }
+        opts = append(opts, grpc.Creds(creds))
+    }
+
+    listener, err := net.Listen("tcp", cfg.GrpcListenAddress)
+    if err != nil {
+        return fmt.Errorf("error starting gRPC server: %w", err)
+    }
+
+    server := grpc.NewServer(opts...)
+    controlpb.RegisterControlServer(server, &controlServer{})
+
+    go func() {
+        fmt.Printf("gRPC control plane started on %s\n", listener.Addr())
+        if err := server.Serve(listener); err != nil {
+            fmt.Printf("gRPC server stopped: %v\n", err)
+        }
+    }()
+    return nil
+}
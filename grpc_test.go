@@ -0,0 +1,82 @@
+// grpc_test.go
+package main
+
+import (
+    "context"
+    "encoding/base64"
+    "net"
+    "testing"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/codes"
+    "google.golang.org/grpc/credentials/insecure"
+    "google.golang.org/grpc/metadata"
+    "google.golang.org/grpc/status"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/controlpb"
+)
+
+// freeAddress returns a local address nothing listens on.
+func freeAddress(t *testing.T) string {
+    t.Helper()
+    listener, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer listener.Close()
+    return listener.Addr().String()
+}
+
+func TestGRPCBasicAuth(t *testing.T) {
+    cfg := &config.Config{
+        GrpcListenAddress: freeAddress(t),
+        WebAuthUsername:   "bench",
+        WebAuthPassword:   "secret",
+    }
+    if err := startGRPCServer(cfg); err != nil {
+        t.Fatal(err)
+    }
+
+    conn, err := grpc.NewClient(cfg.GrpcListenAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    client := controlpb.NewControlClient(conn)
+
+    basic := func(user, pass string) string {
+        return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+    }
+    tests := []struct {
+        name string
+        auth string
+        want codes.Code
+    }{
+        {name: "no credentials", want: codes.Unauthenticated},
+        {name: "wrong password", auth: basic("bench", "wrong"), want: codes.Unauthenticated},
+        {name: "bearer token not configured", auth: "Bearer secret", want: codes.Unauthenticated},
+        {name: "basic auth", auth: basic("bench", "secret"), want: codes.OK},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+            defer cancel()
+            if tt.auth != "" {
+                ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.auth)
+            }
+
+            _, err := client.GetStatus(ctx, &controlpb.GetStatusRequest{})
+            if got := status.Code(err); got != tt.want {
+                t.Errorf("GetStatus() = %v (%v), want %v", got, err, tt.want)
+            }
+            if tt.want == codes.Unauthenticated {
+                _, err = client.StopRun(ctx, &controlpb.StopRunRequest{})
+                if status.Code(err) != codes.Unauthenticated {
+                    t.Errorf("StopRun() = %v, want %v", err, codes.Unauthenticated)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,132 @@
+// history.go
+package main
+
+import (
+    "errors"
+    "fmt"
+    "io/fs"
+    "net/http"
+    "strings"
+
+    "scale_s3_benchmark/benchmark"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/results"
+)
+
+// historyPageHandler serves the run history page.
+func historyPageHandler(w http.ResponseWriter, r *http.Request) {
+    http.ServeFileFS(w, r, webAssets, "templates/history.html")
+}
+
+// runsHandler lists the stored runs (GET /api/runs).
+func runsHandler(cfg *config.Config) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        summaries, err := results.List(cfg.ResultsDir)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+            return
+        }
+        if summaries == nil {
+            summaries = []results.Summary{}
+        }
+        writeJSON(w, http.StatusOK, summaries)
+    }
+}
+
+// runRecordHandler returns the full record of a stored run (GET /api/runs/{id}).
+func runRecordHandler(cfg *config.Config) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+        rec, err := results.Load(cfg.ResultsDir, strings.TrimPrefix(r.URL.Path, "/api/runs/"))
+        if err != nil {
+            code := http.StatusBadRequest
+            if errors.Is(err, fs.ErrNotExist) {
+                code = http.StatusNotFound
+            }
+            http.Error(w, err.Error(), code)
+            return
+        }
+        writeJSON(w, http.StatusOK, rec)
+    }
+}
+
+// reportRecord returns the record to export: the stored run with the given ID, a live snapshot
+// of the run in progress, or else the most recent stored run.
+func reportRecord(cfg *config.Config, id string) (results.Record, error) {
+    if id != "" {
+        return results.Load(cfg.ResultsDir, id)
+    }
+
+    switch monitor.Phase() {
+    case monitor.PhaseIdle, monitor.PhaseCompleted, monitor.PhaseAborted, monitor.PhaseTruncated, monitor.PhaseFailed:
+    default:
+        runCfg := cfg
+        runArtifacts.Lock()
+        if runArtifacts.cfg != nil {
+            runCfg = runArtifacts.cfg
+        }
+        runArtifacts.Unlock()
+        return results.NewRecord(runCfg, monitor.Phase(), benchmark.BenchmarkResult{}), nil
+    }
+
+    summaries, err := results.List(cfg.ResultsDir)
+    if err != nil {
+        return results.Record{}, err
+    }
+    if len(summaries) == 0 {
+        return results.Record{}, fmt.Errorf("no runs recorded yet: %w", fs.ErrNotExist)
+    }
+    return results.Load(cfg.ResultsDir, summaries[0].ID)
+}
+
+// reportHandler downloads a run report (GET /api/report?format=json|csv|html[&id=<run>]).
+// Without an id it exports the run in progress, or the most recent run when idle.
+func reportHandler(cfg *config.Config) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if r.Method != http.MethodGet {
+            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            return
+        }
+
+        format := r.URL.Query().Get("format")
+        if format == "" {
+            format = results.FormatJSON
+        }
+        if format != results.FormatJSON && format != results.FormatCSV && format != results.FormatHTML {
+            http.Error(w, "format must be json, csv or html", http.StatusBadRequest)
+            return
+        }
+
+        rec, err := reportRecord(cfg, r.URL.Query().Get("id"))
+        if err != nil {
+            code := http.StatusBadRequest
+            if errors.Is(err, fs.ErrNotExist) {
+                code = http.StatusNotFound
+            }
+            http.Error(w, err.Error(), code)
+            return
+        }
+
+        w.Header().Set("Content-Type", results.ContentType(format))
+        w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="report-%s.%s"`, rec.ID, format))
+        if err := results.Write(w, rec, format); err != nil {
+            fmt.Printf("Error writing report: %v\n", err)
+        }
+    }
+}
+
+// registerHistoryRoutes adds the run history page, its API and report downloads to the default mux.
+func registerHistoryRoutes(cfg *config.Config) {
+    http.HandleFunc("/history", historyPageHandler)
+    http.HandleFunc("/api/runs", runsHandler(cfg))
+    http.HandleFunc("/api/runs/", runRecordHandler(cfg))
+    http.HandleFunc("/api/report", reportHandler(cfg))
+}
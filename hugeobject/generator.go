@@ -0,0 +1,49 @@
+// hugeobject/generator.go
+package hugeobject
+
+import "encoding/binary"
+
+// pattern is deterministic pseudo-random object content addressed by offset, so any byte range
+// of an object of any size can be produced on demand without storing it. The same seed always
+// yields the same bytes, and the content does not compress or deduplicate.
+type pattern struct {
+    seed uint64
+}
+
+// ReadAt fills b with the content starting at offset off. It never fails; the pattern is endless.
+func (p pattern) ReadAt(b []byte, off int64) (int, error) {
+    n := len(b)
+    pos := uint64(off)
+
+    // Leading bytes up to the next word boundary.
+    for len(b) > 0 && pos%8 != 0 {
+        var word [8]byte
+        binary.LittleEndian.PutUint64(word[:], p.word(pos/8))
+        b[0] = word[pos%8]
+        b = b[1:]
+        pos++
+    }
+
+    // Whole words.
+    for len(b) >= 8 {
+        binary.LittleEndian.PutUint64(b, p.word(pos/8))
+        b = b[8:]
+        pos += 8
+    }
+
+    // Trailing bytes.
+    if len(b) > 0 {
+        var word [8]byte
+        binary.LittleEndian.PutUint64(word[:], p.word(pos/8))
+        copy(b, word[:])
+    }
+    return n, nil
+}
+
+// word returns the i-th 8-byte word of the content, using the splitmix64 mixing function.
+func (p pattern) word(i uint64) uint64 {
+    z := p.seed + (i+1)*0x9e3779b97f4a7c15
+    z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+    z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+    return z ^ (z >> 31)
+}
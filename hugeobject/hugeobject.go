@@ -0,0 +1,309 @@
+// hugeobject/hugeobject.go
+package hugeobject
+
+import (
+    "fmt"
+    "io"
+    "math"
+    "path"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+)
+
+// ObjectResult is the outcome of uploading one huge object.
+type ObjectResult struct {
+    Key      string
+    Size     int64
+    Parts    int
+    Duration time.Duration
+    Err      error
+}
+
+// PartResult is the measurement of one uploaded part.
+type PartResult struct {
+    Size     int64
+    Duration time.Duration // Time of the successful attempt.
+}
+
+// Result holds the outcome of the huge-object workload.
+type Result struct {
+    RunID       string
+    Objects     []ObjectResult
+    PartSize    int64
+    Parts       []PartResult // Every part that was uploaded, across all objects.
+    PartRetries int          // Part attempts that failed and were retried.
+    Duration    time.Duration
+}
+
+// Bytes returns the total size of the objects that were uploaded completely.
+func (r Result) Bytes() int64 {
+    var total int64
+    for _, o := range r.Objects {
+        if o.Err == nil {
+            total += o.Size
+        }
+    }
+    return total
+}
+
+// Run uploads HugeObjectCount objects of HugeObjectSize bytes one after another. Each object is
+// a multipart upload whose parts are generated on the fly and sent HugeObjectConcurrency at a time,
+// so objects far larger than the local disk or memory can be written.
+func Run(cfg *config.Config, s3Clients []*s3.S3) (Result, error) {
+    if cfg.HugeObjectSize <= 0 {
+        return Result{}, fmt.Errorf("hugeObjectSize must be set to the size of each object in bytes")
+    }
+
+    result := Result{RunID: cfg.NewRunID(), PartSize: cfg.HugeObjectPartSize}
+    start := time.Now()
+
+    partCount := int((cfg.HugeObjectSize + cfg.HugeObjectPartSize - 1) / cfg.HugeObjectPartSize)
+    fmt.Printf("Uploading %d objects of %s in %d parts of %s, %d parts at a time...\n",
+        cfg.HugeObjectCount, formatBytes(cfg.HugeObjectSize), partCount, formatBytes(cfg.HugeObjectPartSize), cfg.HugeObjectConcurrency)
+
+    task := progress.Begin("Uploading huge object parts", "parts", int64(cfg.HugeObjectCount*partCount))
+    for i := 0; i < cfg.HugeObjectCount; i++ {
+        if monitor.RunContext().Err() != nil {
+            break
+        }
+        key := path.Join(cfg.HugeObjectPrefix, result.RunID, fmt.Sprintf("HUGE_%d", i))
+        client := s3Clients[i%len(s3Clients)]
+
+        objectStart := time.Now()
+        parts, retries, err := uploadObject(cfg, s3Clients, client, key, pattern{seed: uint64(i)}, task)
+        object := ObjectResult{Key: key, Size: cfg.HugeObjectSize, Parts: partCount, Duration: time.Since(objectStart), Err: err}
+
+        result.Objects = append(result.Objects, object)
+        result.Parts = append(result.Parts, parts...)
+        result.PartRetries += retries
+        if err != nil {
+            progress.Printf("Error uploading %s: %v\n", key, err)
+            continue
+        }
+        progress.Printf("Uploaded %s in %v (%.2f MB/sec)\n", key, object.Duration.Round(time.Millisecond), throughput(object.Size, object.Duration))
+    }
+    task.Done()
+
+    result.Duration = time.Since(start)
+    return result, nil
+}
+
+// uploadObject uploads one object as a multipart upload created on client. Parts are sent
+// through the next client in round-robin order when SpreadPartsAcrossEndpoints is set.
+// It returns the measurements of the uploaded parts and the number of retried attempts.
+func uploadObject(cfg *config.Config, s3Clients []*s3.S3, client *s3.S3, key string, content pattern, task *progress.Task) ([]PartResult, int, error) {
+    ctx, cancel := cfg.OperationContextFrom(monitor.RunContext(), config.OperationPut)
+    created, err := client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+        Bucket: aws.String(cfg.BucketName),
+        Key:    aws.String(key),
+    })
+    cancel()
+    if err != nil {
+        return nil, 0, fmt.Errorf("error creating multipart upload: %w", err)
+    }
+    uploadID := created.UploadId
+
+    partSize := cfg.HugeObjectPartSize
+    partCount := int((cfg.HugeObjectSize + partSize - 1) / partSize)
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var firstErr error
+    var retries int
+    parts := make([]PartResult, 0, partCount)
+    completed := make([]*s3.CompletedPart, 0, partCount)
+    semaphore := make(chan struct{}, cfg.HugeObjectConcurrency)
+
+    for partNumber := 1; partNumber <= partCount; partNumber++ {
+        mu.Lock()
+        failed := firstErr != nil
+        mu.Unlock()
+        if failed {
+            break
+        }
+        if err := monitor.RunContext().Err(); err != nil {
+            mu.Lock()
+            firstErr = fmt.Errorf("run cancelled before part %d: %w", partNumber, err)
+            mu.Unlock()
+            break
+        }
+
+        partClient := client
+        if cfg.SpreadPartsAcrossEndpoints {
+            partClient = s3Clients[partNumber%len(s3Clients)]
+        }
+
+        wg.Add(1)
+        semaphore <- struct{}{}
+        go func(partNumber int, partClient *s3.S3) {
+            defer wg.Done()
+            defer func() { <-semaphore }()
+
+            offset := int64(partNumber-1) * partSize
+            length := partSize
+            if offset+length > cfg.HugeObjectSize {
+                length = cfg.HugeObjectSize - offset
+            }
+
+            etag, duration, attempts, err := uploadPart(cfg, partClient, key, uploadID, int64(partNumber), io.NewSectionReader(content, offset, length))
+
+            mu.Lock()
+            defer mu.Unlock()
+            retries += attempts - 1
+            if err != nil {
+                task.Fail(1)
+                if firstErr == nil {
+                    firstErr = err
+                }
+                return
+            }
+            task.Add(1)
+            parts = append(parts, PartResult{Size: length, Duration: duration})
+            completed = append(completed, &s3.CompletedPart{
+                ETag:       etag,
+                PartNumber: aws.Int64(int64(partNumber)),
+            })
+        }(partNumber, partClient)
+    }
+
+    wg.Wait()
+
+    if firstErr != nil {
+        client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+            Bucket:   aws.String(cfg.BucketName),
+            Key:      aws.String(key),
+            UploadId: uploadID,
+        })
+        return parts, retries, firstErr
+    }
+
+    sort.Slice(completed, func(i, j int) bool { return *completed[i].PartNumber < *completed[j].PartNumber })
+
+    ctx, cancel = cfg.OperationContext(config.OperationPut)
+    defer cancel()
+
+    _, err = client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+        Bucket:          aws.String(cfg.BucketName),
+        Key:             aws.String(key),
+        UploadId:        uploadID,
+        MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+    })
+    if err != nil {
+        return parts, retries, fmt.Errorf("error completing multipart upload: %w", err)
+    }
+    return parts, retries, nil
+}
+
+// uploadPart uploads one part, retrying up to MaxRetries times with exponential backoff until
+// the run is cancelled. It returns the ETag, the duration of the successful attempt and the
+// number of attempts made.
+func uploadPart(cfg *config.Config, client *s3.S3, key string, uploadID *string, partNumber int64, body *io.SectionReader) (*string, time.Duration, int, error) {
+    var err error
+    attempt := 1
+    for ; ; attempt++ {
+        if attempt > 1 {
+            if _, seekErr := body.Seek(0, io.SeekStart); seekErr != nil {
+                return nil, 0, attempt - 1, fmt.Errorf("error rewinding part %d: %w", partNumber, seekErr)
+            }
+        }
+
+        ctx, cancel := cfg.OperationContextFrom(monitor.RunContext(), config.OperationPut)
+        start := time.Now()
+        var output *s3.UploadPartOutput
+        output, err = client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+            Bucket:        aws.String(cfg.BucketName),
+            Key:           aws.String(key),
+            UploadId:      uploadID,
+            PartNumber:    aws.Int64(partNumber),
+            Body:          body,
+            ContentLength: aws.Int64(body.Size()),
+        })
+        duration := time.Since(start)
+        cancel()
+        if err == nil {
+            return output.ETag, duration, attempt, nil
+        }
+        // Exponential backoff before retrying, cut short when the run is cancelled.
+        if attempt >= cfg.MaxRetries || !monitor.SleepRun(time.Duration(math.Pow(2, float64(attempt-1)))*time.Second) {
+            break
+        }
+    }
+    return nil, 0, attempt, fmt.Errorf("error uploading part %d after %d attempts: %w", partNumber, attempt, err)
+}
+
+// PrintReport prints the per-object, per-part and aggregate throughput of the workload.
+func PrintReport(r Result) {
+    fmt.Println("\nHuge Object Upload Report:")
+    fmt.Println("==========================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+
+    failed := 0
+    fmt.Printf("%-40s %12s %8s %12s %12s\n", "Object", "Size", "Parts", "Duration", "MB/sec")
+    for _, o := range r.Objects {
+        if o.Err != nil {
+            failed++
+            fmt.Printf("%-40s %12s %8d %12s %12s\n", o.Key, formatBytes(o.Size), o.Parts, "-", "FAILED")
+            continue
+        }
+        fmt.Printf("%-40s %12s %8d %12v %12.2f\n", o.Key, formatBytes(o.Size), o.Parts, o.Duration.Round(time.Millisecond), throughput(o.Size, o.Duration))
+    }
+
+    fmt.Printf("\nObjects Uploaded: %d\n", len(r.Objects)-failed)
+    fmt.Printf("Objects Failed: %d\n", failed)
+    fmt.Printf("Data Written: %s\n", formatBytes(r.Bytes()))
+    fmt.Printf("Duration: %v\n", r.Duration.Round(time.Millisecond))
+    fmt.Printf("Aggregate Throughput: %.2f MB/sec\n", throughput(r.Bytes(), r.Duration))
+
+    if len(r.Parts) > 0 {
+        rates := make([]float64, len(r.Parts))
+        var total time.Duration
+        for i, p := range r.Parts {
+            rates[i] = throughput(p.Size, p.Duration)
+            total += p.Duration
+        }
+        sort.Float64s(rates)
+        percentile := func(p float64) float64 {
+            return rates[int(float64(len(rates)-1)*p)]
+        }
+
+        fmt.Printf("\nPart Throughput (%d parts of %s):\n", len(r.Parts), formatBytes(r.PartSize))
+        fmt.Printf("Min: %.2f MB/sec\n", rates[0])
+        fmt.Printf("P10: %.2f MB/sec\n", percentile(0.10))
+        fmt.Printf("P50: %.2f MB/sec\n", percentile(0.50))
+        fmt.Printf("P90: %.2f MB/sec\n", percentile(0.90))
+        fmt.Printf("Max: %.2f MB/sec\n", rates[len(rates)-1])
+        fmt.Printf("Avg Part Time: %v\n", (total / time.Duration(len(r.Parts))).Round(time.Millisecond))
+    }
+    fmt.Printf("Part Retries: %d\n", r.PartRetries)
+    fmt.Println("==========================")
+}
+
+// throughput returns bytes per duration in MB/sec.
+func throughput(bytes int64, d time.Duration) float64 {
+    if d <= 0 {
+        return 0
+    }
+    return float64(bytes) / (1024 * 1024) / d.Seconds()
+}
+
+// formatBytes formats a byte count with a binary unit.
+func formatBytes(n int64) string {
+    const unit = 1024
+    if n < unit {
+        return fmt.Sprintf("%d B", n)
+    }
+    div, exp := int64(unit), 0
+    for m := n / unit; m >= unit; m /= unit {
+        div *= unit
+        exp++
+    }
+    return fmt.Sprintf("%.2f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,117 @@
+// internal/stats/stats.go
+package stats
+
+import (
+    "fmt"
+    "math"
+    "time"
+)
+
+// MaxPrintedErrors is the number of errors a command prints before it only counts them.
+const MaxPrintedErrors = 10
+
+// Latency histogram buckets grow by bucketGrowth from bucketBase, covering 100µs to roughly
+// 13 minutes.
+const (
+    bucketBase   = 100 * time.Microsecond
+    bucketGrowth = 1 + Resolution
+
+    // Resolution is the relative width of a histogram bucket: a percentile is reported at most
+    // this fraction above the true value, so smaller changes cannot be told apart.
+    Resolution = 0.01
+
+    // BucketCount is the number of buckets of a Histogram.
+    BucketCount = 1600
+)
+
+// Histogram counts latencies in exponentially growing buckets, so percentiles of any number of
+// requests are kept in fixed memory.
+type Histogram [BucketCount]int64
+
+// Add records one latency.
+func (h *Histogram) Add(d time.Duration) {
+    b := 0
+    if d > bucketBase {
+        b = int(math.Ceil(math.Log(float64(d)/float64(bucketBase)) / math.Log(bucketGrowth)))
+        if b >= BucketCount {
+            b = BucketCount - 1
+        }
+    }
+    h[b]++
+}
+
+// Merge adds the counts of another histogram.
+func (h *Histogram) Merge(other Histogram) {
+    for b, n := range other {
+        h[b] += n
+    }
+}
+
+// Percentile returns the upper bound of the bucket holding the p-th fraction (0-1) of the
+// latencies, or 0 when none were recorded.
+func (h *Histogram) Percentile(p float64) time.Duration {
+    var count int64
+    for _, n := range h {
+        count += n
+    }
+    target := int64(math.Ceil(float64(count) * p))
+    var seen int64
+    for b, n := range h {
+        seen += n
+        if n > 0 && seen >= target {
+            return time.Duration(float64(bucketBase) * math.Pow(bucketGrowth, float64(b))).Round(time.Microsecond)
+        }
+    }
+    return 0
+}
+
+// Latencies summarizes request times in fixed memory: their count, total and slowest time,
+// and a Histogram for percentiles. The zero value is ready to use.
+type Latencies struct {
+    Count     int64
+    Total     time.Duration
+    Max       time.Duration
+    Histogram Histogram
+}
+
+// Add records one request time.
+func (l *Latencies) Add(d time.Duration) {
+    l.Count++
+    l.Total += d
+    if d > l.Max {
+        l.Max = d
+    }
+    l.Histogram.Add(d)
+}
+
+// Merge adds the request times of another summary.
+func (l *Latencies) Merge(other Latencies) {
+    l.Count += other.Count
+    l.Total += other.Total
+    if other.Max > l.Max {
+        l.Max = other.Max
+    }
+    l.Histogram.Merge(other.Histogram)
+}
+
+// Average returns the mean request time, or 0 when none were recorded.
+func (l *Latencies) Average() time.Duration {
+    if l.Count == 0 {
+        return 0
+    }
+    return l.Total / time.Duration(l.Count)
+}
+
+// Percentile returns the p-th fraction (0-1) of the request times, to the resolution of the
+// histogram and never above the slowest one, or 0 when none were recorded.
+func (l *Latencies) Percentile(p float64) time.Duration {
+    if d := l.Histogram.Percentile(p); d < l.Max {
+        return d
+    }
+    return l.Max
+}
+
+// Milliseconds formats a duration as fractional milliseconds for CSV reports.
+func Milliseconds(d time.Duration) string {
+    return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,425 @@
+// jobs.go
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "math/rand"
+    "net/http"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/results"
+)
+
+// Job states besides the phase a finished run ended in (completed, aborted, truncated or failed).
+const (
+    jobQueued   = "queued"
+    jobRunning  = "running"
+    jobCanceled = "canceled"
+)
+
+// errQueueFull is returned when a job is submitted while daemonQueueLimit jobs are waiting.
+var errQueueFull = errors.New("the job queue is full")
+
+// job is a benchmark run submitted to the daemon, as returned by the jobs API.
+type job struct {
+    ID          string     `json:"ID"`
+    Name        string     `json:"Name,omitempty"`
+    Status      string     `json:"Status"`
+    RunID       string     `json:"RunID"`
+    SubmittedAt time.Time  `json:"SubmittedAt"`
+    StartedAt   *time.Time `json:"StartedAt,omitempty"`
+    FinishedAt  *time.Time `json:"FinishedAt,omitempty"`
+    Position    int        `json:"Position,omitempty"`  // Place in the queue while queued, 1 = next.
+    RecordID    string     `json:"RecordID,omitempty"`  // Run record in resultsDir, as served by GET /api/runs/<id>.
+    ReportURL   string     `json:"ReportURL,omitempty"` // HTML report of the run.
+    Error       string     `json:"Error,omitempty"`
+}
+
+// storedJob is a job as saved in the jobs directory, with the configuration it runs.
+type storedJob struct {
+    job
+    Spec json.RawMessage `json:"Spec"`
+
+    canceled bool // Set by cancelJob while the job is running; the run is aborted once it starts.
+}
+
+// jobQueue holds the jobs of the daemon in submission order. A single worker runs the queued
+// ones one at a time.
+var jobQueue struct {
+    sync.Mutex
+    cond       *sync.Cond
+    dir        string // resultsDir/jobs, where every job is saved as <id>.json.
+    resultsDir string
+    limit      int
+    jobs       []*storedJob
+}
+
+// startJobQueue loads the jobs saved by an earlier daemon and starts the worker. Queued jobs
+// are run again; a job that was running when the daemon stopped is marked failed.
+func startJobQueue(cfg *config.Config) error {
+    jobQueue.Lock()
+    defer jobQueue.Unlock()
+
+    jobQueue.cond = sync.NewCond(&jobQueue.Mutex)
+    jobQueue.dir = filepath.Join(cfg.ResultsDir, "jobs")
+    jobQueue.resultsDir = cfg.ResultsDir
+    jobQueue.limit = cfg.DaemonQueueLimit
+    if err := os.MkdirAll(jobQueue.dir, 0700); err != nil {
+        return fmt.Errorf("error creating jobs directory %s: %w", jobQueue.dir, err)
+    }
+
+    entries, err := os.ReadDir(jobQueue.dir)
+    if err != nil {
+        return fmt.Errorf("error reading jobs directory %s: %w", jobQueue.dir, err)
+    }
+    for _, entry := range entries {
+        if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+            continue
+        }
+        path := filepath.Join(jobQueue.dir, entry.Name())
+        data, err := os.ReadFile(path)
+        if err != nil {
+            fmt.Printf("Skipping stored job: %v\n", err)
+            continue
+        }
+        var j storedJob
+        if err := json.Unmarshal(data, &j); err != nil {
+            fmt.Printf("Skipping stored job %s: %v\n", path, err)
+            continue
+        }
+        if j.Status == jobRunning {
+            now := time.Now()
+            j.Status = monitor.PhaseFailed
+            j.FinishedAt = &now
+            j.Error = "the daemon stopped while the job was running"
+            saveJob(&j)
+        }
+        jobQueue.jobs = append(jobQueue.jobs, &j)
+    }
+    sort.SliceStable(jobQueue.jobs, func(i, k int) bool {
+        return jobQueue.jobs[i].SubmittedAt.Before(jobQueue.jobs[k].SubmittedAt)
+    })
+
+    if queued := len(queuedJobs()); queued > 0 {
+        fmt.Printf("Resuming %d queued jobs\n", queued)
+    }
+    go runJobs()
+    return nil
+}
+
+// saveJob writes a job to the jobs directory. The caller holds the jobQueue lock.
+func saveJob(j *storedJob) {
+    data, err := json.MarshalIndent(j, "", "  ")
+    if err != nil {
+        fmt.Printf("Error encoding job %s: %v\n", j.ID, err)
+        return
+    }
+    // The configuration may hold credentials, so the file is only readable by its owner.
+    path := filepath.Join(jobQueue.dir, j.ID+".json")
+    tmp := path + ".tmp"
+    if err := os.WriteFile(tmp, data, 0600); err != nil {
+        fmt.Printf("Error saving job %s: %v\n", j.ID, err)
+        return
+    }
+    if err := os.Rename(tmp, path); err != nil {
+        fmt.Printf("Error saving job %s: %v\n", j.ID, err)
+    }
+}
+
+// queuedJobs returns the jobs waiting to run, next first. The caller holds the jobQueue lock.
+func queuedJobs() []*storedJob {
+    var queued []*storedJob
+    for _, j := range jobQueue.jobs {
+        if j.Status == jobQueued {
+            queued = append(queued, j)
+        }
+    }
+    return queued
+}
+
+// findJob returns the job with the given ID, or nil. The caller holds the jobQueue lock.
+func findJob(id string) *storedJob {
+    for _, j := range jobQueue.jobs {
+        if j.ID == id {
+            return j
+        }
+    }
+    return nil
+}
+
+// jobView returns a copy of a job for an API response, with its queue position filled in.
+// The caller holds the jobQueue lock.
+func jobView(j *storedJob) job {
+    view := j.job
+    if j.Status == jobQueued {
+        for i, q := range queuedJobs() {
+            if q == j {
+                view.Position = i + 1
+            }
+        }
+    }
+    return view
+}
+
+// submitJob queues a run of a posted configuration, which ParseConfig has already accepted.
+func submitJob(name string, spec []byte, runID string) (job, error) {
+    jobQueue.Lock()
+    defer jobQueue.Unlock()
+
+    if len(queuedJobs()) >= jobQueue.limit {
+        return job{}, errQueueFull
+    }
+
+    id := fmt.Sprintf("%s-%04x", time.Now().Format("20060102-150405"), rand.Intn(1<<16))
+    for findJob(id) != nil {
+        id = fmt.Sprintf("%s-%04x", time.Now().Format("20060102-150405"), rand.Intn(1<<16))
+    }
+    // Without a runID of its own, the run is tagged with the job ID.
+    if runID == "" {
+        runID = id
+    }
+    j := &storedJob{
+        job:  job{ID: id, Name: name, Status: jobQueued, RunID: runID, SubmittedAt: time.Now()},
+        Spec: spec,
+    }
+    jobQueue.jobs = append(jobQueue.jobs, j)
+    saveJob(j)
+    jobQueue.cond.Signal()
+    return jobView(j), nil
+}
+
+// cancelJob removes a queued job from the queue or stops a running one. Finished jobs cannot
+// be canceled.
+func cancelJob(id string) (job, int, error) {
+    jobQueue.Lock()
+    defer jobQueue.Unlock()
+
+    j := findJob(id)
+    if j == nil {
+        return job{}, http.StatusNotFound, fmt.Errorf("job %q not found", id)
+    }
+    switch j.Status {
+    case jobQueued:
+        now := time.Now()
+        j.Status = jobCanceled
+        j.FinishedAt = &now
+        saveJob(j)
+    case jobRunning:
+        // The run stops gracefully and the job ends as aborted, with a partial report. A run that
+        // has not started yet is aborted by runJob as soon as it does.
+        j.canceled = true
+        if monitor.RunID() == j.RunID && runActive(monitor.Phase()) {
+            monitor.Abort(jobCancelReason(j))
+        }
+    default:
+        return jobView(j), http.StatusConflict, fmt.Errorf("job %q already finished as %s", id, j.Status)
+    }
+    return jobView(j), http.StatusOK, nil
+}
+
+// jobCancelReason is the abort reason of a run stopped by canceling its job.
+func jobCancelReason(j *storedJob) string {
+    return fmt.Sprintf("job %s canceled via jobs API", j.ID)
+}
+
+// runActive reports whether a run in the given phase is still in progress.
+func runActive(phase string) bool {
+    switch phase {
+    case monitor.PhaseIdle, monitor.PhaseCompleted, monitor.PhaseAborted, monitor.PhaseTruncated, monitor.PhaseFailed:
+        return false
+    }
+    return true
+}
+
+// claimRunSlot waits until no run started through the control API is in progress and claims
+// the slot for a job.
+func claimRunSlot() {
+    for {
+        activeRun.Lock()
+        if !activeRun.running {
+            activeRun.running = true
+            activeRun.Unlock()
+            return
+        }
+        activeRun.Unlock()
+        time.Sleep(time.Second)
+    }
+}
+
+// releaseRunSlot frees the slot claimed by claimRunSlot.
+func releaseRunSlot() {
+    activeRun.Lock()
+    activeRun.running = false
+    activeRun.Unlock()
+}
+
+// runJobs is the worker of the job queue: it runs the queued jobs one at a time, in the order
+// they were submitted. A job is only marked running once it holds the run slot, so canceling
+// it never touches a run started through the control API.
+func runJobs() {
+    for {
+        jobQueue.Lock()
+        for len(queuedJobs()) == 0 {
+            jobQueue.cond.Wait()
+        }
+        jobQueue.Unlock()
+
+        claimRunSlot()
+
+        // The job waited for may have been canceled in the meantime.
+        jobQueue.Lock()
+        queued := queuedJobs()
+        if len(queued) == 0 {
+            jobQueue.Unlock()
+            releaseRunSlot()
+            continue
+        }
+        j := queued[0]
+        now := time.Now()
+        j.Status = jobRunning
+        j.StartedAt = &now
+        saveJob(j)
+        spec, runID := j.Spec, j.RunID
+        jobQueue.Unlock()
+
+        fmt.Printf("Starting job %s (run %s)\n", j.ID, runID)
+        status, recordID, err := runJob(j, spec, runID)
+        releaseRunSlot()
+
+        jobQueue.Lock()
+        finished := time.Now()
+        j.Status = status
+        j.FinishedAt = &finished
+        if err != nil {
+            j.Error = err.Error()
+        }
+        if recordID != "" {
+            j.RecordID = recordID
+            j.ReportURL = "/api/report?format=html&id=" + recordID
+        }
+        saveJob(j)
+        jobQueue.Unlock()
+        fmt.Printf("Job %s finished: %s\n", j.ID, status)
+    }
+}
+
+// runJob runs the benchmark of a job, whose worker holds the run slot, and returns the phase
+// the run ended in and the ID of its run record.
+func runJob(j *storedJob, spec []byte, runID string) (string, string, error) {
+    cfg, err := config.ParseConfig(spec)
+    if err != nil {
+        return monitor.PhaseFailed, "", err
+    }
+    if err := createOutputDirs(cfg); err != nil {
+        return monitor.PhaseFailed, "", fmt.Errorf("error preparing output directories: %w", err)
+    }
+    // Reports are stored with the daemon's own, so they show up in its run history.
+    cfg.ResultsDir = jobQueue.resultsDir
+    cfg.RunID = runID
+
+    // ResetRun clears any abort that arrived before the run started, so a job canceled while
+    // its run was starting is aborted once the run has entered its first phase.
+    removeHook := monitor.OnPhaseChange(func(from, to string) {
+        if to != monitor.PhasePreparing {
+            return
+        }
+        jobQueue.Lock()
+        canceled := j.canceled
+        jobQueue.Unlock()
+        if canceled {
+            monitor.Abort(jobCancelReason(j))
+        }
+    })
+    defer removeHook()
+
+    runErr := runBenchmark(cfg)
+    status := monitor.Phase()
+    if runErr != nil {
+        status = monitor.PhaseFailed
+    }
+    // A run that failed before it started leaves the start time of the previous run behind.
+    recordID := results.RecordID(monitor.GetStats().StartTime)
+    if rec, err := results.Load(cfg.ResultsDir, recordID); err != nil || rec.RunID != runID {
+        recordID = ""
+    }
+    return status, recordID, runErr
+}
+
+// jobsHandler queues a job from a posted configuration (POST) or lists every job (GET).
+// The optional name query parameter labels a posted job.
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+    switch r.Method {
+    case http.MethodGet:
+        jobQueue.Lock()
+        views := make([]job, 0, len(jobQueue.jobs))
+        for _, j := range jobQueue.jobs {
+            views = append(views, jobView(j))
+        }
+        jobQueue.Unlock()
+        writeJSON(w, http.StatusOK, views)
+    case http.MethodPost:
+        body, err := io.ReadAll(r.Body)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        cfg, err := config.ParseConfig(body)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        j, err := submitJob(r.URL.Query().Get("name"), body, cfg.RunID)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusServiceUnavailable)
+            return
+        }
+        writeJSON(w, http.StatusAccepted, j)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// jobHandler returns one job (GET) or cancels it (DELETE).
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+    id := strings.TrimPrefix(r.URL.Path, "/api/jobs/")
+    switch r.Method {
+    case http.MethodGet:
+        jobQueue.Lock()
+        j := findJob(id)
+        var view job
+        if j != nil {
+            view = jobView(j)
+        }
+        jobQueue.Unlock()
+        if j == nil {
+            http.Error(w, fmt.Sprintf("job %q not found", id), http.StatusNotFound)
+            return
+        }
+        writeJSON(w, http.StatusOK, view)
+    case http.MethodDelete:
+        view, code, err := cancelJob(id)
+        if err != nil {
+            http.Error(w, err.Error(), code)
+            return
+        }
+        writeJSON(w, code, view)
+    default:
+        http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+    }
+}
+
+// registerJobRoutes adds the jobs API of the daemon command to the default mux.
+func registerJobRoutes() {
+    http.HandleFunc("/api/jobs", jobsHandler)
+    http.HandleFunc("/api/jobs/", jobHandler)
+}
@@ -0,0 +1,244 @@
+// keystore/keystore.go
+package keystore
+
+import (
+    "bufio"
+    "encoding/binary"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+)
+
+// Store is an append-only list of S3 keys with bounded memory use. The first
+// memoryLimit keys are kept in memory; later keys spill to a data file of
+// length-prefixed records, with a separate index file of 8-byte record offsets
+// so any key can be read back by position without holding the index in RAM.
+// It is safe for concurrent use.
+type Store struct {
+    mu          sync.Mutex
+    memoryLimit int
+    memory      []string
+
+    dir         string
+    dataFile    *os.File
+    indexFile   *os.File
+    dataWriter  *bufio.Writer
+    indexWriter *bufio.Writer
+    dataSize    int64
+    spilled     int64
+    dirty       bool
+}
+
+// New creates a store that keeps up to memoryLimit keys in memory and spills the
+// rest to files under dir. A memoryLimit of zero or less keeps every key in memory.
+func New(dir string, memoryLimit int) *Store {
+    return &Store{dir: dir, memoryLimit: memoryLimit}
+}
+
+// Add appends a key to the store.
+func (s *Store) Add(key string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    return s.add(key)
+}
+
+// AddBatch appends several keys to the store under a single lock.
+func (s *Store) AddBatch(keys []string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    for _, key := range keys {
+        if err := s.add(key); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// add appends a key to the store. The caller must hold s.mu.
+func (s *Store) add(key string) error {
+    if s.memoryLimit <= 0 || len(s.memory) < s.memoryLimit {
+        s.memory = append(s.memory, key)
+        return nil
+    }
+
+    if s.dataFile == nil {
+        if err := s.openSpillFiles(); err != nil {
+            return err
+        }
+    }
+
+    var offset [8]byte
+    binary.LittleEndian.PutUint64(offset[:], uint64(s.dataSize))
+    if _, err := s.indexWriter.Write(offset[:]); err != nil {
+        return fmt.Errorf("error writing key index: %w", err)
+    }
+
+    var length [binary.MaxVarintLen64]byte
+    n := binary.PutUvarint(length[:], uint64(len(key)))
+    if _, err := s.dataWriter.Write(length[:n]); err != nil {
+        return fmt.Errorf("error writing key data: %w", err)
+    }
+    if _, err := s.dataWriter.WriteString(key); err != nil {
+        return fmt.Errorf("error writing key data: %w", err)
+    }
+
+    s.dataSize += int64(n + len(key))
+    s.spilled++
+    s.dirty = true
+    return nil
+}
+
+// openSpillFiles creates the data and index files. The caller must hold s.mu.
+func (s *Store) openSpillFiles() error {
+    if err := os.MkdirAll(s.dir, os.ModePerm); err != nil {
+        return fmt.Errorf("error creating key store directory %s: %w", s.dir, err)
+    }
+
+    dataFile, err := os.CreateTemp(s.dir, "keys-*.dat")
+    if err != nil {
+        return fmt.Errorf("error creating key data file: %w", err)
+    }
+    indexFile, err := os.Create(filepath.Join(s.dir, filepath.Base(dataFile.Name())+".idx"))
+    if err != nil {
+        dataFile.Close()
+        os.Remove(dataFile.Name())
+        return fmt.Errorf("error creating key index file: %w", err)
+    }
+
+    s.dataFile, s.indexFile = dataFile, indexFile
+    s.dataWriter = bufio.NewWriterSize(dataFile, 1<<20)
+    s.indexWriter = bufio.NewWriterSize(indexFile, 1<<16)
+    return nil
+}
+
+// Len returns the number of keys in the store.
+func (s *Store) Len() int {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return len(s.memory) + int(s.spilled)
+}
+
+// Get returns the key at position i. A spilled key is read from disk without holding s.mu,
+// so concurrent readers do not wait on each other's disk reads.
+func (s *Store) Get(i int) (string, error) {
+    s.mu.Lock()
+    if i < 0 || i >= len(s.memory)+int(s.spilled) {
+        s.mu.Unlock()
+        return "", fmt.Errorf("key index %d out of range", i)
+    }
+    if i < len(s.memory) {
+        key := s.memory[i]
+        s.mu.Unlock()
+        return key, nil
+    }
+    if s.dirty {
+        if err := s.flush(); err != nil {
+            s.mu.Unlock()
+            return "", err
+        }
+    }
+    // Records are only appended, so the flushed record at position i stays put while later
+    // keys are written, and os.File.ReadAt is safe alongside those writes.
+    indexFile, dataFile := s.indexFile, s.dataFile
+    position := int64(i - len(s.memory))
+    s.mu.Unlock()
+
+    return readSpilled(indexFile, dataFile, position)
+}
+
+// readSpilled reads the spilled key at position in the index file from the data file.
+func readSpilled(indexFile, dataFile *os.File, position int64) (string, error) {
+    var offsetBuf [8]byte
+    if _, err := indexFile.ReadAt(offsetBuf[:], position*8); err != nil {
+        return "", fmt.Errorf("error reading key index: %w", err)
+    }
+    offset := int64(binary.LittleEndian.Uint64(offsetBuf[:]))
+
+    var header [binary.MaxVarintLen64]byte
+    n, _ := dataFile.ReadAt(header[:], offset)
+    length, read := binary.Uvarint(header[:n])
+    if read <= 0 {
+        return "", fmt.Errorf("corrupt key record at offset %d", offset)
+    }
+
+    key := make([]byte, length)
+    if _, err := dataFile.ReadAt(key, offset+int64(read)); err != nil {
+        return "", fmt.Errorf("error reading key data: %w", err)
+    }
+    return string(key), nil
+}
+
+// Sorted returns a new store with the keys of s in lexical order, with the directory and
+// memory limit of s. The keys are sorted in runs of at most the memory limit, which are
+// spilled to files under the store directory and merged, so sorting holds no more keys in
+// memory than the store itself does.
+func (s *Store) Sorted() (*Store, error) {
+    n := s.Len()
+    runSize := s.memoryLimit
+    if runSize <= 0 || runSize > n {
+        runSize = n
+    }
+
+    var runs []*sortRun
+    defer func() {
+        for _, run := range runs {
+            run.close()
+        }
+    }()
+    for start := 0; start < n; start += runSize {
+        end := min(start+runSize, n)
+        keys := make([]string, 0, end-start)
+        for i := start; i < end; i++ {
+            key, err := s.Get(i)
+            if err != nil {
+                return nil, err
+            }
+            keys = append(keys, key)
+        }
+        sort.Strings(keys)
+        run, err := writeSortRun(s.dir, keys)
+        if err != nil {
+            return nil, err
+        }
+        runs = append(runs, run)
+    }
+
+    sorted := New(s.dir, s.memoryLimit)
+    if err := mergeSortRuns(runs, sorted); err != nil {
+        sorted.Close()
+        return nil, err
+    }
+    return sorted, nil
+}
+
+// flush writes buffered spill records to disk. The caller must hold s.mu.
+func (s *Store) flush() error {
+    if err := s.dataWriter.Flush(); err != nil {
+        return fmt.Errorf("error flushing key data: %w", err)
+    }
+    if err := s.indexWriter.Flush(); err != nil {
+        return fmt.Errorf("error flushing key index: %w", err)
+    }
+    s.dirty = false
+    return nil
+}
+
+// Close releases the spill files and removes them from disk.
+func (s *Store) Close() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    if s.dataFile == nil {
+        return nil
+    }
+    s.dataFile.Close()
+    s.indexFile.Close()
+    os.Remove(s.dataFile.Name())
+    os.Remove(s.indexFile.Name())
+    s.dataFile, s.indexFile = nil, nil
+    return nil
+}
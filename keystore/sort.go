@@ -0,0 +1,126 @@
+// keystore/sort.go
+package keystore
+
+import (
+    "bufio"
+    "container/heap"
+    "encoding/binary"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+)
+
+// sortRun is a file of sorted keys written by Sorted, read back one key at a time while the
+// runs are merged.
+type sortRun struct {
+    file   *os.File
+    reader *bufio.Reader
+    key    string // The next key of the run.
+}
+
+// writeSortRun writes sorted keys as length-prefixed records to a temporary file under dir and
+// returns the run positioned at its first key.
+func writeSortRun(dir string, keys []string) (*sortRun, error) {
+    if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+        return nil, fmt.Errorf("error creating key store directory %s: %w", dir, err)
+    }
+    file, err := os.CreateTemp(dir, "sort-*.dat")
+    if err != nil {
+        return nil, fmt.Errorf("error creating key sort file: %w", err)
+    }
+    run := &sortRun{file: file}
+
+    writer := bufio.NewWriterSize(file, 1<<20)
+    var length [binary.MaxVarintLen64]byte
+    for _, key := range keys {
+        n := binary.PutUvarint(length[:], uint64(len(key)))
+        if _, err := writer.Write(length[:n]); err != nil {
+            run.close()
+            return nil, fmt.Errorf("error writing key sort file: %w", err)
+        }
+        if _, err := writer.WriteString(key); err != nil {
+            run.close()
+            return nil, fmt.Errorf("error writing key sort file: %w", err)
+        }
+    }
+    if err := writer.Flush(); err != nil {
+        run.close()
+        return nil, fmt.Errorf("error writing key sort file: %w", err)
+    }
+    if _, err := file.Seek(0, io.SeekStart); err != nil {
+        run.close()
+        return nil, fmt.Errorf("error reading key sort file: %w", err)
+    }
+    run.reader = bufio.NewReaderSize(file, 1<<16)
+    return run, nil
+}
+
+// next reads the next key of the run into run.key. It returns false at the end of the run.
+func (run *sortRun) next() (bool, error) {
+    length, err := binary.ReadUvarint(run.reader)
+    if errors.Is(err, io.EOF) {
+        return false, nil
+    }
+    if err != nil {
+        return false, fmt.Errorf("error reading key sort file: %w", err)
+    }
+    key := make([]byte, length)
+    if _, err := io.ReadFull(run.reader, key); err != nil {
+        return false, fmt.Errorf("error reading key sort file: %w", err)
+    }
+    run.key = string(key)
+    return true, nil
+}
+
+// close closes the run and removes its file.
+func (run *sortRun) close() {
+    run.file.Close()
+    os.Remove(run.file.Name())
+}
+
+// runHeap orders runs by their next key.
+type runHeap []*sortRun
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].key < h[j].key }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*sortRun)) }
+func (h *runHeap) Pop() any {
+    old := *h
+    run := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return run
+}
+
+// mergeSortRuns adds the keys of every run to dst in lexical order.
+func mergeSortRuns(runs []*sortRun, dst *Store) error {
+    h := make(runHeap, 0, len(runs))
+    for _, run := range runs {
+        ok, err := run.next()
+        if err != nil {
+            return err
+        }
+        if ok {
+            h = append(h, run)
+        }
+    }
+    heap.Init(&h)
+
+    for h.Len() > 0 {
+        run := h[0]
+        if err := dst.Add(run.key); err != nil {
+            return err
+        }
+        ok, err := run.next()
+        if err != nil {
+            return err
+        }
+        if ok {
+            heap.Fix(&h, 0)
+        } else {
+            heap.Pop(&h)
+        }
+    }
+    return nil
+}
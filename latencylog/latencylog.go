@@ -0,0 +1,229 @@
+// latencylog/latencylog.go
+package latencylog
+
+import (
+    "bufio"
+    "compress/gzip"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/logrotate"
+)
+
+// Log formats accepted by Open.
+const (
+    FormatJSONL = config.LatencyLogJSONL // One JSON object per line.
+    FormatCSV   = config.LatencyLogCSV   // One row per entry after a header, latencies in microseconds.
+)
+
+// Entry is a single operation: an upload attempt or a benchmark operation.
+type Entry struct {
+    Time      time.Time     `json:"Time"` // When the operation started.
+    RunID     string        `json:"RunID"`
+    Operation string        `json:"Operation"` // "PUT" for uploads, else the benchmark operation type.
+    Endpoint  string        `json:"Endpoint"`
+    Key       string        `json:"Key"`
+    Size      int64         `json:"Size"`    // Bytes uploaded, or response body bytes read.
+    Latency   time.Duration `json:"Latency"` // In nanoseconds, as measured for the report.
+    Failed    bool          `json:"Failed"`
+}
+
+// Writer appends entries to a log file, gzip-compressed if the path ends in ".gz", and
+// rotates it between entries according to its rotation policy. It is safe for concurrent use.
+type Writer struct {
+    mu       sync.Mutex
+    path     string
+    format   string
+    rotation logrotate.Policy
+    opened   time.Time
+    size     int64 // Bytes in the log, counted before compression for new entries.
+    err      error // First error; the log stops taking entries once set.
+    file     *os.File
+    gz       *gzip.Writer
+    buf      *bufio.Writer
+    out      io.Writer // buf, counting the bytes written to the log.
+    csv      *csv.Writer
+}
+
+// countingWriter adds the length of every write to n.
+type countingWriter struct {
+    w io.Writer
+    n *int64
+}
+
+// Write passes p to the underlying writer and counts it.
+func (c countingWriter) Write(p []byte) (int, error) {
+    n, err := c.w.Write(p)
+    *c.n += int64(n)
+    return n, err
+}
+
+var (
+    current     *Writer
+    currentLock sync.RWMutex
+)
+
+// Open starts logging to path, appending to an existing log. Entries are added with Add until Close.
+func Open(path, format string, rotation logrotate.Policy) error {
+    if format != FormatJSONL && format != FormatCSV {
+        return fmt.Errorf("unknown latency log format %q", format)
+    }
+
+    w := &Writer{path: path, format: format, rotation: rotation}
+    if err := w.open(); err != nil {
+        return err
+    }
+
+    currentLock.Lock()
+    previous := current
+    current = w
+    currentLock.Unlock()
+    if previous != nil {
+        previous.close()
+    }
+    return nil
+}
+
+// open opens the log file for appending, writing the CSV header to a new file.
+func (w *Writer) open() error {
+    file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return fmt.Errorf("error opening latency log %s: %w", w.path, err)
+    }
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return fmt.Errorf("error reading latency log info %s: %w", w.path, err)
+    }
+
+    // A gzip stream appended to another is read back as their concatenation.
+    w.file, w.gz = file, nil
+    w.opened, w.size = time.Now(), info.Size()
+    var out io.Writer = file
+    if strings.HasSuffix(w.path, ".gz") {
+        w.gz = gzip.NewWriter(file)
+        out = w.gz
+    }
+    w.buf = bufio.NewWriterSize(out, 256*1024)
+    w.out = countingWriter{w: w.buf, n: &w.size}
+    w.csv = nil
+    if w.format == FormatCSV {
+        w.csv = csv.NewWriter(w.out)
+        if info.Size() == 0 {
+            w.csv.Write([]string{"Time", "RunID", "Operation", "Endpoint", "Key", "Size", "LatencyUs", "Failed"})
+        }
+    }
+    return nil
+}
+
+// Add appends an entry to the open log. Without an open log it does nothing. Write errors are
+// reported by Close.
+func Add(e Entry) {
+    currentLock.RLock()
+    defer currentLock.RUnlock()
+    if current == nil {
+        return
+    }
+    current.add(e)
+}
+
+// add writes one entry in the log format, rotating the log first if it is due.
+func (w *Writer) add(e Entry) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.err != nil {
+        return
+    }
+    if w.rotation.Due(w.size, w.opened) {
+        w.err = w.closeFile()
+        if w.err == nil {
+            _, w.err = w.rotation.Rotate(w.path)
+        }
+        if w.err == nil {
+            w.err = w.open()
+        }
+        if w.err != nil {
+            w.file = nil
+            return
+        }
+    }
+
+    if w.csv != nil {
+        w.csv.Write([]string{
+            e.Time.Format(time.RFC3339Nano),
+            e.RunID,
+            e.Operation,
+            e.Endpoint,
+            e.Key,
+            strconv.FormatInt(e.Size, 10),
+            strconv.FormatInt(e.Latency.Microseconds(), 10),
+            strconv.FormatBool(e.Failed),
+        })
+        return
+    }
+    line, err := json.Marshal(e)
+    if err != nil {
+        return
+    }
+    w.out.Write(append(line, '\n'))
+}
+
+// Close flushes and closes the open log. It is safe to call without an open log.
+func Close() error {
+    currentLock.Lock()
+    w := current
+    current = nil
+    currentLock.Unlock()
+
+    if w == nil {
+        return nil
+    }
+    return w.close()
+}
+
+// close closes the log and returns the first error it ran into.
+func (w *Writer) close() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.file != nil {
+        if err := w.closeFile(); w.err == nil {
+            w.err = err
+        }
+        w.file = nil
+    }
+    if w.err != nil {
+        return fmt.Errorf("error writing latency log: %w", w.err)
+    }
+    return nil
+}
+
+// closeFile flushes buffered entries and the compressor, then closes the file.
+func (w *Writer) closeFile() error {
+    var firstErr error
+    if w.csv != nil {
+        w.csv.Flush()
+        firstErr = w.csv.Error()
+    }
+    if err := w.buf.Flush(); err != nil && firstErr == nil {
+        firstErr = err
+    }
+    if w.gz != nil {
+        if err := w.gz.Close(); err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    if err := w.file.Close(); err != nil && firstErr == nil {
+        firstErr = err
+    }
+    return firstErr
+}
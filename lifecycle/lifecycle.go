@@ -0,0 +1,476 @@
+// lifecycle/lifecycle.go
+package lifecycle
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "math/rand"
+    "net/url"
+    "os"
+    "path"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/cleanup"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+)
+
+// LagBuckets are the upper bounds of the lag histogram; the last bucket holds the rest.
+var LagBuckets = []time.Duration{0, time.Hour, 6 * time.Hour, 24 * time.Hour, 48 * time.Hour}
+
+// Object is one uploaded object and when it expired.
+type Object struct {
+    Key          string
+    LastModified time.Time // As reported by the listing after the upload.
+    Due          time.Time // When the rule should expire the object.
+    Gone         time.Time // First check at which the object was missing; zero while it is present.
+}
+
+// Lag returns how long after it was due the object was first seen missing. It is negative for
+// objects that expired early.
+func (o Object) Lag() time.Duration {
+    return o.Gone.Sub(o.Due)
+}
+
+// Check is the outcome of one listing of the objects.
+type Check struct {
+    Time      time.Time
+    Remaining int
+    Err       error
+}
+
+// Result is the outcome of a lifecycle observation.
+type Result struct {
+    RunID         string
+    Prefix        string
+    RuleID        string // Rule added to the bucket; empty without lifecycleCreateRule.
+    RuleError     error  // Error removing the rule at the end.
+    Objects       []Object
+    UploadFailed  int64
+    Checks        []Check
+    CheckInterval time.Duration
+    Aborted       bool // The observation was stopped before every object expired or the lag limit.
+    Deleted       int64
+    DeleteFailed  int64
+    DeleteError   error
+}
+
+// Expired returns the objects that were seen to expire, sorted by lag.
+func (r Result) Expired() []Object {
+    var expired []Object
+    for _, o := range r.Objects {
+        if !o.Gone.IsZero() {
+            expired = append(expired, o)
+        }
+    }
+    sort.Slice(expired, func(i, j int) bool { return expired[i].Lag() < expired[j].Lag() })
+    return expired
+}
+
+// Remaining returns the number of objects that had not expired when the observation ended.
+func (r Result) Remaining() int {
+    return len(r.Objects) - len(r.Expired())
+}
+
+// Failures returns the failed uploads, listings and deletes and the objects that never expired.
+func (r Result) Failures() int64 {
+    failures := r.UploadFailed + r.DeleteFailed + int64(r.Remaining())
+    for _, c := range r.Checks {
+        if c.Err != nil {
+            failures++
+        }
+    }
+    if r.RuleError != nil {
+        failures++
+    }
+    if r.DeleteError != nil {
+        failures++
+    }
+    return failures
+}
+
+// dueTime returns when an object modified at lastModified expires under a rule of days. Like S3,
+// the expiration is rounded up to the next midnight UTC.
+func dueTime(lastModified time.Time, days int) time.Time {
+    t := lastModified.UTC().Add(time.Duration(days) * 24 * time.Hour)
+    midnight := t.Truncate(24 * time.Hour)
+    if midnight.Before(t) {
+        midnight = midnight.Add(24 * time.Hour)
+    }
+    return midnight
+}
+
+// Run uploads lifecycleObjects objects with lifecycleTags below <lifecyclePrefix>/<runID>/LIFECYCLE/
+// and lists them every lifecycleCheckSeconds until all are gone, until lifecycleMaxLagHours after
+// the last one was due, or until the run is aborted. With lifecycleCreateRule a matching rule is
+// added to the bucket for the duration of the run.
+func Run(cfg *config.Config, s3Clients []*s3.S3) (result Result, err error) {
+    runID := cfg.NewRunID()
+    result = Result{RunID: runID, Prefix: path.Join(cfg.LifecyclePrefix, runID, "LIFECYCLE"), CheckInterval: time.Duration(cfg.LifecycleCheckSeconds) * time.Second}
+
+    if cfg.LifecycleCreateRule {
+        result.RuleID = "s3-benchmark-" + runID
+        if err := addRule(cfg, s3Clients[0], result.RuleID, result.Prefix+"/"); err != nil {
+            return result, err
+        }
+        fmt.Printf("Added lifecycle rule %s expiring %s/ after %d days\n", result.RuleID, result.Prefix, cfg.LifecycleExpirationDays)
+        // The rule is removed however the run ends, so it never outlives the run.
+        defer func() {
+            result.RuleError = removeRule(cfg, s3Clients[0], result.RuleID)
+        }()
+    }
+
+    result.UploadFailed = upload(cfg, s3Clients, result.Prefix)
+
+    present, err := list(cfg, s3Clients[0], result.Prefix)
+    if err != nil {
+        return result, err
+    }
+    for key, lastModified := range present {
+        result.Objects = append(result.Objects, Object{Key: key, LastModified: lastModified, Due: dueTime(lastModified, cfg.LifecycleExpirationDays)})
+    }
+    sort.Slice(result.Objects, func(i, j int) bool { return result.Objects[i].Key < result.Objects[j].Key })
+    if len(result.Objects) == 0 {
+        return result, fmt.Errorf("no object was stored below %s/", result.Prefix)
+    }
+
+    observe(cfg, s3Clients[0], &result)
+
+    if cfg.LifecycleDeleteRemaining && result.Remaining() > 0 {
+        fmt.Println("\nDeleting the objects that did not expire...")
+        lifecycleCfg := *cfg
+        lifecycleCfg.S3Folder = cfg.LifecyclePrefix
+        deleted, err := cleanup.DeleteRun(&lifecycleCfg, s3Clients[0], runID)
+        result.Deleted, result.DeleteFailed, result.DeleteError = deleted.Deleted, deleted.Failed, err
+    }
+    return result, nil
+}
+
+// upload puts the objects with lifecycleConcurrency workers and returns how many failed.
+func upload(cfg *config.Config, s3Clients []*s3.S3, prefix string) int64 {
+    payload := make([]byte, cfg.LifecycleObjectSize)
+    rand.Read(payload)
+
+    tags := url.Values{}
+    for k, v := range cfg.LifecycleTags {
+        tags.Set(k, v)
+    }
+    tagging := tags.Encode()
+
+    task := progress.Begin("Uploading objects", "objects", int64(cfg.LifecycleObjects))
+    defer task.Done()
+
+    var wg sync.WaitGroup
+    var next, failed, printedErrors int64
+    for w := 0; w < cfg.LifecycleConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := atomic.AddInt64(&next, 1) - 1
+                if i >= int64(cfg.LifecycleObjects) {
+                    return
+                }
+
+                key := fmt.Sprintf("%s/%08d", prefix, i)
+                input := &s3.PutObjectInput{
+                    Bucket:        aws.String(cfg.BucketName),
+                    Key:           aws.String(key),
+                    Body:          bytes.NewReader(payload),
+                    ContentLength: aws.Int64(int64(len(payload))),
+                }
+                if tagging != "" {
+                    input.Tagging = aws.String(tagging)
+                }
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                _, err := client.PutObjectWithContext(ctx, input)
+                cancel()
+                if err != nil {
+                    atomic.AddInt64(&failed, 1)
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error uploading %s: %v\n", key, err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further upload errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    return failed
+}
+
+// list returns the last modification time of every object below prefix.
+func list(cfg *config.Config, s3Client *s3.S3, prefix string) (map[string]time.Time, error) {
+    present := make(map[string]time.Time)
+    input := &s3.ListObjectsV2Input{
+        Bucket: aws.String(cfg.BucketName),
+        Prefix: aws.String(prefix + "/"),
+    }
+    for {
+        // Each page request gets its own LIST deadline.
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := s3Client.ListObjectsV2WithContext(ctx, input)
+        cancel()
+        if err != nil {
+            return nil, fmt.Errorf("error listing %s/: %w", prefix, err)
+        }
+        for _, obj := range page.Contents {
+            present[aws.StringValue(obj.Key)] = aws.TimeValue(obj.LastModified)
+        }
+        if !aws.BoolValue(page.IsTruncated) {
+            return present, nil
+        }
+        input.ContinuationToken = page.NextContinuationToken
+    }
+}
+
+// observe lists the objects every lifecycleCheckSeconds and records when each one disappears.
+func observe(cfg *config.Config, s3Client *s3.S3, result *Result) {
+    var lastDue time.Time
+    for _, o := range result.Objects {
+        if o.Due.After(lastDue) {
+            lastDue = o.Due
+        }
+    }
+    deadline := lastDue.Add(time.Duration(cfg.LifecycleMaxLagHours) * time.Hour)
+    fmt.Printf("\nObserving %d objects due between %s and %s; checking every %ds until %s\n",
+        len(result.Objects), result.Objects[0].Due.Format(time.RFC3339), lastDue.Format(time.RFC3339),
+        cfg.LifecycleCheckSeconds, deadline.Format(time.RFC3339))
+
+    task := progress.Begin("Waiting for expiration", "objects", int64(len(result.Objects)))
+    defer task.Done()
+
+    ticker := time.NewTicker(result.CheckInterval)
+    defer ticker.Stop()
+    remaining := len(result.Objects)
+    for {
+        select {
+        case <-monitor.RunContext().Done():
+            result.Aborted = true
+            return
+        case <-ticker.C:
+        }
+
+        check := Check{Time: time.Now()}
+        present, err := list(cfg, s3Client, result.Prefix)
+        if err != nil {
+            check.Err = err
+            check.Remaining = remaining
+            progress.Printf("%v\n", err)
+        } else {
+            for i := range result.Objects {
+                o := &result.Objects[i]
+                if _, ok := present[o.Key]; !ok && o.Gone.IsZero() {
+                    o.Gone = check.Time
+                    remaining--
+                    task.Add(1)
+                }
+            }
+            check.Remaining = remaining
+        }
+        result.Checks = append(result.Checks, check)
+
+        if remaining == 0 {
+            return
+        }
+        if check.Time.After(deadline) {
+            progress.Printf("%d objects still present %dh after the last one was due; giving up.\n", remaining, cfg.LifecycleMaxLagHours)
+            return
+        }
+    }
+}
+
+// addRule adds a rule expiring the objects below prefix, with lifecycleTags, to the lifecycle
+// configuration of the bucket. The existing rules are kept.
+func addRule(cfg *config.Config, s3Client *s3.S3, ruleID, prefix string) error {
+    rules, err := getRules(cfg, s3Client)
+    if err != nil {
+        return err
+    }
+
+    filter := &s3.LifecycleRuleFilter{Prefix: aws.String(prefix)}
+    if len(cfg.LifecycleTags) > 0 {
+        and := &s3.LifecycleRuleAndOperator{Prefix: aws.String(prefix)}
+        for k, v := range cfg.LifecycleTags {
+            and.Tags = append(and.Tags, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+        }
+        filter = &s3.LifecycleRuleFilter{And: and}
+    }
+    rules = append(rules, &s3.LifecycleRule{
+        ID:         aws.String(ruleID),
+        Status:     aws.String(s3.ExpirationStatusEnabled),
+        Filter:     filter,
+        Expiration: &s3.LifecycleExpiration{Days: aws.Int64(int64(cfg.LifecycleExpirationDays))},
+    })
+
+    _, err = s3Client.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+        Bucket:                 aws.String(cfg.BucketName),
+        LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: rules},
+    })
+    if err != nil {
+        return fmt.Errorf("error adding lifecycle rule to bucket %s: %w", cfg.BucketName, err)
+    }
+    return nil
+}
+
+// removeRule removes the rule added by addRule and keeps the other rules of the bucket.
+func removeRule(cfg *config.Config, s3Client *s3.S3, ruleID string) error {
+    rules, err := getRules(cfg, s3Client)
+    if err != nil {
+        return err
+    }
+
+    var kept []*s3.LifecycleRule
+    for _, rule := range rules {
+        if aws.StringValue(rule.ID) != ruleID {
+            kept = append(kept, rule)
+        }
+    }
+    if len(kept) == len(rules) {
+        return nil
+    }
+
+    // A lifecycle configuration must hold at least one rule.
+    if len(kept) == 0 {
+        _, err = s3Client.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{Bucket: aws.String(cfg.BucketName)})
+    } else {
+        _, err = s3Client.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+            Bucket:                 aws.String(cfg.BucketName),
+            LifecycleConfiguration: &s3.BucketLifecycleConfiguration{Rules: kept},
+        })
+    }
+    if err != nil {
+        return fmt.Errorf("error removing lifecycle rule %s from bucket %s: %w", ruleID, cfg.BucketName, err)
+    }
+    return nil
+}
+
+// getRules returns the lifecycle rules of the bucket, none if it has no lifecycle configuration.
+func getRules(cfg *config.Config, s3Client *s3.S3) ([]*s3.LifecycleRule, error) {
+    output, err := s3Client.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{Bucket: aws.String(cfg.BucketName)})
+    if err != nil {
+        if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchLifecycleConfiguration" {
+            return nil, nil
+        }
+        return nil, fmt.Errorf("error getting lifecycle configuration of bucket %s: %w", cfg.BucketName, err)
+    }
+    return output.Rules, nil
+}
+
+// lagPercentile returns the p-th fraction (0-1) of the lags of objects sorted by lag.
+func lagPercentile(sorted []Object, p float64) time.Duration {
+    if len(sorted) == 0 {
+        return 0
+    }
+    i := int(float64(len(sorted))*p+0.5) - 1
+    if i < 0 {
+        i = 0
+    }
+    if i >= len(sorted) {
+        i = len(sorted) - 1
+    }
+    return sorted[i].Lag()
+}
+
+// PrintReport prints how many objects expired and the distribution of their expiration lag.
+func PrintReport(r Result) {
+    fmt.Println("\nLifecycle Expiration Report:")
+    fmt.Println("============================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Prefix: %s/\n", r.Prefix)
+    if r.RuleID != "" {
+        fmt.Printf("Rule: %s\n", r.RuleID)
+    }
+    fmt.Printf("Objects Observed: %d\n", len(r.Objects))
+    fmt.Printf("Failed Uploads: %d\n", r.UploadFailed)
+    fmt.Printf("Checks: %d (every %v)\n", len(r.Checks), r.CheckInterval)
+
+    expired := r.Expired()
+    fmt.Printf("Expired: %d\n", len(expired))
+    fmt.Printf("Not Expired: %d\n", r.Remaining())
+    if r.Aborted {
+        fmt.Println("The observation was stopped before it completed.")
+    }
+
+    if len(expired) > 0 {
+        fmt.Printf("\nExpiration Lag (after the due time, to within %v):\n", r.CheckInterval)
+        fmt.Printf("  Min: %v\n", expired[0].Lag().Round(time.Second))
+        fmt.Printf("  P50: %v\n", lagPercentile(expired, 0.50).Round(time.Second))
+        fmt.Printf("  P90: %v\n", lagPercentile(expired, 0.90).Round(time.Second))
+        fmt.Printf("  P99: %v\n", lagPercentile(expired, 0.99).Round(time.Second))
+        fmt.Printf("  Max: %v\n", expired[len(expired)-1].Lag().Round(time.Second))
+
+        counts := make([]int, len(LagBuckets)+1)
+        for _, o := range expired {
+            b := sort.Search(len(LagBuckets), func(i int) bool { return o.Lag() <= LagBuckets[i] })
+            counts[b]++
+        }
+        fmt.Printf("\n%-16s %10s %8s\n", "Lag", "Objects", "Share")
+        for b, count := range counts {
+            var label string
+            switch {
+            case b == 0:
+                label = "early or on time"
+            case b == len(LagBuckets):
+                label = fmt.Sprintf("> %gh", LagBuckets[b-1].Hours())
+            default:
+                label = fmt.Sprintf("<= %gh", LagBuckets[b].Hours())
+            }
+            fmt.Printf("%-16s %10d %7.1f%%\n", label, count, float64(count)*100/float64(len(expired)))
+        }
+    }
+
+    if r.RuleError != nil {
+        fmt.Printf("\nRule Error: %v\n", r.RuleError)
+    }
+    if r.Deleted > 0 || r.DeleteFailed > 0 || r.DeleteError != nil {
+        fmt.Printf("\nObjects Deleted: %d\n", r.Deleted)
+        fmt.Printf("Objects Not Deleted: %d\n", r.DeleteFailed)
+        if r.DeleteError != nil {
+            fmt.Printf("Delete Error: %v\n", r.DeleteError)
+        }
+    }
+    fmt.Println("============================")
+}
+
+// WriteCSV writes one row per object with its due time and when it was seen to expire.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating lifecycle report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Key", "LastModified", "Due", "Gone", "LagSeconds"})
+    for _, o := range r.Objects {
+        gone, lag := "", ""
+        if !o.Gone.IsZero() {
+            gone = o.Gone.UTC().Format(time.RFC3339)
+            lag = fmt.Sprintf("%.0f", o.Lag().Seconds())
+        }
+        writer.Write([]string{o.Key, o.LastModified.UTC().Format(time.RFC3339), o.Due.Format(time.RFC3339), gone, lag})
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing lifecycle report %s: %w", filePath, err)
+    }
+    return nil
+}
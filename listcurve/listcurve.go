@@ -0,0 +1,292 @@
+// listcurve/listcurve.go
+package listcurve
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "math/rand"
+    "os"
+    "path"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/cleanup"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// Checkpoint is the outcome of the listings at one prefix size.
+type Checkpoint struct {
+    Objects       int64           // Objects uploaded into the prefix so far.
+    Listed        int64           // Keys returned by the last complete listing.
+    Pages         int64           // Pages of the last complete listing.
+    ListErrors    int64           // Listings that failed part way.
+    FirstPages    stats.Latencies // Time of the first page of every listing.
+    PageLatencies stats.Latencies // Time of every page.
+    Listings      stats.Latencies // Time of every complete listing.
+}
+
+// PageP50 returns the median page latency.
+func (c *Checkpoint) PageP50() time.Duration {
+    return c.PageLatencies.Percentile(0.50)
+}
+
+// PageP99 returns the 99th percentile page latency.
+func (c *Checkpoint) PageP99() time.Duration {
+    return c.PageLatencies.Percentile(0.99)
+}
+
+// FirstPage returns the average time of the first page of a listing.
+func (c *Checkpoint) FirstPage() time.Duration {
+    return c.FirstPages.Average()
+}
+
+// Listing returns the average time of a complete listing.
+func (c *Checkpoint) Listing() time.Duration {
+    return c.Listings.Average()
+}
+
+// KeysPerSecond returns the keys listed per second by a complete listing.
+func (c *Checkpoint) KeysPerSecond() float64 {
+    if listing := c.Listing(); listing > 0 {
+        return float64(c.Listed) / listing.Seconds()
+    }
+    return 0
+}
+
+// Result is the outcome of a listcurve run.
+type Result struct {
+    RunID        string
+    Prefix       string
+    Checkpoints  []Checkpoint
+    UploadFailed int64 // Uploads that failed; the checkpoint sizes count only the stored objects.
+    Deleted      int64
+    DeleteFailed int64
+    DeleteError  error
+    StoppedEarly bool // Every upload of a step failed, so the remaining checkpoints were skipped.
+}
+
+// Failures returns the failed uploads, listings and deletes.
+func (r Result) Failures() int64 {
+    failures := r.UploadFailed + r.DeleteFailed
+    for _, c := range r.Checkpoints {
+        failures += c.ListErrors
+    }
+    if r.DeleteError != nil {
+        failures++
+    }
+    return failures
+}
+
+// Run uploads ListCurveObjects objects into a single prefix, listCurveStep at a time, and after
+// each step times listCurveListings complete paginated listings of the prefix. Objects are
+// written below <listCurvePrefix>/<runID>/LISTCURVE/.
+func Run(cfg *config.Config, s3Clients []*s3.S3) Result {
+    runID := cfg.NewRunID()
+    result := Result{RunID: runID, Prefix: path.Join(cfg.ListCurvePrefix, runID, "LISTCURVE")}
+
+    payload := make([]byte, cfg.ListCurveObjectSize)
+    rand.Read(payload)
+
+    var stored int64
+    for next := int64(0); next < cfg.ListCurveObjects; {
+        end := min(next+cfg.ListCurveStep, cfg.ListCurveObjects)
+        fmt.Printf("\nUploading objects %d to %d into %s/...\n", next, end-1, result.Prefix)
+        uploaded, failed := upload(cfg, s3Clients, result.Prefix, next, end, payload)
+        stored += uploaded
+        result.UploadFailed += failed
+        next = end
+        if uploaded == 0 {
+            fmt.Println("No object of the step could be uploaded; stopping.")
+            result.StoppedEarly = true
+            break
+        }
+
+        fmt.Printf("Listing %d objects %d times...\n", stored, cfg.ListCurveListings)
+        result.Checkpoints = append(result.Checkpoints, list(cfg, s3Clients[0], result.Prefix, stored))
+    }
+
+    if cfg.ListCurveDeleteObjects {
+        fmt.Println("\nDeleting the objects of the run...")
+        curveCfg := *cfg
+        curveCfg.S3Folder = cfg.ListCurvePrefix
+        deleted, err := cleanup.DeleteRun(&curveCfg, s3Clients[0], runID)
+        result.Deleted, result.DeleteFailed, result.DeleteError = deleted.Deleted, deleted.Failed, err
+    }
+    return result
+}
+
+// upload puts the objects with the numbers from to end-1 with listCurveConcurrency workers and
+// returns how many were uploaded and how many failed.
+func upload(cfg *config.Config, s3Clients []*s3.S3, prefix string, from, end int64, payload []byte) (uploaded, failed int64) {
+    task := progress.Begin("Filling prefix", "objects", end-from)
+    defer task.Done()
+
+    var wg sync.WaitGroup
+    var printedErrors int64
+    next := from
+    for w := 0; w < cfg.ListCurveConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := atomic.AddInt64(&next, 1) - 1
+                if i >= end {
+                    return
+                }
+
+                // Zero-padded numbers keep the keys in upload order.
+                key := fmt.Sprintf("%s/%012d", prefix, i)
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                _, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+                    Bucket:        aws.String(cfg.BucketName),
+                    Key:           aws.String(key),
+                    Body:          bytes.NewReader(payload),
+                    ContentLength: aws.Int64(int64(len(payload))),
+                })
+                cancel()
+                if err != nil {
+                    atomic.AddInt64(&failed, 1)
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error uploading %s: %v\n", key, err)
+                    }
+                    continue
+                }
+                atomic.AddInt64(&uploaded, 1)
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further upload errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    return uploaded, failed
+}
+
+// list times listCurveListings complete listings of the prefix, one after the other, so the
+// page latencies are not skewed by concurrent requests.
+func list(cfg *config.Config, s3Client *s3.S3, prefix string, objects int64) Checkpoint {
+    c := Checkpoint{Objects: objects}
+    for n := 0; n < cfg.ListCurveListings; n++ {
+        input := &s3.ListObjectsV2Input{
+            Bucket:  aws.String(cfg.BucketName),
+            Prefix:  aws.String(prefix + "/"),
+            MaxKeys: aws.Int64(int64(cfg.ListCurvePageSize)),
+        }
+
+        var listed, pages int64
+        var failed bool
+        start := time.Now()
+        for {
+            // Each page request gets its own LIST deadline.
+            ctx, cancel := cfg.OperationContext(config.OperationList)
+            pageStart := time.Now()
+            page, err := s3Client.ListObjectsV2WithContext(ctx, input)
+            latency := time.Since(pageStart)
+            cancel()
+            if err != nil {
+                fmt.Printf("Error listing %s/ after %d pages: %v\n", prefix, pages, err)
+                failed = true
+                break
+            }
+
+            if pages == 0 {
+                c.FirstPages.Add(latency)
+            }
+            c.PageLatencies.Add(latency)
+            pages++
+            listed += int64(len(page.Contents))
+
+            if !aws.BoolValue(page.IsTruncated) {
+                break
+            }
+            input.ContinuationToken = page.NextContinuationToken
+        }
+
+        if failed {
+            c.ListErrors++
+            continue
+        }
+        c.Listings.Add(time.Since(start))
+        c.Listed, c.Pages = listed, pages
+    }
+    return c
+}
+
+// PrintReport prints the listing latencies at every checkpoint and how the page latency grew
+// from the first to the last checkpoint.
+func PrintReport(r Result) {
+    fmt.Println("\nLIST Latency vs Prefix Size Report:")
+    fmt.Println("===================================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Prefix: %s/\n", r.Prefix)
+    fmt.Printf("Failed Uploads: %d\n\n", r.UploadFailed)
+
+    fmt.Printf("%12s %12s %8s %12s %12s %12s %14s %12s %8s\n", "Objects", "Listed", "Pages", "First Page", "Page P50", "Page P99", "Full Listing", "Keys/sec", "Errors")
+    for _, c := range r.Checkpoints {
+        fmt.Printf("%12d %12d %8d %12v %12v %12v %14v %12.0f %8d\n", c.Objects, c.Listed, c.Pages,
+            c.FirstPage().Round(time.Microsecond), c.PageP50().Round(time.Microsecond), c.PageP99().Round(time.Microsecond),
+            c.Listing().Round(time.Millisecond), c.KeysPerSecond(), c.ListErrors)
+    }
+
+    if len(r.Checkpoints) > 1 {
+        first, last := r.Checkpoints[0], r.Checkpoints[len(r.Checkpoints)-1]
+        if first.PageP50() > 0 && first.FirstPage() > 0 {
+            fmt.Printf("\nFrom %d to %d objects: page P50 x%.2f, first page x%.2f\n", first.Objects, last.Objects,
+                float64(last.PageP50())/float64(first.PageP50()), float64(last.FirstPage())/float64(first.FirstPage()))
+        }
+    }
+    if r.StoppedEarly {
+        fmt.Println("The run stopped early because no object of a step could be uploaded.")
+    }
+
+    if r.Deleted > 0 || r.DeleteFailed > 0 || r.DeleteError != nil {
+        fmt.Printf("\nObjects Deleted: %d\n", r.Deleted)
+        fmt.Printf("Objects Not Deleted: %d\n", r.DeleteFailed)
+        if r.DeleteError != nil {
+            fmt.Printf("Delete Error: %v\n", r.DeleteError)
+        }
+    }
+    fmt.Println("===================================")
+}
+
+// WriteCSV writes one row per checkpoint, the curve of listing latency over prefix size.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating list curve report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Objects", "Listed", "Pages", "FirstPageMs", "PageP50Ms", "PageP99Ms", "ListingMs", "KeysPerSec", "ListErrors"})
+    for _, c := range r.Checkpoints {
+        writer.Write([]string{
+            strconv.FormatInt(c.Objects, 10),
+            strconv.FormatInt(c.Listed, 10),
+            strconv.FormatInt(c.Pages, 10),
+            stats.Milliseconds(c.FirstPage()),
+            stats.Milliseconds(c.PageP50()),
+            stats.Milliseconds(c.PageP99()),
+            stats.Milliseconds(c.Listing()),
+            fmt.Sprintf("%.0f", c.KeysPerSecond()),
+            strconv.FormatInt(c.ListErrors, 10),
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing list curve report %s: %w", filePath, err)
+    }
+    return nil
+}
@@ -0,0 +1,98 @@
+// logrotate/logrotate.go
+package logrotate
+
+import (
+    "compress/gzip"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+)
+
+// Policy decides when an append-only log is rotated and what happens to the rotated file.
+// The zero Policy never rotates.
+type Policy struct {
+    MaxBytes int64         // Rotate once the log holds this many bytes (0 = no size limit).
+    MaxAge   time.Duration // Rotate once the log was opened this long ago (0 = no age limit).
+    Compress bool          // Gzip rotated logs that are not compressed already.
+}
+
+// Due reports whether a log of the given size, opened at the given time, must be rotated.
+// Logs are written in whole records, so callers check between records.
+func (p Policy) Due(size int64, opened time.Time) bool {
+    if p.MaxBytes > 0 && size >= p.MaxBytes {
+        return true
+    }
+    return p.MaxAge > 0 && time.Since(opened) >= p.MaxAge
+}
+
+// Rotate moves the closed log at path aside, inserting the time before the extensions:
+// "plot/stats_report.csv" becomes "plot/stats_report-20240101-120000.csv". With Compress the
+// rotated file is gzipped in the background and the uncompressed copy removed once done, so
+// an interrupted compression leaves the uncompressed log behind.
+func (p Policy) Rotate(path string) (string, error) {
+    dir, name := filepath.Split(path)
+    base, ext := name, ""
+    if i := strings.Index(name, "."); i > 0 {
+        base, ext = name[:i], name[i:]
+    }
+
+    stamp := time.Now().Format("20060102-150405")
+    rotated := filepath.Join(dir, base+"-"+stamp+ext)
+    for n := 2; fileExists(rotated) || fileExists(rotated+".gz"); n++ {
+        rotated = filepath.Join(dir, fmt.Sprintf("%s-%s-%d%s", base, stamp, n, ext))
+    }
+
+    if err := os.Rename(path, rotated); err != nil {
+        return "", fmt.Errorf("error rotating log %s: %w", path, err)
+    }
+    if p.Compress && !strings.HasSuffix(rotated, ".gz") {
+        go func() {
+            if err := compress(rotated); err != nil {
+                fmt.Printf("Error compressing rotated log: %v\n", err)
+            }
+        }()
+    }
+    return rotated, nil
+}
+
+// compress gzips path to path.gz and removes path.
+func compress(path string) error {
+    in, err := os.Open(path)
+    if err != nil {
+        return fmt.Errorf("error opening %s: %w", path, err)
+    }
+    defer in.Close()
+
+    tmp := path + ".gz.tmp"
+    out, err := os.Create(tmp)
+    if err != nil {
+        return fmt.Errorf("error creating %s: %w", tmp, err)
+    }
+    gz := gzip.NewWriter(out)
+    _, err = io.Copy(gz, in)
+    if closeErr := gz.Close(); err == nil {
+        err = closeErr
+    }
+    if closeErr := out.Close(); err == nil {
+        err = closeErr
+    }
+    if err != nil {
+        os.Remove(tmp)
+        return fmt.Errorf("error compressing %s: %w", path, err)
+    }
+
+    if err := os.Rename(tmp, path+".gz"); err != nil {
+        os.Remove(tmp)
+        return fmt.Errorf("error renaming %s: %w", tmp, err)
+    }
+    return os.Remove(path)
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) bool {
+    _, err := os.Stat(path)
+    return err == nil
+}
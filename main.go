@@ -2,71 +2,288 @@
 package main
 
 import (
+    "flag"
     "fmt"
     "math/rand"
     "os"
+    "path/filepath"
+    "strings"
     "sync"
+    "sync/atomic"
     "syscall"
     "time"
 
+    "github.com/aws/aws-sdk-go/service/s3"
+
     "scale_s3_benchmark/benchmark"
+    "scale_s3_benchmark/cleanup"
     "scale_s3_benchmark/config"
     "scale_s3_benchmark/filegen"
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/latencylog"
+    "scale_s3_benchmark/logrotate"
+    "scale_s3_benchmark/manifest"
     "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/notify"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/results"
     "scale_s3_benchmark/s3upload"
+    "scale_s3_benchmark/storage"
 )
 
 func main() {
-    // Load configuration from config.json.
-    cfg, err := config.LoadConfig("config.json")
+    // Options go before the subcommand, e.g. "--quiet verify manifest.csv".
+    quiet := flag.Bool("quiet", false, "print no progress updates, only the final state of each phase")
+    progressInterval := flag.Duration("progress-interval", 0, "interval between progress updates (default 500ms on a terminal, 10s otherwise)")
+    progressFormat := flag.String("progress-format", progress.ModeAuto, "progress output: auto, text or json")
+    seed := flag.Int64("seed", 0, "fix the randomness of the workload, so runs with the same seed issue the same requests (overrides seed in config.json)")
+    configPath := flag.String("config", envOr(config.EnvPrefix+"CONFIG", "config.json"), "configuration file; not needed when the settings come from S3BENCH_* variables or --set")
+    settings := settingsFlag(config.SettingsFromEnv())
+    flag.Var(settings, "set", "override a setting of the configuration file, as name=value with the JSON name, e.g. --set bucketName=bench; repeatable")
+    flag.Parse()
+
+    mode := *progressFormat
+    if *quiet {
+        mode = progress.ModeQuiet
+    }
+    if err := progress.Configure(mode, *progressInterval); err != nil {
+        fmt.Printf("Error in options: %v\n", err)
+        os.Exit(2)
+    }
+
+    // Load configuration from config.json, overridden by S3BENCH_* variables and --set. In a
+    // container every setting may come from those: an empty --config loads no file, and neither
+    // does a missing config.json that was not asked for.
+    path := *configPath
+    _, explicit := os.LookupEnv(config.EnvPrefix + "CONFIG")
+    if _, err := os.Stat(path); os.IsNotExist(err) && len(settings) > 0 && !explicit && !flagSet("config") {
+        path = ""
+    }
+    cfg, err := config.LoadConfigWithSettings(path, settings)
     if err != nil {
         fmt.Printf("Error loading configuration: %v\n", err)
         os.Exit(1)
     }
+    if *seed != 0 {
+        cfg.Seed = *seed
+    }
 
-    // Initialize statistics.
-    monitor.InitializeStats()
-    monitor.StartPeriodicReporting("plot/stats_report.csv", time.Minute)
-    
-    // Start the web server for the dashboard.
-    //startWebServer()
+    // Keep every local file in workDir, e.g. the emptyDir volume of a pod.
+    if cfg.WorkDir != "" {
+        if err := os.MkdirAll(cfg.WorkDir, os.ModePerm); err != nil {
+            fmt.Printf("Error preparing work directory: %v\n", err)
+            os.Exit(1)
+        }
+        if err := os.Chdir(cfg.WorkDir); err != nil {
+            fmt.Printf("Error changing to work directory: %v\n", err)
+            os.Exit(1)
+        }
+    }
+    // A fresh work directory has none of the directories the outputs are written to.
+    if err := createOutputDirs(cfg); err != nil {
+        fmt.Printf("Error preparing output directories: %v\n", err)
+        os.Exit(1)
+    }
+
+    // Apply the Go runtime tuning before any load is generated.
+    applyRuntimeTuning(cfg)
+
+    // Log failed requests for support tickets to the storage vendor, if configured.
+    monitor.SetErrorLog(cfg.ErrorLogPath, logRotation(cfg))
+
+    // Run a subcommand instead of the benchmark when one is given.
+    if flag.NArg() > 0 {
+        os.Exit(runCommand(cfg, flag.Arg(0), flag.Args()[1:]))
+    }
+
+    // Initialize periodic statistics reporting.
+    monitor.StartPeriodicReporting("plot/stats_report.csv", time.Minute, logRotation(cfg))
+    if cfg.ResourceSampleSeconds > 0 {
+        monitor.StartResourceSampling(time.Duration(cfg.ResourceSampleSeconds) * time.Second)
+    }
+
+    // The run holds the run slot, so the control API cannot start a second run alongside it.
+    activeRun.Lock()
+    activeRun.running = true
+    activeRun.Unlock()
+
+    // Start the web server for the dashboard. A failure is not fatal to the run.
+    if cfg.WebEnabled {
+        if err := startWebServer(cfg); err != nil {
+            fmt.Printf("%v. Continuing without the web server.\n", err)
+        }
+    }
 
     // Seed the random number generator.
     rand.Seed(time.Now().UnixNano())
 
+    if err := runBenchmark(cfg); err != nil {
+        os.Exit(1)
+    }
+}
+
+// runBenchmark executes a complete run: file preparation, uploads, benchmarking and the final report.
+// On a fatal error it writes out whatever artifacts exist and returns the error.
+func runBenchmark(cfg *config.Config) error {
+    // Write out stats, manifest and a partial report if anything below panics.
+    runArtifacts.Lock()
+    runArtifacts.cfg = cfg
+    runArtifacts.manifest = nil
+    runArtifacts.uploader = nil
+    runArtifacts.dumped = false
+    runArtifacts.Unlock()
+    defer recoverAndDump()
+
+    // Every key, manifest entry and report of the run is tagged with its run ID.
+    runID := cfg.NewRunID()
+    fmt.Printf("Run ID: %s\n", runID)
+    if cfg.Seed != 0 {
+        fmt.Printf("Seed: %d\n", cfg.Seed)
+    }
+
+    // Initialize statistics.
+    monitor.ResetRun(runID)
+    monitor.SetPhase(monitor.PhasePreparing)
+    monitor.SetAbortPolicy(monitor.AbortPolicy{
+        ErrorRate:           cfg.AbortErrorRate,
+        Window:              time.Duration(cfg.AbortWindowSeconds) * time.Second,
+        MinOperations:       cfg.AbortMinOperations,
+        ConsecutiveFailures: cfg.AbortConsecutiveFailures,
+        TargetFailures:      cfg.AbortTargetFailures,
+    })
+    runCtx := monitor.RunContext()
+
+    // Tell provisioning pipelines where the run writes, before anything can fail.
+    writeRunDescriptor(cfg, results.DescriptorRunning, "")
+
+    // Cancel whatever is left of the run once its time is up.
+    if cfg.MaxRunDurationSeconds > 0 {
+        limit := time.Duration(cfg.MaxRunDurationSeconds) * time.Second
+        deadline := time.AfterFunc(limit, func() {
+            monitor.Truncate(fmt.Sprintf("maxRunDurationSeconds reached after %v", limit))
+        })
+        defer deadline.Stop()
+    }
+
+    // Profile the tool itself during the configured windows of the run.
+    stopProfiling := startProfiling(cfg, runID)
+    defer stopProfiling()
+
+    // Persist the progress of the run, so what had completed is known even after a crash.
+    stopCheckpoints := startCheckpoints(cfg)
+    defer stopCheckpoints()
+
     // Increase the file descriptor limit to handle many files.
     if err := increaseFileDescriptorLimit(); err != nil {
-        fmt.Printf("Error adjusting file descriptor limits: %v\n", err)
-        return
+        return failRun("Error adjusting file descriptor limits: %v", err)
     }
 
     // Prepare the base directory for generating files.
     if err := filegen.PrepareBaseDirectory(cfg.BaseDirectory); err != nil {
-        fmt.Printf("Error preparing base directory: %v\n", err)
-        return
+        return failRun("Error preparing base directory: %v", err)
     }
 
     // Generate the base set of files (if they don't already exist).
     filegen.GenerateAllBaseFiles(cfg)
 
     // Replicate files locally up to the maximum local files limit only once.
+    monitor.SetPhase(monitor.PhaseReplicating)
     localFiles, err := filegen.ReplicateFilesWithReflinkInParallel(cfg)
     if err != nil {
-        fmt.Printf("Error replicating files with reflink: %v\n", err)
-        return
+        return failRun("Error replicating files with reflink: %v", err)
     }
 
     fmt.Printf("Replication of %d files completed.\n", len(localFiles))
 
-    // Initialize S3 clients.
-    s3Clients, err := s3upload.InitializeS3Clients(cfg)
+    // Initialize the storage backends, one per endpoint.
+    backends, err := s3upload.InitializeBackends(cfg)
     if err != nil {
-        fmt.Printf("Error initializing S3 clients: %v\n", err)
-        return
+        return failRun("Error initializing storage backends: %v", err)
+    }
+
+    // Check the endpoint clocks, since skew makes signed requests fail seemingly at random.
+    if cfg.StorageBackend == config.StorageBackendS3 && cfg.MaxClockSkewSeconds >= 0 {
+        s3Clients := make([]*s3.S3, len(backends))
+        for i, backend := range backends {
+            s3Clients[i] = backend.(*storage.S3Backend).Client
+        }
+        if err := s3upload.CheckClockSkew(cfg, s3Clients); err != nil {
+            return failRun("Error checking clock skew: %v", err)
+        }
+    }
+
+    // Create the bucket if requested and missing. Only allowed with the S3 storage backend.
+    if cfg.CreateBucket {
+        if err := s3upload.EnsureBucket(cfg, backends[0].(*storage.S3Backend).Client); err != nil {
+            return failRun("Error preparing bucket: %v", err)
+        }
     }
 
     // Create an uploader instance.
-    uploader := s3upload.NewUploader(cfg, s3Clients, time.Now())
+    uploader := s3upload.NewUploader(cfg, backends, time.Now(), s3upload.WithRunID(runID))
+
+    // Record every uploaded object so the bucket can be reconciled later with the verify command.
+    uploadManifest, err := manifest.Create(cfg.ManifestPath, cfg.ManifestFormat)
+    if err != nil {
+        return failRun("Error creating manifest: %v", err)
+    }
+    uploader.Manifest = uploadManifest
+
+    // Record every upload that fails for good, so it can be re-driven with the redrive command.
+    uploadFailures, err := manifest.CreateFailures(cfg.FailuresPath)
+    if err != nil {
+        return failRun("Error creating failures file: %v", err)
+    }
+    uploader.Failures = uploadFailures
+
+    // Log the latency of every operation for offline analysis, if configured.
+    if cfg.LatencyLogPath != "" {
+        if err := latencylog.Open(cfg.LatencyLogPath, cfg.LatencyLogFormat, logRotation(cfg)); err != nil {
+            return failRun("Error opening latency log: %v", err)
+        }
+        defer func() {
+            if err := latencylog.Close(); err != nil {
+                fmt.Println(err)
+            }
+        }()
+    }
+
+    // Measure how long each upload takes to reach the replication destination, if configured.
+    if cfg.ReplicationEndpointURL != "" {
+        checker, err := s3upload.NewReplicationChecker(cfg)
+        if err != nil {
+            return failRun("Error creating replication checker: %v", err)
+        }
+        uploader.Replication = checker
+    }
+    runArtifacts.Lock()
+    runArtifacts.manifest = uploadManifest
+    runArtifacts.uploader = uploader
+    runArtifacts.Unlock()
+
+    // Measure the delay of bucket notifications for the uploaded objects, if configured.
+    var stopNotifications func()
+    if cfg.NotificationSource != "" {
+        stopNotifications, err = notify.Start(cfg)
+        if err != nil {
+            return failRun("Error starting notification receiver: %v", err)
+        }
+    }
+
+    monitor.SetPhase(monitor.PhaseUploading)
+    if cfg.TotalDataBytes > 0 {
+        fmt.Printf("Uploading until %s have been written (about %d files)...\n", config.FormatSize(cfg.TotalDataBytes), cfg.TotalFiles)
+        uploader.Progress = progress.Begin("Uploading to S3", "files", 0)
+    } else {
+        uploader.Progress = progress.Begin("Uploading to S3", "files", int64(cfg.TotalFiles))
+    }
+
+    // Read already uploaded objects while the uploads run, if configured.
+    var mixedReads *benchmark.MixedReads
+    if cfg.MixedReadThreads > 0 {
+        fmt.Printf("Reading uploaded objects with %d threads during the uploads...\n", cfg.MixedReadThreads)
+        mixedReads = benchmark.StartMixedReads(cfg, backends[0], uploader.UploadedS3Files)
+    }
 
     totalFilesUploaded := int64(0)
 
@@ -74,68 +291,295 @@ func main() {
     subfolderSemaphore := make(chan struct{}, cfg.MaxConcurrentSubfolders)
     var wg sync.WaitGroup
 
-    for folderIndex := 0; totalFilesUploaded < int64(cfg.TotalFiles); folderIndex++ {
+    // With a data volume goal, folders are started until enough data has been uploaded. A folder
+    // in which every upload failed ends the run instead, since failed bytes are retried elsewhere.
+    var stalled int32
+
+    // Closed once no further folder will be started, so pending pauses end early.
+    allScheduled := make(chan struct{})
+
+    for folderIndex := 0; runCtx.Err() == nil; folderIndex++ {
         filesToProcess := int64(cfg.MaxFilesPerFolder)
-        if int64(cfg.TotalFiles)-totalFilesUploaded < filesToProcess {
-            filesToProcess = int64(cfg.TotalFiles) - totalFilesUploaded
+        if cfg.TotalDataBytes == 0 {
+            if totalFilesUploaded >= int64(cfg.TotalFiles) {
+                break
+            }
+            if int64(cfg.TotalFiles)-totalFilesUploaded < filesToProcess {
+                filesToProcess = int64(cfg.TotalFiles) - totalFilesUploaded
+            }
+        }
+
+        // Acquire a slot in the semaphore.
+        subfolderSemaphore <- struct{}{}
+        if runCtx.Err() != nil || uploader.DataTargetReached() || atomic.LoadInt32(&stalled) != 0 {
+            <-subfolderSemaphore
+            break
         }
 
         wg.Add(1)
 
         go func(folderIdx int, filesCount int64) {
             defer wg.Done()
-
-            // Acquire a slot in the semaphore.
-            subfolderSemaphore <- struct{}{}
+            defer recoverAndDump()
 
             // Process the subfolder.
-            processSubfolder(folderIdx, filesCount, localFiles, uploader, cfg)
+            successes, failures := processSubfolder(folderIdx, filesCount, localFiles, uploader, cfg)
+            if cfg.TotalDataBytes > 0 && successes == 0 && failures > 0 {
+                atomic.StoreInt32(&stalled, 1)
+            }
+
+            // Keep the slot during the pause, so the next folder in it starts after the pause.
+            // There is nothing to pause for once the data goal is reached or the run stalled.
+            pause := cfg.FolderPause(folderIdx)
+            select {
+            case <-allScheduled:
+                pause = 0
+            default:
+            }
+            if pause > 0 && !uploader.DataTargetReached() && atomic.LoadInt32(&stalled) == 0 {
+                progress.Printf("Pausing for %v before the next upload...\n", pause.Round(time.Millisecond))
+                select {
+                case <-time.After(pause):
+                case <-runCtx.Done():
+                case <-allScheduled:
+                }
+            }
 
             // Release the slot in the semaphore.
             <-subfolderSemaphore
-
-            // Pause between folder uploads as per configuration.
-            fmt.Printf("Pausing for %d seconds before the next upload...\n", cfg.PauseDurationSeconds)
-            time.Sleep(time.Duration(cfg.PauseDurationSeconds) * time.Second)
-
         }(folderIndex, filesToProcess)
 
         totalFilesUploaded += filesToProcess
     }
+    close(allScheduled)
 
     wg.Wait()
+    mixedMetrics := mixedReads.Stop()
+    uploader.Progress.Done()
+
+    fmt.Println("All uploads completed.")
+    if cfg.TotalDataBytes > 0 {
+        fmt.Printf("Data written: %s of the %s goal.\n", config.FormatSize(monitor.GetStats().Bytes), config.FormatSize(cfg.TotalDataBytes))
+        if atomic.LoadInt32(&stalled) != 0 {
+            fmt.Println("Stopped early: every upload of a subfolder failed.")
+        }
+    }
+    if reason := monitor.TruncateReason(); reason != "" {
+        stats := monitor.GetStats()
+        if cfg.TotalDataBytes > 0 {
+            fmt.Printf("Stopped early, %s: %d uploads completed.\n", reason, stats.TotalUploads+stats.Skipped)
+        } else {
+            fmt.Printf("Stopped early, %s: %d of %d uploads completed.\n", reason, stats.TotalUploads+stats.Skipped, cfg.TotalFiles)
+        }
+    }
 
-    fmt.Println("\nAll uploads completed.")
+    if stopNotifications != nil {
+        if pending := monitor.PendingNotifications(); pending > 0 {
+            fmt.Printf("Waiting up to %d seconds for %d outstanding notifications...\n", cfg.NotificationGraceSeconds, pending)
+            notify.WaitForPending(time.Duration(cfg.NotificationGraceSeconds) * time.Second)
+        }
+        stopNotifications()
+        monitor.FinishNotifications()
+    }
 
-    // Clean up local files to free up space.
-    cleanupLocalFiles(localFiles)
+    if uploader.Replication != nil {
+        if pending := uploader.Replication.Pending(); pending > 0 {
+            fmt.Printf("Waiting up to %d seconds for %d objects to appear on the replication destination...\n", cfg.ReplicationTimeoutSeconds, pending)
+        }
+        uploader.Replication.Wait()
+    }
 
-    // Perform benchmarking operations.
-    benchmarkResult := benchmark.PerformBenchmarkOperations(cfg, s3Clients[0], uploader.UploadedS3Files, monitor.GetStats().StartTime)
+    runArtifacts.Lock()
+    runArtifacts.manifest = nil
+    uploader.Failures = nil
+    runArtifacts.Unlock()
+    if err := uploadManifest.Close(); err != nil {
+        fmt.Printf("Error closing manifest: %v\n", err)
+    }
+    failed := uploadFailures.Count()
+    if err := uploadFailures.Close(); err != nil {
+        fmt.Printf("Error closing failures file: %v\n", err)
+    }
+
+    // Delete the replicated local files to free up space, unless the cleanup policy keeps them.
+    // They are also kept after failed uploads, which the redrive command uploads from them.
+    if failed > 0 {
+        fmt.Printf("%d uploads failed and were recorded in %s; re-drive them with the redrive command.\n", failed, cfg.FailuresPath)
+        if cfg.CleansLocalFiles() {
+            fmt.Println("Keeping the local files for the redrive command.")
+        }
+    } else if cfg.CleansLocalFiles() {
+        cleanupLocalFiles(localFiles)
+    }
+
+    // Perform benchmarking operations, unless the run was aborted or truncated during the upload phase.
+    var benchmarkResult benchmark.BenchmarkResult
+    if runCtx.Err() == nil {
+        monitor.SetPhase(monitor.PhaseBenchmarking)
+        benchmarkResult = runBenchmarkPhase(cfg, backends[0], uploader.UploadedS3Files)
+    }
+    if mixedMetrics != nil {
+        if benchmarkResult.Metrics == nil {
+            benchmarkResult.Metrics = make(map[benchmark.OperationType]*benchmark.PerformanceMetrics)
+        }
+        benchmarkResult.Metrics[benchmark.OperationGetDuringUpload] = mixedMetrics
+    }
 
     // Generate the final report.
+    monitor.SetPhase(monitor.PhaseReporting)
     benchmark.GenerateFinalReport(benchmarkResult)
+
+    if err := uploader.UploadedS3Files.Close(); err != nil {
+        fmt.Printf("Error closing key store: %v\n", err)
+    }
+
+    // Delete the uploaded objects once the run is reported, if the cleanup policy says so.
+    if cfg.CleansObjects() {
+        result, err := cleanup.DeleteRun(cfg, backends[0].(*storage.S3Backend).Client, runID)
+        if err != nil {
+            fmt.Printf("Error cleaning up objects of run %s: %v\n", runID, err)
+        }
+        cleanup.PrintReport([]cleanup.Result{result})
+    }
+
+    if monitor.AbortReason() != "" {
+        monitor.SetPhase(monitor.PhaseAborted)
+    } else if monitor.TruncateReason() != "" {
+        monitor.SetPhase(monitor.PhaseTruncated)
+    } else {
+        monitor.SetPhase(monitor.PhaseCompleted)
+    }
+    saveRunRecord(cfg, monitor.Phase(), benchmarkResult)
+    return nil
+}
+
+// runBenchmarkPhase runs the benchmark operations on the keys of benchmarkKeySource: the keys
+// uploaded by the run, or the keys listed under keyListingPrefix.
+func runBenchmarkPhase(cfg *config.Config, backend storage.Backend, uploadedS3Files *keystore.Store) benchmark.BenchmarkResult {
+    if cfg.BenchmarkKeySource != config.BenchmarkKeySourceListing {
+        // Uploads finish in a different order on every run, so with a seed the keys are sorted
+        // for the same positions to hold the same keys, and the benchmark to pick the same ones.
+        if cfg.Seed != 0 {
+            sorted, err := uploadedS3Files.Sorted()
+            if err != nil {
+                fmt.Printf("Error sorting uploaded keys: %v\n", err)
+            } else {
+                defer func() {
+                    if err := sorted.Close(); err != nil {
+                        fmt.Printf("Error closing key store: %v\n", err)
+                    }
+                }()
+                uploadedS3Files = sorted
+            }
+        }
+        return benchmark.PerformBenchmarkOperations(cfg, backend, uploadedS3Files, monitor.GetStats().StartTime)
+    }
+
+    keys := keystore.New(cfg.KeyStoreDir, cfg.KeyStoreMemoryLimit)
+    defer func() {
+        if err := keys.Close(); err != nil {
+            fmt.Printf("Error closing key store: %v\n", err)
+        }
+    }()
+
+    fmt.Printf("\nListing benchmark keys under %q...\n", cfg.KeyListingPrefix)
+    if _, err := benchmark.ListKeys(cfg, backend.(*storage.S3Backend).Client, cfg.KeyListingPrefix, keys); err != nil {
+        fmt.Printf("Error listing benchmark keys: %v\n", err)
+    }
+    return benchmark.PerformBenchmarkOperations(cfg, backend, keys, monitor.GetStats().StartTime)
 }
 
 // processSubfolder handles the creation and upload of files to a single subfolder.
-func processSubfolder(folderIndex int, filesToProcess int64, localFiles []string, uploader *s3upload.Uploader, cfg *config.Config) {
-    fmt.Printf("\nProcessing subfolder %d...\n", folderIndex)
+// It returns the number of files uploaded successfully and the number that failed.
+func processSubfolder(folderIndex int, filesToProcess int64, localFiles []string, uploader *s3upload.Uploader, cfg *config.Config) (int64, int64) {
+    progress.Printf("Processing subfolder %d...\n", folderIndex)
 
     // Include the folderIndex in the subfolderName to ensure uniqueness.
-    dateTimeStr := time.Now().Format("02012006150405") // DDMMYYYYHHMMSS
+    dateTimeStr := cfg.FolderTimestamp(time.Now())
     folderFilesCount := fmt.Sprintf("%d", filesToProcess)
     subfolderName := fmt.Sprintf("FOLDER_%s_%s_%d", dateTimeStr, folderFilesCount, folderIndex)
-
-    // Prepare the list of files to upload.
-    filePaths := make([]string, filesToProcess)
-    for i := int64(0); i < filesToProcess; i++ {
-        filePaths[i] = localFiles[i%int64(len(localFiles))]
+    if cfg.SkipExisting || cfg.Seed != 0 {
+        // Keys must be stable across runs for existing objects to be found again, and for
+        // runs with the same seed to write the same keys.
+        subfolderName = fmt.Sprintf("FOLDER_%s_%d", folderFilesCount, folderIndex)
     }
 
+    // The local files are reused following fileSelection; paths are produced as the uploads are scheduled.
+    source := s3upload.SelectFiles(cfg, localFiles, folderIndex)
+
     // Start uploading files to S3 in parallel.
-    uploader.UploadFiles(subfolderName, filePaths)
+    folderStart := time.Now()
+    successes, failures := uploader.UploadFiles(subfolderName, filesToProcess, source)
+    if cfg.TotalDataBytes > 0 {
+        // The folder stops early once the data volume goal is reached.
+        filesToProcess = successes + failures
+    }
+
+    monitor.RecordFolderStats(monitor.FolderStats{
+        Index:     folderIndex,
+        Name:      subfolderName,
+        Files:     filesToProcess,
+        Successes: successes,
+        Failures:  failures,
+        StartTime: folderStart,
+        Duration:  time.Since(folderStart),
+    })
+
+    progress.Printf("Upload completed for subfolder index %d.\n", folderIndex)
+    return successes, failures
+}
 
-    fmt.Printf("\nUpload completed for subfolder index %d.\n", folderIndex)
+// createOutputDirs creates plot/, where the periodic statistics are written, and the
+// directories of the configured output files, relative to the current directory.
+func createOutputDirs(cfg *config.Config) error {
+    dirs := []string{"plot"}
+    for _, path := range []string{cfg.ManifestPath, cfg.FailuresPath, cfg.CheckpointPath, cfg.StateDumpPath,
+        cfg.LatencyLogPath, cfg.ErrorLogPath, cfg.JUnitReportPath, cfg.RunDescriptorPath} {
+        if path != "" {
+            dirs = append(dirs, filepath.Dir(path))
+        }
+    }
+    for _, dir := range dirs {
+        if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// settingsFlag collects the name=value settings of repeated --set flags, by JSON name.
+type settingsFlag map[string]string
+
+func (s settingsFlag) String() string {
+    return ""
+}
+
+func (s settingsFlag) Set(value string) error {
+    name, v, ok := strings.Cut(value, "=")
+    if !ok || name == "" {
+        return fmt.Errorf("expected name=value, got %q", value)
+    }
+    s[name] = v
+    return nil
+}
+
+// flagSet reports whether the flag was given on the command line.
+func flagSet(name string) bool {
+    set := false
+    flag.Visit(func(f *flag.Flag) {
+        if f.Name == name {
+            set = true
+        }
+    })
+    return set
+}
+
+// envOr returns the value of the environment variable, or def if it is not set.
+func envOr(name, def string) string {
+    if value, ok := os.LookupEnv(name); ok {
+        return value
+    }
+    return def
 }
 
 // increaseFileDescriptorLimit increases the file descriptor limit to handle more open files.
@@ -149,6 +593,15 @@ func increaseFileDescriptorLimit() error {
     }
 }
 
+// logRotation returns the rotation policy of the long-running logs.
+func logRotation(cfg *config.Config) logrotate.Policy {
+    return logrotate.Policy{
+        MaxBytes: int64(cfg.LogRotateMB) * 1024 * 1024,
+        MaxAge:   time.Duration(cfg.LogRotateHours) * time.Hour,
+        Compress: cfg.LogRotateCompress,
+    }
+}
+
 // cleanupLocalFiles removes the replicated local files to free up space.
 func cleanupLocalFiles(files []string) {
     for _, filePath := range files {
@@ -0,0 +1,136 @@
+// manifest/failures.go
+package manifest
+
+import (
+    "encoding/csv"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+)
+
+// failureHeader lists the columns of a failures file, in the order of Failure.
+var failureHeader = []string{"Key", "File", "Error", "FailedAt", "RunID", "Attempts"}
+
+// Failure describes an upload that failed permanently, after all its attempts.
+type Failure struct {
+    Key      string
+    File     string // Local file that was uploaded.
+    Error    string // Error of the last attempt.
+    FailedAt time.Time
+    RunID    string
+    Attempts int
+}
+
+// FailureWriter appends failed uploads to a CSV file. It is safe for concurrent use.
+type FailureWriter struct {
+    mu     sync.Mutex
+    file   *os.File
+    writer *csv.Writer
+    count  int
+}
+
+// CreateFailures opens the failures file for appending, creating it with a header if needed.
+func CreateFailures(path string) (*FailureWriter, error) {
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return nil, fmt.Errorf("error opening failures file %s: %w", path, err)
+    }
+
+    w := &FailureWriter{file: file, writer: csv.NewWriter(file)}
+
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return nil, fmt.Errorf("error reading failures file info %s: %w", path, err)
+    }
+    if info.Size() == 0 {
+        if err := w.writer.Write(failureHeader); err != nil {
+            file.Close()
+            return nil, fmt.Errorf("error writing failures header: %w", err)
+        }
+    }
+
+    return w, nil
+}
+
+// Add appends a failed upload. Entries are buffered until Flush or Close.
+func (w *FailureWriter) Add(f Failure) error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.count++
+    return w.writer.Write([]string{f.Key, f.File, f.Error, f.FailedAt.Format(time.RFC3339Nano), f.RunID, strconv.Itoa(f.Attempts)})
+}
+
+// Count returns the number of failures added since the file was opened.
+func (w *FailureWriter) Count() int {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    return w.count
+}
+
+// Flush writes any buffered entries to disk.
+func (w *FailureWriter) Flush() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.writer.Flush()
+    return w.writer.Error()
+}
+
+// Sync writes any buffered entries to disk and waits until the disk has stored them.
+func (w *FailureWriter) Sync() error {
+    if err := w.Flush(); err != nil {
+        return err
+    }
+    return w.file.Sync()
+}
+
+// Close flushes buffered entries and closes the failures file.
+func (w *FailureWriter) Close() error {
+    if err := w.Flush(); err != nil {
+        w.file.Close()
+        return err
+    }
+    return w.file.Close()
+}
+
+// ReadFailures loads every entry from a failures file. A missing file has no failures.
+func ReadFailures(path string) ([]Failure, error) {
+    file, err := os.Open(path)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error opening failures file %s: %w", path, err)
+    }
+    defer file.Close()
+
+    reader := csv.NewReader(file)
+    reader.FieldsPerRecord = -1
+
+    var failures []Failure
+    for line := 1; ; line++ {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("error reading failures line %d: %w", line, err)
+        }
+        if line == 1 && record[0] == "Key" {
+            continue
+        }
+        if len(record) < len(failureHeader) {
+            return nil, fmt.Errorf("malformed failures line %d", line)
+        }
+
+        f := Failure{Key: record[0], File: record[1], Error: record[2], RunID: record[4]}
+        f.FailedAt, _ = time.Parse(time.RFC3339Nano, record[3])
+        f.Attempts, _ = strconv.Atoi(record[5])
+        failures = append(failures, f)
+    }
+
+    return failures, nil
+}
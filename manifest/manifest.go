@@ -0,0 +1,229 @@
+// manifest/manifest.go
+package manifest
+
+import (
+    "bufio"
+    "bytes"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "strconv"
+    "sync"
+    "time"
+
+    "scale_s3_benchmark/config"
+)
+
+// Manifest formats accepted by Create. Read recognizes both.
+const (
+    FormatCSV   = config.ManifestCSV   // One row per entry after a header.
+    FormatJSONL = config.ManifestJSONL // One JSON object per line.
+)
+
+// header lists the CSV columns, in the order of Entry.
+var header = []string{"Key", "Size", "UploadedAt", "RunID", "SHA256", "ETag", "Endpoint", "Attempts"}
+
+// Entry describes a single uploaded object. Manifests written by older versions only have Key,
+// Size, UploadedAt and RunID; the other fields are then empty.
+type Entry struct {
+    Key        string    `json:"key"`
+    Size       int64     `json:"size"`
+    UploadedAt time.Time `json:"uploadedAt"`
+    RunID      string    `json:"runID"`    // Run that uploaded the object; empty in manifests written before run IDs.
+    SHA256     string    `json:"sha256"`   // Hex SHA-256 of the uploaded content.
+    ETag       string    `json:"etag"`     // ETag returned by the upload, without quotes; empty for skipped uploads.
+    Endpoint   string    `json:"endpoint"` // Endpoint the upload was sent to; empty for skipped uploads.
+    Attempts   int       `json:"attempts"` // Upload attempts including the successful one; 0 for skipped uploads.
+}
+
+// Writer appends manifest entries to a file. It is safe for concurrent use.
+type Writer struct {
+    mu     sync.Mutex
+    file   *os.File
+    buf    *bufio.Writer
+    writer *csv.Writer // nil for JSONL manifests.
+}
+
+// Create opens the manifest file for appending in format, creating it with a header if needed.
+func Create(path, format string) (*Writer, error) {
+    if format != FormatCSV && format != FormatJSONL {
+        return nil, fmt.Errorf("unknown manifest format %q", format)
+    }
+
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return nil, fmt.Errorf("error opening manifest file %s: %w", path, err)
+    }
+
+    w := &Writer{file: file, buf: bufio.NewWriter(file)}
+    if format == FormatJSONL {
+        return w, nil
+    }
+    w.writer = csv.NewWriter(w.buf)
+
+    info, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return nil, fmt.Errorf("error reading manifest file info %s: %w", path, err)
+    }
+    if info.Size() == 0 {
+        if err := w.writer.Write(header); err != nil {
+            file.Close()
+            return nil, fmt.Errorf("error writing manifest header: %w", err)
+        }
+    }
+
+    return w, nil
+}
+
+// Add appends an entry to the manifest. Entries are buffered until Flush or Close.
+func (w *Writer) Add(e Entry) error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+
+    if w.writer == nil {
+        line, err := json.Marshal(e)
+        if err != nil {
+            return err
+        }
+        _, err = w.buf.Write(append(line, '\n'))
+        return err
+    }
+    return w.writer.Write([]string{
+        e.Key,
+        strconv.FormatInt(e.Size, 10),
+        e.UploadedAt.Format(time.RFC3339Nano),
+        e.RunID,
+        e.SHA256,
+        e.ETag,
+        e.Endpoint,
+        strconv.Itoa(e.Attempts),
+    })
+}
+
+// Flush writes any buffered entries to disk.
+func (w *Writer) Flush() error {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.writer != nil {
+        w.writer.Flush()
+        if err := w.writer.Error(); err != nil {
+            return err
+        }
+    }
+    return w.buf.Flush()
+}
+
+// Sync writes any buffered entries to disk and waits until the disk has stored them.
+func (w *Writer) Sync() error {
+    if err := w.Flush(); err != nil {
+        return err
+    }
+    return w.file.Sync()
+}
+
+// Close flushes buffered entries and closes the manifest file.
+func (w *Writer) Close() error {
+    if err := w.Flush(); err != nil {
+        w.file.Close()
+        return err
+    }
+    return w.file.Close()
+}
+
+// Read loads every entry from a manifest file. JSONL manifests are recognized by their
+// first byte; anything else is read as CSV.
+func Read(path string) ([]Entry, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil, fmt.Errorf("error opening manifest file %s: %w", path, err)
+    }
+    defer file.Close()
+
+    reader := bufio.NewReader(file)
+    first, err := reader.Peek(1)
+    if err == io.EOF {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading manifest file %s: %w", path, err)
+    }
+    if first[0] == '{' {
+        return readJSONL(reader)
+    }
+    return readCSV(reader)
+}
+
+// readJSONL reads the entries of a JSONL manifest.
+func readJSONL(reader *bufio.Reader) ([]Entry, error) {
+    var entries []Entry
+    scanner := bufio.NewScanner(reader)
+    scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+    for line := 1; scanner.Scan(); line++ {
+        if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+            continue
+        }
+        var entry Entry
+        if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+            return nil, fmt.Errorf("error reading manifest line %d: %w", line, err)
+        }
+        entries = append(entries, entry)
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("error reading manifest: %w", err)
+    }
+    return entries, nil
+}
+
+// readCSV reads the entries of a CSV manifest. Rows with fewer columns than the header,
+// written by older versions, leave the missing fields empty.
+func readCSV(reader io.Reader) ([]Entry, error) {
+    csvReader := csv.NewReader(reader)
+    csvReader.FieldsPerRecord = -1
+
+    var entries []Entry
+    for line := 1; ; line++ {
+        record, err := csvReader.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("error reading manifest line %d: %w", line, err)
+        }
+        if line == 1 && record[0] == "Key" {
+            continue
+        }
+        if len(record) < 2 {
+            return nil, fmt.Errorf("malformed manifest line %d", line)
+        }
+
+        size, err := strconv.ParseInt(record[1], 10, 64)
+        if err != nil {
+            return nil, fmt.Errorf("invalid size on manifest line %d: %w", line, err)
+        }
+        entry := Entry{Key: record[0], Size: size}
+        if len(record) > 2 {
+            entry.UploadedAt, _ = time.Parse(time.RFC3339Nano, record[2])
+        }
+        if len(record) > 3 {
+            entry.RunID = record[3]
+        }
+        if len(record) > 4 {
+            entry.SHA256 = record[4]
+        }
+        if len(record) > 5 {
+            entry.ETag = record[5]
+        }
+        if len(record) > 6 {
+            entry.Endpoint = record[6]
+        }
+        if len(record) > 7 {
+            entry.Attempts, _ = strconv.Atoi(record[7])
+        }
+        entries = append(entries, entry)
+    }
+
+    return entries, nil
+}
@@ -0,0 +1,208 @@
+// migrate/migrate.go
+package migrate
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/storage"
+)
+
+// progressInterval is how often progress is printed while migrating.
+const progressInterval = 5 * time.Second
+
+// Result holds the outcome of a migration run.
+type Result struct {
+    Listed    int64
+    Objects   int64 // Objects copied to the target.
+    Bytes     int64
+    GetErrors int64
+    PutErrors int64
+    GetTime   time.Duration // Sum of GET times, including reading the body.
+    PutTime   time.Duration // Sum of PUT times.
+    Duration  time.Duration
+}
+
+// object is a source object held in memory between the GET and PUT stages.
+type object struct {
+    key  string
+    data []byte
+}
+
+// Run copies objects listed under MigrationSourcePrefix in the source bucket to the target
+// backends through a GET stage and a PUT stage connected by a buffer of objects held in
+// memory, measuring end-to-end throughput. Targets are used in round-robin order.
+func Run(cfg *config.Config, source *s3.S3, targets []storage.Backend) (Result, error) {
+    var (
+        result    Result
+        getNanos  int64
+        putNanos  int64
+        listErr   error
+        targetIdx uint64
+    )
+
+    keys := make(chan string, cfg.MigrationGetConcurrency*2)
+    buffer := make(chan object, cfg.MigrationBufferObjects)
+    start := time.Now()
+
+    // List the source bucket.
+    go func() {
+        defer close(keys)
+        listErr = listKeys(cfg, source, keys, &result.Listed)
+    }()
+
+    // GET stage.
+    var getters sync.WaitGroup
+    for i := 0; i < cfg.MigrationGetConcurrency; i++ {
+        getters.Add(1)
+        go func() {
+            defer getters.Done()
+            for key := range keys {
+                t := time.Now()
+                data, err := getObject(cfg, source, key)
+                atomic.AddInt64(&getNanos, int64(time.Since(t)))
+                if err != nil {
+                    fmt.Printf("Error reading %s from source: %v\n", key, err)
+                    atomic.AddInt64(&result.GetErrors, 1)
+                    continue
+                }
+                buffer <- object{key: key, data: data}
+            }
+        }()
+    }
+    go func() {
+        getters.Wait()
+        close(buffer)
+    }()
+
+    // Progress reporting.
+    done := make(chan struct{})
+    go func() {
+        ticker := time.NewTicker(progressInterval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                objects := atomic.LoadInt64(&result.Objects)
+                mb := float64(atomic.LoadInt64(&result.Bytes)) / (1024 * 1024)
+                elapsed := time.Since(start).Seconds()
+                fmt.Printf("Migrated: %d objects, %.2f MB | %.2f objects/sec, %.2f MB/sec | buffered: %d\n",
+                    objects, mb, float64(objects)/elapsed, mb/elapsed, len(buffer))
+            case <-done:
+                return
+            }
+        }
+    }()
+
+    // PUT stage.
+    var putters sync.WaitGroup
+    for i := 0; i < cfg.MigrationPutConcurrency; i++ {
+        putters.Add(1)
+        go func() {
+            defer putters.Done()
+            for obj := range buffer {
+                target := targets[atomic.AddUint64(&targetIdx, 1)%uint64(len(targets))]
+
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                t := time.Now()
+                _, err := target.PutObject(ctx, obj.key, bytes.NewReader(obj.data), int64(len(obj.data)))
+                atomic.AddInt64(&putNanos, int64(time.Since(t)))
+                cancel()
+                if err != nil {
+                    fmt.Printf("Error writing %s to target %s: %v\n", obj.key, target.Endpoint(), err)
+                    atomic.AddInt64(&result.PutErrors, 1)
+                    continue
+                }
+                atomic.AddInt64(&result.Objects, 1)
+                atomic.AddInt64(&result.Bytes, int64(len(obj.data)))
+            }
+        }()
+    }
+    putters.Wait()
+    close(done)
+
+    result.Duration = time.Since(start)
+    result.GetTime = time.Duration(getNanos)
+    result.PutTime = time.Duration(putNanos)
+    if listErr != nil {
+        return result, listErr
+    }
+    return result, nil
+}
+
+// listKeys sends every key under the source prefix to keys, up to MigrationMaxObjects.
+func listKeys(cfg *config.Config, source *s3.S3, keys chan<- string, listed *int64) error {
+    input := &s3.ListObjectsV2Input{
+        Bucket: aws.String(cfg.MigrationSourceBucket),
+        Prefix: aws.String(cfg.MigrationSourcePrefix),
+    }
+    for {
+        // Each page request gets its own LIST deadline.
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := source.ListObjectsV2WithContext(ctx, input)
+        cancel()
+        if err != nil {
+            return fmt.Errorf("error listing source bucket %s: %w", cfg.MigrationSourceBucket, err)
+        }
+
+        for _, obj := range page.Contents {
+            if cfg.MigrationMaxObjects > 0 && atomic.LoadInt64(listed) >= int64(cfg.MigrationMaxObjects) {
+                return nil
+            }
+            keys <- aws.StringValue(obj.Key)
+            atomic.AddInt64(listed, 1)
+        }
+
+        if !aws.BoolValue(page.IsTruncated) {
+            return nil
+        }
+        input.ContinuationToken = page.NextContinuationToken
+    }
+}
+
+// getObject reads a whole source object into memory.
+func getObject(cfg *config.Config, source *s3.S3, key string) ([]byte, error) {
+    ctx, cancel := cfg.OperationContext(config.OperationGet)
+    defer cancel()
+
+    out, err := source.GetObjectWithContext(ctx, &s3.GetObjectInput{
+        Bucket: aws.String(cfg.MigrationSourceBucket),
+        Key:    aws.String(key),
+    })
+    if err != nil {
+        return nil, err
+    }
+    defer out.Body.Close()
+    return io.ReadAll(out.Body)
+}
+
+// PrintReport prints the migration throughput and per-stage latencies.
+func PrintReport(r Result) {
+    fmt.Println("\nMigration Report:")
+    fmt.Println("=================")
+    fmt.Printf("Objects Listed: %d\n", r.Listed)
+    fmt.Printf("Objects Migrated: %d\n", r.Objects)
+    fmt.Printf("Bytes Migrated: %d (%.2f MB)\n", r.Bytes, float64(r.Bytes)/(1024*1024))
+    fmt.Printf("GET Errors: %d\n", r.GetErrors)
+    fmt.Printf("PUT Errors: %d\n", r.PutErrors)
+    fmt.Printf("Duration: %v\n", r.Duration)
+
+    if seconds := r.Duration.Seconds(); seconds > 0 {
+        fmt.Printf("Throughput: %.2f objects/sec, %.2f MB/sec\n", float64(r.Objects)/seconds, float64(r.Bytes)/(1024*1024)/seconds)
+    }
+    if r.Listed > 0 {
+        fmt.Printf("Avg GET Time: %v\n", time.Duration(int64(r.GetTime)/r.Listed))
+    }
+    if puts := r.Objects + r.PutErrors; puts > 0 {
+        fmt.Printf("Avg PUT Time: %v\n", time.Duration(int64(r.PutTime)/puts))
+    }
+    fmt.Println("=================")
+}
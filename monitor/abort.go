@@ -0,0 +1,283 @@
+// monitor/abort.go
+package monitor
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// AbortPolicy defines when a run is considered broken and must stop.
+type AbortPolicy struct {
+    ErrorRate           float64       // Failure rate (0-1) over Window that aborts the run (0 = disabled).
+    Window              time.Duration // Sliding window for ErrorRate.
+    MinOperations       int64         // Operations required in the window before ErrorRate is evaluated.
+    ConsecutiveFailures int64         // Consecutive failures that abort the run (0 = disabled).
+    TargetFailures      int64         // Consecutive failed requests to one endpoint or key prefix that abort the run (0 = disabled).
+}
+
+// maxAbortErrors is the number of recent errors of the failing target printed when it aborts the run.
+const maxAbortErrors = 5
+
+// failureTarget is an endpoint, or the prefix of a key up to its last "/", whose consecutive
+// failed requests are counted by the abort policy.
+type failureTarget struct {
+    kind string // "endpoint" or "prefix".
+    name string
+}
+
+// String implements fmt.Stringer.
+func (t failureTarget) String() string {
+    return fmt.Sprintf("%s %q", t.kind, t.name)
+}
+
+// matches reports whether a failed request was sent to the target.
+func (t failureTarget) matches(e RequestError) bool {
+    if t.kind == "endpoint" {
+        return e.Endpoint == t.name
+    }
+    return e.Key != "" && keyPrefix(e.Key) == t.name
+}
+
+// outcomeBucket counts the outcomes of one second of operations.
+type outcomeBucket struct {
+    second    int64
+    successes int64
+    failures  int64
+}
+
+var (
+    abortLock        sync.Mutex
+    abortPolicy      AbortPolicy
+    abortBuckets     []outcomeBucket
+    consecutiveFails int64
+    targetFails      map[failureTarget]int64
+    abortReason      string
+    truncateReason   string
+    abortEnabled     int32 // Non-zero when the policy has a check enabled; read without abortLock.
+
+    runCtx, runCancel = context.WithCancel(context.Background())
+)
+
+// SetAbortPolicy enables automatic abort of the run according to the policy.
+func SetAbortPolicy(policy AbortPolicy) {
+    abortLock.Lock()
+    defer abortLock.Unlock()
+
+    abortPolicy = policy
+    abortBuckets = nil
+    targetFails = make(map[failureTarget]int64)
+    if policy.ErrorRate > 0 && policy.Window > 0 {
+        abortBuckets = make([]outcomeBucket, int(policy.Window/time.Second)+1)
+    }
+
+    enabled := int32(0)
+    if abortBuckets != nil || policy.ConsecutiveFailures > 0 || policy.TargetFailures > 0 {
+        enabled = 1
+    }
+    atomic.StoreInt32(&abortEnabled, enabled)
+}
+
+// RunContext returns a context that is cancelled when the run is aborted.
+func RunContext() context.Context {
+    abortLock.Lock()
+    defer abortLock.Unlock()
+    return runCtx
+}
+
+// SleepRun waits for d, or less if the run is cancelled first. It reports whether the full
+// time passed.
+func SleepRun(d time.Duration) bool {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return true
+    case <-RunContext().Done():
+        return false
+    }
+}
+
+// Abort cancels the run with the given reason. Only the first reason is kept.
+func Abort(reason string) {
+    abortLock.Lock()
+    defer abortLock.Unlock()
+    abortLocked(reason)
+}
+
+// abortLocked cancels the run. The caller must hold abortLock.
+func abortLocked(reason string) {
+    if abortReason != "" {
+        return
+    }
+    abortReason = reason
+    fmt.Printf("\nAborting run: %s\n", reason)
+    runCancel()
+}
+
+// violateLocked reports a breach of the abort policy and cancels the run. The caller must hold abortLock.
+func violateLocked(reason string) {
+    PublishEvent(EventSLAViolated, SLAEvent{Reason: reason})
+    abortLocked(reason)
+}
+
+// AbortReason returns why the run was aborted, or an empty string if it was not.
+func AbortReason() string {
+    abortLock.Lock()
+    defer abortLock.Unlock()
+    return abortReason
+}
+
+// Truncate stops the run because it ran out of time. The remaining work is cancelled as on Abort,
+// but the run is reported as truncated rather than aborted, since it breached no abort policy.
+// Only the first reason is kept, and a run already aborted is not truncated.
+func Truncate(reason string) {
+    abortLock.Lock()
+    defer abortLock.Unlock()
+
+    if abortReason != "" || truncateReason != "" {
+        return
+    }
+    truncateReason = reason
+    fmt.Printf("\nTruncating run: %s\n", reason)
+    runCancel()
+}
+
+// TruncateReason returns why the run was truncated, or an empty string if it was not.
+func TruncateReason() string {
+    abortLock.Lock()
+    defer abortLock.Unlock()
+    return truncateReason
+}
+
+// RecordOutcome feeds the result of an operation to the abort policy.
+// Without an abort policy it returns without taking any lock.
+func RecordOutcome(success bool) {
+    if atomic.LoadInt32(&abortEnabled) == 0 {
+        return
+    }
+
+    abortLock.Lock()
+    defer abortLock.Unlock()
+
+    if abortReason != "" || truncateReason != "" {
+        return
+    }
+
+    if success {
+        consecutiveFails = 0
+    } else {
+        consecutiveFails++
+        if abortPolicy.ConsecutiveFailures > 0 && consecutiveFails >= abortPolicy.ConsecutiveFailures {
+            violateLocked(fmt.Sprintf("%d consecutive failures", consecutiveFails))
+            return
+        }
+    }
+
+    if abortBuckets == nil {
+        return
+    }
+
+    now := time.Now().Unix()
+    b := &abortBuckets[now%int64(len(abortBuckets))]
+    if b.second != now {
+        *b = outcomeBucket{second: now}
+    }
+    if success {
+        b.successes++
+    } else {
+        b.failures++
+    }
+
+    // Sum the buckets that fall inside the window.
+    oldest := now - int64(abortPolicy.Window/time.Second)
+    var successes, failures int64
+    for _, bucket := range abortBuckets {
+        if bucket.second > oldest {
+            successes += bucket.successes
+            failures += bucket.failures
+        }
+    }
+
+    total := successes + failures
+    if total < abortPolicy.MinOperations || total == 0 {
+        return
+    }
+    if rate := float64(failures) / float64(total); rate > abortPolicy.ErrorRate {
+        violateLocked(fmt.Sprintf("failure rate %.2f%% over the last %v exceeds %.2f%%", rate*100, abortPolicy.Window, abortPolicy.ErrorRate*100))
+    }
+}
+
+// RecordTargetOutcome feeds the result of a single request to the per-target check of the abort
+// policy: every endpoint and every key prefix counts its own consecutive failures, so a dead
+// endpoint or an unwritable prefix aborts the run even while the other targets keep succeeding.
+// An empty key, such as that of a listing, only counts towards the endpoint.
+func RecordTargetOutcome(endpoint, key string, success bool) {
+    if atomic.LoadInt32(&abortEnabled) == 0 {
+        return
+    }
+
+    abortLock.Lock()
+    defer abortLock.Unlock()
+
+    if abortReason != "" || truncateReason != "" || abortPolicy.TargetFailures <= 0 {
+        return
+    }
+
+    targets := []failureTarget{{kind: "endpoint", name: endpoint}}
+    if key != "" {
+        targets = append(targets, failureTarget{kind: "prefix", name: keyPrefix(key)})
+    }
+    for _, target := range targets {
+        if success {
+            delete(targetFails, target)
+            continue
+        }
+        targetFails[target]++
+        if n := targetFails[target]; n >= abortPolicy.TargetFailures {
+            violateLocked(fmt.Sprintf("%d consecutive failures on %s", n, target))
+            printAbortDiagnostics(target)
+            return
+        }
+    }
+}
+
+// printAbortDiagnostics prints the latest errors of the target that aborted the run and the
+// state the run was in, so a dead target can be told apart from a broken run without waiting
+// for the partial report.
+func printAbortDiagnostics(target failureTarget) {
+    var last []RequestError
+    for _, e := range RecentRequestErrors() {
+        if target.matches(e) {
+            last = append(last, e)
+        }
+    }
+    if len(last) > maxAbortErrors {
+        last = last[len(last)-maxAbortErrors:]
+    }
+
+    fmt.Printf("Last errors on %s:\n", target)
+    if len(last) == 0 {
+        fmt.Println("  none recorded")
+    }
+    for _, e := range last {
+        request := e.Operation
+        if e.Key != "" {
+            request += " " + e.Key
+        }
+        fmt.Printf("  %s %s: %d %s: %s (x-amz-request-id %q)\n", e.Time.Format(time.RFC3339), request, e.StatusCode, e.Code, e.Message, e.RequestID)
+    }
+
+    s := GetStats()
+    fmt.Printf("State: phase %s, %d uploads (%d succeeded, %d failed, %d skipped) in %v\n",
+        Phase(), s.TotalUploads, s.Successes, s.Failures, s.Skipped, time.Since(s.StartTime).Round(time.Second))
+}
+
+// keyPrefix returns the part of a key up to and including its last "/", or "" for a key at the
+// top of the bucket.
+func keyPrefix(key string) string {
+    return key[:strings.LastIndex(key, "/")+1]
+}
@@ -0,0 +1,182 @@
+// monitor/checkpoint.go
+package monitor
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// FolderCursor is the position of the uploads of a subfolder that is still being uploaded.
+type FolderCursor struct {
+    Name      string `json:"Name"`
+    Files     int64  `json:"Files"`     // Files the subfolder is to have.
+    Scheduled int64  `json:"Scheduled"` // Files handed to the upload workers so far, in order.
+    Done      int64  `json:"Done"`      // Scheduled files whose upload finished, successfully or not.
+}
+
+// AddScheduled counts a file handed to an upload worker.
+func (c *FolderCursor) AddScheduled() {
+    atomic.AddInt64(&c.Scheduled, 1)
+}
+
+// AddDone counts a file whose upload finished.
+func (c *FolderCursor) AddDone() {
+    atomic.AddInt64(&c.Done, 1)
+}
+
+var (
+    cursors     = make(map[*FolderCursor]bool)
+    cursorsLock sync.Mutex
+)
+
+// StartFolderCursor starts tracking the uploads of a subfolder of files files, until EndFolderCursor.
+func StartFolderCursor(name string, files int64) *FolderCursor {
+    c := &FolderCursor{Name: name, Files: files}
+    cursorsLock.Lock()
+    cursors[c] = true
+    cursorsLock.Unlock()
+    return c
+}
+
+// EndFolderCursor stops tracking a subfolder once its uploads are done.
+func EndFolderCursor(c *FolderCursor) {
+    cursorsLock.Lock()
+    delete(cursors, c)
+    cursorsLock.Unlock()
+}
+
+// GetFolderCursors returns the positions of the subfolders being uploaded, sorted by name.
+func GetFolderCursors() []FolderCursor {
+    cursorsLock.Lock()
+    defer cursorsLock.Unlock()
+
+    result := make([]FolderCursor, 0, len(cursors))
+    for c := range cursors {
+        result = append(result, FolderCursor{
+            Name:      c.Name,
+            Files:     c.Files,
+            Scheduled: atomic.LoadInt64(&c.Scheduled),
+            Done:      atomic.LoadInt64(&c.Done),
+        })
+    }
+    sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+    return result
+}
+
+// Checkpoint is the progress of a run persisted while it runs, so that what had completed can
+// still be reported after the load generator crashed.
+type Checkpoint struct {
+    StateDump
+    Phase    string         `json:"Phase"`
+    Sequence int64          `json:"Sequence"` // Checkpoints written by the run, this one included.
+    Cursors  []FolderCursor `json:"Cursors"`  // Subfolders still being uploaded.
+}
+
+var checkpointSequence int64
+
+// WriteCheckpoint writes a checkpoint of the run to path. The checkpoint is written to a
+// temporary file, synced to disk and renamed over path, so path always holds a complete
+// checkpoint, even after a crash in the middle of a write.
+func WriteCheckpoint(path, reason string) error {
+    checkpoint := Checkpoint{
+        StateDump: Snapshot(reason),
+        Phase:     Phase(),
+        Sequence:  atomic.AddInt64(&checkpointSequence, 1),
+        Cursors:   GetFolderCursors(),
+    }
+    data, err := json.MarshalIndent(checkpoint, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error encoding checkpoint: %w", err)
+    }
+
+    tmpPath := path + ".tmp"
+    file, err := os.Create(tmpPath)
+    if err != nil {
+        return fmt.Errorf("error creating checkpoint %s: %w", tmpPath, err)
+    }
+    if _, err := file.Write(data); err != nil {
+        file.Close()
+        return fmt.Errorf("error writing checkpoint %s: %w", tmpPath, err)
+    }
+    if err := file.Sync(); err != nil {
+        file.Close()
+        return fmt.Errorf("error syncing checkpoint %s: %w", tmpPath, err)
+    }
+    if err := file.Close(); err != nil {
+        return fmt.Errorf("error closing checkpoint %s: %w", tmpPath, err)
+    }
+    if err := os.Rename(tmpPath, path); err != nil {
+        return fmt.Errorf("error replacing checkpoint %s: %w", path, err)
+    }
+
+    // The rename is only durable once the directory entry is synced too.
+    dir, err := os.Open(filepath.Dir(path))
+    if err != nil {
+        return fmt.Errorf("error opening checkpoint directory: %w", err)
+    }
+    defer dir.Close()
+    if err := dir.Sync(); err != nil {
+        return fmt.Errorf("error syncing checkpoint directory: %w", err)
+    }
+    return nil
+}
+
+// ReadCheckpoint loads a checkpoint written by WriteCheckpoint.
+func ReadCheckpoint(path string) (Checkpoint, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return Checkpoint{}, fmt.Errorf("error reading checkpoint %s: %w", path, err)
+    }
+    var checkpoint Checkpoint
+    if err := json.Unmarshal(data, &checkpoint); err != nil {
+        return Checkpoint{}, fmt.Errorf("error decoding checkpoint %s: %w", path, err)
+    }
+    return checkpoint, nil
+}
+
+// StartCheckpoints writes a checkpoint to path every interval until the returned function is
+// called, which writes a final one. persist is called before each checkpoint to persist the
+// files the checkpoint is to be consistent with; its error is printed but does not stop the
+// checkpoint.
+func StartCheckpoints(path string, interval time.Duration, persist func() error) (stop func()) {
+    atomic.StoreInt64(&checkpointSequence, 0)
+    write := func(reason string) {
+        if err := persist(); err != nil {
+            fmt.Printf("Error syncing run files for checkpoint: %v\n", err)
+        }
+        if err := WriteCheckpoint(path, reason); err != nil {
+            fmt.Println(err)
+        }
+    }
+
+    done := make(chan struct{})
+    stopped := make(chan struct{})
+    go func() {
+        defer close(stopped)
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                write("checkpoint")
+            case <-done:
+                return
+            }
+        }
+    }()
+
+    var once sync.Once
+    return func() {
+        once.Do(func() {
+            close(done)
+            <-stopped
+            write("final")
+        })
+    }
+}
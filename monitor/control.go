@@ -0,0 +1,176 @@
+// monitor/control.go
+package monitor
+
+import (
+    "context"
+    "sync"
+)
+
+// Run phases reported through Phase.
+const (
+    PhaseIdle         = "idle"
+    PhasePreparing    = "preparing"
+    PhaseReplicating  = "replicating"
+    PhaseUploading    = "uploading"
+    PhaseBenchmarking = "benchmarking"
+    PhaseReporting    = "reporting"
+    PhaseCompleted    = "completed"
+    PhaseAborted      = "aborted"
+    PhaseTruncated    = "truncated"
+    PhaseFailed       = "failed"
+)
+
+var (
+    controlLock sync.Mutex
+    phase       = PhaseIdle
+    paused      bool
+    resumed     = make(chan struct{})
+    runID       string
+
+    phaseHooksLock sync.Mutex
+    phaseHooks     = make(map[int]func(from, to string))
+    nextPhaseHook  int
+)
+
+// SetPhase records the phase the run is in, publishes the phase transition and calls the
+// phase hooks.
+func SetPhase(p string) {
+    controlLock.Lock()
+    if p == phase {
+        controlLock.Unlock()
+        return
+    }
+    from := phase
+    switch from {
+    case PhaseIdle, PhaseCompleted, PhaseAborted, PhaseTruncated, PhaseFailed:
+        // Terminal phases are never "finished"; the next run starts from them.
+    default:
+        PublishEvent(EventPhaseFinished, PhaseEvent{Phase: from})
+    }
+    phase = p
+    PublishEvent(EventPhaseStarted, PhaseEvent{Phase: p})
+    controlLock.Unlock()
+
+    // Hooks run unlocked, so they may look at the run state.
+    phaseHooksLock.Lock()
+    hooks := make([]func(from, to string), 0, len(phaseHooks))
+    for id := 0; id < nextPhaseHook; id++ {
+        if hook, ok := phaseHooks[id]; ok {
+            hooks = append(hooks, hook)
+        }
+    }
+    phaseHooksLock.Unlock()
+    for _, hook := range hooks {
+        hook(from, p)
+    }
+}
+
+// OnPhaseChange calls hook with the old and new phase every time the run phase changes, on the
+// goroutine that changed it, until the returned function is called. Unlike Subscribe no
+// transition is ever dropped, but a slow hook holds up the run. Hooks are called in the order
+// they were added.
+func OnPhaseChange(hook func(from, to string)) func() {
+    phaseHooksLock.Lock()
+    defer phaseHooksLock.Unlock()
+
+    id := nextPhaseHook
+    nextPhaseHook++
+    phaseHooks[id] = hook
+    return func() {
+        phaseHooksLock.Lock()
+        delete(phaseHooks, id)
+        phaseHooksLock.Unlock()
+    }
+}
+
+// Phase returns the phase the run is in.
+func Phase() string {
+    controlLock.Lock()
+    defer controlLock.Unlock()
+    return phase
+}
+
+// Pause makes WaitIfPaused block until Resume is called.
+func Pause() {
+    controlLock.Lock()
+    defer controlLock.Unlock()
+    paused = true
+}
+
+// Resume releases every goroutine blocked in WaitIfPaused.
+func Resume() {
+    controlLock.Lock()
+    defer controlLock.Unlock()
+    if paused {
+        paused = false
+        close(resumed)
+        resumed = make(chan struct{})
+    }
+}
+
+// Paused reports whether the run is paused.
+func Paused() bool {
+    controlLock.Lock()
+    defer controlLock.Unlock()
+    return paused
+}
+
+// WaitIfPaused blocks while the run is paused or until ctx is done.
+func WaitIfPaused(ctx context.Context) {
+    controlLock.Lock()
+    if !paused {
+        controlLock.Unlock()
+        return
+    }
+    ch := resumed
+    controlLock.Unlock()
+
+    select {
+    case <-ch:
+    case <-ctx.Done():
+    }
+}
+
+// RunID returns the ID of the current or last run.
+func RunID() string {
+    controlLock.Lock()
+    defer controlLock.Unlock()
+    return runID
+}
+
+// ResetRun prepares the run-scoped state for a new run with the given ID: a fresh run
+// context, no abort or truncate reason, no pause and empty per-run statistics.
+func ResetRun(id string) {
+    controlLock.Lock()
+    runID = id
+    controlLock.Unlock()
+
+    abortLock.Lock()
+    runCancel()
+    runCtx, runCancel = context.WithCancel(context.Background())
+    abortReason = ""
+    truncateReason = ""
+    consecutiveFails = 0
+    abortLock.Unlock()
+
+    Resume()
+    InitializeStats()
+
+    folderStatsLock.Lock()
+    folderStats = nil
+    folderStatsLock.Unlock()
+
+    circuitEventsLock.Lock()
+    circuitEvents = nil
+    circuitEventsLock.Unlock()
+
+    endpointWindowsLock.Lock()
+    circuitStates = make(map[string]string)
+    endpointWindowsLock.Unlock()
+
+    resetNotifications()
+    resetReplication()
+    resetErrorSignatures()
+    resetStalls()
+    resetResourceSamples()
+}
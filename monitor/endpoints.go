@@ -0,0 +1,147 @@
+// monitor/endpoints.go
+package monitor
+
+import (
+    "math"
+    "sort"
+    "sync"
+    "time"
+)
+
+const (
+    // endpointWindowSeconds is the length of the sliding window used for the live endpoint statistics.
+    endpointWindowSeconds = 10
+
+    // Latency histogram buckets grow by latencyBucketGrowth from latencyBucketBase,
+    // covering roughly 1ms to 2 minutes.
+    latencyBucketBase   = time.Millisecond
+    latencyBucketGrowth = 1.25
+    latencyBucketCount  = 54
+)
+
+// EndpointLive holds the live request statistics of one endpoint over the last few seconds.
+type EndpointLive struct {
+    Endpoint     string        `json:"Endpoint"`
+    Rate         float64       `json:"Rate"`         // Requests per second.
+    ErrorRate    float64       `json:"ErrorRate"`    // Fraction (0-1) of requests that failed or returned a 5xx status.
+    P99          time.Duration `json:"P99"`          // 99th percentile time to response headers.
+    CircuitState string        `json:"CircuitState"` // Last circuit breaker state, "closed" if it never changed.
+}
+
+// endpointSecond accumulates the requests of one endpoint completed within one second.
+type endpointSecond struct {
+    second   int64
+    requests int64
+    errors   int64
+    buckets  [latencyBucketCount]int64
+}
+
+// endpointWindow is a ring of per-second accumulators for one endpoint.
+type endpointWindow struct {
+    seconds [endpointWindowSeconds]endpointSecond
+}
+
+var (
+    endpointWindows     = make(map[string]*endpointWindow)
+    circuitStates       = make(map[string]string)
+    endpointWindowsLock sync.Mutex
+)
+
+// latencyBucket returns the histogram bucket for a latency.
+func latencyBucket(d time.Duration) int {
+    if d <= latencyBucketBase {
+        return 0
+    }
+    b := int(math.Ceil(math.Log(float64(d)/float64(latencyBucketBase)) / math.Log(latencyBucketGrowth)))
+    if b >= latencyBucketCount {
+        return latencyBucketCount - 1
+    }
+    return b
+}
+
+// latencyBucketUpper returns the upper bound of a histogram bucket.
+func latencyBucketUpper(b int) time.Duration {
+    return time.Duration(float64(latencyBucketBase) * math.Pow(latencyBucketGrowth, float64(b)))
+}
+
+// RecordEndpointRequest records a completed request to an endpoint, its latency and whether it failed.
+func RecordEndpointRequest(endpoint string, latency time.Duration, failed bool) {
+    now := time.Now().Unix()
+
+    endpointWindowsLock.Lock()
+    defer endpointWindowsLock.Unlock()
+
+    w, ok := endpointWindows[endpoint]
+    if !ok {
+        w = &endpointWindow{}
+        endpointWindows[endpoint] = w
+    }
+
+    s := &w.seconds[now%endpointWindowSeconds]
+    if s.second != now {
+        *s = endpointSecond{second: now}
+    }
+    s.requests++
+    if failed {
+        s.errors++
+    }
+    s.buckets[latencyBucket(latency)]++
+}
+
+// setCircuitState records the current circuit breaker state of an endpoint.
+func setCircuitState(endpoint, state string) {
+    endpointWindowsLock.Lock()
+    defer endpointWindowsLock.Unlock()
+    circuitStates[endpoint] = state
+}
+
+// GetEndpointLive returns the live statistics of every endpoint seen so far, sorted by endpoint.
+// The current, still incomplete second is left out so rates are not under-reported.
+func GetEndpointLive() []EndpointLive {
+    now := time.Now().Unix()
+
+    endpointWindowsLock.Lock()
+    defer endpointWindowsLock.Unlock()
+
+    result := make([]EndpointLive, 0, len(endpointWindows))
+    for endpoint, w := range endpointWindows {
+        var requests, errors int64
+        var buckets [latencyBucketCount]int64
+        for _, s := range w.seconds {
+            if s.second >= now || s.second < now-endpointWindowSeconds+1 {
+                continue
+            }
+            requests += s.requests
+            errors += s.errors
+            for b, n := range s.buckets {
+                buckets[b] += n
+            }
+        }
+
+        live := EndpointLive{
+            Endpoint:     endpoint,
+            Rate:         float64(requests) / float64(endpointWindowSeconds-1),
+            CircuitState: circuitStates[endpoint],
+        }
+        if live.CircuitState == "" {
+            live.CircuitState = "closed"
+        }
+        if requests > 0 {
+            live.ErrorRate = float64(errors) / float64(requests)
+
+            target := int64(math.Ceil(float64(requests) * 0.99))
+            var seen int64
+            for b, n := range buckets {
+                seen += n
+                if seen >= target {
+                    live.P99 = latencyBucketUpper(b)
+                    break
+                }
+            }
+        }
+        result = append(result, live)
+    }
+
+    sort.Slice(result, func(i, j int) bool { return result[i].Endpoint < result[j].Endpoint })
+    return result
+}
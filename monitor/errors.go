@@ -0,0 +1,168 @@
+// monitor/errors.go
+package monitor
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "sort"
+    "sync"
+    "time"
+
+    "scale_s3_benchmark/logrotate"
+)
+
+// RequestError describes a failed storage request, with the identifiers the storage vendor
+// needs to trace it.
+type RequestError struct {
+    Time       time.Time `json:"Time"`
+    RunID      string    `json:"RunID"`
+    Operation  string    `json:"Operation"` // API operation, e.g. "PutObject".
+    Endpoint   string    `json:"Endpoint"`
+    Key        string    `json:"Key,omitempty"`
+    StatusCode int       `json:"StatusCode"` // 0 when no response was received.
+    Code       string    `json:"Code"`
+    Message    string    `json:"Message"`
+    RequestID  string    `json:"RequestID,omitempty"` // x-amz-request-id.
+    HostID     string    `json:"HostID,omitempty"`    // x-amz-id-2.
+}
+
+// ErrorSignature counts the failed requests of one operation with the same status and error
+// code, keeping the identifiers of the first and the latest of them.
+type ErrorSignature struct {
+    Operation  string       `json:"Operation"`
+    StatusCode int          `json:"StatusCode"`
+    Code       string       `json:"Code"`
+    Count      int64        `json:"Count"`
+    First      RequestError `json:"First"`
+    Last       RequestError `json:"Last"`
+}
+
+// recentErrorCount is the number of latest failed requests kept by RecentRequestErrors.
+const recentErrorCount = 100
+
+// errorSignatureKey identifies an ErrorSignature.
+type errorSignatureKey struct {
+    operation  string
+    statusCode int
+    code       string
+}
+
+var (
+    errorSignatures   = make(map[errorSignatureKey]*ErrorSignature)
+    recentErrors      []RequestError // Ring of the latest failed requests; recentErrorsNext is the oldest once full.
+    recentErrorsNext  int
+    errorLogPath      string
+    errorLog          *os.File
+    errorLogRotation  logrotate.Policy
+    errorLogOpened    time.Time
+    errorLogSize      int64
+    requestErrorsLock sync.Mutex
+)
+
+// SetErrorLog sets the file every failed request is appended to as a JSON line. The file is
+// created on the first failure and rotated according to rotation; an empty path disables the log.
+func SetErrorLog(path string, rotation logrotate.Policy) {
+    requestErrorsLock.Lock()
+    defer requestErrorsLock.Unlock()
+
+    if errorLog != nil {
+        errorLog.Close()
+        errorLog = nil
+    }
+    errorLogPath = path
+    errorLogRotation = rotation
+}
+
+// RecordRequestError counts a failed request under its signature and appends it to the error log.
+func RecordRequestError(e RequestError) {
+    e.RunID = RunID()
+
+    requestErrorsLock.Lock()
+    defer requestErrorsLock.Unlock()
+
+    key := errorSignatureKey{e.Operation, e.StatusCode, e.Code}
+    sig, ok := errorSignatures[key]
+    if !ok {
+        sig = &ErrorSignature{Operation: e.Operation, StatusCode: e.StatusCode, Code: e.Code, First: e}
+        errorSignatures[key] = sig
+    }
+    sig.Count++
+    sig.Last = e
+
+    if len(recentErrors) < recentErrorCount {
+        recentErrors = append(recentErrors, e)
+    } else {
+        recentErrors[recentErrorsNext] = e
+        recentErrorsNext = (recentErrorsNext + 1) % recentErrorCount
+    }
+
+    if errorLogPath == "" {
+        return
+    }
+    if errorLog != nil && errorLogRotation.Due(errorLogSize, errorLogOpened) {
+        errorLog.Close()
+        errorLog = nil
+        if _, err := errorLogRotation.Rotate(errorLogPath); err != nil {
+            fmt.Printf("Error rotating error log: %v\n", err)
+        }
+    }
+    if errorLog == nil {
+        file, err := os.OpenFile(errorLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+        if err != nil {
+            fmt.Printf("Error opening error log %s: %v\n", errorLogPath, err)
+            errorLogPath = ""
+            return
+        }
+        errorLog = file
+        errorLogOpened, errorLogSize = time.Now(), 0
+        if info, err := file.Stat(); err == nil {
+            errorLogSize = info.Size()
+        }
+    }
+    line, err := json.Marshal(e)
+    if err != nil {
+        return
+    }
+    n, err := errorLog.Write(append(line, '\n'))
+    errorLogSize += int64(n)
+    if err != nil {
+        fmt.Printf("Error writing error log %s: %v\n", errorLogPath, err)
+    }
+}
+
+// GetErrorSignatures returns the error signatures, the most frequent first.
+func GetErrorSignatures() []ErrorSignature {
+    requestErrorsLock.Lock()
+    defer requestErrorsLock.Unlock()
+
+    signatures := make([]ErrorSignature, 0, len(errorSignatures))
+    for _, sig := range errorSignatures {
+        signatures = append(signatures, *sig)
+    }
+    sort.Slice(signatures, func(i, j int) bool {
+        if signatures[i].Count != signatures[j].Count {
+            return signatures[i].Count > signatures[j].Count
+        }
+        return signatures[i].First.Time.Before(signatures[j].First.Time)
+    })
+    return signatures
+}
+
+// RecentRequestErrors returns the latest failed requests, the oldest first.
+func RecentRequestErrors() []RequestError {
+    requestErrorsLock.Lock()
+    defer requestErrorsLock.Unlock()
+
+    errors := make([]RequestError, 0, len(recentErrors))
+    errors = append(errors, recentErrors[recentErrorsNext:]...)
+    return append(errors, recentErrors[:recentErrorsNext]...)
+}
+
+// resetErrorSignatures forgets the failed requests of the previous run.
+func resetErrorSignatures() {
+    requestErrorsLock.Lock()
+    defer requestErrorsLock.Unlock()
+    errorSignatures = make(map[errorSignatureKey]*ErrorSignature)
+    recentErrors, recentErrorsNext = nil, 0
+}
@@ -0,0 +1,72 @@
+// monitor/events.go
+package monitor
+
+import (
+    "sync"
+    "time"
+)
+
+// Run lifecycle event types.
+const (
+    EventPhaseStarted    = "phase_started"
+    EventPhaseFinished   = "phase_finished"
+    EventFolderCompleted = "folder_completed"
+    EventSLAViolated     = "sla_violated"
+    EventRunFinished     = "run_finished"
+)
+
+// subscriberBuffer is the number of events buffered per subscriber. Events are dropped
+// for subscribers that fall further behind, so a slow client never stalls the run.
+const subscriberBuffer = 256
+
+// Event is a run lifecycle notification.
+type Event struct {
+    Type string      `json:"Type"`
+    Time time.Time   `json:"Time"`
+    Data interface{} `json:"Data"`
+}
+
+// PhaseEvent is the data of phase_started and phase_finished events.
+type PhaseEvent struct {
+    Phase string `json:"Phase"`
+}
+
+// SLAEvent is the data of sla_violated events.
+type SLAEvent struct {
+    Reason string `json:"Reason"`
+}
+
+var (
+    subscribers     = make(map[chan Event]struct{})
+    subscribersLock sync.Mutex
+)
+
+// Subscribe returns a channel receiving every event published from now on and a
+// function that ends the subscription.
+func Subscribe() (<-chan Event, func()) {
+    ch := make(chan Event, subscriberBuffer)
+
+    subscribersLock.Lock()
+    subscribers[ch] = struct{}{}
+    subscribersLock.Unlock()
+
+    return ch, func() {
+        subscribersLock.Lock()
+        delete(subscribers, ch)
+        subscribersLock.Unlock()
+    }
+}
+
+// PublishEvent sends an event to every subscriber without blocking.
+func PublishEvent(eventType string, data interface{}) {
+    e := Event{Type: eventType, Time: time.Now(), Data: data}
+
+    subscribersLock.Lock()
+    defer subscribersLock.Unlock()
+    for ch := range subscribers {
+        select {
+        case ch <- e:
+        default:
+        }
+    }
+}
@@ -0,0 +1,43 @@
+// monitor/faults.go
+package monitor
+
+import "sync/atomic"
+
+// FaultType identifies a kind of injected fault.
+type FaultType int
+
+const (
+    FaultError FaultType = iota
+    FaultLatency
+    FaultTruncate
+)
+
+// FaultStats holds the number of faults injected by type.
+type FaultStats struct {
+    Errors    int64 `json:"Errors"`
+    Latency   int64 `json:"Latency"`
+    Truncated int64 `json:"Truncated"`
+}
+
+var faultStats FaultStats
+
+// RecordFault records an injected fault.
+func RecordFault(ft FaultType) {
+    switch ft {
+    case FaultError:
+        atomic.AddInt64(&faultStats.Errors, 1)
+    case FaultLatency:
+        atomic.AddInt64(&faultStats.Latency, 1)
+    case FaultTruncate:
+        atomic.AddInt64(&faultStats.Truncated, 1)
+    }
+}
+
+// GetFaultStats returns a copy of the injected fault counters.
+func GetFaultStats() FaultStats {
+    return FaultStats{
+        Errors:    atomic.LoadInt64(&faultStats.Errors),
+        Latency:   atomic.LoadInt64(&faultStats.Latency),
+        Truncated: atomic.LoadInt64(&faultStats.Truncated),
+    }
+}
@@ -0,0 +1,51 @@
+// monitor/folders.go
+package monitor
+
+import (
+    "sort"
+    "sync"
+    "time"
+)
+
+// FolderStats holds the upload statistics of a single subfolder.
+type FolderStats struct {
+    Index     int           `json:"Index"`
+    Name      string        `json:"Name"`
+    Files     int64         `json:"Files"`
+    Successes int64         `json:"Successes"`
+    Failures  int64         `json:"Failures"`
+    StartTime time.Time     `json:"StartTime"`
+    Duration  time.Duration `json:"Duration"`
+}
+
+// Throughput returns the number of successfully uploaded files per second.
+func (f FolderStats) Throughput() float64 {
+    if f.Duration <= 0 {
+        return 0
+    }
+    return float64(f.Successes) / f.Duration.Seconds()
+}
+
+var (
+    folderStats     []FolderStats
+    folderStatsLock sync.Mutex
+)
+
+// RecordFolderStats stores the statistics of a completed subfolder.
+func RecordFolderStats(fs FolderStats) {
+    folderStatsLock.Lock()
+    defer folderStatsLock.Unlock()
+    folderStats = append(folderStats, fs)
+    PublishEvent(EventFolderCompleted, fs)
+}
+
+// GetFolderStats returns a copy of the statistics of every completed subfolder, sorted by index.
+func GetFolderStats() []FolderStats {
+    folderStatsLock.Lock()
+    defer folderStatsLock.Unlock()
+
+    result := make([]FolderStats, len(folderStats))
+    copy(result, folderStats)
+    sort.Slice(result, func(i, j int) bool { return result[i].Index < result[j].Index })
+    return result
+}
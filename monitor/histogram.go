@@ -0,0 +1,54 @@
+// monitor/histogram.go
+package monitor
+
+import (
+    "math"
+    "time"
+)
+
+// delayHistogram accumulates delays on the log-scale latency buckets so percentiles
+// can be reported without keeping every sample. It is not safe for concurrent use.
+type delayHistogram struct {
+    count   int64
+    min     time.Duration
+    max     time.Duration
+    total   time.Duration
+    buckets [latencyBucketCount]int64
+}
+
+// add records one delay. Negative delays are recorded as zero.
+func (h *delayHistogram) add(d time.Duration) {
+    if d < 0 {
+        d = 0
+    }
+    if h.count == 0 || d < h.min {
+        h.min = d
+    }
+    if d > h.max {
+        h.max = d
+    }
+    h.count++
+    h.total += d
+    h.buckets[latencyBucket(d)]++
+}
+
+// percentile returns the upper bound of the bucket holding the given percentile (0-1),
+// capped at the largest recorded delay.
+func (h *delayHistogram) percentile(p float64) time.Duration {
+    if h.count == 0 {
+        return 0
+    }
+    target := int64(math.Ceil(float64(h.count) * p))
+    var seen int64
+    for b, n := range h.buckets {
+        seen += n
+        if seen >= target {
+            // The last bucket is open-ended.
+            if upper := latencyBucketUpper(b); b < latencyBucketCount-1 && upper < h.max {
+                return upper
+            }
+            return h.max
+        }
+    }
+    return h.max
+}
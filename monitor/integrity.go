@@ -0,0 +1,28 @@
+// monitor/integrity.go
+package monitor
+
+import "sync/atomic"
+
+// IntegrityStats holds the results of ETag verification.
+type IntegrityStats struct {
+    Checked    int64 `json:"Checked"`
+    Mismatches int64 `json:"Mismatches"`
+}
+
+var integrityStats IntegrityStats
+
+// RecordETagCheck records the outcome of comparing an object's ETag with the local MD5.
+func RecordETagCheck(match bool) {
+    atomic.AddInt64(&integrityStats.Checked, 1)
+    if !match {
+        atomic.AddInt64(&integrityStats.Mismatches, 1)
+    }
+}
+
+// GetIntegrityStats returns a copy of the ETag verification counters.
+func GetIntegrityStats() IntegrityStats {
+    return IntegrityStats{
+        Checked:    atomic.LoadInt64(&integrityStats.Checked),
+        Mismatches: atomic.LoadInt64(&integrityStats.Mismatches),
+    }
+}
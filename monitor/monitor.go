@@ -7,7 +7,10 @@ import (
     "fmt"
     "os"
     "sync"
+    "sync/atomic"
     "time"
+
+    "scale_s3_benchmark/logrotate"
 )
 
 // Stats define a estrutura para armazenar estatísticas.
@@ -15,105 +18,134 @@ type Stats struct {
     TotalUploads int64     `json:"TotalUploads"`
     Successes    int64     `json:"Successes"`
     Failures     int64     `json:"Failures"`
+    Skipped      int64     `json:"Skipped"`
+    Bytes        int64     `json:"Bytes"` // Bytes enviados com sucesso.
     StartTime    time.Time `json:"StartTime"`
 }
 
 var (
-    stats     Stats
+    stats     Stats // Os contadores são atualizados atomicamente; StartTime é protegido por statsLock.
     statsLock sync.Mutex
 )
 
 // InitializeStats inicializa as estatísticas.
 func InitializeStats() {
-    stats = Stats{
-        StartTime: time.Now(),
-    }
+    ResetStats()
 }
 
 // UpdateStats atualiza as estatísticas após um upload.
 func UpdateStats(success bool) {
-    statsLock.Lock()
-    defer statsLock.Unlock()
-
-    stats.TotalUploads++
+    atomic.AddInt64(&stats.TotalUploads, 1)
     if success {
-        stats.Successes++
+        atomic.AddInt64(&stats.Successes, 1)
     } else {
-        stats.Failures++
+        atomic.AddInt64(&stats.Failures, 1)
     }
+
+    RecordOutcome(success)
+}
+
+// AddUploadStats adiciona em lote o resultado de vários uploads, e os bytes enviados com
+// sucesso, às estatísticas globais. Diferente de UpdateStats, não alimenta a política de
+// abort, que o chamador deve atualizar a cada upload com RecordOutcome.
+func AddUploadStats(successes, failures, bytes int64) {
+    if successes+failures == 0 {
+        return
+    }
+    atomic.AddInt64(&stats.TotalUploads, successes+failures)
+    if successes > 0 {
+        atomic.AddInt64(&stats.Successes, successes)
+        atomic.AddInt64(&stats.Bytes, bytes)
+    }
+    if failures > 0 {
+        atomic.AddInt64(&stats.Failures, failures)
+    }
+}
+
+// RecordSkipped registra um upload ignorado porque o objeto já existia no bucket.
+func RecordSkipped() {
+    atomic.AddInt64(&stats.Skipped, 1)
 }
 
 // GetStats retorna uma cópia das estatísticas atuais.
 func GetStats() Stats {
     statsLock.Lock()
-    defer statsLock.Unlock()
-    return stats
+    startTime := stats.StartTime
+    statsLock.Unlock()
+
+    return Stats{
+        TotalUploads: atomic.LoadInt64(&stats.TotalUploads),
+        Successes:    atomic.LoadInt64(&stats.Successes),
+        Failures:     atomic.LoadInt64(&stats.Failures),
+        Skipped:      atomic.LoadInt64(&stats.Skipped),
+        Bytes:        atomic.LoadInt64(&stats.Bytes),
+        StartTime:    startTime,
+    }
 }
 
 // ToJSON retorna as estatísticas em formato JSON.
 func ToJSON() ([]byte, error) {
-    statsLock.Lock()
-    defer statsLock.Unlock()
-    return json.Marshal(stats)
+    return json.Marshal(GetStats())
 }
 
 // ResetStats reseta as estatísticas (opcional).
 func ResetStats() {
     statsLock.Lock()
     defer statsLock.Unlock()
-    stats = Stats{
-        StartTime: time.Now(),
-    }
+
+    atomic.StoreInt64(&stats.TotalUploads, 0)
+    atomic.StoreInt64(&stats.Successes, 0)
+    atomic.StoreInt64(&stats.Failures, 0)
+    atomic.StoreInt64(&stats.Skipped, 0)
+    atomic.StoreInt64(&stats.Bytes, 0)
+    stats.StartTime = time.Now()
 }
 
 // StartPeriodicReporting inicia uma goroutine que grava estatísticas em um arquivo CSV a cada intervalo definido.
-func StartPeriodicReporting(filePath string, interval time.Duration) {
+// O arquivo é rotacionado entre registros conforme a política de rotação.
+func StartPeriodicReporting(filePath string, interval time.Duration, rotation logrotate.Policy) {
     go func() {
         ticker := time.NewTicker(interval)
         defer ticker.Stop()
 
-        // Abrir o arquivo em modo de acréscimo (append), criar se não existir
-        file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+        file, writer, err := openPeriodicReport(filePath)
         if err != nil {
-            fmt.Printf("Erro ao abrir o arquivo de relatório: %v\n", err)
+            fmt.Println(err)
             return
         }
-        defer file.Close()
-
-        writer := csv.NewWriter(file)
-        defer writer.Flush()
-
-        // Escrever cabeçalho CSV se o arquivo estiver vazio
-        fileInfo, err := file.Stat()
-        if err != nil {
-            fmt.Printf("Erro ao obter informações do arquivo: %v\n", err)
-            return
-        }
-        if fileInfo.Size() == 0 {
-            header := []string{"Timestamp", "TotalUploads", "Successes", "Failures"}
-            if err := writer.Write(header); err != nil {
-                fmt.Printf("Erro ao escrever cabeçalho no arquivo de relatório: %v\n", err)
-                return
-            }
-            writer.Flush()
-            if err := writer.Error(); err != nil {
-                fmt.Printf("Erro ao flush do cabeçalho no arquivo de relatório: %v\n", err)
-                return
-            }
-        }
+        defer func() { file.Close() }()
+        opened := time.Now()
 
         for {
             select {
             case <-ticker.C:
-                statsLock.Lock()
-                currentStats := stats
-                statsLock.Unlock()
+                // Rotacionar o arquivo antes do próximo registro, se necessário.
+                if info, err := file.Stat(); err == nil && rotation.Due(info.Size(), opened) {
+                    file.Close()
+                    if _, err := rotation.Rotate(filePath); err != nil {
+                        fmt.Println(err)
+                    }
+                    file, writer, err = openPeriodicReport(filePath)
+                    if err != nil {
+                        fmt.Println(err)
+                        return
+                    }
+                    opened = time.Now()
+                }
+
+                currentStats := GetStats()
+                connTotals := TotalConnStats()
 
                 record := []string{
                     time.Now().Format(time.RFC3339), // Timestamp atual
                     fmt.Sprintf("%d", currentStats.TotalUploads),
                     fmt.Sprintf("%d", currentStats.Successes),
                     fmt.Sprintf("%d", currentStats.Failures),
+                    fmt.Sprintf("%d", connTotals.NewConns),
+                    fmt.Sprintf("%d", connTotals.ReusedConns),
+                    fmt.Sprintf("%d", connTotals.TLSHandshakes),
+                    fmt.Sprintf("%d", connTotals.DNSLookups),
+                    RunID(),
                 }
 
                 if err := writer.Write(record); err != nil {
@@ -129,3 +161,34 @@ func StartPeriodicReporting(filePath string, interval time.Duration) {
         }
     }()
 }
+
+// openPeriodicReport abre o arquivo de relatório em modo de acréscimo (append), criando-o com
+// o cabeçalho CSV se não existir ou estiver vazio.
+func openPeriodicReport(filePath string) (*os.File, *csv.Writer, error) {
+    file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+    if err != nil {
+        return nil, nil, fmt.Errorf("Erro ao abrir o arquivo de relatório: %v", err)
+    }
+
+    writer := csv.NewWriter(file)
+
+    // Escrever cabeçalho CSV se o arquivo estiver vazio
+    fileInfo, err := file.Stat()
+    if err != nil {
+        file.Close()
+        return nil, nil, fmt.Errorf("Erro ao obter informações do arquivo: %v", err)
+    }
+    if fileInfo.Size() == 0 {
+        header := []string{"Timestamp", "TotalUploads", "Successes", "Failures", "NewConns", "ReusedConns", "TLSHandshakes", "DNSLookups", "RunID"}
+        if err := writer.Write(header); err != nil {
+            file.Close()
+            return nil, nil, fmt.Errorf("Erro ao escrever cabeçalho no arquivo de relatório: %v", err)
+        }
+        writer.Flush()
+        if err := writer.Error(); err != nil {
+            file.Close()
+            return nil, nil, fmt.Errorf("Erro ao flush do cabeçalho no arquivo de relatório: %v", err)
+        }
+    }
+    return file, writer, nil
+}
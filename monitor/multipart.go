@@ -0,0 +1,56 @@
+// monitor/multipart.go
+package monitor
+
+import (
+    "sync"
+    "time"
+)
+
+// MultipartStats holds aggregate statistics for multipart uploads.
+type MultipartStats struct {
+    Uploads         int64         `json:"Uploads"`
+    Parts           int64         `json:"Parts"`
+    EndpointsPerObj int64         `json:"EndpointsPerObj"` // Sum of distinct endpoints used per object.
+    TotalTime       time.Duration `json:"TotalTime"`
+    MaxTime         time.Duration `json:"MaxTime"`
+    Abandoned       int64         `json:"Abandoned"`      // Uploads deliberately left incomplete by multipartAbandonPercent.
+    AbandonedParts  int64         `json:"AbandonedParts"` // Parts left behind by the abandoned uploads.
+    AbandonedBytes  int64         `json:"AbandonedBytes"`
+}
+
+var (
+    multipartStats     MultipartStats
+    multipartStatsLock sync.Mutex
+)
+
+// RecordMultipartUpload records a completed multipart upload.
+func RecordMultipartUpload(parts, endpoints int, d time.Duration) {
+    multipartStatsLock.Lock()
+    defer multipartStatsLock.Unlock()
+
+    multipartStats.Uploads++
+    multipartStats.Parts += int64(parts)
+    multipartStats.EndpointsPerObj += int64(endpoints)
+    multipartStats.TotalTime += d
+    if d > multipartStats.MaxTime {
+        multipartStats.MaxTime = d
+    }
+}
+
+// RecordAbandonedMultipart records a multipart upload whose parts were uploaded but which was
+// deliberately neither completed nor aborted.
+func RecordAbandonedMultipart(parts int, bytes int64) {
+    multipartStatsLock.Lock()
+    defer multipartStatsLock.Unlock()
+
+    multipartStats.Abandoned++
+    multipartStats.AbandonedParts += int64(parts)
+    multipartStats.AbandonedBytes += bytes
+}
+
+// GetMultipartStats returns a copy of the multipart upload statistics.
+func GetMultipartStats() MultipartStats {
+    multipartStatsLock.Lock()
+    defer multipartStatsLock.Unlock()
+    return multipartStats
+}
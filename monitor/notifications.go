@@ -0,0 +1,126 @@
+// monitor/notifications.go
+package monitor
+
+import (
+    "sync"
+    "time"
+)
+
+// NotificationStats holds the delay between PutObject completion and the arrival of the
+// matching bucket notification.
+type NotificationStats struct {
+    Expected   int64         `json:"Expected"`   // Objects uploaded while notifications were tracked.
+    Received   int64         `json:"Received"`   // Notifications matched to an uploaded object.
+    Missing    int64         `json:"Missing"`    // Uploaded objects whose notification never arrived.
+    Unmatched  int64         `json:"Unmatched"`  // Notifications for keys this run did not upload.
+    MinDelay   time.Duration `json:"MinDelay"`
+    MaxDelay   time.Duration `json:"MaxDelay"`
+    TotalDelay time.Duration `json:"TotalDelay"`
+    P50        time.Duration `json:"P50"`
+    P99        time.Duration `json:"P99"`
+}
+
+var (
+    notificationsEnabled bool
+    notificationStats    NotificationStats
+    notificationDelays   delayHistogram
+    pendingPuts          = make(map[string]time.Time) // Uploaded keys still waiting for their notification.
+    earlyNotifications   = make(map[string]time.Time) // Notifications that arrived before the upload was recorded.
+    notificationLock     sync.Mutex
+)
+
+// EnableNotificationTracking starts recording uploaded keys so their notifications can be matched.
+func EnableNotificationTracking() {
+    notificationLock.Lock()
+    defer notificationLock.Unlock()
+    notificationsEnabled = true
+}
+
+// RecordObjectPut records the completion of a PutObject. It does nothing unless notification tracking is enabled.
+func RecordObjectPut(key string) {
+    now := time.Now()
+
+    notificationLock.Lock()
+    defer notificationLock.Unlock()
+
+    if !notificationsEnabled {
+        return
+    }
+    notificationStats.Expected++
+    if arrival, ok := earlyNotifications[key]; ok {
+        // The notification beat the PutObject response back to the client.
+        delete(earlyNotifications, key)
+        notificationDelays.add(arrival.Sub(now))
+        return
+    }
+    pendingPuts[key] = now
+}
+
+// RecordNotification records the arrival of an object-created notification for key.
+func RecordNotification(key string) {
+    now := time.Now()
+
+    notificationLock.Lock()
+    defer notificationLock.Unlock()
+
+    if !notificationsEnabled {
+        return
+    }
+    put, ok := pendingPuts[key]
+    if !ok {
+        earlyNotifications[key] = now
+        return
+    }
+    delete(pendingPuts, key)
+    notificationDelays.add(now.Sub(put))
+}
+
+// PendingNotifications returns the number of uploaded objects still waiting for their notification.
+func PendingNotifications() int {
+    notificationLock.Lock()
+    defer notificationLock.Unlock()
+    return len(pendingPuts)
+}
+
+// FinishNotifications stops tracking and counts every object still waiting for its
+// notification as missing, and every notification that matched no upload as unmatched.
+func FinishNotifications() {
+    notificationLock.Lock()
+    defer notificationLock.Unlock()
+
+    if !notificationsEnabled {
+        return
+    }
+    notificationsEnabled = false
+    notificationStats.Missing += int64(len(pendingPuts))
+    notificationStats.Unmatched += int64(len(earlyNotifications))
+    pendingPuts = make(map[string]time.Time)
+    earlyNotifications = make(map[string]time.Time)
+}
+
+// GetNotificationStats returns a copy of the notification delay statistics.
+func GetNotificationStats() NotificationStats {
+    notificationLock.Lock()
+    defer notificationLock.Unlock()
+
+    stats := notificationStats
+    stats.Received = notificationDelays.count
+    stats.MinDelay = notificationDelays.min
+    stats.MaxDelay = notificationDelays.max
+    stats.TotalDelay = notificationDelays.total
+    stats.P50 = notificationDelays.percentile(0.50)
+    stats.P99 = notificationDelays.percentile(0.99)
+    return stats
+}
+
+// resetNotifications clears the notification statistics for a new run.
+func resetNotifications() {
+    notificationLock.Lock()
+    defer notificationLock.Unlock()
+
+    notificationsEnabled = false
+    notificationStats = NotificationStats{}
+    notificationDelays = delayHistogram{}
+    pendingPuts = make(map[string]time.Time)
+    earlyNotifications = make(map[string]time.Time)
+}
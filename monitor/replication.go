@@ -0,0 +1,80 @@
+// monitor/replication.go
+package monitor
+
+import (
+    "sync"
+    "time"
+)
+
+// ReplicationStats holds the delay between an upload completing on the source and the
+// object becoming visible on the replication destination.
+type ReplicationStats struct {
+    Objects    int64         `json:"Objects"`    // Uploaded objects being checked on the destination.
+    Replicated int64         `json:"Replicated"` // Objects found on the destination.
+    TimedOut   int64         `json:"TimedOut"`   // Objects not found on the destination before the timeout.
+    PollErrors int64         `json:"PollErrors"` // HEAD requests to the destination that failed with an error other than not found.
+    MinLag     time.Duration `json:"MinLag"`
+    MaxLag     time.Duration `json:"MaxLag"`
+    TotalLag   time.Duration `json:"TotalLag"`
+    P50        time.Duration `json:"P50"`
+    P90        time.Duration `json:"P90"`
+    P99        time.Duration `json:"P99"`
+}
+
+var (
+    replicationStats ReplicationStats
+    replicationLags  delayHistogram
+    replicationLock  sync.Mutex
+)
+
+// RecordReplicationStarted records an uploaded object whose replication will be checked.
+func RecordReplicationStarted() {
+    replicationLock.Lock()
+    defer replicationLock.Unlock()
+    replicationStats.Objects++
+}
+
+// RecordReplicationLag records an object found on the destination after the given lag.
+func RecordReplicationLag(lag time.Duration) {
+    replicationLock.Lock()
+    defer replicationLock.Unlock()
+    replicationLags.add(lag)
+}
+
+// RecordReplicationTimeout records an object that did not appear on the destination in time.
+func RecordReplicationTimeout() {
+    replicationLock.Lock()
+    defer replicationLock.Unlock()
+    replicationStats.TimedOut++
+}
+
+// RecordReplicationPollError records a failed HEAD request to the destination.
+func RecordReplicationPollError() {
+    replicationLock.Lock()
+    defer replicationLock.Unlock()
+    replicationStats.PollErrors++
+}
+
+// GetReplicationStats returns a copy of the replication lag statistics.
+func GetReplicationStats() ReplicationStats {
+    replicationLock.Lock()
+    defer replicationLock.Unlock()
+
+    stats := replicationStats
+    stats.Replicated = replicationLags.count
+    stats.MinLag = replicationLags.min
+    stats.MaxLag = replicationLags.max
+    stats.TotalLag = replicationLags.total
+    stats.P50 = replicationLags.percentile(0.50)
+    stats.P90 = replicationLags.percentile(0.90)
+    stats.P99 = replicationLags.percentile(0.99)
+    return stats
+}
+
+// resetReplication clears the replication lag statistics for a new run.
+func resetReplication() {
+    replicationLock.Lock()
+    defer replicationLock.Unlock()
+    replicationStats = ReplicationStats{}
+    replicationLags = delayHistogram{}
+}
@@ -0,0 +1,208 @@
+// monitor/resources.go
+package monitor
+
+import (
+    "bufio"
+    "os"
+    "strconv"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+)
+
+// maxResourceSamples bounds the resource time series. When it is full, every other sample is
+// dropped and samples are taken half as often, so long runs keep an even, coarser series.
+const maxResourceSamples = 1000
+
+// ResourceSample is one measurement of the load generator host. Values that cannot be read,
+// such as the /proc based ones outside Linux, are zero.
+type ResourceSample struct {
+    Time             time.Time `json:"Time"`
+    CPUPercent       float64   `json:"CPUPercent"`       // CPU time used by the tool, in percent of one core.
+    HostCPUPercent   float64   `json:"HostCPUPercent"`   // Busy time of all CPUs of the host.
+    RSSBytes         int64     `json:"RSSBytes"`         // Resident memory of the tool.
+    HostMemPercent   float64   `json:"HostMemPercent"`   // Host memory in use, excluding reclaimable caches.
+    NetRxBytesPerSec float64   `json:"NetRxBytesPerSec"` // Received by all network interfaces except loopback.
+    NetTxBytesPerSec float64   `json:"NetTxBytesPerSec"` // Sent by all network interfaces except loopback.
+    OpenFDs          int       `json:"OpenFDs"`
+}
+
+// resourceCounters are the cumulative counters rates are computed from.
+type resourceCounters struct {
+    time      time.Time
+    cpu       time.Duration
+    hostBusy  int64
+    hostTotal int64
+    rx        int64
+    tx        int64
+}
+
+var (
+    resourceSamples     []ResourceSample
+    resourceStride      = 1 // Ticks per recorded sample.
+    resourceTicks       int
+    resourceSamplesLock sync.Mutex
+)
+
+// StartResourceSampling samples the load generator host every interval for the rest of the
+// process. The samples of a run are reset with the run.
+func StartResourceSampling(interval time.Duration) {
+    go func() {
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+
+        previous := readResourceCounters()
+        for range ticker.C {
+            current := readResourceCounters()
+            recordResourceSample(resourceSample(previous, current))
+            previous = current
+        }
+    }()
+}
+
+// recordResourceSample keeps a sample every resourceStride ticks, thinning the series when full.
+func recordResourceSample(s ResourceSample) {
+    resourceSamplesLock.Lock()
+    defer resourceSamplesLock.Unlock()
+
+    resourceTicks++
+    if resourceTicks%resourceStride != 0 {
+        return
+    }
+    if len(resourceSamples) == maxResourceSamples {
+        kept := resourceSamples[:0]
+        for i := 1; i < len(resourceSamples); i += 2 {
+            kept = append(kept, resourceSamples[i])
+        }
+        resourceSamples = kept
+        resourceStride *= 2
+    }
+    resourceSamples = append(resourceSamples, s)
+}
+
+// GetResourceSamples returns a copy of the resource time series of the run.
+func GetResourceSamples() []ResourceSample {
+    resourceSamplesLock.Lock()
+    defer resourceSamplesLock.Unlock()
+    return append([]ResourceSample(nil), resourceSamples...)
+}
+
+// resetResourceSamples forgets the samples of the previous run.
+func resetResourceSamples() {
+    resourceSamplesLock.Lock()
+    defer resourceSamplesLock.Unlock()
+    resourceSamples = nil
+    resourceStride = 1
+    resourceTicks = 0
+}
+
+// resourceSample computes the rates between two readings and adds the current gauges.
+func resourceSample(previous, current resourceCounters) ResourceSample {
+    s := ResourceSample{Time: current.time}
+
+    elapsed := current.time.Sub(previous.time).Seconds()
+    if elapsed > 0 {
+        s.CPUPercent = (current.cpu - previous.cpu).Seconds() / elapsed * 100
+        s.NetRxBytesPerSec = float64(current.rx-previous.rx) / elapsed
+        s.NetTxBytesPerSec = float64(current.tx-previous.tx) / elapsed
+    }
+    if total := current.hostTotal - previous.hostTotal; total > 0 {
+        s.HostCPUPercent = float64(current.hostBusy-previous.hostBusy) / float64(total) * 100
+    }
+
+    s.RSSBytes = readRSS()
+    s.HostMemPercent = readHostMemPercent()
+    if entries, err := os.ReadDir("/proc/self/fd"); err == nil {
+        s.OpenFDs = len(entries)
+    }
+    return s
+}
+
+// readResourceCounters reads the cumulative CPU and network counters.
+func readResourceCounters() resourceCounters {
+    c := resourceCounters{time: time.Now()}
+
+    var usage syscall.Rusage
+    if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err == nil {
+        c.cpu = time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+    }
+
+    // The first line of /proc/stat holds the time all CPUs spent in each state. Guest time,
+    // after the first eight states, is already counted as user time.
+    if fields := procFields("/proc/stat", "cpu "); len(fields) >= 9 {
+        for i, f := range fields[1:9] {
+            n, _ := strconv.ParseInt(f, 10, 64)
+            c.hostTotal += n
+            if i != 3 && i != 4 { // idle and iowait
+                c.hostBusy += n
+            }
+        }
+    }
+
+    if file, err := os.Open("/proc/net/dev"); err == nil {
+        scanner := bufio.NewScanner(file)
+        for scanner.Scan() {
+            name, counters, ok := strings.Cut(scanner.Text(), ":")
+            if !ok || strings.TrimSpace(name) == "lo" {
+                continue
+            }
+            fields := strings.Fields(counters)
+            if len(fields) < 9 {
+                continue
+            }
+            rx, _ := strconv.ParseInt(fields[0], 10, 64)
+            tx, _ := strconv.ParseInt(fields[8], 10, 64)
+            c.rx += rx
+            c.tx += tx
+        }
+        file.Close()
+    }
+    return c
+}
+
+// readRSS returns the resident memory of the process from /proc/self/statm.
+func readRSS() int64 {
+    data, err := os.ReadFile("/proc/self/statm")
+    if err != nil {
+        return 0
+    }
+    fields := strings.Fields(string(data))
+    if len(fields) < 2 {
+        return 0
+    }
+    pages, _ := strconv.ParseInt(fields[1], 10, 64)
+    return pages * int64(os.Getpagesize())
+}
+
+// readHostMemPercent returns the share of host memory that is not available from /proc/meminfo.
+func readHostMemPercent() float64 {
+    total := procFields("/proc/meminfo", "MemTotal:")
+    available := procFields("/proc/meminfo", "MemAvailable:")
+    if len(total) < 2 || len(available) < 2 {
+        return 0
+    }
+    t, _ := strconv.ParseFloat(total[1], 64)
+    a, _ := strconv.ParseFloat(available[1], 64)
+    if t == 0 {
+        return 0
+    }
+    return (t - a) / t * 100
+}
+
+// procFields returns the fields of the first line of a /proc file that starts with prefix.
+func procFields(path, prefix string) []string {
+    file, err := os.Open(path)
+    if err != nil {
+        return nil
+    }
+    defer file.Close()
+
+    scanner := bufio.NewScanner(file)
+    for scanner.Scan() {
+        if line := scanner.Text(); strings.HasPrefix(line, prefix) {
+            return strings.Fields(line)
+        }
+    }
+    return nil
+}
@@ -0,0 +1,67 @@
+// monitor/stalls.go
+package monitor
+
+import (
+    "sort"
+    "sync"
+)
+
+// StallStats counts the transfers cancelled by the stall watchdog because they made no byte
+// progress for stallTimeoutSeconds.
+type StallStats struct {
+    Sending   int64            `json:"Sending"`   // Stalled before the response arrived: while sending the request body or waiting for the headers.
+    Receiving int64            `json:"Receiving"` // Stalled while reading the response body.
+    Endpoints []EndpointStalls `json:"Endpoints,omitempty"`
+}
+
+// EndpointStalls is the number of stalled transfers of one endpoint.
+type EndpointStalls struct {
+    Endpoint string `json:"Endpoint"`
+    Stalls   int64  `json:"Stalls"`
+}
+
+// Total returns the number of stalled transfers.
+func (s StallStats) Total() int64 {
+    return s.Sending + s.Receiving
+}
+
+var (
+    stallStats     StallStats
+    endpointStalls = make(map[string]int64)
+    stallsLock     sync.Mutex
+)
+
+// RecordStall records a transfer to endpoint cancelled by the stall watchdog, and whether it
+// stalled while reading the response body.
+func RecordStall(endpoint string, receiving bool) {
+    stallsLock.Lock()
+    defer stallsLock.Unlock()
+
+    if receiving {
+        stallStats.Receiving++
+    } else {
+        stallStats.Sending++
+    }
+    endpointStalls[endpoint]++
+}
+
+// GetStallStats returns a copy of the stall counters, with the endpoints sorted by name.
+func GetStallStats() StallStats {
+    stallsLock.Lock()
+    defer stallsLock.Unlock()
+
+    s := StallStats{Sending: stallStats.Sending, Receiving: stallStats.Receiving}
+    for endpoint, n := range endpointStalls {
+        s.Endpoints = append(s.Endpoints, EndpointStalls{Endpoint: endpoint, Stalls: n})
+    }
+    sort.Slice(s.Endpoints, func(i, j int) bool { return s.Endpoints[i].Endpoint < s.Endpoints[j].Endpoint })
+    return s
+}
+
+// resetStalls forgets the stalled transfers of the previous run.
+func resetStalls() {
+    stallsLock.Lock()
+    defer stallsLock.Unlock()
+    stallStats = StallStats{}
+    endpointStalls = make(map[string]int64)
+}
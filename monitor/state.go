@@ -0,0 +1,65 @@
+// monitor/state.go
+package monitor
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "time"
+)
+
+// StateDump is a snapshot of every statistic collected during a run.
+type StateDump struct {
+    Time           time.Time         `json:"Time"`
+    RunID          string            `json:"RunID"`
+    Reason         string            `json:"Reason"`
+    AbortReason    string            `json:"AbortReason,omitempty"`
+    TruncateReason string            `json:"TruncateReason,omitempty"` // Why the run was stopped early without being aborted.
+    Stats          Stats             `json:"Stats"`
+    Folders        []FolderStats     `json:"Folders"`
+    Connections    []ConnStats       `json:"Connections"`
+    CircuitEvents  []CircuitEvent    `json:"CircuitEvents"`
+    Multipart      MultipartStats    `json:"Multipart"`
+    Integrity      IntegrityStats    `json:"Integrity"`
+    Faults         FaultStats        `json:"Faults"`
+    Stalls         StallStats        `json:"Stalls"`
+    Notifications  NotificationStats `json:"Notifications"`
+    Replication    ReplicationStats  `json:"Replication"`
+    Errors         []ErrorSignature  `json:"Errors"`    // Failed requests by signature, the most frequent first.
+    Resources      []ResourceSample  `json:"Resources"` // Load generator CPU, memory, network and file descriptors over time.
+}
+
+// Snapshot collects the current statistics into a StateDump.
+func Snapshot(reason string) StateDump {
+    return StateDump{
+        Time:           time.Now(),
+        RunID:          RunID(),
+        Reason:         reason,
+        AbortReason:    AbortReason(),
+        TruncateReason: TruncateReason(),
+        Stats:          GetStats(),
+        Folders:        GetFolderStats(),
+        Connections:    GetConnStats(),
+        CircuitEvents:  GetCircuitEvents(),
+        Multipart:      GetMultipartStats(),
+        Integrity:      GetIntegrityStats(),
+        Faults:         GetFaultStats(),
+        Stalls:         GetStallStats(),
+        Notifications:  GetNotificationStats(),
+        Replication:    GetReplicationStats(),
+        Errors:         GetErrorSignatures(),
+        Resources:      GetResourceSamples(),
+    }
+}
+
+// WriteStateDump writes a JSON snapshot of every statistic to a file.
+func WriteStateDump(path, reason string) error {
+    data, err := json.MarshalIndent(Snapshot(reason), "", "  ")
+    if err != nil {
+        return fmt.Errorf("error encoding state dump: %w", err)
+    }
+    if err := os.WriteFile(path, data, 0644); err != nil {
+        return fmt.Errorf("error writing state dump %s: %w", path, err)
+    }
+    return nil
+}
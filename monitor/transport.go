@@ -0,0 +1,137 @@
+// monitor/transport.go
+package monitor
+
+import (
+    "sort"
+    "sync"
+    "time"
+)
+
+// ConnStats holds HTTP transport counters for a single endpoint.
+type ConnStats struct {
+    Endpoint      string        `json:"Endpoint"`
+    Requests      int64         `json:"Requests"`
+    NewConns      int64         `json:"NewConns"`
+    ReusedConns   int64         `json:"ReusedConns"`
+    TLSHandshakes int64         `json:"TLSHandshakes"`
+    DNSLookups    int64         `json:"DNSLookups"`
+    DialTime      time.Duration `json:"DialTime"`
+    TLSTime       time.Duration `json:"TLSTime"`
+    DNSTime       time.Duration `json:"DNSTime"`
+}
+
+var (
+    connStats     = make(map[string]*ConnStats)
+    connStatsLock sync.Mutex
+)
+
+// connStatsFor returns the counters for an endpoint, creating them if needed.
+// The caller must hold connStatsLock.
+func connStatsFor(endpoint string) *ConnStats {
+    cs, ok := connStats[endpoint]
+    if !ok {
+        cs = &ConnStats{Endpoint: endpoint}
+        connStats[endpoint] = cs
+    }
+    return cs
+}
+
+// RecordConn records that a request obtained a connection, either new or reused from the pool.
+func RecordConn(endpoint string, reused bool) {
+    connStatsLock.Lock()
+    defer connStatsLock.Unlock()
+
+    cs := connStatsFor(endpoint)
+    cs.Requests++
+    if reused {
+        cs.ReusedConns++
+    } else {
+        cs.NewConns++
+    }
+}
+
+// RecordDial records the time spent establishing a TCP connection.
+func RecordDial(endpoint string, d time.Duration) {
+    connStatsLock.Lock()
+    defer connStatsLock.Unlock()
+    connStatsFor(endpoint).DialTime += d
+}
+
+// RecordTLSHandshake records a completed TLS handshake and its duration.
+func RecordTLSHandshake(endpoint string, d time.Duration) {
+    connStatsLock.Lock()
+    defer connStatsLock.Unlock()
+
+    cs := connStatsFor(endpoint)
+    cs.TLSHandshakes++
+    cs.TLSTime += d
+}
+
+// RecordDNSLookup records a completed DNS lookup and its duration.
+func RecordDNSLookup(endpoint string, d time.Duration) {
+    connStatsLock.Lock()
+    defer connStatsLock.Unlock()
+
+    cs := connStatsFor(endpoint)
+    cs.DNSLookups++
+    cs.DNSTime += d
+}
+
+// GetConnStats returns a copy of the transport counters for every endpoint, sorted by endpoint.
+func GetConnStats() []ConnStats {
+    connStatsLock.Lock()
+    defer connStatsLock.Unlock()
+
+    result := make([]ConnStats, 0, len(connStats))
+    for _, cs := range connStats {
+        result = append(result, *cs)
+    }
+    sort.Slice(result, func(i, j int) bool { return result[i].Endpoint < result[j].Endpoint })
+    return result
+}
+
+// TotalConnStats returns the transport counters summed across all endpoints.
+func TotalConnStats() ConnStats {
+    total := ConnStats{Endpoint: "all"}
+    for _, cs := range GetConnStats() {
+        total.Requests += cs.Requests
+        total.NewConns += cs.NewConns
+        total.ReusedConns += cs.ReusedConns
+        total.TLSHandshakes += cs.TLSHandshakes
+        total.DNSLookups += cs.DNSLookups
+        total.DialTime += cs.DialTime
+        total.TLSTime += cs.TLSTime
+        total.DNSTime += cs.DNSTime
+    }
+    return total
+}
+
+// CircuitEvent records a circuit breaker state change for an endpoint.
+type CircuitEvent struct {
+    Time     time.Time `json:"Time"`
+    Endpoint string    `json:"Endpoint"`
+    State    string    `json:"State"`
+}
+
+var (
+    circuitEvents     []CircuitEvent
+    circuitEventsLock sync.Mutex
+)
+
+// RecordCircuitEvent records that the circuit for an endpoint changed state.
+func RecordCircuitEvent(endpoint, state string) {
+    circuitEventsLock.Lock()
+    defer circuitEventsLock.Unlock()
+    circuitEvents = append(circuitEvents, CircuitEvent{Time: time.Now(), Endpoint: endpoint, State: state})
+    setCircuitState(endpoint, state)
+}
+
+// GetCircuitEvents returns a copy of all recorded circuit breaker events in order.
+func GetCircuitEvents() []CircuitEvent {
+    circuitEventsLock.Lock()
+    defer circuitEventsLock.Unlock()
+
+    result := make([]CircuitEvent, len(circuitEvents))
+    copy(result, circuitEvents)
+    return result
+}
@@ -0,0 +1,46 @@
+// notify/amqp.go
+package notify
+
+import (
+    "context"
+    "fmt"
+
+    amqp "github.com/rabbitmq/amqp091-go"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/progress"
+)
+
+// startAMQP consumes NotificationAMQPQueue on the broker at NotificationQueueURL.
+func startAMQP(ctx context.Context, cfg *config.Config) error {
+    conn, err := amqp.Dial(cfg.NotificationQueueURL)
+    if err != nil {
+        return fmt.Errorf("error connecting to AMQP broker: %w", err)
+    }
+    ch, err := conn.Channel()
+    if err != nil {
+        conn.Close()
+        return fmt.Errorf("error opening AMQP channel: %w", err)
+    }
+    deliveries, err := ch.Consume(cfg.NotificationAMQPQueue, "", true, false, false, false, nil)
+    if err != nil {
+        conn.Close()
+        return fmt.Errorf("error consuming AMQP queue %s: %w", cfg.NotificationAMQPQueue, err)
+    }
+
+    go func() {
+        <-ctx.Done()
+        conn.Close()
+    }()
+    go func() {
+        for d := range deliveries {
+            if err := handleMessage(cfg.BucketName, d.Body); err != nil {
+                progress.Printf("Error handling AMQP notification: %v\n", err)
+            }
+        }
+        if ctx.Err() == nil {
+            fmt.Println("AMQP notification consumer stopped: connection closed")
+        }
+    }()
+    return nil
+}
@@ -0,0 +1,101 @@
+// notify/notify.go
+package notify
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+    "strings"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// s3Event is the S3 event notification message format, also used by MinIO and Ceph.
+type s3Event struct {
+    Records []struct {
+        EventName string `json:"eventName"`
+        S3        struct {
+            Bucket struct {
+                Name string `json:"name"`
+            } `json:"bucket"`
+            Object struct {
+                Key string `json:"key"`
+            } `json:"object"`
+        } `json:"s3"`
+    } `json:"Records"`
+}
+
+// snsEnvelope wraps an S3 event delivered through an SNS topic subscribed to the queue.
+type snsEnvelope struct {
+    Type    string `json:"Type"`
+    Message string `json:"Message"`
+}
+
+// Start begins receiving bucket notifications from the configured source and enables
+// notification tracking in the monitor. The returned function stops the receiver.
+func Start(cfg *config.Config) (stop func(), err error) {
+    ctx, cancel := context.WithCancel(context.Background())
+
+    switch cfg.NotificationSource {
+    case config.NotificationSourceWebhook:
+        err = startWebhook(ctx, cfg)
+    case config.NotificationSourceSQS:
+        err = startSQS(ctx, cfg)
+    case config.NotificationSourceAMQP:
+        err = startAMQP(ctx, cfg)
+    default:
+        err = fmt.Errorf("unknown notification source %q", cfg.NotificationSource)
+    }
+    if err != nil {
+        cancel()
+        return nil, err
+    }
+
+    monitor.EnableNotificationTracking()
+    fmt.Printf("Receiving bucket notifications from %s\n", cfg.NotificationSource)
+    return cancel, nil
+}
+
+// WaitForPending blocks until every uploaded object has received its notification, the grace
+// period ends or the run is cancelled.
+func WaitForPending(grace time.Duration) {
+    deadline := time.Now().Add(grace)
+    for monitor.PendingNotifications() > 0 && time.Now().Before(deadline) {
+        if !monitor.SleepRun(100 * time.Millisecond) {
+            return
+        }
+    }
+}
+
+// handleMessage records every object-created notification for bucket found in a message body.
+func handleMessage(bucket string, body []byte) error {
+    var event s3Event
+    if err := json.Unmarshal(body, &event); err != nil {
+        return fmt.Errorf("error decoding notification: %w", err)
+    }
+
+    if len(event.Records) == 0 {
+        var envelope snsEnvelope
+        if err := json.Unmarshal(body, &envelope); err == nil && envelope.Type == "Notification" && envelope.Message != "" {
+            return handleMessage(bucket, []byte(envelope.Message))
+        }
+        // Test events sent when the notification is configured carry no records.
+        return nil
+    }
+
+    for _, r := range event.Records {
+        if !strings.Contains(r.EventName, "ObjectCreated:") || r.S3.Bucket.Name != bucket {
+            continue
+        }
+        // Keys are URL-encoded in notifications, with spaces as '+'.
+        key, err := url.QueryUnescape(r.S3.Object.Key)
+        if err != nil {
+            key = r.S3.Object.Key
+        }
+        monitor.RecordNotification(key)
+    }
+    return nil
+}
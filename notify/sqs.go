@@ -0,0 +1,61 @@
+// notify/sqs.go
+package notify
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/session"
+    "github.com/aws/aws-sdk-go/service/sqs"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/progress"
+)
+
+// startSQS long-polls the SQS queue at NotificationQueueURL. Credentials come from the
+// standard AWS environment and shared configuration, not from accessKey and secretKey,
+// since the queue usually lives in a different account than the storage under test.
+func startSQS(ctx context.Context, cfg *config.Config) error {
+    sess, err := session.NewSession(&aws.Config{Region: aws.String(cfg.Region)})
+    if err != nil {
+        return fmt.Errorf("error creating SQS session: %w", err)
+    }
+    client := sqs.New(sess)
+    queueURL := aws.String(cfg.NotificationQueueURL)
+
+    go func() {
+        for ctx.Err() == nil {
+            out, err := client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+                QueueUrl:            queueURL,
+                MaxNumberOfMessages: aws.Int64(10),
+                WaitTimeSeconds:     aws.Int64(20),
+            })
+            if err != nil {
+                if ctx.Err() == nil {
+                    progress.Printf("Error receiving SQS notifications: %v\n", err)
+                    time.Sleep(time.Second)
+                }
+                continue
+            }
+
+            var entries []*sqs.DeleteMessageBatchRequestEntry
+            for i, msg := range out.Messages {
+                if err := handleMessage(cfg.BucketName, []byte(aws.StringValue(msg.Body))); err != nil {
+                    progress.Printf("Error handling SQS notification: %v\n", err)
+                }
+                entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+                    Id:            aws.String(fmt.Sprint(i)),
+                    ReceiptHandle: msg.ReceiptHandle,
+                })
+            }
+            if len(entries) > 0 {
+                if _, err := client.DeleteMessageBatch(&sqs.DeleteMessageBatchInput{QueueUrl: queueURL, Entries: entries}); err != nil {
+                    progress.Printf("Error deleting SQS notifications: %v\n", err)
+                }
+            }
+        }
+    }()
+    return nil
+}
@@ -0,0 +1,51 @@
+// notify/webhook.go
+package notify
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/progress"
+)
+
+// startWebhook listens for notifications POSTed by the storage system to NotificationWebhookAddress.
+func startWebhook(ctx context.Context, cfg *config.Config) error {
+    listener, err := net.Listen("tcp", cfg.NotificationWebhookAddress)
+    if err != nil {
+        return fmt.Errorf("error listening for webhook notifications on %s: %w", cfg.NotificationWebhookAddress, err)
+    }
+
+    server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // Endpoint checks done when the target is registered use other methods; accept them.
+        if r.Method != http.MethodPost {
+            w.WriteHeader(http.StatusOK)
+            return
+        }
+        body, err := io.ReadAll(r.Body)
+        if err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        if err := handleMessage(cfg.BucketName, body); err != nil {
+            progress.Printf("Error handling webhook notification: %v\n", err)
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+    })}
+
+    go func() {
+        if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+            fmt.Printf("Error serving webhook notifications: %v\n", err)
+        }
+    }()
+    go func() {
+        <-ctx.Done()
+        server.Close()
+    }()
+    return nil
+}
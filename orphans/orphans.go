@@ -0,0 +1,339 @@
+// orphans/orphans.go
+package orphans
+
+import (
+    "encoding/csv"
+    "fmt"
+    "os"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// Upload is one incomplete multipart upload found below the prefix.
+type Upload struct {
+    Key       string
+    UploadID  string
+    Initiated time.Time
+    Parts     int64
+    Bytes     int64
+    Skipped   bool // Initiated less than orphanMinAgeSeconds ago, so left alone.
+    Aborted   bool
+    Err       error // Error listing the parts or aborting the upload.
+}
+
+// Result is the outcome of an orphan cleanup.
+type Result struct {
+    RunID       string // Names the report; no objects are written.
+    Prefix      string
+    DryRun      bool
+    Uploads     []Upload
+    ListPages   stats.Latencies // ListMultipartUploads page times.
+    ListParts   stats.Latencies // Complete ListParts times per upload.
+    Aborts      stats.Latencies // Successful AbortMultipartUpload times.
+    Elapsed     time.Duration
+    StillListed int   // Aborted uploads the store still listed afterwards.
+    ListError   error // Error listing the uploads, before or after the aborts.
+}
+
+// Count returns the uploads that were not skipped, and their parts and bytes.
+func (r Result) Count() (uploads, parts, bytes int64) {
+    for _, u := range r.Uploads {
+        if !u.Skipped {
+            uploads++
+            parts += u.Parts
+            bytes += u.Bytes
+        }
+    }
+    return uploads, parts, bytes
+}
+
+// Errors returns the uploads that could not be inspected or aborted.
+func (r Result) Errors() int64 {
+    var errors int64
+    for _, u := range r.Uploads {
+        if u.Err != nil {
+            errors++
+        }
+    }
+    return errors
+}
+
+// Failures returns the upload errors, a failed listing and the aborted uploads still listed by
+// the store.
+func (r Result) Failures() int64 {
+    failures := r.Errors() + int64(r.StillListed)
+    if r.ListError != nil {
+        failures++
+    }
+    return failures
+}
+
+// Run lists the incomplete multipart uploads below prefix, counts the parts each one left on the
+// store and aborts the ones initiated at least orphanMinAgeSeconds ago, orphanConcurrency at a
+// time. Afterwards the uploads are listed again to check that the aborted ones are gone.
+func Run(cfg *config.Config, s3Clients []*s3.S3, prefix string) Result {
+    result := Result{RunID: cfg.NewRunID(), Prefix: prefix, DryRun: cfg.OrphanDryRun}
+    start := time.Now()
+
+    uploads, pages, err := list(cfg, s3Clients[0], prefix)
+    result.ListPages = pages
+    if err != nil {
+        fmt.Println(err)
+        result.ListError = err
+        result.Elapsed = time.Since(start)
+        return result
+    }
+    minAge := time.Duration(cfg.OrphanMinAgeSeconds) * time.Second
+    for i := range uploads {
+        uploads[i].Skipped = start.Sub(uploads[i].Initiated) < minAge
+    }
+    result.Uploads = uploads
+    fmt.Printf("Found %d incomplete multipart uploads below %s\n", len(uploads), prefix)
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    taskName := "Aborting incomplete uploads"
+    if result.DryRun {
+        taskName = "Inspecting incomplete uploads"
+    }
+    task := progress.Begin(taskName, "uploads", int64(len(uploads)))
+    for w := 0; w < cfg.OrphanConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := int(atomic.AddInt64(&next, 1) - 1)
+                if i >= len(result.Uploads) {
+                    return
+                }
+                u := &result.Uploads[i]
+                if u.Skipped {
+                    task.Add(1)
+                    continue
+                }
+
+                listTime, err := countParts(cfg, client, u)
+                var abortTime time.Duration
+                if err == nil && !result.DryRun {
+                    abortStart := time.Now()
+                    ctx, cancel := cfg.OperationContext(config.OperationDelete)
+                    _, err = client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+                        Bucket:   aws.String(cfg.BucketName),
+                        Key:      aws.String(u.Key),
+                        UploadId: aws.String(u.UploadID),
+                    })
+                    cancel()
+                    abortTime = time.Since(abortStart)
+                    if err != nil {
+                        err = fmt.Errorf("error aborting upload %s of %s: %w", u.UploadID, u.Key, err)
+                    }
+                }
+
+                mu.Lock()
+                if listTime > 0 {
+                    result.ListParts.Add(listTime)
+                }
+                if err == nil && !result.DryRun {
+                    u.Aborted = true
+                    result.Aborts.Add(abortTime)
+                }
+                u.Err = err
+                mu.Unlock()
+
+                if err != nil {
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("%v\n", err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+    task.Done()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+
+    if result.Aborts.Count > 0 {
+        remaining, _, err := list(cfg, s3Clients[0], prefix)
+        if err != nil {
+            result.ListError = err
+        } else {
+            listed := make(map[string]bool, len(remaining))
+            for _, u := range remaining {
+                listed[u.UploadID] = true
+            }
+            for _, u := range result.Uploads {
+                if u.Aborted && listed[u.UploadID] {
+                    result.StillListed++
+                }
+            }
+        }
+    }
+    result.Elapsed = time.Since(start)
+    return result
+}
+
+// list returns every incomplete multipart upload below prefix and the time of each page.
+func list(cfg *config.Config, s3Client *s3.S3, prefix string) ([]Upload, stats.Latencies, error) {
+    var uploads []Upload
+    var pages stats.Latencies
+    input := &s3.ListMultipartUploadsInput{
+        Bucket: aws.String(cfg.BucketName),
+        Prefix: aws.String(prefix),
+    }
+    for {
+        // Each page request gets its own LIST deadline.
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        pageStart := time.Now()
+        page, err := s3Client.ListMultipartUploadsWithContext(ctx, input)
+        latency := time.Since(pageStart)
+        cancel()
+        if err != nil {
+            return uploads, pages, fmt.Errorf("error listing multipart uploads of bucket %s: %w", cfg.BucketName, err)
+        }
+        pages.Add(latency)
+        for _, u := range page.Uploads {
+            uploads = append(uploads, Upload{
+                Key:       aws.StringValue(u.Key),
+                UploadID:  aws.StringValue(u.UploadId),
+                Initiated: aws.TimeValue(u.Initiated),
+            })
+        }
+        if !aws.BoolValue(page.IsTruncated) {
+            return uploads, pages, nil
+        }
+        input.KeyMarker, input.UploadIdMarker = page.NextKeyMarker, page.NextUploadIdMarker
+    }
+}
+
+// countParts lists the parts of an upload into u and returns the time of the complete listing.
+func countParts(cfg *config.Config, s3Client *s3.S3, u *Upload) (time.Duration, error) {
+    input := &s3.ListPartsInput{
+        Bucket:   aws.String(cfg.BucketName),
+        Key:      aws.String(u.Key),
+        UploadId: aws.String(u.UploadID),
+    }
+    start := time.Now()
+    for {
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := s3Client.ListPartsWithContext(ctx, input)
+        cancel()
+        if err != nil {
+            return 0, fmt.Errorf("error listing parts of upload %s of %s: %w", u.UploadID, u.Key, err)
+        }
+        for _, part := range page.Parts {
+            u.Parts++
+            u.Bytes += aws.Int64Value(part.Size)
+        }
+        if !aws.BoolValue(page.IsTruncated) {
+            return time.Since(start), nil
+        }
+        input.PartNumberMarker = page.NextPartNumberMarker
+    }
+}
+
+// PrintReport prints the orphaned uploads, parts and bytes found and the latency of listing and
+// aborting them.
+func PrintReport(r Result) {
+    uploads, parts, bytes := r.Count()
+
+    fmt.Println("\nOrphaned Multipart Upload Report:")
+    fmt.Println("=================================")
+    fmt.Printf("Prefix: %s\n", r.Prefix)
+    if r.DryRun {
+        fmt.Println("Dry run: no upload was aborted.")
+    }
+    fmt.Printf("Incomplete Uploads: %d\n", len(r.Uploads))
+    fmt.Printf("Skipped as Too Recent: %d\n", len(r.Uploads)-int(uploads))
+    fmt.Printf("Orphaned Parts: %d\n", parts)
+    fmt.Printf("Orphaned Bytes: %d\n", bytes)
+    if uploads > 0 {
+        fmt.Printf("Avg Parts per Upload: %.1f\n", float64(parts)/float64(uploads))
+
+        var oldest time.Time
+        for _, u := range r.Uploads {
+            if !u.Skipped && (oldest.IsZero() || u.Initiated.Before(oldest)) {
+                oldest = u.Initiated
+            }
+        }
+        fmt.Printf("Oldest Upload: %s (%v ago)\n", oldest.UTC().Format(time.RFC3339), time.Since(oldest).Round(time.Second))
+    }
+    if !r.DryRun {
+        fmt.Printf("Aborted: %d\n", r.Aborts.Count)
+    }
+    fmt.Printf("Errors: %d\n", r.Errors())
+    fmt.Printf("Duration: %v\n", r.Elapsed.Round(time.Millisecond))
+
+    fmt.Printf("\n%-22s %8s %12s %12s %12s\n", "Request", "Count", "Avg", "P50", "P99")
+    for _, row := range []struct {
+        name      string
+        durations *stats.Latencies
+    }{
+        {"ListMultipartUploads", &r.ListPages},
+        {"ListParts", &r.ListParts},
+        {"AbortMultipartUpload", &r.Aborts},
+    } {
+        if row.durations.Count == 0 {
+            continue
+        }
+        fmt.Printf("%-22s %8d %12v %12v %12v\n", row.name, row.durations.Count, row.durations.Average().Round(time.Microsecond),
+            row.durations.Percentile(0.50).Round(time.Microsecond), row.durations.Percentile(0.99).Round(time.Microsecond))
+    }
+
+    if r.StillListed > 0 {
+        fmt.Printf("\nWarning: %d aborted uploads were still listed afterwards.\n", r.StillListed)
+    }
+    if r.ListError != nil {
+        fmt.Printf("\nListing Error: %v\n", r.ListError)
+    }
+    fmt.Println("=================================")
+}
+
+// WriteCSV writes one row per incomplete upload.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating orphans report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Key", "UploadId", "Initiated", "Parts", "Bytes", "Skipped", "Aborted", "Error"})
+    for _, u := range r.Uploads {
+        errText := ""
+        if u.Err != nil {
+            errText = u.Err.Error()
+        }
+        writer.Write([]string{
+            u.Key,
+            u.UploadID,
+            u.Initiated.UTC().Format(time.RFC3339),
+            strconv.FormatInt(u.Parts, 10),
+            strconv.FormatInt(u.Bytes, 10),
+            strconv.FormatBool(u.Skipped),
+            strconv.FormatBool(u.Aborted),
+            errText,
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing orphans report %s: %w", filePath, err)
+    }
+    return nil
+}
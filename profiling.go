@@ -0,0 +1,88 @@
+// profiling.go
+package main
+
+import (
+    "context"
+    "fmt"
+    "os"
+    "path/filepath"
+    "runtime/pprof"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/progress"
+)
+
+// startProfiling captures the profileWindows of the run in the background, timed from now.
+// The returned function stops the capture, ending a CPU profile in progress early, and waits
+// for its files to be written.
+func startProfiling(cfg *config.Config, runID string) func() {
+    if len(cfg.ProfileWindows) == 0 {
+        return func() {}
+    }
+    if err := os.MkdirAll(cfg.ProfileDir, 0755); err != nil {
+        fmt.Printf("Error creating profile directory %s: %v\n", cfg.ProfileDir, err)
+        return func() {}
+    }
+
+    ctx, cancel := context.WithCancel(context.Background())
+    done := make(chan struct{})
+    start := time.Now()
+
+    go func() {
+        defer close(done)
+        for i, window := range cfg.ProfileWindows {
+            select {
+            case <-time.After(time.Until(start.Add(time.Duration(window.StartSeconds) * time.Second))):
+            case <-ctx.Done():
+                return
+            }
+            captureProfiles(ctx, cfg, runID, i+1, window)
+        }
+    }()
+
+    return func() {
+        cancel()
+        <-done
+    }
+}
+
+// captureProfiles writes the CPU profile of one window, then a heap profile as the window ends.
+// The files are named <runID>-profile<n>-cpu.pprof and <runID>-profile<n>-heap.pprof.
+func captureProfiles(ctx context.Context, cfg *config.Config, runID string, n int, window config.ProfileWindow) {
+    prefix := filepath.Join(cfg.ProfileDir, fmt.Sprintf("%s-profile%d", runID, n))
+
+    cpuFile, err := os.Create(prefix + "-cpu.pprof")
+    if err != nil {
+        fmt.Printf("Error creating CPU profile: %v\n", err)
+        return
+    }
+    if err := pprof.StartCPUProfile(cpuFile); err != nil {
+        fmt.Printf("Error starting CPU profile %d: %v\n", n, err)
+        cpuFile.Close()
+        os.Remove(cpuFile.Name())
+        return
+    }
+    progress.Printf("Capturing CPU profile %d for %ds...\n", n, window.DurationSeconds)
+
+    select {
+    case <-time.After(time.Duration(window.DurationSeconds) * time.Second):
+    case <-ctx.Done():
+    }
+    pprof.StopCPUProfile()
+    if err := cpuFile.Close(); err != nil {
+        fmt.Printf("Error writing CPU profile %s: %v\n", cpuFile.Name(), err)
+    }
+
+    heapFile, err := os.Create(prefix + "-heap.pprof")
+    if err != nil {
+        fmt.Printf("Error creating heap profile: %v\n", err)
+        return
+    }
+    defer heapFile.Close()
+    if err := pprof.WriteHeapProfile(heapFile); err != nil {
+        fmt.Printf("Error writing heap profile %s: %v\n", heapFile.Name(), err)
+        return
+    }
+    progress.Printf("Profiles %d written to %s-cpu.pprof and %s-heap.pprof\n", n, prefix, prefix)
+}
@@ -0,0 +1,256 @@
+// progress/progress.go
+package progress
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+const (
+    // terminalInterval is how often the status line is redrawn in place on a terminal.
+    terminalInterval = 500 * time.Millisecond
+
+    // logInterval is how often a status line is appended when stdout is not a terminal.
+    logInterval = 10 * time.Second
+)
+
+// Output modes selectable with Configure.
+const (
+    ModeAuto  = "auto"  // Redraw the line in place on a terminal, append plain lines otherwise.
+    ModeText  = "text"  // Always append plain lines, also on a terminal.
+    ModeJSON  = "json"  // Append one JSON object per interval, for other programs.
+    ModeQuiet = "quiet" // No periodic output; only the final state of each task is printed.
+)
+
+// Task is one phase of work shown on the status line, such as generating or uploading files.
+// Workers only update its counters; the reporter goroutine is the only one that prints them.
+type Task struct {
+    name   string
+    unit   string
+    total  int64
+    done   int64
+    failed int64
+    start  time.Time
+}
+
+var (
+    mu         sync.Mutex
+    tasks      []*Task
+    lineShown  bool
+    terminal   bool
+    renderOnce sync.Once
+
+    mode     = ModeAuto
+    interval time.Duration // 0 uses the default interval of the mode.
+)
+
+// Configure selects the output mode and the interval between updates; an interval of 0 keeps
+// the default, 500ms on a terminal and 10s otherwise. It must be called before the first Begin.
+func Configure(m string, every time.Duration) error {
+    switch m {
+    case ModeAuto, ModeText, ModeJSON, ModeQuiet:
+    default:
+        return fmt.Errorf("progress mode must be %q, %q, %q or %q, current: %q", ModeAuto, ModeText, ModeJSON, ModeQuiet, m)
+    }
+    if every < 0 {
+        return fmt.Errorf("progress interval must not be negative, current: %v", every)
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+    mode, interval = m, every
+    return nil
+}
+
+// taskState is the JSON form of a task in the json mode.
+type taskState struct {
+    Name           string  `json:"Name"`
+    Unit           string  `json:"Unit"`
+    Done           int64   `json:"Done"`
+    Total          int64   `json:"Total,omitempty"` // Omitted when the amount of work is not known.
+    Failed         int64   `json:"Failed"`
+    Rate           float64 `json:"Rate"` // Items per second since the task began.
+    ElapsedSeconds float64 `json:"ElapsedSeconds"`
+}
+
+// progressLine is one line of output in the json mode.
+type progressLine struct {
+    Time  time.Time   `json:"Time"`
+    Event string      `json:"Event"` // "progress" for periodic updates, "done" when a task ends.
+    Tasks []taskState `json:"Tasks"`
+}
+
+// Begin adds a task to the status line, counting items of the given unit, such as "files".
+// A total of 0 means the amount of work is not known in advance.
+func Begin(name, unit string, total int64) *Task {
+    renderOnce.Do(startRenderer)
+
+    t := &Task{name: name, unit: unit, total: total, start: time.Now()}
+    mu.Lock()
+    tasks = append(tasks, t)
+    mu.Unlock()
+    return t
+}
+
+// Add records n completed items. It is safe to call on a nil task.
+func (t *Task) Add(n int64) {
+    if t != nil {
+        atomic.AddInt64(&t.done, n)
+    }
+}
+
+// Fail records n failed items. It is safe to call on a nil task.
+func (t *Task) Fail(n int64) {
+    if t != nil {
+        atomic.AddInt64(&t.failed, n)
+    }
+}
+
+// Done removes the task from the status line and prints its final state on a line of its own.
+func (t *Task) Done() {
+    if t == nil {
+        return
+    }
+
+    mu.Lock()
+    defer mu.Unlock()
+    for i, other := range tasks {
+        if other == t {
+            tasks = append(tasks[:i], tasks[i+1:]...)
+            break
+        }
+    }
+    clearLine()
+    if mode == ModeJSON {
+        printJSON("done", []*Task{t})
+        return
+    }
+    fmt.Println(t.status())
+}
+
+// Printf prints a message without garbling the status line, which is redrawn on the next tick.
+func Printf(format string, args ...interface{}) {
+    mu.Lock()
+    defer mu.Unlock()
+    clearLine()
+    fmt.Printf(format, args...)
+}
+
+// status formats the counters of the task.
+func (t *Task) status() string {
+    done := atomic.LoadInt64(&t.done)
+    failed := atomic.LoadInt64(&t.failed)
+    elapsed := time.Since(t.start)
+
+    var b strings.Builder
+    if t.total > 0 {
+        fmt.Fprintf(&b, "%s: %d/%d (%.2f%%)", t.name, done, t.total, float64(done)/float64(t.total)*100)
+    } else {
+        fmt.Fprintf(&b, "%s: %d %s", t.name, done, t.unit)
+    }
+    if failed > 0 {
+        fmt.Fprintf(&b, " | Failed: %d", failed)
+    }
+    if elapsed > 0 {
+        fmt.Fprintf(&b, " | Rate: %.2f %s/sec", float64(done)/elapsed.Seconds(), t.unit)
+    }
+    fmt.Fprintf(&b, " | Elapsed: %s", elapsed.Truncate(time.Second))
+    return b.String()
+}
+
+// state returns the counters of the task in JSON form.
+func (t *Task) state() taskState {
+    elapsed := time.Since(t.start)
+    st := taskState{
+        Name:           t.name,
+        Unit:           t.unit,
+        Done:           atomic.LoadInt64(&t.done),
+        Total:          t.total,
+        Failed:         atomic.LoadInt64(&t.failed),
+        ElapsedSeconds: elapsed.Seconds(),
+    }
+    if elapsed > 0 {
+        st.Rate = float64(st.Done) / elapsed.Seconds()
+    }
+    return st
+}
+
+// printJSON prints the state of tasks as a single JSON line. The caller must hold mu.
+func printJSON(event string, ts []*Task) {
+    line := progressLine{Time: time.Now(), Event: event, Tasks: make([]taskState, len(ts))}
+    for i, t := range ts {
+        line.Tasks[i] = t.state()
+    }
+    data, err := json.Marshal(line)
+    if err != nil {
+        return
+    }
+    fmt.Println(string(data))
+}
+
+// clearLine erases the status line so other output starts at the beginning of an empty line.
+// The caller must hold mu.
+func clearLine() {
+    if lineShown {
+        fmt.Print("\r\033[K")
+        lineShown = false
+    }
+}
+
+// render prints the status of every active task. The caller must hold mu.
+func render() {
+    if len(tasks) == 0 {
+        return
+    }
+    if mode == ModeJSON {
+        printJSON("progress", tasks)
+        return
+    }
+
+    parts := make([]string, len(tasks))
+    for i, t := range tasks {
+        parts[i] = t.status()
+    }
+    line := strings.Join(parts, " || ")
+
+    if terminal {
+        fmt.Print("\r\033[K" + line)
+        lineShown = true
+    } else {
+        fmt.Println(line)
+    }
+}
+
+// startRenderer starts the goroutine that owns the status line.
+func startRenderer() {
+    mu.Lock()
+    defer mu.Unlock()
+
+    if mode == ModeQuiet {
+        return
+    }
+
+    every := logInterval
+    if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && mode == ModeAuto {
+        terminal = true
+        every = terminalInterval
+    }
+    if interval > 0 {
+        every = interval
+    }
+
+    go func() {
+        ticker := time.NewTicker(every)
+        defer ticker.Stop()
+        for range ticker.C {
+            mu.Lock()
+            render()
+            mu.Unlock()
+        }
+    }()
+}
@@ -0,0 +1,41 @@
+// progress/sink.go
+package progress
+
+// Counter receives the progress of one task. *Task implements it for the status line; a Sink
+// of an embedding program returns its own.
+type Counter interface {
+    // Add records n completed items.
+    Add(n int64)
+    // Fail records n failed items.
+    Fail(n int64)
+    // Done ends the task.
+    Done()
+}
+
+// Sink starts the Counters of the tasks of a run, such as uploading files or one benchmark
+// phase. Programs that embed the benchmark implement it to route progress to their own
+// display or metrics instead of stdout.
+type Sink interface {
+    Begin(name, unit string, total int64) Counter
+}
+
+// StatusLine is the Sink that shows tasks on the status line, as Begin does.
+var StatusLine Sink = statusLine{}
+
+// statusLine implements StatusLine.
+type statusLine struct{}
+
+func (statusLine) Begin(name, unit string, total int64) Counter {
+    return Begin(name, unit, total)
+}
+
+// Discard is a Sink that drops every update.
+var Discard Sink = discard{}
+
+// discard implements Discard.
+type discard struct{}
+
+func (discard) Begin(string, string, int64) Counter { return discard{} }
+func (discard) Add(int64)                           {}
+func (discard) Fail(int64)                          {}
+func (discard) Done()                               {}
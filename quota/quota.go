@@ -0,0 +1,471 @@
+// quota/quota.go
+package quota
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "math/rand"
+    "os"
+    "path"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/aws/request"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/cleanup"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// maxPrintedWindows limits the rows of the window table to the windows closest to the limit.
+// The CSV report always has every window.
+const maxPrintedWindows = 20
+
+// Error classes of a failed upload attempt.
+const (
+    classOther    = "error"
+    classThrottle = "throttling"
+    classDenial   = "quota or capacity denial"
+)
+
+// throttleCodes are the error codes endpoints use to slow clients down.
+var throttleCodes = map[string]bool{
+    "SlowDown":             true,
+    "Throttling":           true,
+    "ThrottlingException":  true,
+    "RequestLimitExceeded": true,
+    "RequestThrottled":     true,
+    "TooManyRequests":      true,
+}
+
+// denialCodes are the error codes endpoints use to refuse writes over a quota or without space.
+var denialCodes = map[string]bool{
+    "QuotaExceeded":                  true,
+    "InsufficientStorage":            true,
+    "StorageFull":                    true,
+    "XMinioStorageFull":              true,
+    "XMinioAdminBucketQuotaExceeded": true,
+}
+
+// Threshold records the stored objects and bytes when a class of errors first appeared.
+type Threshold struct {
+    Reached    bool
+    After      time.Duration // Time since the start of the run.
+    Objects    int64         // Objects stored when the error occurred.
+    Bytes      int64         // Bytes stored when the error occurred.
+    StatusCode int
+    Code       string
+    Message    string
+}
+
+// Window holds the uploads of one quotaWindowSeconds interval.
+type Window struct {
+    Start       time.Duration // Time since the start of the run.
+    Objects     int64         // Objects stored at the end of the window.
+    Bytes       int64         // Bytes stored at the end of the window.
+    Uploads     int64         // Uploads that succeeded in the window.
+    UploadBytes int64
+    TotalTime   time.Duration // Time of the successful uploads.
+    Throttled   int64         // Attempts rejected by throttling, including ones the SDK retried.
+    Denied      int64         // Uploads refused by a quota or capacity error.
+    Errors      int64         // Uploads that failed for other reasons.
+}
+
+// AvgLatency returns the average time of a successful upload in the window.
+func (w Window) AvgLatency() time.Duration {
+    if w.Uploads == 0 {
+        return 0
+    }
+    return w.TotalTime / time.Duration(w.Uploads)
+}
+
+// Result is the outcome of a quota discovery run.
+type Result struct {
+    RunID         string
+    Prefix        string
+    Objects       int64 // Objects stored.
+    Bytes         int64 // Bytes stored.
+    Throttled     int64
+    Denied        int64
+    Errors        int64
+    FirstThrottle Threshold
+    FirstDenial   Threshold
+    StopReason    string
+    Duration      time.Duration
+    WindowLength  time.Duration
+    Windows       []Window
+    Deleted       int64 // Objects deleted at the end of the run.
+    DeleteFailed  int64
+    DeleteError   error
+
+    stopClass string // Class of the failures that ended the run; empty when a limit of the run ended it.
+}
+
+// Failed reports whether the run ended on errors other than throttling or denials, or its
+// objects could not be deleted.
+func (r Result) Failed() bool {
+    return r.stopClass == classOther || r.DeleteFailed > 0 || r.DeleteError != nil
+}
+
+// PeakRate returns the highest upload rate of a window, in MB per second.
+func (r Result) PeakRate() float64 {
+    var peak float64
+    for _, w := range r.Windows {
+        peak = max(peak, r.windowRate(w))
+    }
+    return peak
+}
+
+// windowRate returns the throughput of a window in MB per second. The last window may be
+// shorter than the others.
+func (r Result) windowRate(w Window) float64 {
+    length := r.WindowLength
+    if end := w.Start + length; end > r.Duration {
+        length = r.Duration - w.Start
+    }
+    if length <= 0 {
+        return 0
+    }
+    return float64(w.UploadBytes) / (1024 * 1024) / length.Seconds()
+}
+
+// discovery holds the shared state of a run.
+type discovery struct {
+    cfg   *config.Config
+    start time.Time
+
+    mu                  sync.Mutex // Guards everything below.
+    result              Result
+    window              Window
+    consecutiveFailures int
+    printedErrors       int
+    stopped             bool
+}
+
+// Run uploads objects of quotaObjectSize until quotaStopErrors uploads in a row fail, which
+// normally means the quota or capacity of the endpoint is reached, or until quotaMaxSize or
+// quotaMaxSeconds. It records the stored objects and bytes at the first throttling and the first
+// denial and the throughput of every window. Objects are written below
+// <quotaPrefix>/<runID>/QUOTA/ and deleted at the end with quotaDeleteObjects.
+func Run(cfg *config.Config, s3Clients []*s3.S3) Result {
+    runID := cfg.NewRunID()
+    d := &discovery{
+        cfg: cfg,
+        result: Result{
+            RunID:        runID,
+            Prefix:       path.Join(cfg.QuotaPrefix, runID, "QUOTA"),
+            WindowLength: time.Duration(cfg.QuotaWindowSeconds) * time.Second,
+        },
+    }
+
+    payload := make([]byte, cfg.QuotaObjectSize)
+    rand.Read(payload)
+
+    fmt.Printf("Uploading objects of %d bytes with %d workers until the endpoint refuses them...\n", cfg.QuotaObjectSize, cfg.QuotaConcurrency)
+    task := progress.Begin("Quota discovery", "objects", 0)
+    d.start = time.Now()
+
+    done := make(chan struct{})
+    go d.closeWindows(done)
+
+    var wg sync.WaitGroup
+    var next int64
+    for w := 0; w < cfg.QuotaConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for d.running() {
+                key := fmt.Sprintf("%s/%x", d.result.Prefix, atomic.AddInt64(&next, 1)-1)
+                duration, err := d.put(client, key, payload)
+                d.record(duration, err)
+                if err != nil {
+                    task.Fail(1)
+                } else {
+                    task.Add(1)
+                }
+            }
+        }(w)
+    }
+    wg.Wait()
+    close(done)
+    task.Done()
+
+    d.mu.Lock()
+    d.result.Duration = time.Since(d.start)
+    d.closeWindowLocked()
+    if d.printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further upload errors were not printed.\n", d.printedErrors-stats.MaxPrintedErrors)
+    }
+    result := d.result
+    d.mu.Unlock()
+
+    if cfg.QuotaDeleteObjects {
+        fmt.Println("Deleting the objects of the run...")
+        quotaCfg := *cfg
+        quotaCfg.S3Folder = cfg.QuotaPrefix
+        deleted, err := cleanup.DeleteRun(&quotaCfg, s3Clients[0], runID)
+        result.Deleted, result.DeleteFailed, result.DeleteError = deleted.Deleted, deleted.Failed, err
+    }
+    return result
+}
+
+// put uploads one object and counts every attempt that was throttled, including the ones
+// the SDK retried successfully.
+func (d *discovery) put(client *s3.S3, key string, payload []byte) (time.Duration, error) {
+    req, _ := client.PutObjectRequest(&s3.PutObjectInput{
+        Bucket:        aws.String(d.cfg.BucketName),
+        Key:           aws.String(key),
+        Body:          bytes.NewReader(payload),
+        ContentLength: aws.Int64(int64(len(payload))),
+    })
+    ctx, cancel := d.cfg.OperationContext(config.OperationPut)
+    defer cancel()
+    req.SetContext(ctx)
+    req.Handlers.Retry.PushFront(func(r *request.Request) {
+        if d.classify(r.Error) == classThrottle {
+            d.recordThrottle(r.Error)
+        }
+    })
+
+    start := time.Now()
+    err := req.Send()
+    return time.Since(start), err
+}
+
+// classify returns the class of an upload error.
+func (d *discovery) classify(err error) string {
+    aerr, ok := err.(awserr.RequestFailure)
+    if !ok {
+        return classOther
+    }
+    if denialCodes[aerr.Code()] || aerr.StatusCode() == 507 {
+        return classDenial
+    }
+    for _, code := range d.cfg.QuotaErrorCodes {
+        if aerr.Code() == code {
+            return classDenial
+        }
+    }
+    if throttleCodes[aerr.Code()] || aerr.StatusCode() == 429 || aerr.StatusCode() == 503 {
+        return classThrottle
+    }
+    return classOther
+}
+
+// running reports whether workers should start another upload, stopping the run once
+// quotaMaxSize or quotaMaxSeconds is reached.
+func (d *discovery) running() bool {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+
+    if d.stopped {
+        return false
+    }
+    if d.cfg.QuotaMaxBytes > 0 && d.result.Bytes >= d.cfg.QuotaMaxBytes {
+        d.stopLocked(fmt.Sprintf("quotaMaxSize of %s stored without reaching a limit", d.cfg.QuotaMaxSize))
+    } else if d.cfg.QuotaMaxSeconds > 0 && time.Since(d.start) >= time.Duration(d.cfg.QuotaMaxSeconds)*time.Second {
+        d.stopLocked(fmt.Sprintf("quotaMaxSeconds of %d reached without reaching a limit", d.cfg.QuotaMaxSeconds))
+    }
+    return !d.stopped
+}
+
+// stopLocked ends the run for the given reason. Uploads in flight still complete.
+func (d *discovery) stopLocked(reason string) {
+    if !d.stopped {
+        d.stopped = true
+        d.result.StopReason = reason
+    }
+}
+
+// recordThrottle counts a throttled attempt and keeps the first one.
+func (d *discovery) recordThrottle(err error) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+
+    d.result.Throttled++
+    d.window.Throttled++
+    d.thresholdLocked(&d.result.FirstThrottle, err)
+}
+
+// record counts the outcome of an upload.
+func (d *discovery) record(duration time.Duration, err error) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+
+    if err == nil {
+        d.consecutiveFailures = 0
+        d.result.Objects++
+        d.result.Bytes += int64(d.cfg.QuotaObjectSize)
+        d.window.Uploads++
+        d.window.UploadBytes += int64(d.cfg.QuotaObjectSize)
+        d.window.TotalTime += duration
+        return
+    }
+
+    // Throttled attempts were already counted by the retry handler.
+    class := d.classify(err)
+    switch class {
+    case classDenial:
+        d.result.Denied++
+        d.window.Denied++
+        d.thresholdLocked(&d.result.FirstDenial, err)
+    case classOther:
+        d.result.Errors++
+        d.window.Errors++
+    }
+    if d.printedErrors++; d.printedErrors <= stats.MaxPrintedErrors {
+        progress.Printf("Upload failed (%s): %v\n", class, err)
+    }
+
+    d.consecutiveFailures++
+    if d.consecutiveFailures >= d.cfg.QuotaStopErrors {
+        d.stopLocked(fmt.Sprintf("%d uploads in a row failed, the last with a %s", d.consecutiveFailures, class))
+        d.result.stopClass = class
+    }
+}
+
+// thresholdLocked fills t with the stored totals and the error, unless it was reached before.
+func (d *discovery) thresholdLocked(t *Threshold, err error) {
+    if t.Reached {
+        return
+    }
+    *t = Threshold{
+        Reached: true,
+        After:   time.Since(d.start),
+        Objects: d.result.Objects,
+        Bytes:   d.result.Bytes,
+        Message: err.Error(),
+    }
+    if aerr, ok := err.(awserr.RequestFailure); ok {
+        t.StatusCode, t.Code, t.Message = aerr.StatusCode(), aerr.Code(), aerr.Message()
+    }
+}
+
+// closeWindows closes a window every quotaWindowSeconds until done is closed.
+func (d *discovery) closeWindows(done chan struct{}) {
+    ticker := time.NewTicker(d.result.WindowLength)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            d.mu.Lock()
+            d.closeWindowLocked()
+            d.mu.Unlock()
+        case <-done:
+            return
+        }
+    }
+}
+
+// closeWindowLocked appends the current window to the result and starts the next one.
+func (d *discovery) closeWindowLocked() {
+    d.window.Start = time.Duration(len(d.result.Windows)) * d.result.WindowLength
+    d.window.Objects = d.result.Objects
+    d.window.Bytes = d.result.Bytes
+    d.result.Windows = append(d.result.Windows, d.window)
+    d.window = Window{}
+}
+
+// PrintReport prints where throttling and denials began and how the throughput developed
+// on the way to the limit.
+func PrintReport(r Result) {
+    fmt.Println("\nQuota Discovery Report:")
+    fmt.Println("=======================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Stopped: %s\n", r.StopReason)
+    fmt.Printf("Duration: %v\n", r.Duration.Round(time.Millisecond))
+    fmt.Printf("Objects Stored: %d\n", r.Objects)
+    fmt.Printf("Data Stored: %d bytes (%.2f GB)\n", r.Bytes, float64(r.Bytes)/(1024*1024*1024))
+    fmt.Printf("Throttled Attempts: %d\n", r.Throttled)
+    fmt.Printf("Denied Uploads: %d\n", r.Denied)
+    fmt.Printf("Other Failed Uploads: %d\n", r.Errors)
+
+    printThreshold("First Throttling", r.FirstThrottle)
+    printThreshold("First Denial", r.FirstDenial)
+
+    if len(r.Windows) > 0 {
+        peak := r.PeakRate()
+        fmt.Printf("\nPeak Throughput: %.2f MB/sec\n", peak)
+        if r.FirstDenial.Reached && peak > 0 {
+            // The last full window before the first denial shows the throughput right at the limit.
+            if i := int(r.FirstDenial.After/r.WindowLength) - 1; i >= 0 && i < len(r.Windows) {
+                rate := r.windowRate(r.Windows[i])
+                fmt.Printf("Throughput Before First Denial: %.2f MB/sec (%.0f%% of peak)\n", rate, rate/peak*100)
+            }
+        }
+
+        windows := r.Windows
+        if len(windows) > maxPrintedWindows {
+            windows = windows[len(windows)-maxPrintedWindows:]
+            fmt.Printf("\nThe last %d of %d windows of %v:\n", maxPrintedWindows, len(r.Windows), r.WindowLength)
+        } else {
+            fmt.Printf("\nWindows of %v:\n", r.WindowLength)
+        }
+        fmt.Printf("%10s %12s %14s %10s %10s %10s %8s %8s\n", "Start", "Objects", "Stored GB", "MB/sec", "Avg", "Throttled", "Denied", "Errors")
+        for _, w := range windows {
+            fmt.Printf("%10v %12d %14.2f %10.2f %10v %10d %8d %8d\n", w.Start, w.Objects, float64(w.Bytes)/(1024*1024*1024),
+                r.windowRate(w), w.AvgLatency().Round(time.Microsecond), w.Throttled, w.Denied, w.Errors)
+        }
+    }
+
+    if r.Deleted > 0 || r.DeleteFailed > 0 || r.DeleteError != nil {
+        fmt.Printf("\nObjects Deleted: %d\n", r.Deleted)
+        fmt.Printf("Objects Not Deleted: %d\n", r.DeleteFailed)
+        if r.DeleteError != nil {
+            fmt.Printf("Delete Error: %v\n", r.DeleteError)
+        }
+    }
+    fmt.Println("=======================")
+}
+
+// printThreshold prints the stored totals at which a class of errors began.
+func printThreshold(name string, t Threshold) {
+    if !t.Reached {
+        fmt.Printf("%s: none\n", name)
+        return
+    }
+    fmt.Printf("%s: after %v at %d objects, %d bytes (%.2f GB)\n", name, t.After.Round(time.Millisecond), t.Objects, t.Bytes, float64(t.Bytes)/(1024*1024*1024))
+    if t.Code != "" {
+        fmt.Printf("  HTTP %d %s: %s\n", t.StatusCode, t.Code, t.Message)
+    } else {
+        fmt.Printf("  %s\n", t.Message)
+    }
+}
+
+// WriteCSV writes one row per window with the stored totals, throughput and errors.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating quota report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"StartSeconds", "Objects", "Bytes", "Uploads", "MBPerSec", "AvgTimeMs", "Throttled", "Denied", "Errors"})
+    for _, w := range r.Windows {
+        writer.Write([]string{
+            strconv.FormatFloat(w.Start.Seconds(), 'f', 0, 64),
+            strconv.FormatInt(w.Objects, 10),
+            strconv.FormatInt(w.Bytes, 10),
+            strconv.FormatInt(w.Uploads, 10),
+            fmt.Sprintf("%.2f", r.windowRate(w)),
+            stats.Milliseconds(w.AvgLatency()),
+            strconv.FormatInt(w.Throttled, 10),
+            strconv.FormatInt(w.Denied, 10),
+            strconv.FormatInt(w.Errors, 10),
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing quota report %s: %w", filePath, err)
+    }
+    return nil
+}
@@ -0,0 +1,146 @@
+// redrive/redrive.go
+package redrive
+
+import (
+    "fmt"
+    "os"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/manifest"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/s3upload"
+    "scale_s3_benchmark/storage"
+)
+
+// Result is the outcome of re-driving the failed uploads of a run.
+type Result struct {
+    Path         string // Failures file that was re-driven and rewritten.
+    RunID        string
+    Pending      int   // Failed uploads of the run, one per key.
+    Uploaded     int64 // Uploaded now; removed from the failures file.
+    Failed       int64 // Failed again; recorded anew in the failures file.
+    MissingFiles int   // Local file no longer exists; left in the failures file.
+    OtherRuns    int   // Entries of other runs, left in the failures file.
+    Elapsed      time.Duration
+}
+
+// Remaining returns the entries left in the failures file.
+func (r Result) Remaining() int {
+    return int(r.Failed) + r.MissingFiles + r.OtherRuns
+}
+
+// Run uploads the failed uploads recorded in the failures file at path again, each from its
+// local file to its original key, and rewrites the file with only the uploads that still
+// failed. Only the failures of one run are re-driven: RunID, or else the run of the last
+// entry. Uploaded objects are appended to the manifest like those of the run itself.
+func Run(cfg *config.Config, backends []storage.Backend, path string) (Result, error) {
+    result := Result{Path: path, RunID: cfg.RunID}
+
+    failures, err := manifest.ReadFailures(path)
+    if err != nil {
+        return result, err
+    }
+    if len(failures) == 0 {
+        return result, nil
+    }
+    if result.RunID == "" {
+        result.RunID = failures[len(failures)-1].RunID
+    }
+
+    // Later entries win when a key failed more than once, e.g. in an earlier re-drive.
+    var kept []manifest.Failure
+    latest := make(map[string]manifest.Failure)
+    var order []string
+    for _, f := range failures {
+        if f.RunID != result.RunID {
+            kept = append(kept, f)
+            result.OtherRuns++
+            continue
+        }
+        if _, ok := latest[f.Key]; !ok {
+            order = append(order, f.Key)
+        }
+        latest[f.Key] = f
+    }
+    result.Pending = len(order)
+
+    var files []s3upload.KeyedFile
+    for _, key := range order {
+        f := latest[key]
+        if _, err := os.Stat(f.File); err != nil {
+            kept = append(kept, f)
+            result.MissingFiles++
+            continue
+        }
+        files = append(files, s3upload.KeyedFile{Path: f.File, Key: f.Key})
+    }
+
+    // The new failures file replaces the old one once the uploads are done.
+    tmpPath := path + ".tmp"
+    os.Remove(tmpPath)
+    remaining, err := manifest.CreateFailures(tmpPath)
+    if err != nil {
+        return result, err
+    }
+    for _, f := range kept {
+        if err := remaining.Add(f); err != nil {
+            remaining.Close()
+            return result, fmt.Errorf("error writing failures file %s: %w", tmpPath, err)
+        }
+    }
+
+    uploadManifest, err := manifest.Create(cfg.ManifestPath, cfg.ManifestFormat)
+    if err != nil {
+        remaining.Close()
+        return result, err
+    }
+
+    uploader := s3upload.NewUploader(cfg, backends, time.Now(),
+        s3upload.WithRunID(result.RunID),
+        s3upload.WithManifest(uploadManifest),
+        s3upload.WithFailures(remaining),
+        s3upload.WithProgress(progress.Begin("Re-driving failed uploads", "files", int64(len(files)))),
+    )
+
+    start := time.Now()
+    result.Uploaded, result.Failed = uploader.UploadKeys("run "+result.RunID, files)
+    result.Elapsed = time.Since(start)
+    uploader.Progress.Done()
+
+    if err := uploader.UploadedS3Files.Close(); err != nil {
+        fmt.Printf("Error closing key store: %v\n", err)
+    }
+    if err := uploadManifest.Close(); err != nil {
+        fmt.Printf("Error closing manifest: %v\n", err)
+    }
+    if err := remaining.Close(); err != nil {
+        return result, fmt.Errorf("error writing failures file %s: %w", tmpPath, err)
+    }
+    if err := os.Rename(tmpPath, path); err != nil {
+        return result, fmt.Errorf("error replacing failures file %s: %w", path, err)
+    }
+    return result, nil
+}
+
+// PrintReport prints the outcome of a re-drive.
+func PrintReport(r Result) {
+    fmt.Println("\nRedrive Report:")
+    fmt.Println("===============")
+    fmt.Printf("Failures File: %s\n", r.Path)
+    if r.Pending == 0 && r.OtherRuns == 0 {
+        fmt.Println("No failed uploads to re-drive.")
+        return
+    }
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Failed Uploads: %d\n", r.Pending)
+    fmt.Printf("Uploaded: %d\n", r.Uploaded)
+    fmt.Printf("Failed Again: %d\n", r.Failed)
+    fmt.Printf("Local Files Missing: %d\n", r.MissingFiles)
+    if r.OtherRuns > 0 {
+        fmt.Printf("Entries of Other Runs: %d\n", r.OtherRuns)
+    }
+    fmt.Printf("Remaining in Failures File: %d\n", r.Remaining())
+    fmt.Printf("Duration: %v\n", r.Elapsed.Round(time.Millisecond))
+    fmt.Println("===============")
+}
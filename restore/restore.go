@@ -0,0 +1,264 @@
+// restore/restore.go
+package restore
+
+import (
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+)
+
+// Result holds the outcome of restoring archived objects.
+type Result struct {
+    Requested     int             // Objects a restore was requested for.
+    RequestErrors int             // RestoreObject calls that failed, e.g. because the object is not archived.
+    Restored      int             // Objects whose restore completed.
+    TimedOut      int             // Objects still being restored when the timeout expired.
+    GetErrors     int             // GETs of restored objects that failed.
+    RestoreTimes  []time.Duration // Time from the restore request to the restore completing, per object.
+    GetTimes      []time.Duration // GET latency of each restored object.
+    Duration      time.Duration
+}
+
+// pendingRestore is an object whose restore was requested and has not completed yet.
+type pendingRestore struct {
+    key         string
+    requestedAt time.Time
+}
+
+// Run requests a restore of up to RestoreMaxObjects objects under RestorePrefix, polls each
+// object with HEAD until its restore completes and then measures a GET of it.
+func Run(cfg *config.Config, s3Client *s3.S3) (Result, error) {
+    var result Result
+    start := time.Now()
+
+    keys, err := listKeys(cfg, s3Client)
+    if err != nil {
+        return result, err
+    }
+    if len(keys) == 0 {
+        return result, fmt.Errorf("no objects found under %q in bucket %s", cfg.RestorePrefix, cfg.BucketName)
+    }
+
+    var mu sync.Mutex
+    var pending []pendingRestore
+
+    fmt.Printf("Requesting restore of %d objects (tier %s, %d days)...\n", len(keys), cfg.RestoreTier, cfg.RestoreDays)
+    forEach(keys, cfg.RestoreConcurrency, func(key string) {
+        requestedAt := time.Now()
+        err := requestRestore(cfg, s3Client, key)
+
+        mu.Lock()
+        defer mu.Unlock()
+        result.Requested++
+        if err != nil {
+            fmt.Printf("Error requesting restore of %s: %v\n", key, err)
+            result.RequestErrors++
+            return
+        }
+        pending = append(pending, pendingRestore{key: key, requestedAt: requestedAt})
+    })
+
+    deadline := start.Add(time.Duration(cfg.RestoreTimeoutMinutes) * time.Minute)
+    interval := time.Duration(cfg.RestorePollIntervalSeconds) * time.Second
+    for len(pending) > 0 {
+        fmt.Printf("Waiting for %d restores (elapsed %v)...\n", len(pending), time.Since(start).Round(time.Second))
+
+        byKey := make(map[string]pendingRestore, len(pending))
+        polled := make([]string, len(pending))
+        for i, p := range pending {
+            byKey[p.key] = p
+            polled[i] = p.key
+        }
+
+        var stillPending []pendingRestore
+        forEach(polled, cfg.RestoreConcurrency, func(key string) {
+            p := byKey[key]
+            restored, err := restoreCompleted(cfg, s3Client, key)
+            if err != nil {
+                fmt.Printf("Error checking restore status of %s: %v\n", key, err)
+            }
+            if !restored {
+                mu.Lock()
+                stillPending = append(stillPending, p)
+                mu.Unlock()
+                return
+            }
+            restoreTime := time.Since(p.requestedAt)
+
+            getTime, err := timeGet(cfg, s3Client, key)
+
+            mu.Lock()
+            defer mu.Unlock()
+            result.Restored++
+            result.RestoreTimes = append(result.RestoreTimes, restoreTime)
+            if err != nil {
+                fmt.Printf("Error reading restored object %s: %v\n", key, err)
+                result.GetErrors++
+                return
+            }
+            result.GetTimes = append(result.GetTimes, getTime)
+        })
+        pending = stillPending
+
+        if len(pending) == 0 {
+            break
+        }
+        if time.Now().Add(interval).After(deadline) {
+            result.TimedOut = len(pending)
+            break
+        }
+        time.Sleep(interval)
+    }
+
+    result.Duration = time.Since(start)
+    return result, nil
+}
+
+// listKeys returns up to RestoreMaxObjects keys under RestorePrefix.
+func listKeys(cfg *config.Config, s3Client *s3.S3) ([]string, error) {
+    var keys []string
+    input := &s3.ListObjectsV2Input{
+        Bucket: aws.String(cfg.BucketName),
+        Prefix: aws.String(cfg.RestorePrefix),
+    }
+    for {
+        // Each page request gets its own LIST deadline.
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := s3Client.ListObjectsV2WithContext(ctx, input)
+        cancel()
+        if err != nil {
+            return nil, fmt.Errorf("error listing bucket %s: %w", cfg.BucketName, err)
+        }
+
+        for _, obj := range page.Contents {
+            keys = append(keys, aws.StringValue(obj.Key))
+            if len(keys) >= cfg.RestoreMaxObjects {
+                return keys, nil
+            }
+        }
+
+        if !aws.BoolValue(page.IsTruncated) {
+            return keys, nil
+        }
+        input.ContinuationToken = page.NextContinuationToken
+    }
+}
+
+// requestRestore issues a RestoreObject request for key.
+func requestRestore(cfg *config.Config, s3Client *s3.S3, key string) error {
+    ctx, cancel := cfg.OperationContext(config.OperationRestore)
+    defer cancel()
+
+    _, err := s3Client.RestoreObjectWithContext(ctx, &s3.RestoreObjectInput{
+        Bucket: aws.String(cfg.BucketName),
+        Key:    aws.String(key),
+        RestoreRequest: &s3.RestoreRequest{
+            Days:                 aws.Int64(int64(cfg.RestoreDays)),
+            GlacierJobParameters: &s3.GlacierJobParameters{Tier: aws.String(cfg.RestoreTier)},
+        },
+    })
+    return err
+}
+
+// restoreCompleted reports whether the restore of key has finished, based on the
+// x-amz-restore header: ongoing-request="false" once the restored copy is available.
+func restoreCompleted(cfg *config.Config, s3Client *s3.S3, key string) (bool, error) {
+    ctx, cancel := cfg.OperationContext(config.OperationHead)
+    defer cancel()
+
+    out, err := s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+        Bucket: aws.String(cfg.BucketName),
+        Key:    aws.String(key),
+    })
+    if err != nil {
+        return false, err
+    }
+    return strings.Contains(aws.StringValue(out.Restore), `ongoing-request="false"`), nil
+}
+
+// timeGet measures a full GET of key, including reading the body.
+func timeGet(cfg *config.Config, s3Client *s3.S3, key string) (time.Duration, error) {
+    ctx, cancel := cfg.OperationContext(config.OperationGet)
+    defer cancel()
+
+    start := time.Now()
+    out, err := s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+        Bucket: aws.String(cfg.BucketName),
+        Key:    aws.String(key),
+    })
+    if err != nil {
+        return 0, err
+    }
+    defer out.Body.Close()
+    if _, err := io.Copy(io.Discard, out.Body); err != nil {
+        return 0, err
+    }
+    return time.Since(start), nil
+}
+
+// forEach calls fn for every key with at most concurrency calls running at once.
+func forEach(keys []string, concurrency int, fn func(key string)) {
+    sem := make(chan struct{}, concurrency)
+    var wg sync.WaitGroup
+    for _, key := range keys {
+        wg.Add(1)
+        sem <- struct{}{}
+        go func(k string) {
+            defer wg.Done()
+            defer func() { <-sem }()
+            fn(k)
+        }(key)
+    }
+    wg.Wait()
+}
+
+// PrintReport prints the restore request outcomes, the time-to-restore distribution and the
+// GET latency of restored objects.
+func PrintReport(r Result) {
+    fmt.Println("\nArchive Restore Report:")
+    fmt.Println("=======================")
+    fmt.Printf("Restores Requested: %d\n", r.Requested)
+    fmt.Printf("Request Errors: %d\n", r.RequestErrors)
+    fmt.Printf("Restored: %d\n", r.Restored)
+    fmt.Printf("Timed Out: %d\n", r.TimedOut)
+    fmt.Printf("Duration: %v\n", r.Duration.Round(time.Second))
+
+    printDistribution("Time to Restore", r.RestoreTimes)
+    printDistribution("Restored Object GET", r.GetTimes)
+    if r.GetErrors > 0 {
+        fmt.Printf("GET Errors: %d\n", r.GetErrors)
+    }
+    fmt.Println("=======================")
+}
+
+// printDistribution prints min, average, percentiles and max of a set of durations.
+func printDistribution(name string, times []time.Duration) {
+    if len(times) == 0 {
+        return
+    }
+    sorted := append([]time.Duration(nil), times...)
+    sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+    var total time.Duration
+    for _, t := range sorted {
+        total += t
+    }
+    percentile := func(p float64) time.Duration {
+        return sorted[int(float64(len(sorted)-1)*p)]
+    }
+
+    fmt.Printf("\n%s:\n", name)
+    fmt.Printf("Min: %v\n", sorted[0])
+    fmt.Printf("Avg: %v\n", total/time.Duration(len(sorted)))
+    fmt.Printf("P50: %v\n", percentile(0.50))
+    fmt.Printf("P99: %v\n", percentile(0.99))
+    fmt.Printf("Max: %v\n", sorted[len(sorted)-1])
+}
@@ -0,0 +1,124 @@
+// results/descriptor.go
+package results
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "time"
+
+    "scale_s3_benchmark/config"
+)
+
+// DescriptorRunning is the status of a run descriptor written when the run starts.
+const DescriptorRunning = "running"
+
+// Descriptor is the machine-readable description of a run, written to runDescriptorPath for
+// provisioning pipelines that chain the benchmark and collect its artifacts.
+type Descriptor struct {
+    RunID          string     `json:"RunID"`
+    Status         string     `json:"Status"` // "running" at startup, then the final phase of the run.
+    StartedAt      time.Time  `json:"StartedAt"`
+    FinishedAt     *time.Time `json:"FinishedAt,omitempty"`
+    StorageBackend string     `json:"StorageBackend"`
+    Endpoints      []string   `json:"Endpoints"`
+    Bucket         string     `json:"Bucket"`
+    Prefix         string     `json:"Prefix"` // Every key of the run is below it.
+    Seed           int64      `json:"Seed,omitempty"`
+    Artifacts      Artifacts  `json:"Artifacts"`
+}
+
+// Artifacts are the absolute paths of the files a run writes. At startup they are the paths
+// the run will write to; once it has ended, only the files that exist are listed.
+type Artifacts struct {
+    Manifest    string   `json:"Manifest,omitempty"`
+    Failures    string   `json:"Failures,omitempty"`
+    Checkpoint  string   `json:"Checkpoint,omitempty"`
+    StateDump   string   `json:"StateDump,omitempty"` // Only written when the run fails.
+    LatencyLog  string   `json:"LatencyLog,omitempty"`
+    ErrorLog    string   `json:"ErrorLog,omitempty"`
+    StatsReport string   `json:"StatsReport,omitempty"`
+    Record      string   `json:"Record,omitempty"` // The run record in resultsDir, once the run has ended.
+    JUnitReport string   `json:"JUnitReport,omitempty"`
+    Profiles    []string `json:"Profiles,omitempty"`
+}
+
+// NewDescriptor describes the run runID started at startedAt, with the given status. record is
+// the path of its run record, or "" while the run is going.
+func NewDescriptor(cfg *config.Config, runID, status string, startedAt time.Time, record string) Descriptor {
+    d := Descriptor{
+        RunID:          runID,
+        Status:         status,
+        StartedAt:      startedAt,
+        StorageBackend: cfg.StorageBackend,
+        Endpoints:      cfg.EndpointURLs,
+        Bucket:         cfg.BucketName,
+        Prefix:         cfg.RunPrefix(runID) + "/",
+        Seed:           cfg.Seed,
+        Artifacts: Artifacts{
+            Manifest:    absPath(cfg.ManifestPath),
+            Failures:    absPath(cfg.FailuresPath),
+            Checkpoint:  absPath(cfg.CheckpointPath),
+            StateDump:   absPath(cfg.StateDumpPath),
+            LatencyLog:  absPath(cfg.LatencyLogPath),
+            ErrorLog:    absPath(cfg.ErrorLogPath),
+            StatsReport: absPath("plot/stats_report.csv"),
+            Record:      absPath(record),
+            JUnitReport: absPath(cfg.JUnitReportPath),
+        },
+    }
+    if cfg.CheckpointSeconds < 0 {
+        d.Artifacts.Checkpoint = ""
+    }
+    if status == DescriptorRunning {
+        return d
+    }
+
+    now := time.Now()
+    d.FinishedAt = &now
+    for _, path := range []*string{&d.Artifacts.Manifest, &d.Artifacts.Failures, &d.Artifacts.Checkpoint, &d.Artifacts.StateDump,
+        &d.Artifacts.LatencyLog, &d.Artifacts.ErrorLog, &d.Artifacts.StatsReport, &d.Artifacts.Record, &d.Artifacts.JUnitReport} {
+        if *path == "" {
+            continue
+        }
+        if _, err := os.Stat(*path); err != nil {
+            *path = ""
+        }
+    }
+    if profiles, err := filepath.Glob(filepath.Join(cfg.ProfileDir, runID+"-profile*.pprof")); err == nil {
+        for _, p := range profiles {
+            d.Artifacts.Profiles = append(d.Artifacts.Profiles, absPath(p))
+        }
+    }
+    return d
+}
+
+// absPath returns path made absolute, or "" for an empty path.
+func absPath(path string) string {
+    if path == "" {
+        return ""
+    }
+    if abs, err := filepath.Abs(path); err == nil {
+        return abs
+    }
+    return path
+}
+
+// WriteDescriptor writes d to path as JSON. The file is replaced in one step, so a pipeline
+// polling it never reads a partial descriptor.
+func WriteDescriptor(path string, d Descriptor) error {
+    data, err := json.MarshalIndent(d, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error encoding run descriptor: %w", err)
+    }
+
+    tmpPath := path + ".tmp"
+    if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+        return fmt.Errorf("error writing run descriptor %s: %w", tmpPath, err)
+    }
+    if err := os.Rename(tmpPath, path); err != nil {
+        return fmt.Errorf("error replacing run descriptor %s: %w", path, err)
+    }
+    return nil
+}
@@ -0,0 +1,198 @@
+// results/diff.go
+package results
+
+import (
+    "fmt"
+    "math"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+)
+
+// Units of the values compared by Diff, which decide how they are printed.
+const (
+    unitCount    = "count"
+    unitRate     = "rate"
+    unitBytes    = "bytes"
+    unitDuration = "duration"
+    unitPercent  = "percent"
+)
+
+// DiffRow compares one metric of two records.
+type DiffRow struct {
+    Section      string // "uploads" or the benchmark operation.
+    Metric       string
+    Unit         string
+    A, B         float64
+    HigherBetter bool
+    Change       float64 // Relative change from A to B in percent; +Inf when A is zero and B is not.
+    Regression   bool    // B is worse than A by more than the threshold.
+    Improvement  bool    // B is better than A by more than the threshold.
+}
+
+// Delta returns the absolute change from A to B.
+func (r DiffRow) Delta() float64 {
+    return r.B - r.A
+}
+
+// OpsPerSec returns the successful operations per second of the operation. Records written
+// before the rate was stored compute it from the elapsed time.
+func (op Operation) OpsPerSec() float64 {
+    if op.Rate == 0 && op.Elapsed > 0 {
+        return float64(op.Operations-op.Errors) / op.Elapsed.Seconds()
+    }
+    return op.Rate
+}
+
+// BytesPerSec returns the response body bytes per second of the operation.
+func (op Operation) BytesPerSec() float64 {
+    if op.ByteRate == 0 && op.Elapsed > 0 {
+        return float64(op.Bytes) / op.Elapsed.Seconds()
+    }
+    return op.ByteRate
+}
+
+// ErrorPercent returns the failed operations as a percentage of all operations.
+func (op Operation) ErrorPercent() float64 {
+    if op.Operations == 0 {
+        return 0
+    }
+    return float64(op.Errors) / float64(op.Operations) * 100
+}
+
+// Diff compares the upload statistics and every benchmark operation of record b with those of
+// record a. A metric that got worse by more than threshold percent is a regression. Percentiles
+// come from a latency histogram, so their changes are only flagged beyond its resolution.
+func Diff(a, b Record, threshold float64) []DiffRow {
+    var rows []DiffRow
+    percentileThreshold := math.Max(threshold, stats.Resolution*100)
+    add := func(section, metric, unit string, va, vb float64, higherBetter bool) {
+        threshold := threshold
+        if isPercentile(metric) {
+            threshold = percentileThreshold
+        }
+        row := DiffRow{Section: section, Metric: metric, Unit: unit, A: va, B: vb, HigherBetter: higherBetter}
+        switch {
+        case va == vb:
+        case va == 0:
+            row.Change = math.Inf(1)
+        default:
+            row.Change = (vb - va) / math.Abs(va) * 100
+        }
+        if va != vb && math.Abs(row.Change) > threshold {
+            better := (vb > va) == higherBetter
+            row.Regression = !better
+            row.Improvement = better
+        }
+        rows = append(rows, row)
+    }
+
+    sa, sb := a.Summary(), b.Summary()
+    add("uploads", "Successes", unitCount, float64(sa.Uploads), float64(sb.Uploads), true)
+    add("uploads", "Failures", unitCount, float64(sa.Failures), float64(sb.Failures), false)
+    add("uploads", "Files/sec", unitRate, sa.UploadRate, sb.UploadRate, true)
+    add("uploads", "Data Written", unitBytes, float64(a.State.Stats.Bytes), float64(b.State.Stats.Bytes), true)
+    add("uploads", "Duration", unitDuration, float64(a.FinishedAt.Sub(a.StartedAt)), float64(b.FinishedAt.Sub(b.StartedAt)), false)
+
+    names := a.OperationNames()
+    for _, name := range b.OperationNames() {
+        if _, ok := a.Benchmark[name]; !ok {
+            names = append(names, name)
+        }
+    }
+    for _, name := range names {
+        oa, ob := a.Benchmark[name], b.Benchmark[name]
+        add(name, "Ops/sec", unitRate, oa.OpsPerSec(), ob.OpsPerSec(), true)
+        if oa.Bytes > 0 || ob.Bytes > 0 {
+            add(name, "Bytes/sec", unitBytes, oa.BytesPerSec(), ob.BytesPerSec(), true)
+        }
+        add(name, "Errors", unitPercent, oa.ErrorPercent(), ob.ErrorPercent(), false)
+        add(name, "Avg", unitDuration, float64(oa.AvgTime), float64(ob.AvgTime), false)
+        // Records written before P50 and P90 were stored have neither.
+        if oa.P50Time > 0 && ob.P50Time > 0 {
+            add(name, "P50", unitDuration, float64(oa.P50Time), float64(ob.P50Time), false)
+            add(name, "P90", unitDuration, float64(oa.P90Time), float64(ob.P90Time), false)
+        }
+        add(name, "P99", unitDuration, float64(oa.P99Time), float64(ob.P99Time), false)
+        add(name, "Max", unitDuration, float64(oa.MaxTime), float64(ob.MaxTime), false)
+    }
+    return rows
+}
+
+// isPercentile reports whether a metric of Diff is a latency percentile.
+func isPercentile(metric string) bool {
+    return metric == "P50" || metric == "P90" || metric == "P99"
+}
+
+// formatDiffValue formats a value of a DiffRow in its unit.
+func formatDiffValue(unit string, v float64) string {
+    switch unit {
+    case unitRate:
+        return fmt.Sprintf("%.1f", v)
+    case unitBytes:
+        if v < 0 {
+            return "-" + config.FormatSize(int64(-v))
+        }
+        return config.FormatSize(int64(v))
+    case unitDuration:
+        return time.Duration(v).Round(time.Microsecond).String()
+    case unitPercent:
+        return fmt.Sprintf("%.2f%%", v)
+    default:
+        return fmt.Sprintf("%.0f", v)
+    }
+}
+
+// PrintDiff prints the rows of Diff side by side, marking regressions and improvements beyond
+// threshold percent, and returns the number of regressions.
+func PrintDiff(a, b Record, rows []DiffRow, threshold float64) int {
+    fmt.Println("\nReport Diff:")
+    fmt.Println("============")
+    for _, r := range []struct {
+        label string
+        rec   Record
+    }{{"A", a}, {"B", b}} {
+        fmt.Printf("%s: %s (run %s, %s, started %s, bucket %s)\n", r.label, r.rec.ID, r.rec.RunID, r.rec.Status, r.rec.StartedAt.UTC().Format(time.RFC3339), r.rec.Bucket)
+    }
+    fmt.Printf("Regression Threshold: %.1f%%\n", threshold)
+    if a.Seed != 0 && b.Seed != 0 && a.Seed != b.Seed {
+        fmt.Printf("Warning: the runs were made with different seeds (A: %d, B: %d), so their workloads differ.\n", a.Seed, b.Seed)
+    }
+    if a.GetTiming != b.GetTiming {
+        fmt.Printf("Warning: the GET times cover different things (A: %s, B: %s).\n", a.GetTiming, b.GetTiming)
+    }
+
+    regressions := 0
+    section := ""
+    fmt.Printf("\n%-20s %-14s %14s %14s %14s %10s\n", "Section", "Metric", "A", "B", "Delta", "Change")
+    for _, row := range rows {
+        label := ""
+        if row.Section != section {
+            section, label = row.Section, row.Section
+        }
+        change := "-"
+        switch {
+        case math.IsInf(row.Change, 1):
+            change = "new"
+        case row.A != row.B:
+            change = fmt.Sprintf("%+.1f%%", row.Change)
+        }
+        delta := formatDiffValue(row.Unit, row.Delta())
+        if row.Delta() > 0 {
+            delta = "+" + delta
+        }
+        mark := ""
+        if row.Regression {
+            mark = "  << REGRESSION"
+            regressions++
+        } else if row.Improvement {
+            mark = "  improved"
+        }
+        fmt.Printf("%-20s %-14s %14s %14s %14s %10s%s\n", label, row.Metric, formatDiffValue(row.Unit, row.A), formatDiffValue(row.Unit, row.B), delta, change, mark)
+    }
+
+    fmt.Printf("\nRegressions Beyond %.1f%%: %d\n", threshold, regressions)
+    fmt.Println("============")
+    return regressions
+}
@@ -0,0 +1,262 @@
+// results/export.go
+package results
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "html/template"
+    "io"
+    "sort"
+    "strconv"
+    "time"
+
+    "scale_s3_benchmark/config"
+)
+
+// Report formats accepted by Write.
+const (
+    FormatJSON = "json"
+    FormatCSV  = "csv"
+    FormatHTML = "html"
+)
+
+// ContentType returns the MIME type of a report format.
+func ContentType(format string) string {
+    switch format {
+    case FormatCSV:
+        return "text/csv"
+    case FormatHTML:
+        return "text/html; charset=utf-8"
+    default:
+        return "application/json"
+    }
+}
+
+// Write renders a record as a report in the given format.
+func Write(w io.Writer, rec Record, format string) error {
+    switch format {
+    case FormatJSON:
+        enc := json.NewEncoder(w)
+        enc.SetIndent("", "  ")
+        return enc.Encode(rec)
+    case FormatCSV:
+        return writeCSV(w, rec)
+    case FormatHTML:
+        return reportTemplate.Execute(w, rec)
+    default:
+        return fmt.Errorf("unknown report format %q", format)
+    }
+}
+
+// ms formats a duration as fractional milliseconds.
+func ms(d time.Duration) string {
+    return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// OperationNames returns the benchmarked operation types in alphabetical order.
+func (r Record) OperationNames() []string {
+    names := make([]string, 0, len(r.Benchmark))
+    for name := range r.Benchmark {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// writeCSV writes the record as Section,Item,Metric,Value rows, one value per row.
+func writeCSV(w io.Writer, rec Record) error {
+    cw := csv.NewWriter(w)
+    row := func(section, item, metric, value string) {
+        cw.Write([]string{section, item, metric, value})
+    }
+    num := func(n int64) string { return strconv.FormatInt(n, 10) }
+
+    row("Section", "Item", "Metric", "Value")
+
+    row("run", rec.ID, "RunID", rec.RunID)
+    row("run", rec.ID, "Status", rec.Status)
+    row("run", rec.ID, "StartedAt", rec.StartedAt.Format(time.RFC3339))
+    row("run", rec.ID, "FinishedAt", rec.FinishedAt.Format(time.RFC3339))
+    row("run", rec.ID, "Bucket", rec.Bucket)
+    row("run", rec.ID, "TotalFiles", strconv.Itoa(rec.TotalFiles))
+    for _, worker := range rec.Workers {
+        row("run", rec.ID, "Worker", worker)
+    }
+    if rec.State.AbortReason != "" {
+        row("run", rec.ID, "AbortReason", rec.State.AbortReason)
+    }
+
+    stats := rec.State.Stats
+    row("uploads", "", "TotalUploads", num(stats.TotalUploads))
+    row("uploads", "", "Successes", num(stats.Successes))
+    row("uploads", "", "Failures", num(stats.Failures))
+    row("uploads", "", "Skipped", num(stats.Skipped))
+    row("uploads", "", "Bytes", num(stats.Bytes))
+
+    for _, name := range rec.OperationNames() {
+        op := rec.Benchmark[name]
+        row("operation", name, "Operations", num(op.Operations))
+        row("operation", name, "Errors", num(op.Errors))
+        row("operation", name, "MinTimeMs", ms(op.MinTime))
+        row("operation", name, "AvgTimeMs", ms(op.AvgTime))
+        row("operation", name, "MaxTimeMs", ms(op.MaxTime))
+        row("operation", name, "P50TimeMs", ms(op.P50Time))
+        row("operation", name, "P90TimeMs", ms(op.P90Time))
+        row("operation", name, "P99TimeMs", ms(op.P99Time))
+        row("operation", name, "OpsPerSec", strconv.FormatFloat(op.Rate, 'f', 1, 64))
+    }
+    row("benchmark", "", "DurationMs", ms(rec.BenchmarkDuration))
+
+    for _, f := range rec.State.Folders {
+        row("folder", f.Name, "Files", num(f.Files))
+        row("folder", f.Name, "Successes", num(f.Successes))
+        row("folder", f.Name, "Failures", num(f.Failures))
+        row("folder", f.Name, "DurationMs", ms(f.Duration))
+        row("folder", f.Name, "FilesPerSec", strconv.FormatFloat(f.Throughput(), 'f', 2, 64))
+    }
+
+    for _, cs := range rec.State.Connections {
+        row("connection", cs.Endpoint, "Requests", num(cs.Requests))
+        row("connection", cs.Endpoint, "NewConns", num(cs.NewConns))
+        row("connection", cs.Endpoint, "ReusedConns", num(cs.ReusedConns))
+        row("connection", cs.Endpoint, "TLSHandshakes", num(cs.TLSHandshakes))
+        row("connection", cs.Endpoint, "DNSLookups", num(cs.DNSLookups))
+    }
+
+    for _, e := range rec.State.CircuitEvents {
+        row("circuit", e.Endpoint, e.Time.Format(time.RFC3339), e.State)
+    }
+
+    if mp := rec.State.Multipart; mp.Uploads > 0 {
+        row("multipart", "", "Objects", num(mp.Uploads))
+        row("multipart", "", "Parts", num(mp.Parts))
+        row("multipart", "", "MaxTimeMs", ms(mp.MaxTime))
+    }
+
+    if integrity := rec.State.Integrity; integrity.Checked > 0 {
+        row("integrity", "", "Checked", num(integrity.Checked))
+        row("integrity", "", "Mismatches", num(integrity.Mismatches))
+    }
+
+    if stalls := rec.State.Stalls; stalls.Total() > 0 {
+        row("stalls", "", "Sending", num(stalls.Sending))
+        row("stalls", "", "Receiving", num(stalls.Receiving))
+        for _, e := range stalls.Endpoints {
+            row("stalls", e.Endpoint, "Stalls", num(e.Stalls))
+        }
+    }
+
+    percent := func(f float64) string { return strconv.FormatFloat(f, 'f', 1, 64) }
+    for _, r := range rec.State.Resources {
+        at := r.Time.Format(time.RFC3339)
+        row("resources", at, "CPUPercent", percent(r.CPUPercent))
+        row("resources", at, "HostCPUPercent", percent(r.HostCPUPercent))
+        row("resources", at, "RSSBytes", num(r.RSSBytes))
+        row("resources", at, "HostMemPercent", percent(r.HostMemPercent))
+        row("resources", at, "NetRxBytesPerSec", num(int64(r.NetRxBytesPerSec)))
+        row("resources", at, "NetTxBytesPerSec", num(int64(r.NetTxBytesPerSec)))
+        row("resources", at, "OpenFDs", strconv.Itoa(r.OpenFDs))
+    }
+
+    cw.Flush()
+    return cw.Error()
+}
+
+// reportTemplate renders a self-contained HTML report of a record.
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+    "ms":   ms,
+    "size": config.FormatSize,
+    "rate": func(bytesPerSec float64) string { return config.FormatSize(int64(bytesPerSec)) },
+    "time": func(t time.Time) string { return t.Format(time.RFC3339) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <title>Benchmark Report {{.ID}}</title>
+    <style>
+        body { font-family: Arial, sans-serif; margin: 20px; }
+        h1, h2 { color: #333; }
+        table { border-collapse: collapse; margin-bottom: 20px; }
+        th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+        th:first-child, td:first-child { text-align: left; }
+    </style>
+</head>
+<body>
+    <h1>Benchmark Report {{.ID}}</h1>
+    <table>
+        <tr><td>Run ID</td><td>{{.RunID}}</td></tr>
+        <tr><td>Status</td><td>{{.Status}}{{with .State.AbortReason}} ({{.}}){{end}}</td></tr>
+        <tr><td>Started</td><td>{{time .StartedAt}}</td></tr>
+        <tr><td>Finished</td><td>{{time .FinishedAt}}</td></tr>
+        <tr><td>Bucket</td><td>{{.Bucket}}</td></tr>
+        <tr><td>Endpoints</td><td>{{range $i, $e := .Endpoints}}{{if $i}}, {{end}}{{$e}}{{end}}</td></tr>
+        <tr><td>Total Uploads</td><td>{{.State.Stats.TotalUploads}}</td></tr>
+        <tr><td>Successes</td><td>{{.State.Stats.Successes}}</td></tr>
+        <tr><td>Failures</td><td>{{.State.Stats.Failures}}</td></tr>
+        <tr><td>Skipped</td><td>{{.State.Stats.Skipped}}</td></tr>
+        <tr><td>Data Written</td><td>{{size .State.Stats.Bytes}}</td></tr>
+    </table>
+{{if .Benchmark}}
+    <h2>Benchmark Operations</h2>
+    <table>
+        <tr><th>Operation</th><th>Operations</th><th>Errors</th><th>Min (ms)</th><th>Avg (ms)</th><th>Max (ms)</th></tr>
+{{- range $name := .OperationNames}}{{with index $.Benchmark $name}}
+        <tr><td>{{$name}}</td><td>{{.Operations}}</td><td>{{.Errors}}</td><td>{{ms .MinTime}}</td><td>{{ms .AvgTime}}</td><td>{{ms .MaxTime}}</td></tr>
+{{- end}}{{end}}
+    </table>
+    <p>Benchmarking Duration: {{ms .BenchmarkDuration}} ms</p>
+{{end}}
+{{- if .State.Folders}}
+    <h2>Subfolders</h2>
+    <table>
+        <tr><th>Subfolder</th><th>Files</th><th>Successes</th><th>Failures</th><th>Duration (ms)</th><th>Files/sec</th></tr>
+{{- range .State.Folders}}
+        <tr><td>{{.Name}}</td><td>{{.Files}}</td><td>{{.Successes}}</td><td>{{.Failures}}</td><td>{{ms .Duration}}</td><td>{{printf "%.2f" .Throughput}}</td></tr>
+{{- end}}
+    </table>
+{{end}}
+{{- if .State.Connections}}
+    <h2>Connections</h2>
+    <table>
+        <tr><th>Endpoint</th><th>Requests</th><th>New</th><th>Reused</th><th>TLS Handshakes</th><th>DNS Lookups</th></tr>
+{{- range .State.Connections}}
+        <tr><td>{{.Endpoint}}</td><td>{{.Requests}}</td><td>{{.NewConns}}</td><td>{{.ReusedConns}}</td><td>{{.TLSHandshakes}}</td><td>{{.DNSLookups}}</td></tr>
+{{- end}}
+    </table>
+{{end}}
+{{- if .State.CircuitEvents}}
+    <h2>Circuit Breaker Events</h2>
+    <table>
+        <tr><th>Time</th><th>Endpoint</th><th>State</th></tr>
+{{- range .State.CircuitEvents}}
+        <tr><td>{{time .Time}}</td><td>{{.Endpoint}}</td><td>{{.State}}</td></tr>
+{{- end}}
+    </table>
+{{end}}
+{{- with .State.Integrity}}{{if .Checked}}
+    <h2>Integrity Verification</h2>
+    <p>ETags Checked: {{.Checked}}, Integrity Failures: {{.Mismatches}}</p>
+{{end}}{{end}}
+{{- with .State.Stalls}}{{if .Total}}
+    <h2>Stalled Transfers</h2>
+    <p>While Sending: {{.Sending}}, While Receiving: {{.Receiving}}</p>
+    <table>
+        <tr><th>Endpoint</th><th>Stalls</th></tr>
+{{- range .Endpoints}}
+        <tr><td>{{.Endpoint}}</td><td>{{.Stalls}}</td></tr>
+{{- end}}
+    </table>
+{{end}}{{end}}
+{{- if .State.Resources}}
+    <h2>Load Generator Resources</h2>
+    <table>
+        <tr><th>Time</th><th>Process CPU (%)</th><th>Host CPU (%)</th><th>Process Memory</th><th>Host Memory (%)</th><th>Received/s</th><th>Sent/s</th><th>Open FDs</th></tr>
+{{- range .State.Resources}}
+        <tr><td>{{time .Time}}</td><td>{{printf "%.1f" .CPUPercent}}</td><td>{{printf "%.1f" .HostCPUPercent}}</td><td>{{size .RSSBytes}}</td><td>{{printf "%.1f" .HostMemPercent}}</td><td>{{rate .NetRxBytesPerSec}}</td><td>{{rate .NetTxBytesPerSec}}</td><td>{{.OpenFDs}}</td></tr>
+{{- end}}
+    </table>
+{{end}}
+</body>
+</html>
+`))
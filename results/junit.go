@@ -0,0 +1,260 @@
+// results/junit.go
+package results
+
+import (
+    "encoding/xml"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// SLACheck is the outcome of one SLA assertion or error-rate check, a test case of the JUnit report.
+type SLACheck struct {
+    Class   string // "run", "UPLOAD" or the benchmark operation checked.
+    Name    string
+    Elapsed time.Duration // How long the checked operation ran.
+    Failure string        // Why the check failed; empty when it passed.
+}
+
+// Passed reports whether the check passed.
+func (c SLACheck) Passed() bool {
+    return c.Failure == ""
+}
+
+// CheckSLA checks a record: that the run neither failed nor breached the abort policy, that
+// the uploads and every benchmark operation stayed within slaMaxErrorPercent, and every
+// assertion of slaAssertions.
+func CheckSLA(cfg *config.Config, rec Record) []SLACheck {
+    duration := rec.FinishedAt.Sub(rec.StartedAt)
+    run := SLACheck{Class: "run", Name: "status", Elapsed: duration}
+    switch rec.Status {
+    case monitor.PhaseAborted:
+        run.Failure = "run aborted: " + rec.State.AbortReason
+    case monitor.PhaseFailed:
+        run.Failure = "run failed: " + rec.State.Reason
+    }
+    checks := []SLACheck{run}
+
+    errorRate := config.SLAAssertion{Metric: config.SLAMetricErrorPercent, Max: cfg.SLAMaxErrorPercent}
+    uploads := SLACheck{Class: config.SLAUploads, Name: errorRate.Name(), Elapsed: duration}
+    if pct := uploadErrorPercent(rec); pct > cfg.SLAMaxErrorPercent {
+        uploads.Failure = fmt.Sprintf("errorPercent was %.2f%% (%d of %d uploads failed), above %g%%",
+            pct, rec.State.Stats.Failures, rec.State.Stats.TotalUploads, cfg.SLAMaxErrorPercent)
+    }
+    checks = append(checks, uploads)
+    for _, name := range rec.OperationNames() {
+        op := rec.Benchmark[name]
+        check := SLACheck{Class: name, Name: errorRate.Name(), Elapsed: op.Elapsed}
+        if pct := op.ErrorPercent(); pct > cfg.SLAMaxErrorPercent {
+            check.Failure = fmt.Sprintf("errorPercent was %.2f%% (%d of %d operations failed), above %g%%",
+                pct, op.Errors, op.Operations, cfg.SLAMaxErrorPercent)
+        }
+        checks = append(checks, check)
+    }
+
+    for _, a := range cfg.SLAAssertions {
+        checks = append(checks, checkAssertion(rec, a))
+    }
+    return checks
+}
+
+// checkAssertion checks one SLA assertion against a record.
+func checkAssertion(rec Record, a config.SLAAssertion) SLACheck {
+    check := SLACheck{Class: a.Operation, Name: a.Name()}
+
+    var value float64
+    if a.Operation == config.SLAUploads {
+        check.Elapsed = rec.FinishedAt.Sub(rec.StartedAt)
+        if a.Metric == config.SLAMetricOpsPerSec {
+            value = rec.Summary().UploadRate
+        } else {
+            value = uploadErrorPercent(rec)
+        }
+    } else {
+        op, ok := rec.Benchmark[a.Operation]
+        if !ok {
+            check.Failure = fmt.Sprintf("%s was not benchmarked", a.Operation)
+            return check
+        }
+        check.Elapsed = op.Elapsed
+        value = operationMetric(op, a.Metric)
+    }
+
+    unit := ""
+    if a.IsLatency() {
+        unit = "ms"
+    } else if a.Metric == config.SLAMetricErrorPercent {
+        unit = "%"
+    }
+    switch {
+    case a.Max > 0 && value > a.Max:
+        check.Failure = fmt.Sprintf("%s was %.2f%s, above %g%s", a.Metric, value, unit, a.Max, unit)
+    case a.Min > 0 && value < a.Min:
+        check.Failure = fmt.Sprintf("%s was %.2f%s, below %g%s", a.Metric, value, unit, a.Min, unit)
+    }
+    return check
+}
+
+// operationMetric returns the metric of an SLA assertion for a benchmark operation, with
+// latencies in milliseconds.
+func operationMetric(op Operation, metric string) float64 {
+    ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+    switch metric {
+    case config.SLAMetricAvg:
+        return ms(op.AvgTime)
+    case config.SLAMetricP50:
+        return ms(op.P50Time)
+    case config.SLAMetricP90:
+        return ms(op.P90Time)
+    case config.SLAMetricP99:
+        return ms(op.P99Time)
+    case config.SLAMetricMax:
+        return ms(op.MaxTime)
+    case config.SLAMetricOpsPerSec:
+        return op.OpsPerSec()
+    default:
+        return op.ErrorPercent()
+    }
+}
+
+// uploadErrorPercent returns the failed uploads as a percentage of all uploads of a record.
+func uploadErrorPercent(rec Record) float64 {
+    if rec.State.Stats.TotalUploads == 0 {
+        return 0
+    }
+    return float64(rec.State.Stats.Failures) / float64(rec.State.Stats.TotalUploads) * 100
+}
+
+// JUnit XML elements, as read by Jenkins and GitLab.
+type junitTestSuites struct {
+    XMLName  xml.Name         `xml:"testsuites"`
+    Name     string           `xml:"name,attr"`
+    Tests    int              `xml:"tests,attr"`
+    Failures int              `xml:"failures,attr"`
+    Time     string           `xml:"time,attr"`
+    Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+    Name       string          `xml:"name,attr"`
+    Tests      int             `xml:"tests,attr"`
+    Failures   int             `xml:"failures,attr"`
+    Time       string          `xml:"time,attr"`
+    Timestamp  string          `xml:"timestamp,attr"`
+    Properties []junitProperty `xml:"properties>property"`
+    Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+    Name  string `xml:"name,attr"`
+    Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+    Classname string        `xml:"classname,attr"`
+    Name      string        `xml:"name,attr"`
+    Time      string        `xml:"time,attr"`
+    Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+    Message string `xml:"message,attr"`
+    Type    string `xml:"type,attr"`
+    Text    string `xml:",chardata"`
+}
+
+// seconds formats a duration as the fractional seconds of JUnit time attributes.
+func seconds(d time.Duration) string {
+    return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the checks of a record as a JUnit XML report with one test suite for the
+// run and one test case per check.
+func WriteJUnit(w io.Writer, rec Record, checks []SLACheck) error {
+    duration := rec.FinishedAt.Sub(rec.StartedAt)
+    suite := junitTestSuite{
+        Name:      "scale_s3_benchmark." + rec.RunID,
+        Tests:     len(checks),
+        Time:      seconds(duration),
+        Timestamp: rec.StartedAt.Format("2006-01-02T15:04:05"),
+        Properties: []junitProperty{
+            {Name: "runID", Value: rec.RunID},
+            {Name: "status", Value: rec.Status},
+            {Name: "bucket", Value: rec.Bucket},
+            {Name: "endpoints", Value: strings.Join(rec.Endpoints, ",")},
+        },
+    }
+    if rec.Seed != 0 {
+        suite.Properties = append(suite.Properties, junitProperty{Name: "seed", Value: fmt.Sprint(rec.Seed)})
+    }
+    for _, c := range checks {
+        tc := junitTestCase{Classname: c.Class, Name: c.Name, Time: seconds(c.Elapsed)}
+        if !c.Passed() {
+            suite.Failures++
+            tc.Failure = &junitFailure{Message: c.Failure, Type: "SLAViolation", Text: c.Failure}
+        }
+        suite.Cases = append(suite.Cases, tc)
+    }
+
+    doc := junitTestSuites{
+        Name:     "scale_s3_benchmark",
+        Tests:    suite.Tests,
+        Failures: suite.Failures,
+        Time:     suite.Time,
+        Suites:   []junitTestSuite{suite},
+    }
+    if _, err := io.WriteString(w, xml.Header); err != nil {
+        return err
+    }
+    enc := xml.NewEncoder(w)
+    enc.Indent("", "  ")
+    if err := enc.Encode(doc); err != nil {
+        return fmt.Errorf("error encoding JUnit report: %w", err)
+    }
+    _, err := io.WriteString(w, "\n")
+    return err
+}
+
+// SaveJUnit writes the JUnit XML report of a record's checks to path, creating its directory
+// if needed.
+func SaveJUnit(path string, rec Record, checks []SLACheck) error {
+    if dir := filepath.Dir(path); dir != "." {
+        if err := os.MkdirAll(dir, 0755); err != nil {
+            return fmt.Errorf("error creating directory %s: %w", dir, err)
+        }
+    }
+    f, err := os.Create(path)
+    if err != nil {
+        return fmt.Errorf("error creating JUnit report %s: %w", path, err)
+    }
+    if err := WriteJUnit(f, rec, checks); err != nil {
+        f.Close()
+        return err
+    }
+    if err := f.Close(); err != nil {
+        return fmt.Errorf("error writing JUnit report %s: %w", path, err)
+    }
+    return nil
+}
+
+// PrintSLA prints how many checks passed and why the others failed.
+func PrintSLA(checks []SLACheck) {
+    failed := 0
+    for _, c := range checks {
+        if !c.Passed() {
+            failed++
+        }
+    }
+    fmt.Printf("SLA checks: %d passed, %d failed\n", len(checks)-failed, failed)
+    for _, c := range checks {
+        if !c.Passed() {
+            fmt.Printf("  FAIL %s %s: %s\n", c.Class, c.Name, c.Failure)
+        }
+    }
+}
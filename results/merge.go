@@ -0,0 +1,313 @@
+// results/merge.go
+package results
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "scale_s3_benchmark/benchmark"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// StatusMixed is the status of a merged record whose workers ended with different statuses.
+const StatusMixed = "mixed"
+
+// Merge combines the records of workers that ran one benchmark side by side, on several hosts
+// or on hand-partitioned data sets, into one record with the given ID. Counts are summed,
+// rates are summed since the workers ran at the same time, and the percentiles are taken
+// from the summed latency histograms, not averaged. Measurements that only make sense per
+// host, such as resource usage, faults and notification or replication delays, are left out.
+// The returned warnings describe what could not be combined exactly.
+func Merge(id string, records []Record) (Record, []string, error) {
+    if len(records) < 2 {
+        return Record{}, nil, fmt.Errorf("at least two reports are needed to merge, got %d", len(records))
+    }
+
+    var warnings []string
+    merged := Record{
+        ID:        id,
+        Status:    records[0].Status,
+        Seed:      records[0].Seed,
+        Benchmark: make(map[string]Operation),
+    }
+    var runIDs, buckets, getTimings, keyAccesses []string
+    byOperation := make(map[string][]Operation)
+
+    for _, rec := range records {
+        merged.Workers = append(merged.Workers, rec.ID)
+        runIDs = appendDistinct(runIDs, rec.RunID)
+        buckets = appendDistinct(buckets, rec.Bucket)
+        getTimings = appendDistinct(getTimings, rec.GetTiming)
+        keyAccesses = appendDistinct(keyAccesses, rec.KeyAccess)
+        for _, endpoint := range rec.Endpoints {
+            merged.Endpoints = appendDistinct(merged.Endpoints, endpoint)
+        }
+        if rec.Status != merged.Status {
+            merged.Status = StatusMixed
+        }
+        if rec.Seed != merged.Seed {
+            merged.Seed = 0
+        }
+        if merged.StartedAt.IsZero() || (!rec.StartedAt.IsZero() && rec.StartedAt.Before(merged.StartedAt)) {
+            merged.StartedAt = rec.StartedAt
+        }
+        if rec.FinishedAt.After(merged.FinishedAt) {
+            merged.FinishedAt = rec.FinishedAt
+        }
+        merged.TotalFiles += rec.TotalFiles
+        merged.BenchmarkDuration = max(merged.BenchmarkDuration, rec.BenchmarkDuration)
+        for name, op := range rec.Benchmark {
+            byOperation[name] = append(byOperation[name], op)
+        }
+    }
+
+    merged.RunID = strings.Join(runIDs, ",")
+    merged.Bucket = strings.Join(buckets, ",")
+    merged.GetTiming = strings.Join(getTimings, ",")
+    merged.KeyAccess = strings.Join(keyAccesses, ",")
+    if len(getTimings) > 1 {
+        warnings = append(warnings, fmt.Sprintf("the workers timed GETs differently (%s), so the GET percentiles mix both", merged.GetTiming))
+    }
+
+    for name, ops := range byOperation {
+        op, exact := mergeOperations(ops)
+        merged.Benchmark[name] = op
+        if !exact {
+            warnings = append(warnings, fmt.Sprintf("%s: a report has no latency histogram, so its P50, P90 and P99 are the highest of the workers', an upper bound", name))
+        }
+    }
+    sort.Strings(warnings)
+
+    merged.State = mergeStates(merged, records)
+    return merged, warnings, nil
+}
+
+// mergeOperations combines the measurements of one operation by several workers. It reports
+// false if the percentiles could not be combined from the latency histograms.
+func mergeOperations(ops []Operation) (Operation, bool) {
+    var merged Operation
+    var totalTime float64
+    exact := true
+    for _, op := range ops {
+        merged.Operations += op.Operations
+        merged.Errors += op.Errors
+        merged.Bytes += op.Bytes
+        if merged.MinTime == 0 || (op.MinTime > 0 && op.MinTime < merged.MinTime) {
+            merged.MinTime = op.MinTime
+        }
+        merged.MaxTime = max(merged.MaxTime, op.MaxTime)
+        merged.Elapsed = max(merged.Elapsed, op.Elapsed)
+        totalTime += float64(op.AvgTime) * float64(op.Operations)
+
+        merged.Rate += op.OpsPerSec()
+        merged.ByteRate += op.BytesPerSec()
+
+        switch {
+        case !exact:
+        case len(op.Latency) == 0 || (merged.Latency != nil && len(op.Latency) != len(merged.Latency)):
+            exact = false
+        case merged.Latency == nil:
+            merged.Latency = append([]int64(nil), op.Latency...)
+        default:
+            for b, n := range op.Latency {
+                merged.Latency[b] += n
+            }
+        }
+    }
+    if merged.Operations > 0 {
+        merged.AvgTime = time.Duration(totalTime / float64(merged.Operations))
+    }
+
+    if exact {
+        // A percentile is the upper bound of its histogram bucket, which can exceed the slowest operation.
+        percentile := func(p float64) time.Duration {
+            d, ok := benchmark.LatencyPercentile(merged.Latency, p)
+            exact = exact && ok
+            return min(d, merged.MaxTime)
+        }
+        merged.P50Time, merged.P90Time, merged.P99Time = percentile(0.50), percentile(0.90), percentile(0.99)
+    }
+    if !exact {
+        merged.Latency = nil
+        merged.P50Time, merged.P90Time, merged.P99Time = 0, 0, 0
+        for _, op := range ops {
+            merged.P50Time = max(merged.P50Time, op.P50Time)
+            merged.P90Time = max(merged.P90Time, op.P90Time)
+            merged.P99Time = max(merged.P99Time, op.P99Time)
+        }
+    }
+    return merged, exact
+}
+
+// mergeStates combines the upload statistics of the workers' runs. The subfolders of each
+// worker are prefixed with its record ID.
+func mergeStates(merged Record, records []Record) monitor.StateDump {
+    state := monitor.StateDump{
+        Time:   merged.FinishedAt,
+        RunID:  merged.RunID,
+        Reason: "merged",
+    }
+    state.Stats.StartTime = merged.StartedAt
+
+    var abortReasons, truncateReasons []string
+    connections := make(map[string]*monitor.ConnStats)
+    var endpoints []string
+    signatures := make(map[[3]string]*monitor.ErrorSignature)
+    var signatureOrder [][3]string
+    endpointStalls := make(map[string]int64)
+
+    for _, rec := range records {
+        s := rec.State
+        if s.AbortReason != "" {
+            abortReasons = appendDistinct(abortReasons, rec.ID+": "+s.AbortReason)
+        }
+        if s.TruncateReason != "" {
+            truncateReasons = appendDistinct(truncateReasons, rec.ID+": "+s.TruncateReason)
+        }
+
+        state.Stats.TotalUploads += s.Stats.TotalUploads
+        state.Stats.Successes += s.Stats.Successes
+        state.Stats.Failures += s.Stats.Failures
+        state.Stats.Skipped += s.Stats.Skipped
+        state.Stats.Bytes += s.Stats.Bytes
+
+        for _, f := range s.Folders {
+            f.Index = len(state.Folders)
+            f.Name = rec.ID + "/" + f.Name
+            state.Folders = append(state.Folders, f)
+        }
+
+        for _, cs := range s.Connections {
+            c, ok := connections[cs.Endpoint]
+            if !ok {
+                c = &monitor.ConnStats{Endpoint: cs.Endpoint}
+                connections[cs.Endpoint] = c
+                endpoints = append(endpoints, cs.Endpoint)
+            }
+            c.Requests += cs.Requests
+            c.NewConns += cs.NewConns
+            c.ReusedConns += cs.ReusedConns
+            c.TLSHandshakes += cs.TLSHandshakes
+            c.DNSLookups += cs.DNSLookups
+            c.DialTime += cs.DialTime
+            c.TLSTime += cs.TLSTime
+            c.DNSTime += cs.DNSTime
+        }
+
+        state.CircuitEvents = append(state.CircuitEvents, s.CircuitEvents...)
+
+        mp := &state.Multipart
+        mp.Uploads += s.Multipart.Uploads
+        mp.Parts += s.Multipart.Parts
+        mp.EndpointsPerObj += s.Multipart.EndpointsPerObj
+        mp.TotalTime += s.Multipart.TotalTime
+        mp.MaxTime = max(mp.MaxTime, s.Multipart.MaxTime)
+        mp.Abandoned += s.Multipart.Abandoned
+        mp.AbandonedParts += s.Multipart.AbandonedParts
+        mp.AbandonedBytes += s.Multipart.AbandonedBytes
+
+        state.Integrity.Checked += s.Integrity.Checked
+        state.Integrity.Mismatches += s.Integrity.Mismatches
+
+        state.Stalls.Sending += s.Stalls.Sending
+        state.Stalls.Receiving += s.Stalls.Receiving
+        for _, e := range s.Stalls.Endpoints {
+            endpointStalls[e.Endpoint] += e.Stalls
+        }
+
+        for _, e := range s.Errors {
+            key := [3]string{e.Operation, fmt.Sprint(e.StatusCode), e.Code}
+            sig, ok := signatures[key]
+            if !ok {
+                sig = &monitor.ErrorSignature{Operation: e.Operation, StatusCode: e.StatusCode, Code: e.Code, First: e.First, Last: e.Last}
+                signatures[key] = sig
+                signatureOrder = append(signatureOrder, key)
+            }
+            sig.Count += e.Count
+            if e.First.Time.Before(sig.First.Time) {
+                sig.First = e.First
+            }
+            if e.Last.Time.After(sig.Last.Time) {
+                sig.Last = e.Last
+            }
+        }
+    }
+
+    state.AbortReason = strings.Join(abortReasons, "; ")
+    state.TruncateReason = strings.Join(truncateReasons, "; ")
+    for _, endpoint := range endpoints {
+        state.Connections = append(state.Connections, *connections[endpoint])
+    }
+    for endpoint, n := range endpointStalls {
+        state.Stalls.Endpoints = append(state.Stalls.Endpoints, monitor.EndpointStalls{Endpoint: endpoint, Stalls: n})
+    }
+    sort.Slice(state.Stalls.Endpoints, func(i, j int) bool { return state.Stalls.Endpoints[i].Endpoint < state.Stalls.Endpoints[j].Endpoint })
+    sort.SliceStable(state.CircuitEvents, func(i, j int) bool { return state.CircuitEvents[i].Time.Before(state.CircuitEvents[j].Time) })
+    for _, key := range signatureOrder {
+        state.Errors = append(state.Errors, *signatures[key])
+    }
+    sort.SliceStable(state.Errors, func(i, j int) bool { return state.Errors[i].Count > state.Errors[j].Count })
+    return state
+}
+
+// appendDistinct appends s to list unless it is empty or already there.
+func appendDistinct(list []string, s string) []string {
+    if s == "" {
+        return list
+    }
+    for _, existing := range list {
+        if existing == s {
+            return list
+        }
+    }
+    return append(list, s)
+}
+
+// PrintMerged prints a merged record and the warnings of Merge.
+func PrintMerged(rec Record, workers []Record, warnings []string) {
+    fmt.Println("\nMerged Report:")
+    fmt.Println("==============")
+    fmt.Printf("Report ID: %s\n", rec.ID)
+    fmt.Printf("Run IDs: %s\n", rec.RunID)
+    fmt.Printf("Status: %s\n", rec.Status)
+    if rec.State.AbortReason != "" {
+        fmt.Printf("Abort Reasons: %s\n", rec.State.AbortReason)
+    }
+    fmt.Printf("Bucket: %s\n", rec.Bucket)
+    fmt.Printf("Started: %s\n", rec.StartedAt.UTC().Format(time.RFC3339))
+    fmt.Printf("Finished: %s (%v)\n", rec.FinishedAt.UTC().Format(time.RFC3339), rec.FinishedAt.Sub(rec.StartedAt).Round(time.Second))
+
+    fmt.Printf("\nWorkers: %d\n", len(workers))
+    fmt.Printf("%-26s %-24s %-10s %10s %10s %12s %12s\n", "Report ID", "Run ID", "Status", "Uploads", "Failures", "Uploads/s", "Data")
+    for _, w := range workers {
+        s := w.Summary()
+        fmt.Printf("%-26s %-24s %-10s %10d %10d %12.1f %12s\n", w.ID, w.RunID, w.Status, s.Uploads, s.Failures, s.UploadRate, config.FormatSize(w.State.Stats.Bytes))
+    }
+
+    s := rec.Summary()
+    fmt.Printf("\nUploads: %d succeeded, %d failed, %d skipped\n", rec.State.Stats.Successes, rec.State.Stats.Failures, rec.State.Stats.Skipped)
+    fmt.Printf("Data Written: %s\n", config.FormatSize(rec.State.Stats.Bytes))
+    fmt.Printf("Aggregate Upload Rate: %.1f files/sec over the span of all workers\n", s.UploadRate)
+
+    if len(rec.Benchmark) > 0 {
+        fmt.Printf("\n%-18s %12s %8s %12s %10s %10s %10s %10s %10s %10s\n", "Operation", "Operations", "Errors", "Ops/s", "MB/s", "Avg", "P50", "P90", "P99", "Max")
+        for _, name := range rec.OperationNames() {
+            op := rec.Benchmark[name]
+            fmt.Printf("%-18s %12d %8d %12.1f %10.2f %10v %10v %10v %10v %10v\n", name, op.Operations, op.Errors, op.Rate, op.ByteRate/(1024*1024),
+                op.AvgTime.Round(time.Microsecond), op.P50Time.Round(time.Microsecond), op.P90Time.Round(time.Microsecond),
+                op.P99Time.Round(time.Microsecond), op.MaxTime.Round(time.Microsecond))
+        }
+        fmt.Println("Ops/s and MB/s are the sums over the workers; the percentiles are those of all their operations together.")
+    }
+
+    if len(warnings) > 0 {
+        fmt.Println("\nWarnings:")
+        for _, w := range warnings {
+            fmt.Printf("  - %s\n", w)
+        }
+    }
+    fmt.Println("==============")
+}
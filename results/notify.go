@@ -0,0 +1,115 @@
+// results/notify.go
+package results
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "scale_s3_benchmark/config"
+)
+
+// notifyTimeout bounds the summary request, so an unreachable webhook cannot hold up the end of a run.
+const notifyTimeout = 10 * time.Second
+
+// OperationNotice is the summary of one benchmark operation in a RunNotice.
+type OperationNotice struct {
+    Rate    float64 `json:"Rate"` // Operations per second while the operation ran.
+    P99Time string  `json:"P99Time"`
+    Errors  int64   `json:"Errors"`
+}
+
+// RunNotice is the summary of a run posted to runWebhookURL.
+type RunNotice struct {
+    ID           string                     `json:"ID"`
+    RunID        string                     `json:"RunID"`
+    Status       string                     `json:"Status"`
+    Bucket       string                     `json:"Bucket"`
+    Duration     string                     `json:"Duration"`
+    Uploads      int64                      `json:"Uploads"`
+    Failures     int64                      `json:"Failures"`
+    UploadRate   float64                    `json:"UploadRate"` // Successful uploads per second over the whole run.
+    Operations   map[string]OperationNotice `json:"Operations"`
+    SLAPassed    bool                       `json:"SLAPassed"`              // The run ended without breaching the abort policy.
+    SLAViolation string                     `json:"SLAViolation,omitempty"` // Why the run was aborted.
+    ReportURL    string                     `json:"ReportURL,omitempty"`
+    Text         string                     `json:"text"` // Human-readable rendering of the notice.
+}
+
+// NewRunNotice summarizes a record. The report link is only set when reportBaseURL is configured.
+func NewRunNotice(cfg *config.Config, rec Record) RunNotice {
+    summary := rec.Summary()
+    n := RunNotice{
+        ID:           rec.ID,
+        RunID:        rec.RunID,
+        Status:       rec.Status,
+        Bucket:       rec.Bucket,
+        Duration:     rec.FinishedAt.Sub(rec.StartedAt).Round(time.Second).String(),
+        Uploads:      summary.Uploads,
+        Failures:     summary.Failures,
+        UploadRate:   summary.UploadRate,
+        Operations:   make(map[string]OperationNotice),
+        SLAPassed:    rec.State.AbortReason == "",
+        SLAViolation: rec.State.AbortReason,
+    }
+    for name, op := range rec.Benchmark {
+        notice := OperationNotice{P99Time: op.P99Time.String(), Errors: op.Errors}
+        if op.Elapsed > 0 {
+            notice.Rate = float64(op.Operations) / op.Elapsed.Seconds()
+        }
+        n.Operations[name] = notice
+    }
+    if cfg.ReportBaseURL != "" {
+        n.ReportURL = fmt.Sprintf("%s/api/report?format=%s&id=%s", cfg.ReportBaseURL, FormatHTML, rec.ID)
+    }
+    n.Text = n.text(rec.OperationNames())
+    return n
+}
+
+// text renders the notice as a few lines, one per benchmark operation.
+func (n RunNotice) text(operations []string) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "Run %s %s on bucket %s after %s\n", n.ID, n.Status, n.Bucket, n.Duration)
+    fmt.Fprintf(&b, "Uploads: %d succeeded, %d failed, %.2f files/sec\n", n.Uploads, n.Failures, n.UploadRate)
+    for _, name := range operations {
+        op := n.Operations[name]
+        fmt.Fprintf(&b, "%s: %.2f ops/sec, p99 %s, %d errors\n", name, op.Rate, op.P99Time, op.Errors)
+    }
+    if n.SLAPassed {
+        b.WriteString("SLA: passed\n")
+    } else {
+        fmt.Fprintf(&b, "SLA: violated, %s\n", n.SLAViolation)
+    }
+    if n.ReportURL != "" {
+        fmt.Fprintf(&b, "Report: %s\n", n.ReportURL)
+    }
+    return strings.TrimSuffix(b.String(), "\n")
+}
+
+// PostRunNotice posts the summary of a record to runWebhookURL in runWebhookFormat.
+func PostRunNotice(cfg *config.Config, rec Record) error {
+    notice := NewRunNotice(cfg, rec)
+
+    var payload interface{} = notice
+    if cfg.RunWebhookFormat == config.RunWebhookSlack {
+        payload = map[string]string{"text": notice.Text}
+    }
+    body, err := json.Marshal(payload)
+    if err != nil {
+        return fmt.Errorf("error encoding run summary: %w", err)
+    }
+
+    client := &http.Client{Timeout: notifyTimeout}
+    resp, err := client.Post(cfg.RunWebhookURL, "application/json", bytes.NewReader(body))
+    if err != nil {
+        return fmt.Errorf("error posting run summary: %w", err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode >= 300 {
+        return fmt.Errorf("error posting run summary: webhook returned %s", resp.Status)
+    }
+    return nil
+}
@@ -0,0 +1,82 @@
+// results/output.go
+package results
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+    "path"
+    "path/filepath"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+    "github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+    "scale_s3_benchmark/config"
+)
+
+// PrintRecord writes the run record to standard output as JSON, between marker lines, so a
+// container log collector can pick it out of the rest of the output.
+func PrintRecord(rec Record) error {
+    fmt.Println("--- BEGIN RUN RECORD ---")
+    if err := Write(os.Stdout, rec, FormatJSON); err != nil {
+        return fmt.Errorf("error printing run record: %w", err)
+    }
+    fmt.Println("--- END RUN RECORD ---")
+    return nil
+}
+
+// UploadArtifacts uploads the artifacts listed in the descriptor of a finished run, and an HTML
+// report of rec, below prefix/<run ID>/ in the bucket of reportOutput. It returns the key prefix
+// the artifacts were uploaded to. Artifacts are streamed from disk, as multipart uploads when
+// large, and ones that no longer exist are skipped. Every artifact is attempted, and the first
+// error is returned.
+func UploadArtifacts(cfg *config.Config, s3Client *s3.S3, rec Record, d Descriptor) (string, error) {
+    bucket, prefix, _ := cfg.ReportBucket()
+    keyPrefix := path.Join(prefix, d.RunID) + "/"
+    uploader := s3manager.NewUploaderWithClient(s3Client)
+
+    var firstErr error
+    put := func(name string, body io.Reader, contentType string) {
+        _, err := uploader.Upload(&s3manager.UploadInput{
+            Bucket:      aws.String(bucket),
+            Key:         aws.String(keyPrefix + name),
+            Body:        body,
+            ContentType: aws.String(contentType),
+        })
+        if err != nil && firstErr == nil {
+            firstErr = fmt.Errorf("error uploading %s to s3://%s/%s: %w", name, bucket, keyPrefix, err)
+        }
+    }
+
+    a := d.Artifacts
+    files := append([]string{a.Manifest, a.Failures, a.Checkpoint, a.StateDump, a.LatencyLog, a.ErrorLog, a.StatsReport, a.Record, a.JUnitReport}, a.Profiles...)
+    for _, file := range files {
+        if file == "" {
+            continue
+        }
+        f, err := os.Open(file)
+        if os.IsNotExist(err) {
+            continue
+        }
+        if err != nil {
+            if firstErr == nil {
+                firstErr = fmt.Errorf("error reading artifact %s: %w", file, err)
+            }
+            continue
+        }
+        put(filepath.Base(file), f, "application/octet-stream")
+        f.Close()
+    }
+
+    var report bytes.Buffer
+    if err := Write(&report, rec, FormatHTML); err != nil {
+        if firstErr == nil {
+            firstErr = fmt.Errorf("error rendering HTML report: %w", err)
+        }
+    } else {
+        put("report.html", &report, ContentType(FormatHTML))
+    }
+    return keyPrefix, firstErr
+}
@@ -0,0 +1,206 @@
+// results/results.go
+package results
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strings"
+    "time"
+
+    "scale_s3_benchmark/benchmark"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// Operation summarizes the benchmark measurements of one operation type.
+type Operation struct {
+    Operations int64         `json:"Operations"`
+    Errors     int64         `json:"Errors"`
+    MinTime    time.Duration `json:"MinTime"`
+    MaxTime    time.Duration `json:"MaxTime"`
+    AvgTime    time.Duration `json:"AvgTime"`
+    P50Time    time.Duration `json:"P50Time"`
+    P90Time    time.Duration `json:"P90Time"`
+    P99Time    time.Duration `json:"P99Time"`
+    Elapsed    time.Duration `json:"Elapsed"` // Wall-clock time the operation was run for.
+    Bytes      int64         `json:"Bytes"` // Response body bytes read, for GET operations.
+    Rate       float64       `json:"Rate"` // Successful operations per second; in a merged record the sum over the workers.
+    ByteRate   float64       `json:"ByteRate"` // Response body bytes per second; in a merged record the sum over the workers.
+    Latency    []int64       `json:"Latency,omitempty"` // Latency histogram bucket counts, so the percentiles of several records can be combined.
+}
+
+// Record is everything stored about a single run.
+type Record struct {
+    ID                string               `json:"ID"`
+    RunID             string               `json:"RunID"` // Namespace of the run's keys, manifest entries and metrics.
+    StartedAt         time.Time            `json:"StartedAt"`
+    FinishedAt        time.Time            `json:"FinishedAt"`
+    Status            string               `json:"Status"`
+    Bucket            string               `json:"Bucket"`
+    Endpoints         []string             `json:"Endpoints"`
+    TotalFiles        int                  `json:"TotalFiles"`
+    Benchmark         map[string]Operation `json:"Benchmark"`
+    BenchmarkDuration time.Duration        `json:"BenchmarkDuration"`
+    GetTiming         string               `json:"GetTiming"` // "full" or "firstbyte": what the GET times cover.
+    KeyAccess         string               `json:"KeyAccess"` // How the benchmark reads picked their keys, e.g. "zipf (exponent 1.1)".
+    Seed              int64                `json:"Seed,omitempty"` // Seed of the workload, 0 if it was not fixed.
+    State             monitor.StateDump    `json:"State"`
+    Workers           []string             `json:"Workers,omitempty"` // Records combined into this one by Merge, if any.
+}
+
+// Summary is the short form of a Record used when listing runs.
+type Summary struct {
+    ID         string    `json:"ID"`
+    RunID      string    `json:"RunID"`
+    StartedAt  time.Time `json:"StartedAt"`
+    FinishedAt time.Time `json:"FinishedAt"`
+    Status     string    `json:"Status"`
+    Bucket     string    `json:"Bucket"`
+    Uploads    int64     `json:"Uploads"`
+    Failures   int64     `json:"Failures"`
+    UploadRate float64   `json:"UploadRate"` // Successful uploads per second over the whole run.
+}
+
+// RecordID returns the ID a run started at start is stored under in the results directory.
+func RecordID(start time.Time) string {
+    return start.Format("20060102-150405")
+}
+
+// NewRecord builds a Record from the current statistics and a benchmark result.
+func NewRecord(cfg *config.Config, status string, result benchmark.BenchmarkResult) Record {
+    state := monitor.Snapshot(status)
+
+    rec := Record{
+        ID:                RecordID(state.Stats.StartTime),
+        RunID:             state.RunID,
+        StartedAt:         state.Stats.StartTime,
+        FinishedAt:        state.Time,
+        Status:            status,
+        Bucket:            cfg.BucketName,
+        Endpoints:         cfg.EndpointURLs,
+        TotalFiles:        cfg.TotalFiles,
+        Benchmark:         make(map[string]Operation),
+        BenchmarkDuration: result.Duration,
+        GetTiming:         result.GetTiming,
+        KeyAccess:         result.Access,
+        Seed:              cfg.Seed,
+        State:             state,
+    }
+
+    for opType, metrics := range result.Metrics {
+        op := Operation{
+            Operations: metrics.TotalOperations,
+            Errors:     metrics.ErrorCount,
+            MinTime:    metrics.MinTime,
+            MaxTime:    metrics.MaxTime,
+            Bytes:      metrics.TotalBytes,
+            P50Time:    metrics.Percentile(0.50),
+            P90Time:    metrics.Percentile(0.90),
+            P99Time:    metrics.Percentile(0.99),
+            Elapsed:    metrics.Elapsed,
+            Latency:    metrics.LatencyCounts(),
+        }
+        if metrics.TotalOperations > 0 {
+            op.AvgTime = time.Duration(int64(metrics.TotalTime) / metrics.TotalOperations)
+        }
+        if metrics.Elapsed > 0 {
+            op.Rate = float64(metrics.TotalOperations-metrics.ErrorCount) / metrics.Elapsed.Seconds()
+            op.ByteRate = float64(metrics.TotalBytes) / metrics.Elapsed.Seconds()
+        }
+        rec.Benchmark[string(opType)] = op
+    }
+
+    return rec
+}
+
+// Summary returns the short form of the record.
+func (r Record) Summary() Summary {
+    s := Summary{
+        ID:         r.ID,
+        RunID:      r.RunID,
+        StartedAt:  r.StartedAt,
+        FinishedAt: r.FinishedAt,
+        Status:     r.Status,
+        Bucket:     r.Bucket,
+        Uploads:    r.State.Stats.Successes,
+        Failures:   r.State.Stats.Failures,
+    }
+    if elapsed := r.FinishedAt.Sub(r.StartedAt).Seconds(); elapsed > 0 {
+        s.UploadRate = float64(s.Uploads) / elapsed
+    }
+    return s
+}
+
+// Save writes a record to dir as <ID>.json, creating the directory if needed.
+func Save(dir string, rec Record) error {
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return fmt.Errorf("error creating results directory %s: %w", dir, err)
+    }
+
+    data, err := json.MarshalIndent(rec, "", "  ")
+    if err != nil {
+        return fmt.Errorf("error encoding run %s: %w", rec.ID, err)
+    }
+
+    path := filepath.Join(dir, rec.ID+".json")
+    if err := os.WriteFile(path, data, 0644); err != nil {
+        return fmt.Errorf("error writing run %s: %w", path, err)
+    }
+    return nil
+}
+
+// Load reads the record with the given ID from dir.
+func Load(dir, id string) (Record, error) {
+    var rec Record
+
+    if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+        return rec, fmt.Errorf("invalid run id %q", id)
+    }
+
+    return ReadFile(filepath.Join(dir, id+".json"))
+}
+
+// ReadFile reads a record from a JSON file, such as one written by Save or a JSON report
+// downloaded from another host.
+func ReadFile(path string) (Record, error) {
+    var rec Record
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return rec, fmt.Errorf("error reading run %s: %w", path, err)
+    }
+    if err := json.Unmarshal(data, &rec); err != nil {
+        return rec, fmt.Errorf("error decoding run %s: %w", path, err)
+    }
+    return rec, nil
+}
+
+// List returns the summaries of every stored run, most recent first.
+// A missing directory is not an error; it simply holds no runs.
+func List(dir string) ([]Summary, error) {
+    entries, err := os.ReadDir(dir)
+    if os.IsNotExist(err) {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, fmt.Errorf("error reading results directory %s: %w", dir, err)
+    }
+
+    var summaries []Summary
+    for _, entry := range entries {
+        if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+            continue
+        }
+        rec, err := Load(dir, strings.TrimSuffix(entry.Name(), ".json"))
+        if err != nil {
+            fmt.Printf("Skipping stored run: %v\n", err)
+            continue
+        }
+        summaries = append(summaries, rec.Summary())
+    }
+
+    sort.Slice(summaries, func(i, j int) bool { return summaries[i].StartedAt.After(summaries[j].StartedAt) })
+    return summaries, nil
+}
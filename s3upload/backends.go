@@ -0,0 +1,120 @@
+// s3upload/backends.go
+package s3upload
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/storage"
+)
+
+// InitializeBackends creates one storage backend per configured endpoint for the selected
+// storage backend. GCS and Azure fall back to their public endpoint when none is configured.
+// Every HTTP backend uses the same tuned, traced and fault-injecting transport as the S3 clients;
+// for the filesystem backend each endpoint is a directory.
+func InitializeBackends(cfg *config.Config) ([]storage.Backend, error) {
+    if cfg.StorageBackend == config.StorageBackendS3 {
+        s3Clients, err := InitializeS3Clients(cfg)
+        if err != nil {
+            return nil, err
+        }
+        backends := make([]storage.Backend, len(s3Clients))
+        for i, client := range s3Clients {
+            backends[i] = storage.NewS3(client, cfg.BucketName)
+        }
+        return backends, nil
+    }
+
+    endpoints := cfg.EndpointURLs
+    if len(endpoints) == 0 {
+        endpoints = []string{""}
+    }
+
+    var backends []storage.Backend
+    for _, endpoint := range endpoints {
+        var backend storage.Backend
+        var err error
+
+        switch cfg.StorageBackend {
+        case config.StorageBackendGCS:
+            label := endpoint
+            if label == "" {
+                label = storage.GCSDefaultEndpoint
+            }
+            backend, err = storage.NewGCS(context.Background(), endpoint, cfg.BucketName, cfg.GCSCredentialsFile,
+                newInstrumentedTransport(cfg, label), clientTimeout(cfg))
+        case config.StorageBackendAzure:
+            if endpoint == "" {
+                endpoint = storage.AzureServiceURL(cfg.AzureAccountName)
+            }
+            backend, err = storage.NewAzure(endpoint, cfg.BucketName, cfg.AzureAccountName, cfg.AzureAccountKey,
+                &http.Client{Transport: newInstrumentedTransport(cfg, endpoint), Timeout: clientTimeout(cfg)})
+        case config.StorageBackendFilesystem:
+            var fsBackend *storage.FilesystemBackend
+            fsBackend, err = storage.NewFilesystem(endpoint, cfg.BucketName)
+            if err == nil {
+                backend = instrumentedBackend{fsBackend}
+            }
+        }
+        if err != nil {
+            fmt.Printf("Error creating %s backend for endpoint %q: %v\n", cfg.StorageBackend, endpoint, err)
+            continue
+        }
+        backends = append(backends, backend)
+    }
+
+    if len(backends) == 0 {
+        return nil, fmt.Errorf("no %s backends were created. Check endpoints and credentials", cfg.StorageBackend)
+    }
+    return backends, nil
+}
+
+// newInstrumentedTransport returns the tuned transport wrapped with the tracing, stall watchdog
+// and fault injection layers.
+func newInstrumentedTransport(cfg *config.Config, endpoint string) http.RoundTripper {
+    return newFaultTransport(cfg, newStallTransport(cfg, endpoint, newTracingTransport(endpoint, newHTTPTransport(cfg))))
+}
+
+// instrumentedBackend reports the latency and outcome of every call to a backend that
+// does not go through the HTTP transport, so it still appears in the endpoint panels.
+type instrumentedBackend struct {
+    storage.Backend
+}
+
+// PutObject implements storage.Backend.
+func (b instrumentedBackend) PutObject(ctx context.Context, key string, body io.ReadSeeker, size int64) (string, error) {
+    start := time.Now()
+    etag, err := b.Backend.PutObject(ctx, key, body, size)
+    monitor.RecordEndpointRequest(b.Endpoint(), time.Since(start), err != nil)
+    return etag, err
+}
+
+// GetObject implements storage.Backend.
+func (b instrumentedBackend) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+    start := time.Now()
+    body, err := b.Backend.GetObject(ctx, key)
+    monitor.RecordEndpointRequest(b.Endpoint(), time.Since(start), err != nil && !errors.Is(err, storage.ErrNotFound))
+    return body, err
+}
+
+// HeadObject implements storage.Backend.
+func (b instrumentedBackend) HeadObject(ctx context.Context, key string) (int64, error) {
+    start := time.Now()
+    size, err := b.Backend.HeadObject(ctx, key)
+    monitor.RecordEndpointRequest(b.Endpoint(), time.Since(start), err != nil && !errors.Is(err, storage.ErrNotFound))
+    return size, err
+}
+
+// DeleteObject implements storage.Backend.
+func (b instrumentedBackend) DeleteObject(ctx context.Context, key string) error {
+    start := time.Now()
+    err := b.Backend.DeleteObject(ctx, key)
+    monitor.RecordEndpointRequest(b.Endpoint(), time.Since(start), err != nil)
+    return err
+}
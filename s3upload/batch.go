@@ -0,0 +1,78 @@
+// s3upload/batch.go
+package s3upload
+
+import (
+    "sync/atomic"
+    "time"
+
+    "scale_s3_benchmark/monitor"
+)
+
+const (
+    // statsBatchSize is the number of finished uploads a worker accumulates before publishing its counters.
+    statsBatchSize = 64
+
+    // statsFlushInterval bounds how long a busy worker keeps finished uploads unpublished.
+    statsFlushInterval = 200 * time.Millisecond
+)
+
+// uploadBatch accumulates the outcome of the uploads of one worker, so workers do not touch
+// the shared counters and key store after every object. Counters are published by flush;
+// keys are handed to the key store once the worker is done with its subfolder.
+type uploadBatch struct {
+    // Not yet published to the global statistics and the status line.
+    pendingSuccesses int64
+    pendingSkipped   int64
+    pendingFailures  int64
+    pendingBytes     int64
+    flushedAt        time.Time
+
+    // Totals for the subfolder and the keys uploaded in it.
+    successes int64
+    failures  int64
+    keys      []string
+}
+
+// success records an uploaded object of size bytes.
+func (b *uploadBatch) success(s3Key string, size int64) {
+    b.pendingSuccesses++
+    b.pendingBytes += size
+    b.successes++
+    b.keys = append(b.keys, s3Key)
+}
+
+// skipped records an object that already existed and was not uploaded again.
+func (b *uploadBatch) skipped(s3Key string) {
+    b.pendingSkipped++
+    b.successes++
+    b.keys = append(b.keys, s3Key)
+}
+
+// failure records an object that could not be uploaded.
+func (b *uploadBatch) failure() {
+    b.pendingFailures++
+    b.failures++
+}
+
+// due reports whether the pending counters should be published.
+func (b *uploadBatch) due() bool {
+    pending := b.pendingSuccesses + b.pendingSkipped + b.pendingFailures
+    return pending >= statsBatchSize || (pending > 0 && time.Since(b.flushedAt) >= statsFlushInterval)
+}
+
+// flush publishes the pending counters of a batch to the global statistics and the status line.
+func (u *Uploader) flush(b *uploadBatch) {
+    monitor.AddUploadStats(b.pendingSuccesses, b.pendingFailures, b.pendingBytes)
+    if n := b.pendingSuccesses + b.pendingSkipped; n > 0 {
+        atomic.AddInt64(&u.SuccessCount, n)
+        if u.Progress != nil {
+            u.Progress.Add(n)
+        }
+    }
+    if b.pendingFailures > 0 && u.Progress != nil {
+        u.Progress.Fail(b.pendingFailures)
+    }
+
+    b.pendingSuccesses, b.pendingSkipped, b.pendingFailures, b.pendingBytes = 0, 0, 0, 0
+    b.flushedAt = time.Now()
+}
@@ -0,0 +1,59 @@
+// s3upload/bucket.go
+package s3upload
+
+import (
+    "fmt"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+)
+
+// EnsureBucket creates the configured bucket if it does not exist yet, applying the
+// region, versioning and Object Lock settings from the configuration.
+// An existing bucket is left untouched.
+func EnsureBucket(cfg *config.Config, s3Client *s3.S3) error {
+    _, err := s3Client.HeadBucket(&s3.HeadBucketInput{
+        Bucket: aws.String(cfg.BucketName),
+    })
+    if err == nil {
+        fmt.Printf("Bucket %s already exists.\n", cfg.BucketName)
+        return nil
+    }
+    if aerr, ok := err.(awserr.RequestFailure); !ok || aerr.StatusCode() != 404 {
+        return fmt.Errorf("error checking bucket %s: %w", cfg.BucketName, err)
+    }
+
+    input := &s3.CreateBucketInput{
+        Bucket:                     aws.String(cfg.BucketName),
+        ObjectLockEnabledForBucket: aws.Bool(cfg.BucketObjectLockEnabled),
+    }
+    // us-east-1 is the default location and must not be sent as a constraint.
+    if cfg.Region != "us-east-1" {
+        input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+            LocationConstraint: aws.String(cfg.Region),
+        }
+    }
+
+    if _, err := s3Client.CreateBucket(input); err != nil {
+        return fmt.Errorf("error creating bucket %s: %w", cfg.BucketName, err)
+    }
+    fmt.Printf("Bucket %s created in region %s.\n", cfg.BucketName, cfg.Region)
+
+    // Object Lock turns versioning on implicitly.
+    if cfg.BucketVersioning && !cfg.BucketObjectLockEnabled {
+        _, err := s3Client.PutBucketVersioning(&s3.PutBucketVersioningInput{
+            Bucket: aws.String(cfg.BucketName),
+            VersioningConfiguration: &s3.VersioningConfiguration{
+                Status: aws.String(s3.BucketVersioningStatusEnabled),
+            },
+        })
+        if err != nil {
+            return fmt.Errorf("error enabling versioning on bucket %s: %w", cfg.BucketName, err)
+        }
+    }
+
+    return nil
+}
@@ -0,0 +1,148 @@
+// s3upload/circuit.go
+package s3upload
+
+import (
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+)
+
+// Circuit breaker states.
+const (
+    circuitClosed   = "closed"
+    circuitOpen     = "open"
+    circuitHalfOpen = "half-open"
+)
+
+// circuitBreaker stops routing requests to an endpoint whose error rate crosses a
+// threshold. After a cooldown a single probe request is let through; the circuit
+// closes again if it succeeds and re-opens if it fails.
+type circuitBreaker struct {
+    mu        sync.Mutex
+    endpoint  string
+    threshold float64
+    window    int
+    cooldown  time.Duration
+
+    state     string
+    successes int
+    failures  int
+    openedAt  time.Time
+    probing   bool
+}
+
+// newCircuitBreaker creates a closed circuit for an endpoint.
+func newCircuitBreaker(endpoint string, threshold float64, window int, cooldown time.Duration) *circuitBreaker {
+    return &circuitBreaker{
+        endpoint:  endpoint,
+        threshold: threshold,
+        window:    window,
+        cooldown:  cooldown,
+        state:     circuitClosed,
+    }
+}
+
+// allow reports whether a request may be sent to the endpoint.
+func (cb *circuitBreaker) allow() bool {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    switch cb.state {
+    case circuitOpen:
+        if time.Since(cb.openedAt) < cb.cooldown {
+            return false
+        }
+        cb.transition(circuitHalfOpen)
+        cb.probing = true
+        return true
+    case circuitHalfOpen:
+        // Only one probe at a time.
+        if cb.probing {
+            return false
+        }
+        cb.probing = true
+        return true
+    default:
+        return true
+    }
+}
+
+// record updates the circuit with the outcome of a request.
+func (cb *circuitBreaker) record(success bool) {
+    cb.mu.Lock()
+    defer cb.mu.Unlock()
+
+    if cb.state == circuitHalfOpen {
+        cb.probing = false
+        if success {
+            cb.transition(circuitClosed)
+        } else {
+            cb.transition(circuitOpen)
+        }
+        return
+    }
+    if cb.state == circuitOpen {
+        return
+    }
+
+    if success {
+        cb.successes++
+    } else {
+        cb.failures++
+    }
+
+    total := cb.successes + cb.failures
+    if total < cb.window {
+        return
+    }
+    if float64(cb.failures)/float64(total) >= cb.threshold {
+        cb.transition(circuitOpen)
+    }
+    cb.successes, cb.failures = 0, 0
+}
+
+// transition moves the circuit to a new state and reports the event. The caller must hold cb.mu.
+func (cb *circuitBreaker) transition(state string) {
+    if state == cb.state {
+        return
+    }
+    cb.state = state
+    if state == circuitOpen {
+        cb.openedAt = time.Now()
+    }
+    if state == circuitClosed {
+        cb.successes, cb.failures = 0, 0
+    }
+    monitor.RecordCircuitEvent(cb.endpoint, state)
+    progress.Printf("Circuit for endpoint %s is now %s\n", cb.endpoint, state)
+}
+
+// nextClient returns the index of the next backend (and S3 client) in round-robin order,
+// skipping endpoints whose circuit is open. If every circuit is open the plain
+// round-robin choice is returned so uploads keep progressing.
+func (u *Uploader) nextClient() int {
+    n := uint64(len(u.Backends))
+    first := atomic.AddUint64(&u.ClientIndex, 1)
+    if u.Breakers == nil {
+        return int(first % n)
+    }
+
+    for i := uint64(0); i < n; i++ {
+        idx := int((first + i) % n)
+        if u.Breakers[idx].allow() {
+            return idx
+        }
+    }
+    return int(first % n)
+}
+
+// recordResult reports the outcome of a request sent through the backend at idx to its circuit breaker.
+func (u *Uploader) recordResult(idx int, err error) {
+    if u.Breakers == nil {
+        return
+    }
+    u.Breakers[idx].record(err == nil)
+}
@@ -0,0 +1,73 @@
+// s3upload/clock.go
+package s3upload
+
+import (
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+)
+
+// MeasureClockSkew returns how far the clock of the endpoint behind s3Client is ahead of the
+// local clock, from the Date header of a HeadBucket response. Any response will do, even an
+// error caused by the skew itself. The Date header is compared with the middle of the request,
+// and its one-second resolution limits the accuracy to about half a second.
+func MeasureClockSkew(cfg *config.Config, s3Client *s3.S3) (time.Duration, error) {
+    req, _ := s3Client.HeadBucketRequest(&s3.HeadBucketInput{
+        Bucket: aws.String(cfg.BucketName),
+    })
+    ctx, cancel := cfg.OperationContext(config.OperationHead)
+    defer cancel()
+    req.SetContext(ctx)
+
+    start := time.Now()
+    err := req.Send()
+    end := time.Now()
+    if req.HTTPResponse == nil {
+        return 0, fmt.Errorf("error reaching endpoint: %w", err)
+    }
+
+    date, err := http.ParseTime(req.HTTPResponse.Header.Get("Date"))
+    if err != nil {
+        return 0, fmt.Errorf("error reading Date header: %w", err)
+    }
+    // The header truncates the endpoint's time to the second; assume the middle of that second.
+    remote := date.Add(500 * time.Millisecond)
+    local := start.Add(end.Sub(start) / 2)
+    return remote.Sub(local), nil
+}
+
+// CheckClockSkew measures the clock skew against every endpoint and warns about skew beyond
+// maxClockSkewSeconds, which makes requests fail with SignatureDoesNotMatch or
+// RequestTimeTooSkewed. With abortOnClockSkew such skew is returned as an error instead.
+// Endpoints that cannot be measured are reported and skipped.
+func CheckClockSkew(cfg *config.Config, s3Clients []*s3.S3) error {
+    limit := time.Duration(cfg.MaxClockSkewSeconds) * time.Second
+
+    for _, s3Client := range s3Clients {
+        skew, err := MeasureClockSkew(cfg, s3Client)
+        if err != nil {
+            fmt.Printf("Error measuring clock skew against %s: %v\n", s3Client.Endpoint, err)
+            continue
+        }
+        if skew.Abs() <= limit {
+            continue
+        }
+
+        direction := "ahead of"
+        if skew < 0 {
+            direction = "behind"
+        }
+        if cfg.AbortOnClockSkew {
+            return fmt.Errorf("the clock of %s is %v %s the local clock, more than the %v allowed by maxClockSkewSeconds",
+                s3Client.Endpoint, skew.Abs().Round(100*time.Millisecond), direction, limit)
+        }
+        fmt.Printf("Warning: the clock of %s is %v %s the local clock. Requests may fail with SignatureDoesNotMatch or RequestTimeTooSkewed.\n",
+            s3Client.Endpoint, skew.Abs().Round(100*time.Millisecond), direction)
+    }
+    return nil
+}
@@ -0,0 +1,141 @@
+// s3upload/content.go
+package s3upload
+
+import (
+    "crypto/md5"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+    "strings"
+
+    "scale_s3_benchmark/config"
+)
+
+// objectContent is the body uploaded for a local file. With the "unique" file selection the
+// first bytes of the file are replaced by a header derived from the object key, so every
+// object differs from the others while keeping the size of its local file.
+type objectContent struct {
+    file   *os.File
+    header []byte // Replaces the start of the file; nil uploads the file unchanged.
+    size   int64
+    offset int64 // Position of Read, moved by Seek.
+}
+
+// openContent opens the body of the object s3Key, read from filePath. The caller must Close it.
+func (u *Uploader) openContent(filePath, s3Key string) (*objectContent, error) {
+    f, err := os.Open(filePath)
+    if err != nil {
+        return nil, fmt.Errorf("error opening file %s: %w", filePath, err)
+    }
+    info, err := f.Stat()
+    if err != nil {
+        f.Close()
+        return nil, fmt.Errorf("error reading file info %s: %w", filePath, err)
+    }
+
+    c := &objectContent{file: f, size: info.Size()}
+    if u.Config.FileSelection == config.FileSelectionUnique {
+        c.header = contentHeader(u.seededKey(s3Key))
+        if int64(len(c.header)) > c.size {
+            c.header = c.header[:c.size]
+        }
+    }
+    return c, nil
+}
+
+// contentHeader returns the header written at the start of the object s3Key: the hex MD5 of
+// the key and a newline. Keys include the run ID, so objects also differ between runs, unless
+// a seed is set, see seededKey.
+func contentHeader(s3Key string) []byte {
+    return []byte(fmt.Sprintf("%x\n", md5.Sum([]byte(s3Key))))
+}
+
+// seededKey returns the key that the random choices about the object s3Key are derived from.
+// With a seed it is the key below the run prefix, so runs with the same seed but different
+// run IDs make the same choices; without one it is s3Key itself.
+func (u *Uploader) seededKey(s3Key string) string {
+    if u.Config.Seed == 0 {
+        return s3Key
+    }
+    return strings.TrimPrefix(s3Key, u.Config.RunPrefix(u.RunID)+"/")
+}
+
+// contentSHA256 returns the hex SHA-256 of the content of the object s3Key. Unless every
+// object has unique content, the checksum of each local file is computed once.
+func (u *Uploader) contentSHA256(filePath, s3Key string) (string, error) {
+    shared := u.Config.FileSelection != config.FileSelectionUnique
+    if shared {
+        if sum, ok := u.checksums.Load(filePath); ok {
+            return sum.(string), nil
+        }
+    }
+
+    f, err := u.openContent(filePath, s3Key)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    h := sha256.New()
+    if _, err := io.Copy(h, f); err != nil {
+        return "", fmt.Errorf("error reading file %s: %w", filePath, err)
+    }
+    sum := hex.EncodeToString(h.Sum(nil))
+    if shared {
+        u.checksums.Store(filePath, sum)
+    }
+    return sum, nil
+}
+
+// ReadAt reads the content at off, with the header in place of the first bytes of the file.
+func (c *objectContent) ReadAt(p []byte, off int64) (int, error) {
+    n, err := c.file.ReadAt(p, off)
+    if off < int64(len(c.header)) {
+        copy(p[:n], c.header[off:])
+    }
+    return n, err
+}
+
+// Read reads the content from the current position.
+func (c *objectContent) Read(p []byte) (int, error) {
+    if c.offset >= c.size {
+        return 0, io.EOF
+    }
+    n, err := c.ReadAt(p, c.offset)
+    c.offset += int64(n)
+    if err == io.EOF && n > 0 {
+        err = nil
+    }
+    return n, err
+}
+
+// Seek sets the position of Read, so SDKs can measure and rewind the body on retries.
+func (c *objectContent) Seek(offset int64, whence int) (int64, error) {
+    switch whence {
+    case io.SeekStart:
+    case io.SeekCurrent:
+        offset += c.offset
+    case io.SeekEnd:
+        offset += c.size
+    default:
+        return 0, errors.New("invalid whence")
+    }
+    if offset < 0 {
+        return 0, errors.New("negative position")
+    }
+    c.offset = offset
+    return offset, nil
+}
+
+// Size returns the size of the content, the same as the local file.
+func (c *objectContent) Size() int64 {
+    return c.size
+}
+
+// Close closes the local file.
+func (c *objectContent) Close() error {
+    return c.file.Close()
+}
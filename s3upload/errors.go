@@ -0,0 +1,99 @@
+// s3upload/errors.go
+package s3upload
+
+import (
+    "net/http"
+
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/aws/awsutil"
+    "github.com/aws/aws-sdk-go/aws/request"
+    "github.com/aws/aws-sdk-go/aws/session"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/monitor"
+)
+
+// newS3Client creates an S3 client that reports every failed request, and the outcome of every
+// request for the per-target abort check, to the monitor.
+func newS3Client(sess *session.Session) *s3.S3 {
+    client := s3.New(sess)
+    client.Handlers.Complete.PushBackNamed(request.NamedHandler{
+        Name: "scale_s3_benchmark.RecordRequestError",
+        Fn:   recordRequestError,
+    })
+    client.Handlers.Complete.PushBackNamed(request.NamedHandler{
+        Name: "scale_s3_benchmark.RecordTargetOutcome",
+        Fn:   recordTargetOutcome,
+    })
+    return client
+}
+
+// recordRequestError records the error code, message and request IDs of a failed request,
+// after the SDK's retries. Requests cancelled by the tool and HEAD requests that find no
+// object, which callers use as existence checks, are not failures and are skipped.
+func recordRequestError(r *request.Request) {
+    if r.Error == nil || notFailure(r) {
+        return
+    }
+
+    e := monitor.RequestError{
+        Time:      r.Time,
+        Operation: r.Operation.Name,
+        Endpoint:  r.ClientInfo.Endpoint,
+        Message:   r.Error.Error(),
+    }
+    if aerr, ok := r.Error.(awserr.Error); ok {
+        e.Code = aerr.Code()
+        e.Message = aerr.Message()
+        if orig := aerr.OrigErr(); orig != nil {
+            e.Message += ": " + orig.Error()
+        }
+    }
+    if reqErr, ok := r.Error.(awserr.RequestFailure); ok {
+        e.StatusCode = reqErr.StatusCode()
+        e.RequestID = reqErr.RequestID()
+    }
+    if s3Err, ok := r.Error.(s3.RequestFailure); ok {
+        e.HostID = s3Err.HostID()
+    }
+    if r.HTTPResponse != nil {
+        if e.RequestID == "" {
+            e.RequestID = r.HTTPResponse.Header.Get("X-Amz-Request-Id")
+        }
+        if e.HostID == "" {
+            e.HostID = r.HTTPResponse.Header.Get("X-Amz-Id-2")
+        }
+    }
+
+    e.Key = requestKey(r)
+    monitor.RecordRequestError(e)
+}
+
+// recordTargetOutcome feeds whether a request succeeded, after the SDK's retries, to the
+// per-target abort check. Requests that recordRequestError skips count neither way.
+func recordTargetOutcome(r *request.Request) {
+    if r.Error != nil && notFailure(r) {
+        return
+    }
+    monitor.RecordTargetOutcome(r.ClientInfo.Endpoint, requestKey(r), r.Error == nil)
+}
+
+// notFailure reports whether the error of a request is expected rather than a failure: the
+// request was cancelled by the tool, or it is a HEAD request that found no object.
+func notFailure(r *request.Request) bool {
+    if aerr, ok := r.Error.(awserr.Error); ok && aerr.Code() == request.CanceledErrorCode {
+        return true
+    }
+    reqErr, ok := r.Error.(awserr.RequestFailure)
+    return ok && r.Operation.HTTPMethod == http.MethodHead && reqErr.StatusCode() == http.StatusNotFound
+}
+
+// requestKey returns the object key a request was sent for, or "" for requests without one.
+func requestKey(r *request.Request) string {
+    if keys, _ := awsutil.ValuesAtPath(r.Params, "Key"); len(keys) == 1 {
+        if key, ok := keys[0].(*string); ok && key != nil {
+            return *key
+        }
+    }
+    return ""
+}
@@ -0,0 +1,79 @@
+// s3upload/etag.go
+package s3upload
+
+import (
+    "crypto/md5"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+)
+
+// verifyETag compares the ETag returned by S3 with the one computed from the uploaded content
+// and records a mismatch as an integrity failure. partSize is the part size used for
+// multipart uploads; it is only consulted when the ETag has the multipart "<md5>-<parts>" form.
+func (u *Uploader) verifyETag(filePath, s3Key string, etag *string, partSize int64) {
+    if !u.Config.VerifyETag || etag == nil {
+        return
+    }
+
+    remote := strings.Trim(*etag, "\"")
+    local, err := u.expectedETag(filePath, s3Key, remote, partSize)
+    if err != nil {
+        progress.Printf("Error computing ETag for %s: %v\n", filePath, err)
+        return
+    }
+
+    monitor.RecordETagCheck(local == remote)
+    if local != remote {
+        progress.Printf("ETag mismatch for %s: expected %s, got %s\n", s3Key, local, remote)
+    }
+}
+
+// expectedETag computes the ETag S3 should return for an object. For single-part uploads
+// this is the hex MD5 of the content; for multipart uploads it is the MD5 of the
+// concatenated binary part MD5s followed by "-<part count>".
+func (u *Uploader) expectedETag(filePath, s3Key, remote string, partSize int64) (string, error) {
+    f, err := u.openContent(filePath, s3Key)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+
+    dash := strings.LastIndex(remote, "-")
+    if dash < 0 || partSize <= 0 {
+        h := md5.New()
+        if _, err := io.Copy(h, f); err != nil {
+            return "", err
+        }
+        return hex.EncodeToString(h.Sum(nil)), nil
+    }
+
+    if _, err := strconv.Atoi(remote[dash+1:]); err != nil {
+        return "", fmt.Errorf("unexpected ETag format %q", remote)
+    }
+
+    var partDigests []byte
+    parts := 0
+    for {
+        h := md5.New()
+        n, err := io.CopyN(h, f, partSize)
+        if n > 0 {
+            partDigests = append(partDigests, h.Sum(nil)...)
+            parts++
+        }
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return "", err
+        }
+    }
+
+    sum := md5.Sum(partDigests)
+    return fmt.Sprintf("%s-%d", hex.EncodeToString(sum[:]), parts), nil
+}
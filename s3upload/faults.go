@@ -0,0 +1,99 @@
+// s3upload/faults.go
+package s3upload
+
+import (
+    "bytes"
+    "io"
+    "math/rand"
+    "net/http"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// faultTransport wraps an http.RoundTripper and injects errors, latency and truncated
+// responses according to the fault injection settings, so the retry and reporting
+// paths can be exercised without a misbehaving cluster.
+type faultTransport struct {
+    cfg  *config.Config
+    base http.RoundTripper
+}
+
+// faultsEnabled reports whether any fault injection setting is active.
+func faultsEnabled(cfg *config.Config) bool {
+    return cfg.FaultErrorRate > 0 || cfg.FaultLatencyMs > 0 || cfg.FaultTruncateRate > 0
+}
+
+// newFaultTransport returns base unchanged when fault injection is disabled.
+func newFaultTransport(cfg *config.Config, base http.RoundTripper) http.RoundTripper {
+    if !faultsEnabled(cfg) {
+        return base
+    }
+    return &faultTransport{cfg: cfg, base: base}
+}
+
+// RoundTrip executes a single HTTP transaction, possibly injecting a fault.
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    if t.cfg.FaultLatencyMs > 0 {
+        monitor.RecordFault(monitor.FaultLatency)
+        select {
+        case <-time.After(time.Duration(t.cfg.FaultLatencyMs) * time.Millisecond):
+        case <-req.Context().Done():
+            return nil, req.Context().Err()
+        }
+    }
+
+    if rand.Float64() < t.cfg.FaultErrorRate {
+        monitor.RecordFault(monitor.FaultError)
+        if req.Body != nil {
+            req.Body.Close()
+        }
+        body := "<Error><Code>ServiceUnavailable</Code><Message>Injected fault</Message></Error>"
+        return &http.Response{
+            Status:        "503 Service Unavailable",
+            StatusCode:    http.StatusServiceUnavailable,
+            Proto:         "HTTP/1.1",
+            ProtoMajor:    1,
+            ProtoMinor:    1,
+            Header:        http.Header{"Content-Type": []string{"application/xml"}},
+            Body:          io.NopCloser(bytes.NewBufferString(body)),
+            ContentLength: int64(len(body)),
+            Request:       req,
+        }, nil
+    }
+
+    resp, err := t.base.RoundTrip(req)
+    if err != nil || resp.Body == nil {
+        return resp, err
+    }
+
+    if rand.Float64() < t.cfg.FaultTruncateRate {
+        monitor.RecordFault(monitor.FaultTruncate)
+        resp.Body = &truncatedBody{body: resp.Body, remaining: resp.ContentLength / 2}
+    }
+    return resp, nil
+}
+
+// truncatedBody returns io.ErrUnexpectedEOF after a fixed number of bytes, simulating
+// a connection dropped in the middle of a response.
+type truncatedBody struct {
+    body      io.ReadCloser
+    remaining int64
+}
+
+func (b *truncatedBody) Read(p []byte) (int, error) {
+    if b.remaining <= 0 {
+        return 0, io.ErrUnexpectedEOF
+    }
+    if int64(len(p)) > b.remaining {
+        p = p[:b.remaining]
+    }
+    n, err := b.body.Read(p)
+    b.remaining -= int64(n)
+    return n, err
+}
+
+func (b *truncatedBody) Close() error {
+    return b.body.Close()
+}
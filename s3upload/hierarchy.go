@@ -0,0 +1,34 @@
+// s3upload/hierarchy.go
+package s3upload
+
+import (
+    "fmt"
+    "path"
+    "strconv"
+)
+
+// leafDir returns the directory, relative to its subfolder, of the i-th file of a subfolder
+// when a key hierarchy is configured. Files fill one leaf after the other, and consecutive
+// leaves differ in the deepest level first, as in a walk of the tree. Without a hierarchy
+// it returns "".
+func (u *Uploader) leafDir(i int64) string {
+    fanOut := u.Config.HierarchyFanOut
+    if len(fanOut) == 0 {
+        return ""
+    }
+
+    leaf := i / int64(u.Config.HierarchyFilesPerLeaf)
+    dirs := make([]string, len(fanOut))
+    for level := len(fanOut) - 1; level >= 0; level-- {
+        n := int(leaf % int64(fanOut[level]))
+        leaf /= int64(fanOut[level])
+
+        // Zero-pad to the widest directory of the level so keys sort in tree order.
+        dir := fmt.Sprintf("%0*d", len(strconv.Itoa(fanOut[level]-1)), n)
+        if len(u.Config.HierarchyLevelNames) > 0 {
+            dir = u.Config.HierarchyLevelNames[level] + "=" + dir
+        }
+        dirs[level] = dir
+    }
+    return path.Join(dirs...)
+}
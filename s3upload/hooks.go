@@ -0,0 +1,56 @@
+// s3upload/hooks.go
+package s3upload
+
+import (
+    "fmt"
+    "time"
+)
+
+// UploadInfo describes a finished upload, passed to the AfterUpload hooks.
+type UploadInfo struct {
+    Path     string        // Local file the object was read from.
+    Key      string        // Object key.
+    Size     int64         // Bytes uploaded; 0 for a failed upload.
+    ETag     string        // Without quotes; empty for a failed upload or a store that reports none.
+    Endpoint string        // Endpoint of the successful attempt.
+    Attempts int           // Upload attempts made, including the successful one.
+    Duration time.Duration // From the first attempt to the end of the last.
+    Err      error         // Why the upload failed; nil on success.
+}
+
+// BeforeUploadHook is called before the first attempt of each upload. Returning an error fails
+// the upload without sending it; the error is recorded like any other upload failure.
+type BeforeUploadHook func(path, key string) error
+
+// AfterUploadHook is called once each upload succeeded or failed for good. Skipped and
+// abandoned uploads are not reported.
+type AfterUploadHook func(info UploadInfo)
+
+// WithBeforeUpload calls hook before every upload. Hooks run on the upload workers, in the
+// order they were added, so they must be safe for concurrent use and slow ones slow the uploads.
+func WithBeforeUpload(hook BeforeUploadHook) Option {
+    return func(u *Uploader) { u.beforeUpload = append(u.beforeUpload, hook) }
+}
+
+// WithAfterUpload calls hook after every upload. Hooks run on the upload workers, in the order
+// they were added, so they must be safe for concurrent use and slow ones slow the uploads.
+func WithAfterUpload(hook AfterUploadHook) Option {
+    return func(u *Uploader) { u.afterUpload = append(u.afterUpload, hook) }
+}
+
+// runBeforeUpload calls the BeforeUpload hooks until one refuses the upload.
+func (u *Uploader) runBeforeUpload(filePath, s3Key string) error {
+    for _, hook := range u.beforeUpload {
+        if err := hook(filePath, s3Key); err != nil {
+            return fmt.Errorf("upload of %s refused by hook: %w", s3Key, err)
+        }
+    }
+    return nil
+}
+
+// runAfterUpload passes info to the AfterUpload hooks.
+func (u *Uploader) runAfterUpload(info UploadInfo) {
+    for _, hook := range u.afterUpload {
+        hook(info)
+    }
+}
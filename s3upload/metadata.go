@@ -0,0 +1,20 @@
+// s3upload/metadata.go
+package s3upload
+
+import (
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+)
+
+// UploadTimeMetadataKey is the user metadata key holding the upload time of an object when
+// uploadTimeMetadata is set. S3 returns it as the x-amz-meta-uploaded-at header.
+const UploadTimeMetadataKey = "uploaded-at"
+
+// objectMetadata returns the user metadata to set on an object uploaded now, or nil.
+func (u *Uploader) objectMetadata() map[string]*string {
+    if !u.Config.UploadTimeMetadata {
+        return nil
+    }
+    return map[string]*string{UploadTimeMetadataKey: aws.String(time.Now().UTC().Format(time.RFC3339Nano))}
+}
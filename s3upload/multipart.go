@@ -0,0 +1,201 @@
+// s3upload/multipart.go
+package s3upload
+
+import (
+    "errors"
+    "fmt"
+    "io"
+    "math"
+    "math/rand"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// errPartFailed marks a multipart upload that failed because a part still failed after
+// maxRetries attempts. The parts were already retried, so the upload is not restarted.
+var errPartFailed = errors.New("multipart part failed")
+
+// errAbandoned is returned for a multipart upload deliberately left incomplete by
+// multipartAbandonPercent. It is neither a success nor a failure and is not retried.
+var errAbandoned = errors.New("multipart upload abandoned")
+
+// uploadMultipart uploads a file as a multipart upload. When SpreadPartsAcrossEndpoints is set,
+// each part is sent through the next S3 client in round-robin order instead of the client
+// that created the upload. A failed part is retried on its own, up to maxRetries attempts.
+// The latency log gets a single entry covering the whole upload.
+func (u *Uploader) uploadMultipart(filePath, s3Key string, fileSize int64) (object uploadedObject, err error) {
+    partSize, err := u.Config.MultipartPartSizeFor(fileSize)
+    if err != nil {
+        return uploadedObject{}, fmt.Errorf("cannot upload %s as multipart: %v: %w", s3Key, err, errPartFailed)
+    }
+
+    clientIndex := u.nextClient()
+    s3Client := u.S3Clients[clientIndex]
+
+    start := time.Now()
+    defer func() {
+        // An abandoned upload never wrote an object, so it is not a PUT to log.
+        if !errors.Is(err, errAbandoned) {
+            u.logLatency(clientIndex, s3Key, fileSize, start, err)
+        }
+    }()
+
+    createInput := &s3.CreateMultipartUploadInput{
+        Bucket:   aws.String(u.Config.BucketName),
+        Key:      aws.String(s3Key),
+        Metadata: u.objectMetadata(),
+    }
+    if u.objectLockEnabled() {
+        createInput.ObjectLockMode, createInput.ObjectLockRetainUntilDate, createInput.ObjectLockLegalHoldStatus = u.objectLockParams()
+    }
+
+    ctx, cancel := u.Config.OperationContextFrom(monitor.RunContext(), config.OperationPut)
+    created, err := s3Client.CreateMultipartUploadWithContext(ctx, createInput)
+    cancel()
+    u.recordResult(clientIndex, err)
+    if err != nil {
+        return uploadedObject{}, fmt.Errorf("error creating multipart upload for %s: %w", s3Key, err)
+    }
+    uploadID := created.UploadId
+
+    partCount := int((fileSize + partSize - 1) / partSize)
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var firstErr error
+    completed := make([]*s3.CompletedPart, 0, partCount)
+    endpointsUsed := make(map[int]bool)
+    semaphore := make(chan struct{}, u.Config.MultipartConcurrency)
+
+    for partNumber := 1; partNumber <= partCount; partNumber++ {
+        partClientIndex := clientIndex
+        if u.Config.SpreadPartsAcrossEndpoints {
+            partClientIndex = u.nextClient()
+        }
+
+        wg.Add(1)
+        semaphore <- struct{}{}
+        go func(partNumber, partClientIndex int) {
+            defer wg.Done()
+            defer func() { <-semaphore }()
+
+            offset := int64(partNumber-1) * partSize
+            length := partSize
+            if offset+length > fileSize {
+                length = fileSize - offset
+            }
+
+            var etag *string
+            var err error
+            for attempt := 1; attempt <= u.Config.MaxRetries; attempt++ {
+                etag, err = u.uploadPart(u.S3Clients[partClientIndex], filePath, s3Key, uploadID, int64(partNumber), offset, length)
+                u.recordResult(partClientIndex, err)
+                if err == nil || attempt == u.Config.MaxRetries {
+                    break
+                }
+                // Exponential backoff before retrying, cut short when the run is cancelled.
+                if !monitor.SleepRun(time.Duration(math.Pow(2, float64(attempt))) * time.Second) {
+                    break
+                }
+            }
+            if err != nil {
+                err = fmt.Errorf("%w: %w", errPartFailed, err)
+            }
+
+            mu.Lock()
+            defer mu.Unlock()
+            if err != nil {
+                if firstErr == nil {
+                    firstErr = err
+                }
+                return
+            }
+            completed = append(completed, &s3.CompletedPart{
+                ETag:       etag,
+                PartNumber: aws.Int64(int64(partNumber)),
+            })
+            endpointsUsed[partClientIndex] = true
+        }(partNumber, partClientIndex)
+    }
+
+    wg.Wait()
+
+    if firstErr != nil {
+        s3Client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+            Bucket:   aws.String(u.Config.BucketName),
+            Key:      aws.String(s3Key),
+            UploadId: uploadID,
+        })
+        return uploadedObject{}, firstErr
+    }
+
+    // An abandoned upload keeps its parts on the store until the orphans command or a
+    // lifecycle rule aborts it.
+    if u.abandons(s3Key) {
+        monitor.RecordAbandonedMultipart(partCount, fileSize)
+        return uploadedObject{}, errAbandoned
+    }
+
+    sort.Slice(completed, func(i, j int) bool { return *completed[i].PartNumber < *completed[j].PartNumber })
+
+    ctx, cancel = u.Config.OperationContext(config.OperationPut)
+    defer cancel()
+
+    output, err := s3Client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+        Bucket:          aws.String(u.Config.BucketName),
+        Key:             aws.String(s3Key),
+        UploadId:        uploadID,
+        MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+    })
+    if err != nil {
+        return uploadedObject{}, fmt.Errorf("error completing multipart upload for %s: %w", s3Key, err)
+    }
+
+    monitor.RecordMultipartUpload(partCount, len(endpointsUsed), time.Since(start))
+    u.verifyETag(filePath, s3Key, output.ETag, partSize)
+    return u.uploaded(clientIndex, aws.StringValue(output.ETag)), nil
+}
+
+// uploadPart uploads a single byte range of a file as one part of a multipart upload.
+func (u *Uploader) uploadPart(s3Client *s3.S3, filePath, s3Key string, uploadID *string, partNumber, offset, length int64) (*string, error) {
+    fileData, err := u.openContent(filePath, s3Key)
+    if err != nil {
+        return nil, err
+    }
+    defer fileData.Close()
+
+    ctx, cancel := u.Config.OperationContextFrom(monitor.RunContext(), config.OperationPut)
+    defer cancel()
+
+    output, err := s3Client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+        Bucket:        aws.String(u.Config.BucketName),
+        Key:           aws.String(s3Key),
+        UploadId:      uploadID,
+        PartNumber:    aws.Int64(partNumber),
+        Body:          io.NewSectionReader(fileData, offset, length),
+        ContentLength: aws.Int64(length),
+    })
+    if err != nil {
+        return nil, fmt.Errorf("error uploading part %d of %s: %w", partNumber, s3Key, err)
+    }
+    return output.ETag, nil
+}
+
+// abandons reports whether the multipart upload of s3Key is to be left incomplete, following
+// MultipartAbandonPercent. With a seed the same keys are abandoned on every run.
+func (u *Uploader) abandons(s3Key string) bool {
+    if u.Config.MultipartAbandonPercent <= 0 {
+        return false
+    }
+    if u.Config.Seed != 0 {
+        return u.Config.Rand("abandon/"+u.seededKey(s3Key)).Float64()*100 < u.Config.MultipartAbandonPercent
+    }
+    return rand.Float64()*100 < u.Config.MultipartAbandonPercent
+}
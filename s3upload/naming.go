@@ -0,0 +1,47 @@
+// s3upload/naming.go
+package s3upload
+
+import "path"
+
+// KeySpec is what the key of an uploaded object is named from.
+type KeySpec struct {
+    RunPrefix string // s3Folder/runID; cleanup and verification only find keys below it.
+    Subfolder string // Name of the subfolder being uploaded, such as "folder-0".
+    Index     int64  // Position of the file in the subfolder, from 0.
+    Path      string // Local file uploaded.
+}
+
+// KeyNamer names the objects UploadFiles uploads, for example after a production hashing
+// scheme. KeyName is called once per file, from one goroutine per UploadFiles call, and must
+// return a different key for every file of a run.
+type KeyNamer interface {
+    KeyName(spec KeySpec) string
+}
+
+// KeyNamerFunc adapts a function to a KeyNamer.
+type KeyNamerFunc func(spec KeySpec) string
+
+// KeyName implements KeyNamer.
+func (f KeyNamerFunc) KeyName(spec KeySpec) string {
+    return f(spec)
+}
+
+// WithKeyNamer names the uploaded objects with n instead of the built-in layout of
+// RunPrefix/subfolder[/level.../leaf]/fileName, which DefaultKeyName returns.
+func WithKeyNamer(n KeyNamer) Option {
+    return func(u *Uploader) { u.keyNamer = n }
+}
+
+// DefaultKeyName returns the key the Uploader gives the object of spec without a KeyNamer,
+// following the key hierarchy and file selection settings. Custom namers can build on it.
+func (u *Uploader) DefaultKeyName(spec KeySpec) string {
+    return path.Join(spec.RunPrefix, spec.Subfolder, u.leafDir(spec.Index), u.objectName(spec.Path, spec.Index))
+}
+
+// keyName returns the key of the object of spec.
+func (u *Uploader) keyName(spec KeySpec) string {
+    if u.keyNamer != nil {
+        return u.keyNamer.KeyName(spec)
+    }
+    return u.DefaultKeyName(spec)
+}
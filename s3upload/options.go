@@ -0,0 +1,43 @@
+// s3upload/options.go
+package s3upload
+
+import (
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/manifest"
+    "scale_s3_benchmark/progress"
+)
+
+// Option configures an Uploader created by NewUploader. Options are the stable way for other
+// programs to set up an Uploader; the exported fields they set may still be assigned directly.
+type Option func(*Uploader)
+
+// WithRunID places the uploaded keys below s3Folder/<id>.
+func WithRunID(id string) Option {
+    return func(u *Uploader) { u.RunID = id }
+}
+
+// WithManifest records every uploaded object in w.
+func WithManifest(w *manifest.Writer) Option {
+    return func(u *Uploader) { u.Manifest = w }
+}
+
+// WithFailures records every upload that failed after all attempts in w.
+func WithFailures(w *manifest.FailureWriter) Option {
+    return func(u *Uploader) { u.Failures = w }
+}
+
+// WithProgress counts the uploads in c, such as a Counter of a custom progress.Sink.
+func WithProgress(c progress.Counter) Option {
+    return func(u *Uploader) { u.Progress = c }
+}
+
+// WithReplication measures the replication lag of every upload with c.
+func WithReplication(c *ReplicationChecker) Option {
+    return func(u *Uploader) { u.Replication = c }
+}
+
+// WithKeyStore collects the uploaded keys in s instead of a new store, so they can be shared
+// with a benchmark Runner created beforehand. No default store is created; the caller closes s.
+func WithKeyStore(s *keystore.Store) Option {
+    return func(u *Uploader) { u.UploadedS3Files = s }
+}
@@ -0,0 +1,150 @@
+// s3upload/replication.go
+package s3upload
+
+import (
+    "sync"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// replicationItem is an uploaded object waiting to appear on the replication destination.
+type replicationItem struct {
+    key      string
+    uploaded time.Time
+    nextPoll time.Time
+}
+
+// ReplicationChecker polls the replication destination with HEAD after each upload and
+// records how long every object took to appear there.
+type ReplicationChecker struct {
+    cfg      *config.Config
+    client   *s3.S3
+    bucket   string
+    interval time.Duration
+    timeout  time.Duration
+
+    mu     sync.Mutex
+    cond   *sync.Cond
+    queue  []replicationItem // Ordered by nextPoll, since every item waits the same interval.
+    closed bool
+    wg     sync.WaitGroup
+}
+
+// NewReplicationChecker creates a client for the replication destination and starts the polling workers.
+func NewReplicationChecker(cfg *config.Config) (*ReplicationChecker, error) {
+    client, err := NewClient(cfg, cfg.ReplicationEndpointURL, cfg.ReplicationAccessKey, cfg.ReplicationSecretKey)
+    if err != nil {
+        return nil, err
+    }
+
+    c := &ReplicationChecker{
+        cfg:      cfg,
+        client:   client,
+        bucket:   cfg.ReplicationBucketName,
+        interval: time.Duration(cfg.ReplicationPollIntervalMs) * time.Millisecond,
+        timeout:  time.Duration(cfg.ReplicationTimeoutSeconds) * time.Second,
+    }
+    c.cond = sync.NewCond(&c.mu)
+
+    for i := 0; i < cfg.ReplicationCheckers; i++ {
+        c.wg.Add(1)
+        go c.worker()
+    }
+    return c, nil
+}
+
+// Track starts checking the replication of an object uploaded just now.
+func (c *ReplicationChecker) Track(key string) {
+    now := time.Now()
+    monitor.RecordReplicationStarted()
+
+    c.mu.Lock()
+    c.queue = append(c.queue, replicationItem{key: key, uploaded: now, nextPoll: now})
+    c.cond.Signal()
+    c.mu.Unlock()
+}
+
+// Wait blocks until every tracked object has been found on the destination or has timed out.
+// No objects may be tracked afterwards.
+func (c *ReplicationChecker) Wait() {
+    c.mu.Lock()
+    c.closed = true
+    c.cond.Broadcast()
+    c.mu.Unlock()
+    c.wg.Wait()
+}
+
+// Pending returns the number of objects not yet found on the destination.
+func (c *ReplicationChecker) Pending() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return len(c.queue)
+}
+
+// worker polls queued objects until the queue is empty and the checker is closed, or the run is aborted.
+func (c *ReplicationChecker) worker() {
+    defer c.wg.Done()
+    runCtx := monitor.RunContext()
+
+    for {
+        c.mu.Lock()
+        for len(c.queue) == 0 && !c.closed {
+            c.cond.Wait()
+        }
+        if len(c.queue) == 0 {
+            c.mu.Unlock()
+            return
+        }
+        item := c.queue[0]
+        c.queue = c.queue[1:]
+        c.mu.Unlock()
+
+        select {
+        case <-time.After(time.Until(item.nextPoll)):
+        case <-runCtx.Done():
+            return
+        }
+
+        found, err := c.exists(item.key)
+        switch {
+        case found:
+            monitor.RecordReplicationLag(time.Since(item.uploaded))
+            continue
+        case err != nil:
+            monitor.RecordReplicationPollError()
+        }
+
+        if time.Since(item.uploaded) >= c.timeout {
+            monitor.RecordReplicationTimeout()
+            continue
+        }
+        item.nextPoll = time.Now().Add(c.interval)
+        c.mu.Lock()
+        c.queue = append(c.queue, item)
+        c.mu.Unlock()
+    }
+}
+
+// exists reports whether key is present on the destination. A missing object is not an error.
+func (c *ReplicationChecker) exists(key string) (bool, error) {
+    ctx, cancel := c.cfg.OperationContext(config.OperationHead)
+    defer cancel()
+
+    _, err := c.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+        Bucket: aws.String(c.bucket),
+        Key:    aws.String(key),
+    })
+    if err == nil {
+        return true, nil
+    }
+    if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == 404 {
+        return false, nil
+    }
+    return false, err
+}
@@ -2,7 +2,9 @@
 package s3upload
 
 import (
+    "crypto/tls"
     "fmt"
+    "net"
     "net/http" // Added import for net/http
     "time"
 
@@ -16,28 +18,45 @@ import (
 
 // InitializeS3Clients initializes and returns a slice of S3 clients based on the provided endpoints.
 func InitializeS3Clients(cfg *config.Config) ([]*s3.S3, error) {
+    return initializeS3Clients(cfg, true)
+}
+
+// InitializeUntracedS3Clients is like InitializeS3Clients, but the clients skip the tracing and
+// fault injection layers, whose per-request bookkeeping would dominate tiny-object workloads.
+func InitializeUntracedS3Clients(cfg *config.Config) ([]*s3.S3, error) {
+    return initializeS3Clients(cfg, false)
+}
+
+// initializeS3Clients creates one S3 client per endpoint, optionally with the tracing and fault injection layers.
+func initializeS3Clients(cfg *config.Config, traced bool) ([]*s3.S3, error) {
     var s3Clients []*s3.S3
     for _, endpoint := range cfg.EndpointURLs {
-        sess, err := session.NewSession(&aws.Config{
-            Region:           aws.String("us-east-1"), // Consider making region configurable
+        awsCfg := &aws.Config{
+            Region:           aws.String(cfg.Region),
             Endpoint:         aws.String(endpoint),
             Credentials:      credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, ""),
-            S3ForcePathStyle: aws.Bool(true),
-            HTTPClient: &http.Client{
-                Transport: &http.Transport{
-                    MaxIdleConns:        cfg.MaxIdleConns,
-                    MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
-                },
-                Timeout: time.Duration(cfg.HttpTimeout) * time.Second,
-            },
-        })
+            S3ForcePathStyle: aws.Bool(!cfg.UseTransferAcceleration),
+            S3UseAccelerate:  aws.Bool(cfg.UseTransferAcceleration),
+        }
+
+        var sess *session.Session
+        var err error
+        if traced {
+            sess, err = newSession(cfg, endpoint, awsCfg)
+        } else {
+            awsCfg.HTTPClient = &http.Client{
+                Transport: newHTTPTransport(cfg),
+                Timeout:   clientTimeout(cfg),
+            }
+            sess, err = session.NewSession(awsCfg)
+        }
 
         if err != nil {
             fmt.Printf("Error creating S3 session for endpoint %s: %v\n", endpoint, err)
             continue
         }
 
-        s3Clients = append(s3Clients, s3.New(sess))
+        s3Clients = append(s3Clients, newS3Client(sess))
     }
 
     if len(s3Clients) == 0 {
@@ -47,3 +66,93 @@ func InitializeS3Clients(cfg *config.Config) ([]*s3.S3, error) {
     return s3Clients, nil
 }
 
+// NewAcceleratedClient returns an S3 client that sends requests through the bucket's
+// S3 Transfer Acceleration endpoint (bucket.s3-accelerate.amazonaws.com).
+func NewAcceleratedClient(cfg *config.Config) (*s3.S3, error) {
+    sess, err := newSession(cfg, "s3-accelerate.amazonaws.com", &aws.Config{
+        Region:          aws.String(cfg.Region),
+        Credentials:     credentials.NewStaticCredentials(cfg.AccessKey, cfg.SecretKey, ""),
+        S3UseAccelerate: aws.Bool(true),
+    })
+    if err != nil {
+        return nil, fmt.Errorf("error creating accelerated S3 session: %w", err)
+    }
+
+    return newS3Client(sess), nil
+}
+
+// NewClient returns a path-style S3 client for an endpoint outside endpointURLs, such as a
+// replication destination or a migration source, using the same tuned and traced transport.
+func NewClient(cfg *config.Config, endpoint, accessKey, secretKey string) (*s3.S3, error) {
+    sess, err := newSession(cfg, endpoint, &aws.Config{
+        Region:           aws.String(cfg.Region),
+        Endpoint:         aws.String(endpoint),
+        Credentials:      credentials.NewStaticCredentials(accessKey, secretKey, ""),
+        S3ForcePathStyle: aws.Bool(true),
+    })
+    if err != nil {
+        return nil, fmt.Errorf("error creating S3 session for endpoint %s: %w", endpoint, err)
+    }
+    return newS3Client(sess), nil
+}
+
+// newSession creates a session whose HTTP client uses the tuned transport, wrapped
+// with the tracing, stall watchdog and fault injection layers. The wrappers are installed after the
+// session is created because the SDK can only apply a custom CA bundle
+// (AWS_CA_BUNDLE) to a plain *http.Transport.
+func newSession(cfg *config.Config, endpoint string, awsCfg *aws.Config) (*session.Session, error) {
+    awsCfg.HTTPClient = &http.Client{
+        Transport: newHTTPTransport(cfg),
+        Timeout:   clientTimeout(cfg),
+    }
+
+    sess, err := session.NewSession(awsCfg)
+    if err != nil {
+        return nil, err
+    }
+
+    httpClient := sess.Config.HTTPClient
+    httpClient.Transport = newFaultTransport(cfg, newStallTransport(cfg, endpoint, newTracingTransport(endpoint, httpClient.Transport)))
+    return sess, nil
+}
+
+// clientTimeout returns the overall HTTP client timeout. It is disabled when per-operation
+// timeouts are configured, since those are enforced through request contexts instead.
+func clientTimeout(cfg *config.Config) time.Duration {
+    if cfg.PerOperationTimeouts() {
+        return 0
+    }
+    return time.Duration(cfg.HttpTimeout) * time.Second
+}
+
+// newHTTPTransport builds the http.Transport used by the S3 clients from the connection tuning options.
+func newHTTPTransport(cfg *config.Config) *http.Transport {
+    dialer := &net.Dialer{
+        Timeout:   time.Duration(cfg.DialTimeout) * time.Second,
+        KeepAlive: 30 * time.Second,
+    }
+
+    dialContext := dialer.DialContext
+    if cfg.ThrottleBandwidthKBps > 0 || cfg.ThrottleLatencyMs > 0 {
+        dialContext = throttledDialContext(dialContext, cfg.ThrottleBandwidthKBps, time.Duration(cfg.ThrottleLatencyMs)*time.Millisecond)
+    }
+
+    transport := &http.Transport{
+        Proxy:               http.ProxyFromEnvironment,
+        DialContext:         dialContext,
+        MaxIdleConns:        cfg.MaxIdleConns,
+        MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+        MaxConnsPerHost:     cfg.MaxConnsPerHost,
+        IdleConnTimeout:     time.Duration(cfg.IdleConnTimeout) * time.Second,
+        TLSHandshakeTimeout: time.Duration(cfg.TLSHandshakeTimeout) * time.Second,
+        DisableKeepAlives:   cfg.DisableKeepAlives,
+        ForceAttemptHTTP2:   cfg.EnableHTTP2,
+    }
+
+    if !cfg.EnableHTTP2 {
+        // A non-nil, empty map disables the transport's automatic HTTP/2 upgrade.
+        transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+    }
+
+    return transport
+}
@@ -0,0 +1,43 @@
+// s3upload/selection.go
+package s3upload
+
+import (
+    "fmt"
+    "path/filepath"
+
+    "scale_s3_benchmark/config"
+)
+
+// SelectFiles returns the FileSource of a subfolder following FileSelection. The sequential
+// and unique selections take the local files in turn; the random selection draws them from a
+// hash of the seed, the subfolder index and the file position, so the same key always gets
+// the same local file and skipExisting still finds the objects of an interrupted run.
+func SelectFiles(cfg *config.Config, localFiles []string, folderIndex int) FileSource {
+    n := uint64(len(localFiles))
+    if cfg.FileSelection == config.FileSelectionRandom {
+        return func(i int64) string {
+            return localFiles[mix(uint64(cfg.Seed)^uint64(folderIndex)<<40^uint64(i))%n]
+        }
+    }
+    return func(i int64) string {
+        return localFiles[uint64(i)%n]
+    }
+}
+
+// mix is the splitmix64 finalizer, spreading consecutive inputs over the whole range.
+func mix(x uint64) uint64 {
+    x += 0x9e3779b97f4a7c15
+    x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+    x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+    return x ^ (x >> 31)
+}
+
+// objectName returns the file name in the key of the i-th file of a subfolder. With the random
+// selection a local file may be drawn several times in a subfolder, so objects are named after
+// their position instead of their local file.
+func (u *Uploader) objectName(filePath string, i int64) string {
+    if u.Config.FileSelection == config.FileSelectionRandom {
+        return fmt.Sprintf("object_%d%s", i, filepath.Ext(filePath))
+    }
+    return filepath.Base(filePath)
+}
@@ -0,0 +1,167 @@
+// s3upload/stall.go
+package s3upload
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/monitor"
+)
+
+// ErrStalled is wrapped by the errors of transfers cancelled by the stall watchdog.
+var ErrStalled = errors.New("transfer stalled")
+
+// stallError is the error of a stalled transfer. It is temporary, so the SDK retries the request.
+type stallError struct {
+    timeout time.Duration
+}
+
+func (e *stallError) Error() string {
+    return fmt.Sprintf("%v: no progress for %v", ErrStalled, e.timeout)
+}
+
+func (e *stallError) Unwrap() error { return ErrStalled }
+
+func (e *stallError) Temporary() bool { return true }
+
+// stallTransport wraps an http.RoundTripper and cancels every request that sends or receives
+// no byte for the stall timeout, instead of leaving the worker hanging on a stuck connection
+// until the HTTP timeout.
+type stallTransport struct {
+    endpoint string
+    timeout  time.Duration
+    base     http.RoundTripper
+}
+
+// newStallTransport returns base unchanged when the stall watchdog is disabled.
+func newStallTransport(cfg *config.Config, endpoint string, base http.RoundTripper) http.RoundTripper {
+    if cfg.StallTimeoutSeconds <= 0 {
+        return base
+    }
+    return &stallTransport{endpoint: endpoint, timeout: time.Duration(cfg.StallTimeoutSeconds) * time.Second, base: base}
+}
+
+// RoundTrip executes a single HTTP transaction under the watch of a stallWatch, which lasts
+// until the response body is read to the end or closed.
+func (t *stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    ctx, cancel := context.WithCancel(req.Context())
+    w := newStallWatch(t, cancel)
+
+    req = req.WithContext(ctx)
+    if req.Body != nil && req.Body != http.NoBody {
+        req.Body = &watchedBody{body: req.Body, watch: w}
+    }
+
+    resp, err := t.base.RoundTrip(req)
+    if err != nil {
+        w.stop()
+        cancel()
+        if w.hasStalled() {
+            return nil, &stallError{timeout: t.timeout}
+        }
+        return nil, err
+    }
+
+    atomic.StoreInt32(&w.receiving, 1)
+    w.progress()
+    resp.Body = &watchedBody{body: resp.Body, watch: w, response: true}
+    return resp, nil
+}
+
+// stallWatch cancels a request once no byte was sent or received for the timeout.
+type stallWatch struct {
+    transport *stallTransport
+    cancel    context.CancelFunc
+    last      int64 // UnixNano of the latest progress.
+    receiving int32 // Non-zero once the response headers arrived.
+    stalled   int32
+
+    mu      sync.Mutex
+    timer   *time.Timer
+    stopped bool
+}
+
+// newStallWatch starts watching a request.
+func newStallWatch(t *stallTransport, cancel context.CancelFunc) *stallWatch {
+    w := &stallWatch{transport: t, cancel: cancel, last: time.Now().UnixNano()}
+    w.mu.Lock()
+    w.timer = time.AfterFunc(t.timeout, w.check)
+    w.mu.Unlock()
+    return w
+}
+
+// progress records that bytes were sent or received.
+func (w *stallWatch) progress() {
+    atomic.StoreInt64(&w.last, time.Now().UnixNano())
+}
+
+// check cancels the request if it made no progress for the timeout, and otherwise checks
+// again once the timeout has passed since the latest progress.
+func (w *stallWatch) check() {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    if w.stopped {
+        return
+    }
+
+    idle := time.Since(time.Unix(0, atomic.LoadInt64(&w.last)))
+    if idle < w.transport.timeout {
+        w.timer.Reset(w.transport.timeout - idle)
+        return
+    }
+
+    atomic.StoreInt32(&w.stalled, 1)
+    w.stopped = true
+    monitor.RecordStall(w.transport.endpoint, atomic.LoadInt32(&w.receiving) != 0)
+    w.cancel()
+}
+
+// stop ends the watch without cancelling the request.
+func (w *stallWatch) stop() {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.stopped = true
+    w.timer.Stop()
+}
+
+// hasStalled reports whether the watch cancelled the request.
+func (w *stallWatch) hasStalled() bool {
+    return atomic.LoadInt32(&w.stalled) != 0
+}
+
+// watchedBody reports the progress of a request or response body to its stallWatch, and turns
+// the error of a read cut short by the watch into a stallError.
+type watchedBody struct {
+    body     io.ReadCloser
+    watch    *stallWatch
+    response bool
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+    n, err := b.body.Read(p)
+    if n > 0 {
+        b.watch.progress()
+    }
+    if err != nil && err != io.EOF && b.watch.hasStalled() {
+        return n, &stallError{timeout: b.watch.transport.timeout}
+    }
+    if err == io.EOF && b.response {
+        b.watch.stop()
+    }
+    return n, err
+}
+
+func (b *watchedBody) Close() error {
+    if b.response {
+        b.watch.stop()
+        defer b.watch.cancel()
+    }
+    return b.body.Close()
+}
@@ -0,0 +1,126 @@
+// s3upload/throttle.go
+package s3upload
+
+import (
+    "context"
+    "net"
+    "sync"
+    "time"
+)
+
+// tokenBucket limits throughput to a fixed number of bytes per second.
+type tokenBucket struct {
+    mu       sync.Mutex
+    rate     float64 // Bytes per second.
+    burst    float64
+    tokens   float64
+    lastFill time.Time
+}
+
+// newTokenBucket creates a bucket refilled at bytesPerSec, allowing bursts of up to 100ms of traffic.
+func newTokenBucket(bytesPerSec int) *tokenBucket {
+    rate := float64(bytesPerSec)
+    burst := rate / 10
+    if burst < 1024 {
+        burst = 1024
+    }
+    return &tokenBucket{rate: rate, burst: burst, tokens: burst, lastFill: time.Now()}
+}
+
+// take blocks until n bytes may be transferred. n must not exceed the burst size.
+func (b *tokenBucket) take(n int) {
+    b.mu.Lock()
+    now := time.Now()
+    b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+    if b.tokens > b.burst {
+        b.tokens = b.burst
+    }
+    b.lastFill = now
+    b.tokens -= float64(n)
+    deficit := -b.tokens
+    b.mu.Unlock()
+
+    if deficit > 0 {
+        time.Sleep(time.Duration(deficit / b.rate * float64(time.Second)))
+    }
+}
+
+// throttledConn is a net.Conn with a per-connection bandwidth cap and an added
+// round-trip latency, used to simulate WAN clients.
+type throttledConn struct {
+    net.Conn
+    readBucket  *tokenBucket
+    writeBucket *tokenBucket
+    latency     time.Duration
+
+    mu         sync.Mutex
+    pendingRTT bool // Set by Write, cleared by the next Read: one added delay per request/response exchange.
+}
+
+func (c *throttledConn) Read(p []byte) (int, error) {
+    c.mu.Lock()
+    delay := c.pendingRTT
+    c.pendingRTT = false
+    c.mu.Unlock()
+    if delay && c.latency > 0 {
+        time.Sleep(c.latency)
+    }
+
+    if c.readBucket != nil {
+        if len(p) > int(c.readBucket.burst) {
+            p = p[:int(c.readBucket.burst)]
+        }
+        n, err := c.Conn.Read(p)
+        c.readBucket.take(n)
+        return n, err
+    }
+    return c.Conn.Read(p)
+}
+
+func (c *throttledConn) Write(p []byte) (int, error) {
+    c.mu.Lock()
+    c.pendingRTT = true
+    c.mu.Unlock()
+
+    if c.writeBucket == nil {
+        return c.Conn.Write(p)
+    }
+
+    written := 0
+    for written < len(p) {
+        chunk := len(p) - written
+        if chunk > int(c.writeBucket.burst) {
+            chunk = int(c.writeBucket.burst)
+        }
+        c.writeBucket.take(chunk)
+        n, err := c.Conn.Write(p[written : written+chunk])
+        written += n
+        if err != nil {
+            return written, err
+        }
+    }
+    return written, nil
+}
+
+// throttledDialContext wraps a dial function so every new connection is throttled
+// according to the given per-connection bandwidth (KB/s, 0 = unlimited) and latency.
+func throttledDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error), bandwidthKBps int, latency time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+    return func(ctx context.Context, network, addr string) (net.Conn, error) {
+        // The TCP handshake costs one round trip.
+        if latency > 0 {
+            time.Sleep(latency)
+        }
+
+        conn, err := dial(ctx, network, addr)
+        if err != nil {
+            return nil, err
+        }
+
+        tc := &throttledConn{Conn: conn, latency: latency}
+        if bandwidthKBps > 0 {
+            tc.readBucket = newTokenBucket(bandwidthKBps * 1024)
+            tc.writeBucket = newTokenBucket(bandwidthKBps * 1024)
+        }
+        return tc, nil
+    }
+}
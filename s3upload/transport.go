@@ -0,0 +1,84 @@
+// s3upload/transport.go
+package s3upload
+
+import (
+    "crypto/tls"
+    "net/http"
+    "net/http/httptrace"
+    "sync"
+    "time"
+
+    "scale_s3_benchmark/monitor"
+)
+
+// tracingTransport wraps an http.RoundTripper and records connection-pool
+// metrics for every request through httptrace.
+type tracingTransport struct {
+    endpoint string
+    base     http.RoundTripper
+}
+
+// newTracingTransport returns a RoundTripper that reports transport metrics for the given endpoint.
+func newTracingTransport(endpoint string, base http.RoundTripper) http.RoundTripper {
+    return &tracingTransport{endpoint: endpoint, base: base}
+}
+
+// RoundTrip executes a single HTTP transaction with a client trace attached.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    // Happy-eyeballs dials IPv4 and IPv6 addresses in parallel, so the callbacks can run
+    // concurrently and each dial is timed under its own network and address.
+    var mu sync.Mutex
+    var dnsStart, tlsStart time.Time
+    dialStarts := make(map[string]time.Time)
+
+    trace := &httptrace.ClientTrace{
+        GotConn: func(info httptrace.GotConnInfo) {
+            monitor.RecordConn(t.endpoint, info.Reused)
+        },
+        DNSStart: func(httptrace.DNSStartInfo) {
+            mu.Lock()
+            dnsStart = time.Now()
+            mu.Unlock()
+        },
+        DNSDone: func(httptrace.DNSDoneInfo) {
+            mu.Lock()
+            start := dnsStart
+            mu.Unlock()
+            monitor.RecordDNSLookup(t.endpoint, time.Since(start))
+        },
+        ConnectStart: func(network, addr string) {
+            mu.Lock()
+            dialStarts[network+" "+addr] = time.Now()
+            mu.Unlock()
+        },
+        ConnectDone: func(network, addr string, err error) {
+            mu.Lock()
+            start, ok := dialStarts[network+" "+addr]
+            delete(dialStarts, network+" "+addr)
+            mu.Unlock()
+            if err == nil && ok {
+                monitor.RecordDial(t.endpoint, time.Since(start))
+            }
+        },
+        TLSHandshakeStart: func() {
+            mu.Lock()
+            tlsStart = time.Now()
+            mu.Unlock()
+        },
+        TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+            mu.Lock()
+            start := tlsStart
+            mu.Unlock()
+            if err == nil {
+                monitor.RecordTLSHandshake(t.endpoint, time.Since(start))
+            }
+        },
+    }
+
+    req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+    start := time.Now()
+    resp, err := t.base.RoundTrip(req)
+    monitor.RecordEndpointRequest(t.endpoint, time.Since(start), err != nil || resp.StatusCode >= 500)
+    return resp, err
+}
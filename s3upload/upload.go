@@ -2,117 +2,572 @@
 package s3upload
 
 import (
+    "errors"
     "fmt"
     "math"
     "os"
-    "path/filepath"
+    "strings"
     "sync"
     "sync/atomic"
     "time"
 
     "github.com/aws/aws-sdk-go/aws"
     "github.com/aws/aws-sdk-go/service/s3"
+    "github.com/aws/aws-sdk-go/service/s3/s3manager"
 
     "scale_s3_benchmark/config"
+    "scale_s3_benchmark/keystore"
+    "scale_s3_benchmark/latencylog"
+    "scale_s3_benchmark/manifest"
     "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+    "scale_s3_benchmark/storage"
 )
 
 // Uploader handles uploading files to S3 with retry logic.
 type Uploader struct {
     Config          *config.Config
-    S3Clients       []*s3.S3
+    Backends        []storage.Backend // One backend per endpoint; uploads are spread across them.
+    S3Clients       []*s3.S3          // The S3 clients behind Backends when the storage backend is S3, used for S3-only features.
     SuccessCount    int64
     ClientIndex     uint64
-    UploadedS3Files []string
+    UploadedS3Files *keystore.Store
     Mutex           sync.Mutex
     StartTime       time.Time
+    Managers        []*s3manager.Uploader   // One managed uploader per S3 client, used by the s3manager backend.
+    Manifest        *manifest.Writer        // Records every uploaded object; nil disables the manifest.
+    Failures        *manifest.FailureWriter // Records every upload that failed after all attempts; nil disables it.
+    Breakers        []*circuitBreaker       // One circuit breaker per S3 client; nil when disabled.
+    Replication     *ReplicationChecker     // Measures replication lag of every upload; nil disables the check.
+    Progress        progress.Counter        // Counts the uploads, e.g. a status line task; nil shows no progress.
+    RunID           string                  // Run the uploads belong to; keys are placed below s3Folder/RunID.
+
+    sizes          sync.Map // Local file sizes by path, looked up once per file.
+    checksums      sync.Map // Content SHA-256 by local file path, when all objects of a file share its content.
+    scheduledBytes int64    // Bytes of the uploads scheduled so far that did not fail, for the totalDataSize goal.
+
+    concurrency int64      // Concurrent uploads per subfolder; adjustable while running.
+    limitMu     sync.Mutex // Guards the per-subfolder active upload counters.
+    limitCond   *sync.Cond // Signalled when an upload finishes or the concurrency changes.
+
+    beforeUpload []BeforeUploadHook // Called before each upload, see WithBeforeUpload.
+    afterUpload  []AfterUploadHook  // Called after each upload, see WithAfterUpload.
+    keyNamer     KeyNamer           // Names the uploaded objects; nil uses DefaultKeyName.
+}
+
+// uploadedObject describes a completed upload, for the manifest.
+type uploadedObject struct {
+    etag     string // Without quotes.
+    endpoint string
 }
 
-// NewUploader creates a new Uploader instance.
-func NewUploader(cfg *config.Config, s3Clients []*s3.S3, startTime time.Time) *Uploader {
-    return &Uploader{
-        Config:          cfg,
-        S3Clients:       s3Clients,
-        UploadedS3Files: make([]string, 0),
-        StartTime:       startTime,
+// NewUploader creates a new Uploader instance, applying the options in order.
+func NewUploader(cfg *config.Config, backends []storage.Backend, startTime time.Time, opts ...Option) *Uploader {
+    var s3Clients []*s3.S3
+    for _, backend := range backends {
+        if s3Backend, ok := backend.(*storage.S3Backend); ok {
+            s3Clients = append(s3Clients, s3Backend.Client)
+        }
+    }
+
+    u := &Uploader{
+        Config:      cfg,
+        Backends:    backends,
+        S3Clients:   s3Clients,
+        StartTime:   startTime,
+        concurrency: int64(cfg.MaxConcurrentUploads),
+    }
+    u.limitCond = sync.NewCond(&u.limitMu)
+
+    if cfg.CircuitBreakerErrorRate > 0 {
+        for _, backend := range backends {
+            u.Breakers = append(u.Breakers, newCircuitBreaker(backend.Endpoint(), cfg.CircuitBreakerErrorRate,
+                cfg.CircuitBreakerWindow, time.Duration(cfg.CircuitBreakerCooldownSeconds)*time.Second))
+        }
+    }
+
+    if cfg.UploadBackend == config.UploadBackendS3Manager {
+        for _, client := range s3Clients {
+            u.Managers = append(u.Managers, s3manager.NewUploaderWithClient(client, func(m *s3manager.Uploader) {
+                if cfg.S3ManagerPartSize > 0 {
+                    m.PartSize = cfg.S3ManagerPartSize
+                }
+                if cfg.S3ManagerConcurrency > 0 {
+                    m.Concurrency = cfg.S3ManagerConcurrency
+                }
+            }))
+        }
     }
+
+    for _, opt := range opts {
+        opt(u)
+    }
+    // The default store is only created when WithKeyStore did not supply one, so no store is
+    // left open behind the caller's.
+    if u.UploadedS3Files == nil {
+        u.UploadedS3Files = keystore.New(cfg.KeyStoreDir, cfg.KeyStoreMemoryLimit)
+    }
+    return u
+}
+
+// FileSource returns the local file to upload as the i-th file of a subfolder. It lets
+// subfolders of millions of files be uploaded without building the list of paths first.
+type FileSource func(i int64) string
+
+// KeyedFile is a local file with the key to upload it to.
+type KeyedFile struct {
+    Path string
+    Key  string
+}
+
+// UploadFiles concurrently uploads count files, taken from source, to S3 with a specified concurrency.
+// It returns the number of files uploaded successfully and the number that failed.
+func (u *Uploader) UploadFiles(subfolderName string, count int64, source FileSource) (successes, failures int64) {
+    return u.upload(subfolderName, count, func(i int64) (KeyedFile, bool) {
+        filePath := source(i)
+        if u.Config.TotalDataBytes > 0 {
+            if u.DataTargetReached() {
+                return KeyedFile{}, false
+            }
+            size, _ := u.fileSize(filePath)
+            atomic.AddInt64(&u.scheduledBytes, size)
+        }
+
+        // S3 key structure: s3Folder/runID/subfolderName[/level.../leaf]/fileName, unless a KeyNamer is set.
+        s3Key := u.keyName(KeySpec{RunPrefix: u.Config.RunPrefix(u.RunID), Subfolder: subfolderName, Index: i, Path: filePath})
+        return KeyedFile{Path: filePath, Key: s3Key}, true
+    })
+}
+
+// UploadKeys concurrently uploads each of files to its own key, like UploadFiles. name
+// identifies the uploads in error messages.
+func (u *Uploader) UploadKeys(name string, files []KeyedFile) (successes, failures int64) {
+    return u.upload(name, int64(len(files)), func(i int64) (KeyedFile, bool) {
+        return files[i], true
+    })
 }
 
-// UploadFiles concurrently uploads a list of files to S3 with a specified concurrency.
-func (u *Uploader) UploadFiles(subfolderName string, filePaths []string) {
+// upload concurrently uploads count files, the i-th taken from next, which returns false to
+// stop early. Workers are started on demand up to the concurrency limit and take further files
+// when they finish one. Each worker batches its statistics and keys, see uploadBatch.
+func (u *Uploader) upload(name string, count int64, next func(i int64) (KeyedFile, bool)) (successes, failures int64) {
     var wg sync.WaitGroup
-    semaphore := make(chan struct{}, u.Config.MaxConcurrentUploads)
-
-    for _, filePath := range filePaths {
-        wg.Add(1)
-        go func(fp string) {
-            defer wg.Done()
-            semaphore <- struct{}{}
-            err := u.UploadFileWithRetry(fp, subfolderName)
-            if err != nil {
-                fmt.Printf("Error uploading file %s: %v\n", fp, err)
+    var mu sync.Mutex // Guards successes and failures while workers merge their batches.
+
+    // Concurrency slots for this subfolder, re-checked against the current limit on every acquire.
+    active := 0
+    acquire := func() {
+        u.limitMu.Lock()
+        for active >= u.Concurrency() {
+            u.limitCond.Wait()
+        }
+        active++
+        u.limitMu.Unlock()
+    }
+    release := func() {
+        u.limitMu.Lock()
+        active--
+        u.limitCond.Broadcast()
+        u.limitMu.Unlock()
+    }
+
+    runCtx := monitor.RunContext()
+
+    // Where the uploads are, for the checkpoints.
+    cursor := monitor.StartFolderCursor(name, count)
+    defer monitor.EndFolderCursor(cursor)
+
+    // Files handed to idle workers. The slot for a file is acquired before it is handed over
+    // and released by the worker once the upload is done.
+    files := make(chan KeyedFile)
+    worker := func(f KeyedFile) {
+        defer wg.Done()
+
+        batch := uploadBatch{flushedAt: time.Now()}
+        defer func() {
+            u.flush(&batch)
+            if err := u.UploadedS3Files.AddBatch(batch.keys); err != nil {
+                progress.Printf("Error storing uploaded keys of %s: %v\n", name, err)
+            }
+            mu.Lock()
+            successes += batch.successes
+            failures += batch.failures
+            mu.Unlock()
+        }()
+
+        for {
+            monitor.WaitIfPaused(runCtx)
+            if runCtx.Err() == nil {
+                if err := u.uploadFileWithRetry(f.Path, f.Key, &batch); err != nil {
+                    progress.Printf("Error uploading file %s: %v\n", f.Path, err)
+                    batch.failure()
+                    if u.Config.TotalDataBytes > 0 {
+                        // Give the bytes back so another upload makes up for this one.
+                        size, _ := u.fileSize(f.Path)
+                        atomic.AddInt64(&u.scheduledBytes, -size)
+                    }
+                }
+                cursor.AddDone()
+                if batch.due() {
+                    u.flush(&batch)
+                }
+            }
+            release()
+
+            var ok bool
+            if f, ok = <-files; !ok {
+                return
             }
-            <-semaphore
-        }(filePath)
+        }
+    }
+
+    for i := int64(0); i < count; i++ {
+        // Stop scheduling new uploads once the run has been aborted.
+        if runCtx.Err() != nil {
+            break
+        }
+
+        f, ok := next(i)
+        if !ok {
+            break
+        }
+        acquire()
+        cursor.AddScheduled()
+        select {
+        case files <- f:
+        default:
+            wg.Add(1)
+            go worker(f)
+        }
     }
+    close(files)
 
     wg.Wait()
+    return successes, failures
 }
 
-// UploadFileWithRetry attempts to upload a file to S3, retrying on failure.
-func (u *Uploader) UploadFileWithRetry(filePath string, subfolderName string) error {
-    // S3 key structure: s3Folder/subfolderName/fileName
-    fileName := filepath.Base(filePath)
-    s3Key := filepath.Join(u.Config.S3Folder, subfolderName, fileName)
+// DataTargetReached reports whether uploads totalling TotalDataBytes have been scheduled,
+// not counting failed ones. It is always false when the goal is a number of files.
+func (u *Uploader) DataTargetReached() bool {
+    return u.Config.TotalDataBytes > 0 && atomic.LoadInt64(&u.scheduledBytes) >= u.Config.TotalDataBytes
+}
 
-    for attempt := 1; attempt <= u.Config.MaxRetries; attempt++ {
-        if err := u.uploadFile(filePath, s3Key); err == nil {
-            atomic.AddInt64(&u.SuccessCount, 1)
+// fileSize returns the size of a local file. Sizes are cached, since the same local files are
+// uploaded many times.
+func (u *Uploader) fileSize(filePath string) (int64, error) {
+    if size, ok := u.sizes.Load(filePath); ok {
+        return size.(int64), nil
+    }
+    info, err := os.Stat(filePath)
+    if err != nil {
+        return 0, fmt.Errorf("error reading file info %s: %w", filePath, err)
+    }
+    u.sizes.Store(filePath, info.Size())
+    return info.Size(), nil
+}
 
-            // Update global statistics
-            monitor.UpdateStats(true)
+// Concurrency returns the current number of concurrent uploads allowed per subfolder.
+func (u *Uploader) Concurrency() int {
+    return int(atomic.LoadInt64(&u.concurrency))
+}
 
-            elapsedTime := time.Since(u.StartTime).Seconds()
-            rate := float64(u.SuccessCount) / elapsedTime
-            progress := float64(u.SuccessCount) / float64(u.Config.TotalFiles) * 100
-            fmt.Printf("Uploading to S3: %d/%d (%.2f%%) | Rate: %.2f files/sec\r", u.SuccessCount, u.Config.TotalFiles, progress, rate)
+// SetConcurrency changes the number of concurrent uploads allowed per subfolder.
+// It takes effect immediately for uploads waiting for a slot.
+func (u *Uploader) SetConcurrency(n int) {
+    if n < 1 {
+        n = 1
+    }
+    atomic.StoreInt64(&u.concurrency, int64(n))
 
-            // Store uploaded S3 key
-            u.Mutex.Lock()
-            u.UploadedS3Files = append(u.UploadedS3Files, s3Key)
-            u.Mutex.Unlock()
+    u.limitMu.Lock()
+    u.limitCond.Broadcast()
+    u.limitMu.Unlock()
+}
 
+// uploadFileWithRetry attempts to upload a file to S3, retrying on failure. Successful and
+// skipped uploads are recorded in batch; a failure is returned to the caller to record.
+// Abandoned multipart uploads are neither and are only counted in the multipart statistics.
+func (u *Uploader) uploadFileWithRetry(filePath, s3Key string, batch *uploadBatch) error {
+    if u.Config.SkipExisting && u.objectExists(filePath, s3Key) {
+        batch.skipped(s3Key)
+        monitor.RecordSkipped()
+        u.recordManifest(filePath, s3Key, uploadedObject{}, 0)
+
+        return nil
+    }
+
+    if err := u.runBeforeUpload(filePath, s3Key); err != nil {
+        progress.Printf("Error: %v\n", err)
+        monitor.RecordOutcome(false)
+        u.recordFailure(filePath, s3Key, err, 0)
+        u.runAfterUpload(UploadInfo{Path: filePath, Key: s3Key, Err: err})
+        return err
+    }
+
+    start := time.Now()
+    for attempt := 1; attempt <= u.Config.MaxRetries; attempt++ {
+        object, err := u.uploadFile(filePath, s3Key)
+        if errors.Is(err, errAbandoned) {
             return nil
-        } else if attempt < u.Config.MaxRetries {
+        }
+        // The parts of a multipart upload were retried one by one; restarting the whole
+        // upload would send every part again.
+        final := errors.Is(err, errPartFailed)
+        if err == nil {
+            monitor.RecordObjectPut(s3Key)
+            if u.Replication != nil {
+                u.Replication.Track(s3Key)
+            }
+
+            // The abort policy is fed per upload; the counters are published with the batch.
+            monitor.RecordOutcome(true)
+            size, _ := u.fileSize(filePath)
+            batch.success(s3Key, size)
+            u.recordManifest(filePath, s3Key, object, attempt)
+            u.runAfterUpload(UploadInfo{
+                Path:     filePath,
+                Key:      s3Key,
+                Size:     size,
+                ETag:     object.etag,
+                Endpoint: object.endpoint,
+                Attempts: attempt,
+                Duration: time.Since(start),
+            })
+
+            return nil
+        }
+
+        // Exponential backoff before retrying. A run cancelled before or during the wait gets
+        // no further attempts.
+        if attempt < u.Config.MaxRetries && !final && monitor.RunContext().Err() == nil {
             backoffDuration := time.Duration(math.Pow(2, float64(attempt))) * time.Second
-            time.Sleep(backoffDuration) // Exponential backoff before retrying.
-        } else {
-            fmt.Printf("\nFailed to upload %s after %d attempts\n", filePath, u.Config.MaxRetries)
-            // Update global statistics
-            monitor.UpdateStats(false)
-            return fmt.Errorf("failed to upload %s after %d attempts", filePath, u.Config.MaxRetries)
+            if monitor.SleepRun(backoffDuration) {
+                continue
+            }
         }
+        progress.Printf("Failed to upload %s after %d attempts\n", filePath, attempt)
+        monitor.RecordOutcome(false)
+        u.recordFailure(filePath, s3Key, err, attempt)
+        u.runAfterUpload(UploadInfo{Path: filePath, Key: s3Key, Attempts: attempt, Duration: time.Since(start), Err: err})
+        return fmt.Errorf("failed to upload %s after %d attempts", filePath, attempt)
     }
 
     return fmt.Errorf("failed to upload %s after %d attempts", filePath, u.Config.MaxRetries)
 }
 
+// recordManifest appends an uploaded object to the manifest, if one is configured. attempts
+// is the number of upload attempts it took; skipped uploads have none and no object details.
+func (u *Uploader) recordManifest(filePath, s3Key string, object uploadedObject, attempts int) {
+    if u.Manifest == nil {
+        return
+    }
+
+    size, err := u.fileSize(filePath)
+    if err != nil {
+        progress.Printf("Error reading file info %s for manifest: %v\n", filePath, err)
+        return
+    }
+    checksum, err := u.contentSHA256(filePath, s3Key)
+    if err != nil {
+        progress.Printf("Error computing checksum of %s for manifest: %v\n", filePath, err)
+    }
+    entry := manifest.Entry{
+        Key:        s3Key,
+        Size:       size,
+        UploadedAt: time.Now(),
+        RunID:      u.RunID,
+        SHA256:     checksum,
+        ETag:       object.etag,
+        Endpoint:   object.endpoint,
+        Attempts:   attempts,
+    }
+    if err := u.Manifest.Add(entry); err != nil {
+        progress.Printf("Error writing manifest entry for %s: %v\n", s3Key, err)
+    }
+}
+
+// recordFailure appends an upload that failed after attempts attempts to the failures file, if
+// one is configured, so it can be re-driven later.
+func (u *Uploader) recordFailure(filePath, s3Key string, err error, attempts int) {
+    if u.Failures == nil {
+        return
+    }
+
+    failure := manifest.Failure{
+        Key:      s3Key,
+        File:     filePath,
+        Error:    strings.Join(strings.Fields(err.Error()), " "), // SDK errors span several lines.
+        FailedAt: time.Now(),
+        RunID:    u.RunID,
+        Attempts: attempts,
+    }
+    if err := u.Failures.Add(failure); err != nil {
+        progress.Printf("Error writing failure entry for %s: %v\n", s3Key, err)
+    }
+}
+
+// logLatency adds an upload attempt through the client at clientIndex to the latency log.
+func (u *Uploader) logLatency(clientIndex int, s3Key string, size int64, start time.Time, err error) {
+    latencylog.Add(latencylog.Entry{
+        Time:      start,
+        RunID:     u.RunID,
+        Operation: config.OperationPut,
+        Endpoint:  u.Backends[clientIndex].Endpoint(),
+        Key:       s3Key,
+        Size:      size,
+        Latency:   time.Since(start),
+        Failed:    err != nil,
+    })
+}
+
+// objectExists reports whether s3Key already exists in the bucket with the same size as the local file.
+// Any error, including a missing object, is treated as "does not exist" so the file is uploaded.
+func (u *Uploader) objectExists(filePath, s3Key string) bool {
+    info, err := os.Stat(filePath)
+    if err != nil {
+        return false
+    }
+
+    clientIndex := u.nextClient()
+
+    ctx, cancel := u.Config.OperationContextFrom(monitor.RunContext(), config.OperationHead)
+    defer cancel()
+
+    size, err := u.Backends[clientIndex].HeadObject(ctx, s3Key)
+    if errors.Is(err, storage.ErrNotFound) {
+        // A missing object is a healthy answer from the endpoint.
+        u.recordResult(clientIndex, nil)
+    } else {
+        u.recordResult(clientIndex, err)
+    }
+    if err != nil {
+        return false
+    }
+    return size == info.Size()
+}
+
 // uploadFile uploads a single file to S3 using a selected S3 client.
-func (u *Uploader) uploadFile(filePath, s3Key string) error {
-    clientIndex := atomic.AddUint64(&u.ClientIndex, 1)
-    s3Client := u.S3Clients[clientIndex%uint64(len(u.S3Clients))]
+func (u *Uploader) uploadFile(filePath, s3Key string) (uploadedObject, error) {
+    if u.Config.StorageBackend != config.StorageBackendS3 {
+        return u.uploadFileGeneric(filePath, s3Key)
+    }
+
+    if u.Config.UploadBackend == config.UploadBackendS3Manager {
+        return u.uploadFileManaged(filePath, s3Key)
+    }
+
+    if u.Config.MultipartThreshold > 0 {
+        info, err := os.Stat(filePath)
+        if err != nil {
+            return uploadedObject{}, fmt.Errorf("error reading file info %s: %w", filePath, err)
+        }
+        if info.Size() >= u.Config.MultipartThreshold {
+            return u.uploadMultipart(filePath, s3Key, info.Size())
+        }
+    }
+
+    clientIndex := u.nextClient()
+    s3Client := u.S3Clients[clientIndex]
 
-    fileData, err := os.Open(filePath)
+    fileData, err := u.openContent(filePath, s3Key)
     if err != nil {
-        return fmt.Errorf("error opening file %s: %w", filePath, err)
+        return uploadedObject{}, err
     }
     defer fileData.Close()
 
-    _, err = s3Client.PutObject(&s3.PutObjectInput{
-        Bucket: aws.String(u.Config.BucketName),
-        Key:    aws.String(s3Key),
-        Body:   fileData,
-    })
-    return err
+    input := &s3.PutObjectInput{
+        Bucket:   aws.String(u.Config.BucketName),
+        Key:      aws.String(s3Key),
+        Body:     fileData,
+        Metadata: u.objectMetadata(),
+    }
+    if u.objectLockEnabled() {
+        contentMD5, err := contentMD5Base64(fileData)
+        if err != nil {
+            return uploadedObject{}, err
+        }
+        input.ContentMD5 = aws.String(contentMD5)
+        input.ObjectLockMode, input.ObjectLockRetainUntilDate, input.ObjectLockLegalHoldStatus = u.objectLockParams()
+    }
+
+    ctx, cancel := u.Config.OperationContextFrom(monitor.RunContext(), config.OperationPut)
+    defer cancel()
+
+    start := time.Now()
+    output, err := s3Client.PutObjectWithContext(ctx, input)
+    u.recordResult(clientIndex, err)
+    u.logLatency(clientIndex, s3Key, fileData.Size(), start, err)
+    if err != nil {
+        return uploadedObject{}, err
+    }
+    u.verifyETag(filePath, s3Key, output.ETag, 0)
+    return u.uploaded(clientIndex, aws.StringValue(output.ETag)), nil
 }
 
+// uploadFileManaged uploads a single file through the SDK's s3manager.Uploader,
+// which switches to multipart automatically for large files.
+func (u *Uploader) uploadFileManaged(filePath, s3Key string) (uploadedObject, error) {
+    clientIndex := u.nextClient()
+    manager := u.Managers[clientIndex]
+
+    fileData, err := u.openContent(filePath, s3Key)
+    if err != nil {
+        return uploadedObject{}, err
+    }
+    defer fileData.Close()
+
+    input := &s3manager.UploadInput{
+        Bucket:   aws.String(u.Config.BucketName),
+        Key:      aws.String(s3Key),
+        Body:     fileData,
+        Metadata: u.objectMetadata(),
+    }
+    if u.objectLockEnabled() {
+        input.ObjectLockMode, input.ObjectLockRetainUntilDate, input.ObjectLockLegalHoldStatus = u.objectLockParams()
+    }
+
+    ctx, cancel := u.Config.OperationContextFrom(monitor.RunContext(), config.OperationPut)
+    defer cancel()
+
+    start := time.Now()
+    output, err := manager.UploadWithContext(ctx, input)
+    u.recordResult(clientIndex, err)
+    u.logLatency(clientIndex, s3Key, fileData.Size(), start, err)
+    if err != nil {
+        return uploadedObject{}, err
+    }
+    u.verifyETag(filePath, s3Key, output.ETag, manager.PartSize)
+    return u.uploaded(clientIndex, aws.StringValue(output.ETag)), nil
+}
+
+// uploadFileGeneric uploads a single file through the storage backend interface. It is used
+// for non-S3 storage backends, which support neither multipart uploads nor Object Lock.
+func (u *Uploader) uploadFileGeneric(filePath, s3Key string) (uploadedObject, error) {
+    clientIndex := u.nextClient()
+
+    fileData, err := u.openContent(filePath, s3Key)
+    if err != nil {
+        return uploadedObject{}, err
+    }
+    defer fileData.Close()
+
+    ctx, cancel := u.Config.OperationContextFrom(monitor.RunContext(), config.OperationPut)
+    defer cancel()
+
+    start := time.Now()
+    etag, err := u.Backends[clientIndex].PutObject(ctx, s3Key, fileData, fileData.Size())
+    u.recordResult(clientIndex, err)
+    u.logLatency(clientIndex, s3Key, fileData.Size(), start, err)
+    if err != nil {
+        return uploadedObject{}, err
+    }
+    if etag != "" {
+        u.verifyETag(filePath, s3Key, aws.String(etag), 0)
+    }
+    return u.uploaded(clientIndex, etag), nil
+}
+
+// uploaded describes an upload through the client at clientIndex that returned etag.
+func (u *Uploader) uploaded(clientIndex int, etag string) uploadedObject {
+    return uploadedObject{etag: strings.Trim(etag, "\""), endpoint: u.Backends[clientIndex].Endpoint()}
+}
@@ -0,0 +1,481 @@
+// script/script.go
+package script
+
+import (
+    "bytes"
+    "context"
+    "encoding/csv"
+    "errors"
+    "fmt"
+    "io"
+    "math/rand"
+    "os"
+    "path"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/request"
+    "github.com/aws/aws-sdk-go/service/s3"
+    "go.starlark.net/starlark"
+    "go.starlark.net/syntax"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/progress"
+)
+
+// entryPoint is the function of the script called for every operation.
+const entryPoint = "operation"
+
+// maxSteps is the number of Starlark computation steps after which loading the script or one
+// call of its operation function is stopped, so a runaway script cannot hang a worker.
+const maxSteps = 10_000_000
+
+// Operations the script can return, by their name in the script. Each is timed out like the
+// request type of the same name.
+const (
+    OpPut    = "PUT"
+    OpGet    = "GET"
+    OpHead   = "HEAD"
+    OpDelete = "DELETE"
+    OpList   = "LIST"
+)
+
+// Request is an operation decided by the script.
+type Request struct {
+    Op      string            // One of the Op constants.
+    Key     string            // Object key; the prefix listed by LIST.
+    Size    int64             // Bytes written by PUT.
+    Headers map[string]string // Extra HTTP headers sent with the request.
+}
+
+// Script is a loaded workload script. Its globals are frozen once loaded, so one Script can be
+// called from several workers at the same time.
+type Script struct {
+    Path      string
+    operation starlark.Callable
+}
+
+// Load runs the Starlark file at filePath and returns its operation function. prefix and runID
+// are available to the script as the globals prefix and run_id.
+func Load(filePath, prefix, runID string) (*Script, error) {
+    src, err := os.ReadFile(filePath)
+    if err != nil {
+        return nil, fmt.Errorf("error reading workload script %s: %w", filePath, err)
+    }
+
+    predeclared := starlark.StringDict{
+        "prefix":  starlark.String(prefix),
+        "run_id":  starlark.String(runID),
+        "random":  starlark.NewBuiltin("random", builtinRandom),
+        "randint": starlark.NewBuiltin("randint", builtinRandint),
+        "choice":  starlark.NewBuiltin("choice", builtinChoice),
+    }
+    thread := &starlark.Thread{Name: "load"}
+    thread.SetMaxExecutionSteps(maxSteps)
+    thread.SetLocal("rng", rand.New(rand.NewSource(time.Now().UnixNano())))
+    globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, filePath, src, predeclared)
+    if err != nil {
+        return nil, fmt.Errorf("error loading workload script %s: %w", filePath, scriptError(err))
+    }
+
+    fn, ok := globals[entryPoint].(starlark.Callable)
+    if !ok {
+        return nil, fmt.Errorf("workload script %s must define a function %s(i, worker)", filePath, entryPoint)
+    }
+    return &Script{Path: filePath, operation: fn}, nil
+}
+
+// Next calls the operation function of the script for the i-th operation, issued by worker.
+// It returns false when the script returns None, to stop the worker. rng is the source of the
+// random, randint and choice functions. The call is stopped after maxSteps steps, or when ctx
+// is done.
+func (s *Script) Next(ctx context.Context, i int64, worker int, rng *rand.Rand) (Request, bool, error) {
+    thread := &starlark.Thread{Name: fmt.Sprintf("worker-%d", worker)}
+    thread.SetMaxExecutionSteps(maxSteps)
+    thread.SetLocal("rng", rng)
+    stop := context.AfterFunc(ctx, func() { thread.Cancel(ctx.Err().Error()) })
+    defer stop()
+    v, err := starlark.Call(thread, s.operation, starlark.Tuple{starlark.MakeInt64(i), starlark.MakeInt(worker)}, nil)
+    if err != nil {
+        return Request{}, false, fmt.Errorf("error in %s(%d, %d): %w", entryPoint, i, worker, scriptError(err))
+    }
+    if v == starlark.None {
+        return Request{}, false, nil
+    }
+
+    req, err := decodeRequest(v)
+    if err != nil {
+        return Request{}, false, fmt.Errorf("invalid result of %s(%d, %d): %w", entryPoint, i, worker, err)
+    }
+    return req, true, nil
+}
+
+// decodeRequest converts the dict returned by the script into a Request.
+func decodeRequest(v starlark.Value) (Request, error) {
+    dict, ok := v.(*starlark.Dict)
+    if !ok {
+        return Request{}, fmt.Errorf("got %s, want dict or None", v.Type())
+    }
+
+    var req Request
+    for _, item := range dict.Items() {
+        name, ok := starlark.AsString(item[0])
+        if !ok {
+            return Request{}, fmt.Errorf("got %s key, want string", item[0].Type())
+        }
+        switch name {
+        case "op":
+            op, ok := starlark.AsString(item[1])
+            if !ok {
+                return Request{}, fmt.Errorf("op: got %s, want string", item[1].Type())
+            }
+            req.Op = strings.ToUpper(op)
+        case "key":
+            key, ok := starlark.AsString(item[1])
+            if !ok {
+                return Request{}, fmt.Errorf("key: got %s, want string", item[1].Type())
+            }
+            req.Key = key
+        case "size":
+            if err := starlark.AsInt(item[1], &req.Size); err != nil {
+                return Request{}, fmt.Errorf("size: %w", err)
+            }
+        case "headers":
+            headers, ok := item[1].(*starlark.Dict)
+            if !ok {
+                return Request{}, fmt.Errorf("headers: got %s, want dict", item[1].Type())
+            }
+            req.Headers = make(map[string]string, headers.Len())
+            for _, h := range headers.Items() {
+                k, kok := starlark.AsString(h[0])
+                v, vok := starlark.AsString(h[1])
+                if !kok || !vok {
+                    return Request{}, fmt.Errorf("headers: got %s: %s, want string: string", h[0].Type(), h[1].Type())
+                }
+                req.Headers[k] = v
+            }
+        default:
+            return Request{}, fmt.Errorf("unknown field %q", name)
+        }
+    }
+
+    switch req.Op {
+    case OpPut, OpGet, OpHead, OpDelete, OpList:
+    case "STAT":
+        req.Op = OpHead
+    case "":
+        return Request{}, errors.New("op is missing")
+    default:
+        return Request{}, fmt.Errorf("unknown op %q, want PUT, GET, HEAD, DELETE or LIST", req.Op)
+    }
+    if req.Key == "" && req.Op != OpList {
+        return Request{}, errors.New("key is missing")
+    }
+    if req.Size < 0 {
+        return Request{}, fmt.Errorf("size must not be negative, current: %d", req.Size)
+    }
+    return req, nil
+}
+
+// scriptError adds the Starlark backtrace to errors raised by the script.
+func scriptError(err error) error {
+    var evalErr *starlark.EvalError
+    if errors.As(err, &evalErr) {
+        return errors.New(evalErr.Backtrace())
+    }
+    return err
+}
+
+// threadRand returns the random source of the worker running the script.
+func threadRand(thread *starlark.Thread) *rand.Rand {
+    return thread.Local("rng").(*rand.Rand)
+}
+
+// builtinRandom implements random(): a float in [0, 1).
+func builtinRandom(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 0); err != nil {
+        return nil, err
+    }
+    return starlark.Float(threadRand(thread).Float64()), nil
+}
+
+// builtinRandint implements randint(a, b): an int in [a, b].
+func builtinRandint(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    var lo, hi int64
+    if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &lo, &hi); err != nil {
+        return nil, err
+    }
+    if hi < lo {
+        return nil, fmt.Errorf("%s: empty range [%d, %d]", b.Name(), lo, hi)
+    }
+    return starlark.MakeInt64(lo + threadRand(thread).Int63n(hi-lo+1)), nil
+}
+
+// builtinChoice implements choice(seq): a random element of a non-empty sequence.
+func builtinChoice(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+    var seq starlark.Indexable
+    if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &seq); err != nil {
+        return nil, err
+    }
+    if seq.Len() == 0 {
+        return nil, fmt.Errorf("%s: empty sequence", b.Name())
+    }
+    return seq.Index(threadRand(thread).Intn(seq.Len())), nil
+}
+
+// Operation is the outcome of the requests of one operation type.
+type Operation struct {
+    Name      string
+    Requests  int64
+    Errors    int64
+    Bytes     int64           // Written by PUT, read by GET.
+    Latencies stats.Latencies // Times of the successful requests.
+}
+
+// Percentile returns the p-th fraction (0-1) of the request times.
+func (o *Operation) Percentile(q float64) time.Duration {
+    return o.Latencies.Percentile(q)
+}
+
+// Average returns the mean request time.
+func (o *Operation) Average() time.Duration {
+    return o.Latencies.Average()
+}
+
+// Result is the outcome of a scripted workload.
+type Result struct {
+    RunID      string
+    Script     string
+    Prefix     string
+    Operations []Operation // In alphabetical order.
+    Elapsed    time.Duration
+}
+
+// Failures returns the failed requests over all operations.
+func (r Result) Failures() int64 {
+    var failures int64
+    for _, o := range r.Operations {
+        failures += o.Errors
+    }
+    return failures
+}
+
+// Run loads workloadScript and issues the requests it decides with scriptConcurrency workers,
+// until scriptOperations operations were issued, scriptDurationSeconds have passed or every
+// worker got None from the script. A script error stops the workload and is returned with the
+// result so far.
+func Run(cfg *config.Config, s3Clients []*s3.S3) (Result, error) {
+    runID := cfg.NewRunID()
+    prefix := path.Join(cfg.ScriptPrefix, runID)
+    result := Result{RunID: runID, Script: cfg.WorkloadScript, Prefix: prefix}
+
+    sc, err := Load(cfg.WorkloadScript, prefix, runID)
+    if err != nil {
+        return result, err
+    }
+
+    fmt.Printf("Running %s with %d workers for %ds...\n", cfg.WorkloadScript, cfg.ScriptConcurrency, cfg.ScriptDurationSeconds)
+    task := progress.Begin("Scripted workload", "ops", cfg.ScriptOperations)
+    defer task.Done()
+
+    ctx, cancel := context.WithTimeout(monitor.RunContext(), time.Duration(cfg.ScriptDurationSeconds)*time.Second)
+    defer cancel()
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    var scriptErr error
+    ops := make(map[string]*Operation)
+
+    start := time.Now()
+    for w := 0; w < cfg.ScriptConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            rng := cfg.Rand(fmt.Sprintf("script/%d", w))
+            var payload []byte
+
+            for ctx.Err() == nil {
+                i := atomic.AddInt64(&next, 1) - 1
+                if cfg.ScriptOperations > 0 && i >= cfg.ScriptOperations {
+                    return
+                }
+
+                req, ok, err := sc.Next(ctx, i, w, rng)
+                if err != nil && ctx.Err() != nil {
+                    return
+                }
+                if err != nil {
+                    mu.Lock()
+                    if scriptErr == nil {
+                        scriptErr = err
+                    }
+                    mu.Unlock()
+                    cancel()
+                    return
+                }
+                if !ok {
+                    return
+                }
+
+                if int64(len(payload)) < req.Size {
+                    payload = make([]byte, req.Size)
+                    rng.Read(payload)
+                }
+                n, latency, err := issue(ctx, cfg, client, req, payload)
+                if ctx.Err() != nil && err != nil {
+                    // Cut short by the end of the workload, not a failure of the request.
+                    return
+                }
+
+                mu.Lock()
+                o := ops[req.Op]
+                if o == nil {
+                    o = &Operation{Name: req.Op}
+                    ops[req.Op] = o
+                }
+                o.Requests++
+                if err != nil {
+                    o.Errors++
+                } else {
+                    o.Bytes += n
+                    o.Latencies.Add(latency)
+                }
+                mu.Unlock()
+
+                if err != nil {
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error in %s %s: %v\n", req.Op, req.Key, err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+    result.Elapsed = time.Since(start)
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further request errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    for _, o := range ops {
+        result.Operations = append(result.Operations, *o)
+    }
+    sort.Slice(result.Operations, func(i, j int) bool { return result.Operations[i].Name < result.Operations[j].Name })
+    return result, scriptErr
+}
+
+// issue sends req and returns the bytes written or read and the time it took. PUT bodies are
+// the first Size bytes of payload.
+func issue(ctx context.Context, cfg *config.Config, client *s3.S3, req Request, payload []byte) (int64, time.Duration, error) {
+    opCtx, opCancel := cfg.OperationContext(req.Op)
+    defer opCancel()
+    // The workload deadline ends requests in flight as well.
+    stop := context.AfterFunc(ctx, opCancel)
+    defer stop()
+
+    headers := func(r *request.Request) {
+        for k, v := range req.Headers {
+            r.HTTPRequest.Header.Set(k, v)
+        }
+    }
+    bucket, key := aws.String(cfg.BucketName), aws.String(req.Key)
+
+    start := time.Now()
+    switch req.Op {
+    case OpPut:
+        _, err := client.PutObjectWithContext(opCtx, &s3.PutObjectInput{
+            Bucket:        bucket,
+            Key:           key,
+            Body:          bytes.NewReader(payload[:req.Size]),
+            ContentLength: aws.Int64(req.Size),
+        }, headers)
+        return req.Size, time.Since(start), err
+    case OpGet:
+        output, err := client.GetObjectWithContext(opCtx, &s3.GetObjectInput{Bucket: bucket, Key: key}, headers)
+        if err != nil {
+            return 0, time.Since(start), err
+        }
+        defer output.Body.Close()
+        n, err := io.Copy(io.Discard, output.Body)
+        return n, time.Since(start), err
+    case OpHead:
+        _, err := client.HeadObjectWithContext(opCtx, &s3.HeadObjectInput{Bucket: bucket, Key: key}, headers)
+        return 0, time.Since(start), err
+    case OpDelete:
+        _, err := client.DeleteObjectWithContext(opCtx, &s3.DeleteObjectInput{Bucket: bucket, Key: key}, headers)
+        return 0, time.Since(start), err
+    case OpList:
+        _, err := client.ListObjectsV2WithContext(opCtx, &s3.ListObjectsV2Input{Bucket: bucket, Prefix: key}, headers)
+        return 0, time.Since(start), err
+    }
+    return 0, 0, fmt.Errorf("unknown operation %s", req.Op)
+}
+
+// PrintReport prints the requests, errors, latencies and throughput of every operation type the
+// script issued.
+func PrintReport(r Result) {
+    fmt.Println("\nScripted Workload Report:")
+    fmt.Println("=========================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Script: %s\n", r.Script)
+    fmt.Printf("Prefix: %s\n", r.Prefix)
+    fmt.Printf("Duration: %v\n", r.Elapsed.Round(time.Millisecond))
+
+    fmt.Printf("\n%-8s %10s %8s %10s %10s %10s %10s %10s %10s\n", "Op", "Requests", "Errors", "Avg", "P50", "P90", "P99", "Ops/sec", "MB/s")
+    for _, o := range r.Operations {
+        opsPerSec, mbps := 0.0, 0.0
+        if r.Elapsed > 0 {
+            opsPerSec = float64(o.Requests-o.Errors) / r.Elapsed.Seconds()
+            mbps = float64(o.Bytes) / (1024 * 1024) / r.Elapsed.Seconds()
+        }
+        fmt.Printf("%-8s %10d %8d %10v %10v %10v %10v %10.2f %10.2f\n", o.Name, o.Requests, o.Errors,
+            o.Average().Round(time.Microsecond), o.Percentile(0.50).Round(time.Microsecond), o.Percentile(0.90).Round(time.Microsecond),
+            o.Percentile(0.99).Round(time.Microsecond), opsPerSec, mbps)
+    }
+    fmt.Println("=========================")
+}
+
+// WriteCSV writes the outcome of every operation type to a CSV file.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating script report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Operation", "Requests", "Errors", "Bytes", "AvgMs", "P50Ms", "P90Ms", "P99Ms", "OpsPerSec"})
+    for _, o := range r.Operations {
+        opsPerSec := 0.0
+        if r.Elapsed > 0 {
+            opsPerSec = float64(o.Requests-o.Errors) / r.Elapsed.Seconds()
+        }
+        writer.Write([]string{
+            o.Name,
+            strconv.FormatInt(o.Requests, 10),
+            strconv.FormatInt(o.Errors, 10),
+            strconv.FormatInt(o.Bytes, 10),
+            stats.Milliseconds(o.Average()),
+            stats.Milliseconds(o.Percentile(0.50)),
+            stats.Milliseconds(o.Percentile(0.90)),
+            stats.Milliseconds(o.Percentile(0.99)),
+            fmt.Sprintf("%.3f", opsPerSec),
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing script report %s: %w", filePath, err)
+    }
+    return nil
+}
@@ -0,0 +1,150 @@
+// state.go
+package main
+
+import (
+    "fmt"
+    "path/filepath"
+    "sync"
+
+    "scale_s3_benchmark/benchmark"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/latencylog"
+    "scale_s3_benchmark/manifest"
+    "scale_s3_benchmark/monitor"
+    "scale_s3_benchmark/results"
+    "scale_s3_benchmark/s3upload"
+)
+
+// runArtifacts holds the outputs that must be flushed if the run ends abnormally.
+var runArtifacts struct {
+    sync.Mutex
+    cfg      *config.Config
+    manifest *manifest.Writer
+    uploader *s3upload.Uploader
+    dumped   bool
+}
+
+// failRun reports a fatal run error, writes out whatever artifacts exist and returns the error.
+func failRun(format string, args ...interface{}) error {
+    err := fmt.Errorf(format, args...)
+    fmt.Println(err)
+    monitor.SetPhase(monitor.PhaseFailed)
+    dumpState(err.Error())
+    return err
+}
+
+// recoverAndDump writes out the run artifacts when a panic unwinds the calling goroutine,
+// then re-panics so the original stack trace is still printed.
+func recoverAndDump() {
+    if r := recover(); r != nil {
+        dumpState(fmt.Sprintf("panic: %v", r))
+        panic(r)
+    }
+}
+
+// dumpState flushes the manifest, the failures file and the latency log, writes a JSON state dump and prints a partial report.
+// It only runs once, no matter how many goroutines fail.
+func dumpState(reason string) {
+    runArtifacts.Lock()
+    defer runArtifacts.Unlock()
+
+    if runArtifacts.dumped || runArtifacts.cfg == nil {
+        return
+    }
+    runArtifacts.dumped = true
+
+    if runArtifacts.manifest != nil {
+        if err := runArtifacts.manifest.Flush(); err != nil {
+            fmt.Printf("Error flushing manifest: %v\n", err)
+        }
+    }
+    if runArtifacts.uploader != nil && runArtifacts.uploader.Failures != nil {
+        if err := runArtifacts.uploader.Failures.Flush(); err != nil {
+            fmt.Printf("Error flushing failures file: %v\n", err)
+        }
+    }
+
+    if err := latencylog.Close(); err != nil {
+        fmt.Println(err)
+    }
+
+    if err := monitor.WriteStateDump(runArtifacts.cfg.StateDumpPath, reason); err != nil {
+        fmt.Printf("Error writing state dump: %v\n", err)
+    } else {
+        fmt.Printf("State written to %s\n", runArtifacts.cfg.StateDumpPath)
+    }
+
+    benchmark.GenerateFinalReport(benchmark.BenchmarkResult{})
+    saveRunRecord(runArtifacts.cfg, monitor.PhaseFailed, benchmark.BenchmarkResult{})
+}
+
+// saveRunRecord stores the outcome of the run in the results directory for the run history.
+// It also writes the JUnit report, publishes the run_finished event with the run summary, posts
+// the summary to runWebhookURL and sends the reports to reportOutput, if configured.
+func saveRunRecord(cfg *config.Config, status string, result benchmark.BenchmarkResult) {
+    rec := results.NewRecord(cfg, status, result)
+    recordPath := filepath.Join(cfg.ResultsDir, rec.ID+".json")
+    if err := results.Save(cfg.ResultsDir, rec); err != nil {
+        fmt.Printf("Error saving run record: %v\n", err)
+        recordPath = ""
+    }
+    if cfg.JUnitReportPath != "" {
+        checks := results.CheckSLA(cfg, rec)
+        results.PrintSLA(checks)
+        if err := results.SaveJUnit(cfg.JUnitReportPath, rec, checks); err != nil {
+            fmt.Println(err)
+        } else {
+            fmt.Printf("JUnit report written to %s\n", cfg.JUnitReportPath)
+        }
+    }
+    writeRunDescriptor(cfg, status, recordPath)
+    monitor.PublishEvent(monitor.EventRunFinished, rec.Summary())
+
+    if cfg.RunWebhookURL != "" {
+        if err := results.PostRunNotice(cfg, rec); err != nil {
+            fmt.Printf("%v\n", err)
+        }
+    }
+
+    switch {
+    case cfg.ReportOutput == config.ReportOutputStdout:
+        if err := results.PrintRecord(rec); err != nil {
+            fmt.Println(err)
+        }
+    case cfg.ReportOutput != "":
+        uploadReports(cfg, rec, runDescriptor(cfg, status, recordPath))
+    }
+}
+
+// uploadReports uploads the artifacts of the finished run and its HTML report to the bucket of
+// reportOutput, so nothing needs to be copied out of the container.
+func uploadReports(cfg *config.Config, rec results.Record, d results.Descriptor) {
+    s3Clients, err := s3upload.InitializeUntracedS3Clients(cfg)
+    if err != nil {
+        fmt.Printf("Error uploading reports: %v\n", err)
+        return
+    }
+    bucket, _, _ := cfg.ReportBucket()
+    keyPrefix, err := results.UploadArtifacts(cfg, s3Clients[0], rec, d)
+    if err != nil {
+        fmt.Println(err)
+        return
+    }
+    fmt.Printf("Reports uploaded to s3://%s/%s\n", bucket, keyPrefix)
+}
+
+// writeRunDescriptor writes the run descriptor of the current run to runDescriptorPath, if
+// configured, with the given status and the path of the run record, once there is one.
+func writeRunDescriptor(cfg *config.Config, status, recordPath string) {
+    if cfg.RunDescriptorPath == "" {
+        return
+    }
+    if err := results.WriteDescriptor(cfg.RunDescriptorPath, runDescriptor(cfg, status, recordPath)); err != nil {
+        fmt.Println(err)
+    }
+}
+
+// runDescriptor describes the current run with the given status and run record path.
+func runDescriptor(cfg *config.Config, status, recordPath string) results.Descriptor {
+    return results.NewDescriptor(cfg, monitor.RunID(), status, monitor.GetStats().StartTime, recordPath)
+}
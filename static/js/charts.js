@@ -236,6 +236,123 @@ function updateRateCharts(currentTime) {
     uploadsPerHourChart.update();
 }
 
+// Painéis por endpoint, indexados pelo endpoint.
+let endpointPanels = {};
+
+// Cria o painel (valores e gráfico) de um endpoint.
+function createEndpointPanel(endpoint) {
+    let panel = document.createElement('div');
+    panel.className = 'endpoint-panel';
+
+    let title = document.createElement('h3');
+    title.textContent = endpoint;
+    panel.appendChild(title);
+
+    let values = document.createElement('p');
+    panel.appendChild(values);
+
+    let container = document.createElement('div');
+    container.className = 'chart-container endpoint-chart';
+    let canvas = document.createElement('canvas');
+    container.appendChild(canvas);
+    panel.appendChild(container);
+
+    document.getElementById('endpoints').appendChild(panel);
+
+    let chart = new Chart(canvas.getContext('2d'), {
+        type: 'line',
+        data: {
+            labels: [],
+            datasets: [
+                {
+                    label: 'Requisições/s',
+                    data: [],
+                    borderColor: 'rgba(54, 162, 235, 1)',
+                    borderWidth: 1,
+                    fill: false,
+                    yAxisID: 'rate'
+                },
+                {
+                    label: 'p99 (ms)',
+                    data: [],
+                    borderColor: 'rgba(255, 159, 64, 1)',
+                    borderWidth: 1,
+                    fill: false,
+                    yAxisID: 'latency'
+                },
+                {
+                    label: 'Erros (%)',
+                    data: [],
+                    borderColor: 'rgba(255, 99, 132, 1)',
+                    borderWidth: 1,
+                    fill: false,
+                    yAxisID: 'latency'
+                }
+            ]
+        },
+        options: {
+            responsive: true,
+            maintainAspectRatio: false,
+            scales: {
+                x: {
+                    type: 'time',
+                    time: {
+                        unit: 'minute',
+                        tooltipFormat: 'HH:mm:ss'
+                    }
+                },
+                rate: {
+                    position: 'left',
+                    beginAtZero: true,
+                    title: {
+                        display: true,
+                        text: 'Requisições/s'
+                    }
+                },
+                latency: {
+                    position: 'right',
+                    beginAtZero: true,
+                    grid: { drawOnChartArea: false },
+                    title: {
+                        display: true,
+                        text: 'ms / %'
+                    }
+                }
+            }
+        }
+    });
+
+    endpointPanels[endpoint] = { panel: panel, values: values, chart: chart };
+    return endpointPanels[endpoint];
+}
+
+// Atualiza os painéis com as estatísticas por endpoint.
+function updateEndpoints(endpoints) {
+    const now = new Date();
+    const maxDataPoints = 60;
+
+    endpoints.forEach(ep => {
+        let entry = endpointPanels[ep.Endpoint] || createEndpointPanel(ep.Endpoint);
+
+        const p99Ms = ep.P99 / 1e6; // time.Duration em nanossegundos
+        const errorPct = ep.ErrorRate * 100;
+
+        entry.values.textContent = 'Taxa: ' + ep.Rate.toFixed(2) + ' req/s | Erros: ' + errorPct.toFixed(2) +
+            '% | p99: ' + p99Ms.toFixed(1) + ' ms | Circuito: ' + ep.CircuitState;
+        entry.panel.classList.toggle('circuit-open', ep.CircuitState !== 'closed');
+
+        entry.chart.data.labels.push(now);
+        entry.chart.data.datasets[0].data.push(ep.Rate);
+        entry.chart.data.datasets[1].data.push(p99Ms);
+        entry.chart.data.datasets[2].data.push(errorPct);
+        if (entry.chart.data.labels.length > maxDataPoints) {
+            entry.chart.data.labels.shift();
+            entry.chart.data.datasets.forEach(dataset => dataset.data.shift());
+        }
+        entry.chart.update();
+    });
+}
+
 // Conectar ao SSE para atualizações em tempo real.
 if (!!window.EventSource) {
     let source = new EventSource('/events');
@@ -245,6 +362,10 @@ if (!!window.EventSource) {
         updateStats(data);
     };
 
+    source.addEventListener('endpoints', function(event) {
+        updateEndpoints(JSON.parse(event.data));
+    });
+
     source.onerror = function(err) {
         console.error("Falha no EventSource:", err);
         source.close();
@@ -0,0 +1,183 @@
+// static/js/history.js
+
+// Go serializa time.Duration em nanossegundos.
+const NS_PER_MS = 1e6;
+
+let comparisonCharts = [];
+
+// Formata uma duração em nanossegundos como milissegundos.
+function formatMs(ns) {
+    return (ns / NS_PER_MS).toFixed(2) + ' ms';
+}
+
+// Carrega a lista de execuções e preenche a tabela.
+async function loadRuns() {
+    const response = await fetch('/api/runs');
+    if (!response.ok) {
+        console.error('Falha ao carregar execuções:', await response.text());
+        return;
+    }
+    const runs = await response.json();
+
+    const tbody = document.querySelector('#runs-table tbody');
+    tbody.innerHTML = '';
+    runs.forEach(run => {
+        const row = document.createElement('tr');
+
+        const selectCell = document.createElement('td');
+        const checkbox = document.createElement('input');
+        checkbox.type = 'checkbox';
+        checkbox.value = run.ID;
+        checkbox.addEventListener('click', event => event.stopPropagation());
+        checkbox.addEventListener('change', updateCompareButton);
+        selectCell.appendChild(checkbox);
+        row.appendChild(selectCell);
+
+        [
+            run.ID,
+            run.RunID || '-',
+            new Date(run.StartedAt).toLocaleString(),
+            run.Status,
+            run.Bucket,
+            run.Uploads,
+            run.Failures,
+            run.UploadRate.toFixed(2)
+        ].forEach(value => {
+            const cell = document.createElement('td');
+            cell.textContent = value;
+            row.appendChild(cell);
+        });
+
+        row.addEventListener('click', () => showReport(run.ID));
+        tbody.appendChild(row);
+    });
+}
+
+// Retorna os IDs das execuções marcadas.
+function selectedRuns() {
+    return Array.from(document.querySelectorAll('#runs-table input:checked')).map(cb => cb.value);
+}
+
+// Habilita o botão de comparação somente com exatamente duas execuções marcadas.
+function updateCompareButton() {
+    document.getElementById('compare-button').disabled = selectedRuns().length !== 2;
+}
+
+// Busca o registro completo de uma execução.
+async function fetchRun(id) {
+    const response = await fetch('/api/runs/' + encodeURIComponent(id));
+    if (!response.ok) {
+        throw new Error(await response.text());
+    }
+    return response.json();
+}
+
+// Mostra o relatório de uma execução.
+async function showReport(id) {
+    let run;
+    try {
+        run = await fetchRun(id);
+    } catch (err) {
+        console.error('Falha ao carregar execução:', err);
+        return;
+    }
+
+    const stats = run.State.Stats;
+    const lines = [
+        'Run ID: ' + (run.RunID || '-'),
+        'Status: ' + run.Status + (run.State.AbortReason ? ' (' + run.State.AbortReason + ')' : ''),
+        'Started: ' + new Date(run.StartedAt).toLocaleString(),
+        'Finished: ' + new Date(run.FinishedAt).toLocaleString(),
+        'Bucket: ' + run.Bucket,
+        'Endpoints: ' + (run.Endpoints || []).join(', '),
+        'Total Uploads: ' + stats.TotalUploads,
+        'Successes: ' + stats.Successes,
+        'Failures: ' + stats.Failures,
+        'Skipped: ' + stats.Skipped,
+        'Subfolders: ' + (run.State.Folders || []).length,
+        'Benchmarking Duration: ' + formatMs(run.BenchmarkDuration)
+    ];
+    if (run.State.Integrity.Checked > 0) {
+        lines.push('ETags Checked: ' + run.State.Integrity.Checked + ', Integrity Failures: ' + run.State.Integrity.Mismatches);
+    }
+
+    document.getElementById('report-id').textContent = run.ID;
+    document.querySelectorAll('#report-downloads a').forEach(link => {
+        link.href = '/api/report?format=' + link.dataset.format + '&id=' + encodeURIComponent(run.ID);
+    });
+    document.getElementById('report-summary').textContent = lines.join('\n');
+
+    const tbody = document.querySelector('#report-operations tbody');
+    tbody.innerHTML = '';
+    Object.keys(run.Benchmark || {}).sort().forEach(op => {
+        const m = run.Benchmark[op];
+        const row = document.createElement('tr');
+        [op, m.Operations, m.Errors, formatMs(m.MinTime), formatMs(m.AvgTime), formatMs(m.MaxTime)].forEach(value => {
+            const cell = document.createElement('td');
+            cell.textContent = value;
+            row.appendChild(cell);
+        });
+        tbody.appendChild(row);
+    });
+
+    document.getElementById('report').hidden = false;
+}
+
+// Cria um gráfico de barras comparando duas execuções.
+function barChart(canvasId, labels, runs, valueOf) {
+    const colors = ['rgba(54, 162, 235, 0.6)', 'rgba(255, 99, 132, 0.6)'];
+    return new Chart(document.getElementById(canvasId).getContext('2d'), {
+        type: 'bar',
+        data: {
+            labels: labels,
+            datasets: runs.map((run, i) => ({
+                label: run.ID,
+                data: labels.map(label => valueOf(run, label)),
+                backgroundColor: colors[i]
+            }))
+        },
+        options: {
+            responsive: true,
+            maintainAspectRatio: false,
+            scales: { y: { beginAtZero: true } }
+        }
+    });
+}
+
+// Compara as duas execuções marcadas.
+async function compareRuns() {
+    let runs;
+    try {
+        runs = await Promise.all(selectedRuns().map(fetchRun));
+    } catch (err) {
+        console.error('Falha ao carregar execuções:', err);
+        return;
+    }
+
+    comparisonCharts.forEach(chart => chart.destroy());
+
+    const operations = Array.from(new Set(runs.flatMap(run => Object.keys(run.Benchmark || {})))).sort();
+    const metric = (run, op) => (run.Benchmark || {})[op];
+
+    const folderCount = Math.max(...runs.map(run => (run.State.Folders || []).length));
+    const folderLabels = Array.from({ length: folderCount }, (_, i) => String(i));
+    const folderThroughput = (run, label) => {
+        const folder = (run.State.Folders || []).find(f => String(f.Index) === label);
+        if (!folder || folder.Duration <= 0) {
+            return null;
+        }
+        return folder.Successes / (folder.Duration / 1e9);
+    };
+
+    comparisonCharts = [
+        barChart('latencyChart', operations, runs, (run, op) => metric(run, op) ? metric(run, op).AvgTime / NS_PER_MS : null),
+        barChart('operationsChart', operations, runs, (run, op) => metric(run, op) ? metric(run, op).Operations : null),
+        barChart('folderChart', folderLabels, runs, folderThroughput)
+    ];
+
+    document.getElementById('comparison-ids').textContent = runs.map(run => run.ID).join(' vs ');
+    document.getElementById('comparison').hidden = false;
+}
+
+document.getElementById('compare-button').addEventListener('click', compareRuns);
+loadRuns();
@@ -0,0 +1,105 @@
+// storage/azure.go
+package storage
+
+import (
+    "context"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net/http"
+
+    "github.com/Azure/azure-sdk-for-go/sdk/azcore"
+    "github.com/Azure/azure-sdk-for-go/sdk/azcore/streaming"
+    "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+    "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+    "github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// AzureBackend is a Backend for Azure Blob Storage. The bucket is a blob container.
+type AzureBackend struct {
+    endpoint  string
+    container *container.Client
+}
+
+// AzureServiceURL returns the public Blob service URL of a storage account.
+func AzureServiceURL(accountName string) string {
+    return fmt.Sprintf("https://%s.blob.core.windows.net/", accountName)
+}
+
+// NewAzure creates an Azure Blob Storage backend authenticated with a shared account key.
+// An empty serviceURL selects https://<account>.blob.core.windows.net/. Requests are sent
+// through httpClient, so the tuned and instrumented transport is used.
+func NewAzure(serviceURL, containerName, accountName, accountKey string, httpClient *http.Client) (*AzureBackend, error) {
+    if serviceURL == "" {
+        serviceURL = AzureServiceURL(accountName)
+    }
+
+    cred, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+    if err != nil {
+        return nil, fmt.Errorf("error creating Azure credential: %w", err)
+    }
+
+    client, err := azblob.NewClientWithSharedKeyCredential(serviceURL, cred, &azblob.ClientOptions{
+        ClientOptions: azcore.ClientOptions{Transport: httpClient},
+    })
+    if err != nil {
+        return nil, fmt.Errorf("error creating Azure client for %s: %w", serviceURL, err)
+    }
+
+    return &AzureBackend{
+        endpoint:  serviceURL,
+        container: client.ServiceClient().NewContainerClient(containerName),
+    }, nil
+}
+
+// Endpoint implements Backend.
+func (b *AzureBackend) Endpoint() string {
+    return b.endpoint
+}
+
+// PutObject implements Backend.
+func (b *AzureBackend) PutObject(ctx context.Context, key string, body io.ReadSeeker, size int64) (string, error) {
+    resp, err := b.container.NewBlockBlobClient(key).Upload(ctx, streaming.NopCloser(body), nil)
+    if err != nil {
+        return "", err
+    }
+    if len(resp.ContentMD5) > 0 {
+        return `"` + hex.EncodeToString(resp.ContentMD5) + `"`, nil
+    }
+    return "", nil
+}
+
+// GetObject implements Backend.
+func (b *AzureBackend) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+    resp, err := b.container.NewBlobClient(key).DownloadStream(ctx, nil)
+    if err != nil {
+        return nil, azureNotFound(err)
+    }
+    return resp.Body, nil
+}
+
+// HeadObject implements Backend.
+func (b *AzureBackend) HeadObject(ctx context.Context, key string) (int64, error) {
+    props, err := b.container.NewBlobClient(key).GetProperties(ctx, nil)
+    if err != nil {
+        return 0, azureNotFound(err)
+    }
+    if props.ContentLength == nil {
+        return 0, nil
+    }
+    return *props.ContentLength, nil
+}
+
+// DeleteObject implements Backend.
+func (b *AzureBackend) DeleteObject(ctx context.Context, key string) error {
+    _, err := b.container.NewBlobClient(key).Delete(ctx, nil)
+    return err
+}
+
+// azureNotFound converts a missing-blob error into ErrNotFound.
+func azureNotFound(err error) error {
+    if bloberror.HasCode(err, bloberror.BlobNotFound) {
+        return ErrNotFound
+    }
+    return err
+}
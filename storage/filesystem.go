@@ -0,0 +1,127 @@
+// storage/filesystem.go
+package storage
+
+import (
+    "context"
+    "crypto/md5"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+)
+
+// FilesystemBackend is a Backend that stores objects as files under a directory, such as
+// an NFS mount or a local disk. It gives a baseline for the same workload without an
+// object gateway in the path. The bucket is a subdirectory of the root and each key
+// maps to a file below it.
+type FilesystemBackend struct {
+    root string
+    dir  string
+}
+
+// NewFilesystem creates a filesystem backend storing objects under root/bucket.
+// The root must be an existing directory; the bucket directory is created if missing.
+func NewFilesystem(root, bucket string) (*FilesystemBackend, error) {
+    info, err := os.Stat(root)
+    if err != nil {
+        return nil, fmt.Errorf("error reading filesystem root %s: %w", root, err)
+    }
+    if !info.IsDir() {
+        return nil, fmt.Errorf("filesystem root %s is not a directory", root)
+    }
+
+    dir := filepath.Join(root, bucket)
+    if err := os.MkdirAll(dir, 0755); err != nil {
+        return nil, fmt.Errorf("error creating bucket directory %s: %w", dir, err)
+    }
+    return &FilesystemBackend{root: root, dir: dir}, nil
+}
+
+// Endpoint implements Backend.
+func (b *FilesystemBackend) Endpoint() string {
+    return b.root
+}
+
+// path returns the file that holds the object stored under key.
+func (b *FilesystemBackend) path(key string) string {
+    return filepath.Join(b.dir, filepath.FromSlash(key))
+}
+
+// PutObject implements Backend. The file is synced before returning so a write is only
+// acknowledged once it is durable, as with an object store.
+func (b *FilesystemBackend) PutObject(ctx context.Context, key string, body io.ReadSeeker, size int64) (string, error) {
+    if err := ctx.Err(); err != nil {
+        return "", err
+    }
+
+    path := b.path(key)
+    if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+        return "", fmt.Errorf("error creating directory for %s: %w", key, err)
+    }
+
+    f, err := os.Create(path)
+    if err != nil {
+        return "", err
+    }
+
+    hash := md5.New()
+    if _, err := io.Copy(io.MultiWriter(f, hash), body); err != nil {
+        f.Close()
+        return "", err
+    }
+    if err := f.Sync(); err != nil {
+        f.Close()
+        return "", err
+    }
+    if err := f.Close(); err != nil {
+        return "", err
+    }
+    return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+// GetObject implements Backend.
+func (b *FilesystemBackend) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    f, err := os.Open(b.path(key))
+    if err != nil {
+        return nil, filesystemNotFound(err)
+    }
+    return f, nil
+}
+
+// HeadObject implements Backend.
+func (b *FilesystemBackend) HeadObject(ctx context.Context, key string) (int64, error) {
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+    info, err := os.Stat(b.path(key))
+    if err != nil {
+        return 0, filesystemNotFound(err)
+    }
+    return info.Size(), nil
+}
+
+// DeleteObject implements Backend. Deleting a missing file succeeds, as it does in S3.
+func (b *FilesystemBackend) DeleteObject(ctx context.Context, key string) error {
+    if err := ctx.Err(); err != nil {
+        return err
+    }
+    err := os.Remove(b.path(key))
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil
+    }
+    return err
+}
+
+// filesystemNotFound converts a missing-file error into ErrNotFound.
+func filesystemNotFound(err error) error {
+    if errors.Is(err, fs.ErrNotExist) {
+        return ErrNotFound
+    }
+    return err
+}
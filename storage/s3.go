@@ -0,0 +1,81 @@
+// storage/s3.go
+package storage
+
+import (
+    "context"
+    "io"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Backend is a Backend for S3 and S3-compatible endpoints.
+type S3Backend struct {
+    Client *s3.S3 // Exposed for S3-only features such as multipart uploads and Object Lock.
+    Bucket string
+}
+
+// NewS3 wraps an S3 client as a Backend for the given bucket.
+func NewS3(client *s3.S3, bucket string) *S3Backend {
+    return &S3Backend{Client: client, Bucket: bucket}
+}
+
+// Endpoint implements Backend.
+func (b *S3Backend) Endpoint() string {
+    return b.Client.Endpoint
+}
+
+// PutObject implements Backend.
+func (b *S3Backend) PutObject(ctx context.Context, key string, body io.ReadSeeker, size int64) (string, error) {
+    output, err := b.Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+        Bucket: aws.String(b.Bucket),
+        Key:    aws.String(key),
+        Body:   body,
+    })
+    if err != nil {
+        return "", err
+    }
+    return aws.StringValue(output.ETag), nil
+}
+
+// GetObject implements Backend.
+func (b *S3Backend) GetObject(ctx context.Context, key string) (io.ReadCloser, error) {
+    output, err := b.Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+        Bucket: aws.String(b.Bucket),
+        Key:    aws.String(key),
+    })
+    if err != nil {
+        return nil, s3NotFound(err)
+    }
+    return output.Body, nil
+}
+
+// HeadObject implements Backend.
+func (b *S3Backend) HeadObject(ctx context.Context, key string) (int64, error) {
+    output, err := b.Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+        Bucket: aws.String(b.Bucket),
+        Key:    aws.String(key),
+    })
+    if err != nil {
+        return 0, s3NotFound(err)
+    }
+    return aws.Int64Value(output.ContentLength), nil
+}
+
+// DeleteObject implements Backend.
+func (b *S3Backend) DeleteObject(ctx context.Context, key string) error {
+    _, err := b.Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+        Bucket: aws.String(b.Bucket),
+        Key:    aws.String(key),
+    })
+    return err
+}
+
+// s3NotFound converts a 404 response into ErrNotFound.
+func s3NotFound(err error) error {
+    if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == 404 {
+        return ErrNotFound
+    }
+    return err
+}
@@ -0,0 +1,31 @@
+// storage/storage.go
+package storage
+
+import (
+    "context"
+    "errors"
+    "io"
+)
+
+// ErrNotFound is returned by HeadObject and GetObject when the object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Backend is an object store the upload and benchmark workloads run against.
+// Each Backend talks to a single endpoint and a single bucket (or container).
+type Backend interface {
+    // Endpoint identifies the endpoint in statistics and reports.
+    Endpoint() string
+
+    // PutObject stores size bytes read from body under key. The returned ETag is the
+    // object's MD5 in S3 ETag form ("<hex>") when the provider reports one, or empty.
+    PutObject(ctx context.Context, key string, body io.ReadSeeker, size int64) (etag string, err error)
+
+    // GetObject returns the contents of an object. The caller must close the body.
+    GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+
+    // HeadObject returns the size of an object.
+    HeadObject(ctx context.Context, key string) (size int64, err error)
+
+    // DeleteObject removes an object.
+    DeleteObject(ctx context.Context, key string) error
+}
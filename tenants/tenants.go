@@ -0,0 +1,419 @@
+// tenants/tenants.go
+package tenants
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "math/rand"
+    "os"
+    "path"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/cleanup"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+const (
+    // maxPrintedBuckets limits the rows of the per-bucket table. Beyond it only the slowest
+    // buckets are printed; the CSV report always has every bucket.
+    maxPrintedBuckets = 50
+    slowestBuckets    = 10
+)
+
+// Bucket is the outcome of one tenant bucket.
+type Bucket struct {
+    Name       string
+    Created    bool          // Created by the run; false when it already existed.
+    CreateTime time.Duration // Time of the CreateBucket request.
+    Err        error         // Why the bucket could not be provisioned; it then gets no objects.
+    Deleted    bool          // Emptied and deleted at the end of the run.
+    Uploaded   int64
+    Failed     int64
+    Bytes      int64
+    TotalTime  time.Duration
+    Latency    stats.Histogram
+
+    mu sync.Mutex // Guards the upload statistics while workers record them.
+}
+
+// AvgLatency returns the average time of a successful upload to the bucket.
+func (b *Bucket) AvgLatency() time.Duration {
+    if b.Uploaded == 0 {
+        return 0
+    }
+    return b.TotalTime / time.Duration(b.Uploaded)
+}
+
+// P99Latency returns the 99th percentile time of a successful upload to the bucket.
+func (b *Bucket) P99Latency() time.Duration {
+    return b.Latency.Percentile(0.99)
+}
+
+// Result is the outcome of a bucket-per-tenant run.
+type Result struct {
+    RunID             string
+    Prefix            string
+    Buckets           []*Bucket
+    ProvisionDuration time.Duration // Wall-clock time to create every bucket.
+    Duration          time.Duration // Wall-clock time of the uploads.
+    DeleteErrors      int64         // Objects or buckets that could not be deleted.
+}
+
+// Failures returns the buckets that could not be provisioned, the failed uploads and the
+// objects or buckets that could not be deleted.
+func (r Result) Failures() int64 {
+    failures := r.DeleteErrors
+    for _, b := range r.Buckets {
+        failures += b.Failed
+        if b.Err != nil {
+            failures++
+        }
+    }
+    return failures
+}
+
+// BucketRate returns the objects per second uploaded to a bucket over the upload phase.
+func (r Result) BucketRate(b *Bucket) float64 {
+    if r.Duration <= 0 {
+        return 0
+    }
+    return float64(b.Uploaded) / r.Duration.Seconds()
+}
+
+// BucketName returns the name of the n-th tenant bucket from tenantBucketTemplate. The tenant
+// number is zero-padded to the width of the largest one, so the buckets sort in tenant order.
+func BucketName(cfg *config.Config, runID string, n int) string {
+    number := fmt.Sprintf("%0*d", len(strconv.Itoa(cfg.TenantCount-1)), n)
+    return strings.NewReplacer("{bucket}", cfg.BucketName, "{run}", runID, "{n}", number).Replace(cfg.TenantBucketTemplate)
+}
+
+// Run creates TenantCount buckets and uploads TenantObjects objects across them, evenly or
+// skewed by tenantZipfExponent, then deletes them again with tenantDeleteBuckets. Objects are
+// written below <tenantPrefix>/<runID>/TENANTS/ in every bucket.
+func Run(cfg *config.Config, s3Clients []*s3.S3) Result {
+    runID := cfg.NewRunID()
+    result := Result{RunID: runID, Prefix: path.Join(cfg.TenantPrefix, runID, "TENANTS")}
+    for n := 0; n < cfg.TenantCount; n++ {
+        result.Buckets = append(result.Buckets, &Bucket{Name: BucketName(cfg, runID, n)})
+    }
+
+    fmt.Printf("Provisioning %d tenant buckets with %d workers...\n", cfg.TenantCount, cfg.TenantProvisionConcurrency)
+    start := time.Now()
+    provision(cfg, s3Clients, result.Buckets)
+    result.ProvisionDuration = time.Since(start)
+
+    var available []*Bucket
+    for _, b := range result.Buckets {
+        if b.Err == nil {
+            available = append(available, b)
+        }
+    }
+    if len(available) == 0 {
+        fmt.Println("No tenant bucket could be provisioned.")
+        return result
+    }
+
+    fmt.Printf("Uploading %d objects of %d bytes across %d buckets with %d workers...\n", cfg.TenantObjects, cfg.TenantObjectSize, len(available), cfg.TenantConcurrency)
+    start = time.Now()
+    upload(cfg, s3Clients, available, result.Prefix)
+    result.Duration = time.Since(start)
+
+    if cfg.TenantDeleteBuckets {
+        result.DeleteErrors = deleteBuckets(cfg, s3Clients[0], available, runID)
+    }
+    return result
+}
+
+// provision creates the buckets, TenantProvisionConcurrency at a time, and times each request.
+// A bucket the credentials already own is used as it is.
+func provision(cfg *config.Config, s3Clients []*s3.S3, buckets []*Bucket) {
+    task := progress.Begin("Creating tenant buckets", "buckets", int64(len(buckets)))
+    defer task.Done()
+
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    for w := 0; w < cfg.TenantProvisionConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := atomic.AddInt64(&next, 1) - 1
+                if i >= int64(len(buckets)) {
+                    return
+                }
+                b := buckets[i]
+
+                input := &s3.CreateBucketInput{Bucket: aws.String(b.Name)}
+                // us-east-1 is the default location and must not be sent as a constraint.
+                if cfg.Region != "us-east-1" {
+                    input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+                        LocationConstraint: aws.String(cfg.Region),
+                    }
+                }
+
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                opStart := time.Now()
+                _, err := client.CreateBucketWithContext(ctx, input)
+                b.CreateTime = time.Since(opStart)
+                cancel()
+
+                if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeBucketAlreadyOwnedByYou {
+                    err = nil
+                } else if err == nil {
+                    b.Created = true
+                }
+                if err != nil {
+                    b.Err = err
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error creating bucket %s: %v\n", b.Name, err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further bucket errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+}
+
+// upload puts TenantObjects objects of one in-memory payload into the buckets. Each object
+// goes to the next bucket in turn, or with tenantZipfExponent to a bucket drawn from a zipf
+// distribution in which the first bucket is the busiest tenant.
+func upload(cfg *config.Config, s3Clients []*s3.S3, buckets []*Bucket, prefix string) {
+    payload := make([]byte, cfg.TenantObjectSize)
+    rand.Read(payload)
+
+    task := progress.Begin("Tenant uploads", "objects", cfg.TenantObjects)
+    defer task.Done()
+
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    for w := 0; w < cfg.TenantConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            var zipf *rand.Zipf
+            if cfg.TenantZipfExponent > 0 && len(buckets) > 1 {
+                zipf = rand.NewZipf(rand.New(rand.NewSource(int64(w))), cfg.TenantZipfExponent, 1, uint64(len(buckets)-1))
+            }
+
+            for {
+                i := atomic.AddInt64(&next, 1) - 1
+                if i >= cfg.TenantObjects {
+                    return
+                }
+
+                b := buckets[i%int64(len(buckets))]
+                if zipf != nil {
+                    b = buckets[zipf.Uint64()]
+                }
+                key := fmt.Sprintf("%s/%x", prefix, i)
+
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                opStart := time.Now()
+                _, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+                    Bucket:        aws.String(b.Name),
+                    Key:           aws.String(key),
+                    Body:          bytes.NewReader(payload),
+                    ContentLength: aws.Int64(int64(len(payload))),
+                })
+                duration := time.Since(opStart)
+                cancel()
+
+                b.mu.Lock()
+                if err != nil {
+                    b.Failed++
+                } else {
+                    b.Uploaded++
+                    b.Bytes += int64(len(payload))
+                    b.TotalTime += duration
+                    b.Latency.Add(duration)
+                }
+                b.mu.Unlock()
+
+                if err != nil {
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error uploading %s to %s: %v\n", key, b.Name, err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further upload errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+}
+
+// deleteBuckets deletes the objects of the run from every bucket, then the buckets the run
+// created. Buckets that existed before are left in place. It returns the objects and buckets
+// that could not be deleted.
+func deleteBuckets(cfg *config.Config, s3Client *s3.S3, buckets []*Bucket, runID string) int64 {
+    fmt.Printf("Deleting the objects of %d tenant buckets...\n", len(buckets))
+    var failures int64
+    for _, b := range buckets {
+        bucketCfg := *cfg
+        bucketCfg.BucketName = b.Name
+        bucketCfg.S3Folder = cfg.TenantPrefix
+        deleted, err := cleanup.DeleteRun(&bucketCfg, s3Client, runID)
+        failures += deleted.Failed
+        if err != nil {
+            fmt.Printf("Error emptying bucket %s: %v\n", b.Name, err)
+            failures++
+            continue
+        }
+        if !b.Created {
+            continue
+        }
+
+        ctx, cancel := cfg.OperationContext(config.OperationDelete)
+        _, err = s3Client.DeleteBucketWithContext(ctx, &s3.DeleteBucketInput{Bucket: aws.String(b.Name)})
+        cancel()
+        if err != nil {
+            fmt.Printf("Error deleting bucket %s: %v\n", b.Name, err)
+            failures++
+            continue
+        }
+        b.Deleted = true
+    }
+    return failures
+}
+
+// PrintReport prints the provisioning time, the aggregate upload rate and the throughput and
+// latency of every tenant bucket.
+func PrintReport(r Result) {
+    fmt.Println("\nBucket-per-Tenant Report:")
+    fmt.Println("=========================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+
+    var created, existing, failed, deleted int
+    var createTotal, createMax time.Duration
+    for _, b := range r.Buckets {
+        switch {
+        case b.Err != nil:
+            failed++
+        case b.Created:
+            created++
+        default:
+            existing++
+        }
+        if b.Deleted {
+            deleted++
+        }
+        if b.Err == nil {
+            createTotal += b.CreateTime
+            createMax = max(createMax, b.CreateTime)
+        }
+    }
+    fmt.Printf("Tenant Buckets: %d (%d created, %d already existed, %d failed)\n", len(r.Buckets), created, existing, failed)
+    fmt.Printf("Provisioning Duration: %v\n", r.ProvisionDuration.Round(time.Millisecond))
+    if provisioned := created + existing; provisioned > 0 {
+        fmt.Printf("Provisioning Rate: %.2f buckets/sec\n", float64(provisioned)/r.ProvisionDuration.Seconds())
+        fmt.Printf("CreateBucket Latency: avg %v, max %v\n", (createTotal / time.Duration(provisioned)).Round(time.Microsecond), createMax.Round(time.Microsecond))
+    }
+
+    var active []*Bucket
+    var uploaded, uploadFailed, bytesWritten int64
+    for _, b := range r.Buckets {
+        uploaded += b.Uploaded
+        uploadFailed += b.Failed
+        bytesWritten += b.Bytes
+        if b.Err == nil {
+            active = append(active, b)
+        }
+    }
+    fmt.Printf("\nObjects Uploaded: %d\n", uploaded)
+    fmt.Printf("Objects Failed: %d\n", uploadFailed)
+    fmt.Printf("Data Written: %.2f MB\n", float64(bytesWritten)/(1024*1024))
+    fmt.Printf("Duration: %v\n", r.Duration.Round(time.Millisecond))
+    if seconds := r.Duration.Seconds(); seconds > 0 {
+        fmt.Printf("Rate: %.2f objects/sec\n", float64(uploaded)/seconds)
+        fmt.Printf("Throughput: %.2f MB/sec\n", float64(bytesWritten)/(1024*1024)/seconds)
+    }
+
+    if len(active) > 0 && r.Duration > 0 {
+        // Slowest first, so the tenants that fell behind lead the table.
+        sort.SliceStable(active, func(i, j int) bool { return active[i].Uploaded < active[j].Uploaded })
+        median := active[len(active)/2]
+        fmt.Printf("Per-Bucket Rate: min %.2f, median %.2f, max %.2f objects/sec\n",
+            r.BucketRate(active[0]), r.BucketRate(median), r.BucketRate(active[len(active)-1]))
+
+        rows := active
+        if len(rows) > maxPrintedBuckets {
+            rows = rows[:slowestBuckets]
+            fmt.Printf("\nThe %d slowest of %d buckets:\n", slowestBuckets, len(active))
+        } else {
+            fmt.Println()
+        }
+        fmt.Printf("%-40s %10s %8s %12s %12s %12s %12s\n", "Bucket", "Objects", "Failed", "MB", "Objects/sec", "Avg", "P99")
+        for _, b := range rows {
+            fmt.Printf("%-40s %10d %8d %12.2f %12.2f %12v %12v\n", b.Name, b.Uploaded, b.Failed,
+                float64(b.Bytes)/(1024*1024), r.BucketRate(b), b.AvgLatency().Round(time.Microsecond), b.P99Latency())
+        }
+    }
+
+    if deleted > 0 || r.DeleteErrors > 0 {
+        fmt.Printf("\nBuckets Deleted: %d\n", deleted)
+        fmt.Printf("Delete Errors: %d\n", r.DeleteErrors)
+    }
+    fmt.Println("=========================")
+}
+
+// WriteCSV writes one row per tenant bucket with its provisioning outcome, throughput and latency.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating tenant report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Bucket", "Created", "CreateTimeMs", "Error", "Objects", "Failed", "Bytes", "ObjectsPerSec", "AvgTimeMs", "P99TimeMs", "Deleted"})
+    for _, b := range r.Buckets {
+        errText := ""
+        if b.Err != nil {
+            errText = b.Err.Error()
+        }
+        writer.Write([]string{
+            b.Name,
+            strconv.FormatBool(b.Created),
+            stats.Milliseconds(b.CreateTime),
+            errText,
+            strconv.FormatInt(b.Uploaded, 10),
+            strconv.FormatInt(b.Failed, 10),
+            strconv.FormatInt(b.Bytes, 10),
+            fmt.Sprintf("%.2f", r.BucketRate(b)),
+            stats.Milliseconds(b.AvgLatency()),
+            stats.Milliseconds(b.P99Latency()),
+            strconv.FormatBool(b.Deleted),
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing tenant report %s: %w", filePath, err)
+    }
+    return nil
+}
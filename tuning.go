@@ -0,0 +1,48 @@
+// tuning.go
+package main
+
+import (
+    "fmt"
+    "math"
+    "runtime"
+    "runtime/debug"
+
+    "scale_s3_benchmark/config"
+)
+
+// ballast is a large allocation that is never read or written. It counts towards the live heap,
+// so with the default GC pacing collections run less often, but its pages are never touched
+// and take no physical memory.
+var ballast []byte
+
+// applyRuntimeTuning applies the Go runtime settings of the configuration. They are process-wide,
+// so they are applied once at startup; unset options keep the runtime defaults and environment overrides.
+func applyRuntimeTuning(cfg *config.Config) {
+    if cfg.GoMaxProcs > 0 {
+        runtime.GOMAXPROCS(cfg.GoMaxProcs)
+    }
+    if cfg.GCPercent != 0 {
+        debug.SetGCPercent(cfg.GCPercent)
+    }
+    if cfg.MemoryLimitMB > 0 {
+        debug.SetMemoryLimit(cfg.MemoryLimitMB << 20)
+    }
+    if cfg.BallastMB > 0 {
+        ballast = make([]byte, cfg.BallastMB<<20)
+    }
+
+    if cfg.GoMaxProcs > 0 || cfg.GCPercent != 0 || cfg.MemoryLimitMB > 0 || cfg.BallastMB > 0 {
+        gcPercent := debug.SetGCPercent(-1)
+        debug.SetGCPercent(gcPercent)
+        fmt.Printf("Go runtime: GOMAXPROCS=%d, GC percent=%d, memory limit=%s, ballast=%d MiB\n",
+            runtime.GOMAXPROCS(0), gcPercent, formatMemoryLimit(debug.SetMemoryLimit(-1)), len(ballast)>>20)
+    }
+}
+
+// formatMemoryLimit formats a runtime memory limit, which is math.MaxInt64 when there is none.
+func formatMemoryLimit(limit int64) string {
+    if limit == math.MaxInt64 {
+        return "none"
+    }
+    return fmt.Sprintf("%d MiB", limit>>20)
+}
@@ -0,0 +1,158 @@
+// verify/inventory.go
+package verify
+
+import (
+    "compress/gzip"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/progress"
+)
+
+// inventoryManifest is the manifest.json written with every S3 Inventory report.
+type inventoryManifest struct {
+    SourceBucket      string `json:"sourceBucket"`
+    FileFormat        string `json:"fileFormat"`
+    FileSchema        string `json:"fileSchema"`
+    CreationTimestamp string `json:"creationTimestamp"` // Milliseconds since the epoch.
+    Files             []struct {
+        Key string `json:"key"`
+    } `json:"files"`
+}
+
+// parseS3URL splits s3://bucket/key into its bucket and key.
+func parseS3URL(s string) (bucket, key string, err error) {
+    u, err := url.Parse(s)
+    if err != nil || u.Scheme != "s3" || u.Host == "" || len(u.Path) < 2 {
+        return "", "", fmt.Errorf("invalid S3 URL %q, expected s3://bucket/key", s)
+    }
+    return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
+
+// readInventory loads the objects under the configured S3 folder from the S3 Inventory report
+// whose manifest.json is at InventoryManifest. Only CSV reports are supported. For versioned
+// inventories only the latest, non-delete-marker version of each key is kept.
+func readInventory(cfg *config.Config, s3Client *s3.S3) (listing, error) {
+    destBucket, manifestKey, err := parseS3URL(cfg.InventoryManifest)
+    if err != nil {
+        return listing{}, err
+    }
+
+    body, err := getObject(s3Client, destBucket, manifestKey)
+    if err != nil {
+        return listing{}, fmt.Errorf("error reading inventory manifest %s: %w", cfg.InventoryManifest, err)
+    }
+    var m inventoryManifest
+    err = json.NewDecoder(body).Decode(&m)
+    body.Close()
+    if err != nil {
+        return listing{}, fmt.Errorf("error decoding inventory manifest %s: %w", cfg.InventoryManifest, err)
+    }
+
+    if m.FileFormat != "CSV" {
+        return listing{}, fmt.Errorf("inventory format %q is not supported, only CSV", m.FileFormat)
+    }
+    if m.SourceBucket != cfg.BucketName {
+        return listing{}, fmt.Errorf("inventory is for bucket %s, not %s", m.SourceBucket, cfg.BucketName)
+    }
+    millis, err := strconv.ParseInt(m.CreationTimestamp, 10, 64)
+    if err != nil {
+        return listing{}, fmt.Errorf("invalid inventory creationTimestamp %q: %w", m.CreationTimestamp, err)
+    }
+
+    columns := make(map[string]int)
+    for i, name := range strings.Split(m.FileSchema, ",") {
+        columns[strings.TrimSpace(name)] = i
+    }
+    for _, required := range []string{"Key", "Size"} {
+        if _, ok := columns[required]; !ok {
+            return listing{}, fmt.Errorf("inventory schema %q has no %s field", m.FileSchema, required)
+        }
+    }
+
+    l := listing{
+        Source:       "inventory " + cfg.InventoryManifest,
+        SnapshotTime: time.UnixMilli(millis),
+        Objects:      make(map[string]int64),
+    }
+    task := progress.Begin("Reading inventory files", "files", int64(len(m.Files)))
+    defer task.Done()
+    for _, f := range m.Files {
+        if err := readInventoryFile(cfg, s3Client, destBucket, f.Key, columns, l.Objects); err != nil {
+            return listing{}, err
+        }
+        task.Add(1)
+    }
+    return l, nil
+}
+
+// readInventoryFile adds the rows of one gzipped CSV inventory data file to objects.
+func readInventoryFile(cfg *config.Config, s3Client *s3.S3, bucket, key string, columns map[string]int, objects map[string]int64) error {
+    body, err := getObject(s3Client, bucket, key)
+    if err != nil {
+        return fmt.Errorf("error reading inventory file %s: %w", key, err)
+    }
+    defer body.Close()
+
+    gz, err := gzip.NewReader(body)
+    if err != nil {
+        return fmt.Errorf("error decompressing inventory file %s: %w", key, err)
+    }
+    reader := csv.NewReader(gz)
+    reader.FieldsPerRecord = -1
+
+    field := func(row []string, name string) string {
+        if i, ok := columns[name]; ok && i < len(row) {
+            return row[i]
+        }
+        return ""
+    }
+
+    for {
+        row, err := reader.Read()
+        if err == io.EOF {
+            return nil
+        }
+        if err != nil {
+            return fmt.Errorf("error parsing inventory file %s: %w", key, err)
+        }
+
+        if field(row, "IsLatest") == "false" || field(row, "IsDeleteMarker") == "true" {
+            continue
+        }
+        // Keys are URL-encoded in inventory reports.
+        objectKey, err := url.QueryUnescape(field(row, "Key"))
+        if err != nil {
+            objectKey = field(row, "Key")
+        }
+        if !strings.HasPrefix(objectKey, listPrefix(cfg)) {
+            continue
+        }
+
+        size, _ := strconv.ParseInt(field(row, "Size"), 10, 64)
+        objects[objectKey] = size
+    }
+}
+
+// getObject opens an object for reading. The caller must close the body.
+func getObject(s3Client *s3.S3, bucket, key string) (io.ReadCloser, error) {
+    // The body is read after this function returns, so only the client timeout applies.
+    out, err := s3Client.GetObject(&s3.GetObjectInput{
+        Bucket: aws.String(bucket),
+        Key:    aws.String(key),
+    })
+    if err != nil {
+        return nil, err
+    }
+    return out.Body, nil
+}
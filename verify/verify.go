@@ -0,0 +1,305 @@
+// verify/verify.go
+package verify
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/aws/awserr"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/manifest"
+    "scale_s3_benchmark/progress"
+)
+
+// maxListedExamples is the number of keys printed for each discrepancy category.
+const maxListedExamples = 20
+
+// headConcurrency is the number of concurrent HEAD requests used to re-check discrepancies.
+const headConcurrency = 16
+
+// SizeMismatch describes an object whose size in the bucket differs from the manifest.
+type SizeMismatch struct {
+    Key          string
+    ExpectedSize int64
+    ActualSize   int64
+}
+
+// Result holds the outcome of reconciling the bucket listing against the manifest.
+type Result struct {
+    Source          string    // "list" or the S3 Inventory manifest the listing came from.
+    SnapshotTime    time.Time // When the listing was taken.
+    ManifestObjects int
+    BucketObjects   int
+    NotYetListed    int            // Objects uploaded after the snapshot, which the listing cannot contain.
+    Missing         []string       // Absent from the listing and from the bucket.
+    Unlisted        []string       // Absent from the listing but found with HEAD.
+    Extra           []string       // Listed but not in the manifest.
+    SizeMismatches  []SizeMismatch // Size differs from the manifest, confirmed with HEAD.
+    StaleSizes      []SizeMismatch // Listed size differs from the manifest but HEAD returns the expected size.
+}
+
+// OK reports whether the bucket and its listing match the manifest exactly.
+func (r Result) OK() bool {
+    return len(r.Missing) == 0 && len(r.Unlisted) == 0 && len(r.Extra) == 0 &&
+        len(r.SizeMismatches) == 0 && len(r.StaleSizes) == 0
+}
+
+// Completeness returns the fraction (0-1) of manifest objects present in the bucket at the
+// snapshot that the listing reports with their actual size. Missing objects are data loss,
+// not listing errors, and are left out.
+func (r Result) Completeness() float64 {
+    present := r.ManifestObjects - r.NotYetListed - len(r.Missing)
+    if present <= 0 {
+        return 1
+    }
+    return float64(present-len(r.Unlisted)-len(r.StaleSizes)) / float64(present)
+}
+
+// listing is a snapshot of the objects under the configured S3 folder.
+type listing struct {
+    Source       string
+    SnapshotTime time.Time
+    Objects      map[string]int64 // Listed size by key.
+}
+
+// Run compares keys and sizes of the manifest entries with a listing of the configured S3
+// folder, taken from the S3 Inventory report at InventoryManifest or from a full paged LIST.
+// With RunID set, only the entries and objects of that run are compared.
+// Every object missing from the listing or listed with the wrong size is re-checked with
+// HEAD, to tell a stale or incomplete listing apart from lost or damaged objects.
+func Run(cfg *config.Config, s3Client *s3.S3, entries []manifest.Entry) (Result, error) {
+    // Later entries win when a key was uploaded more than once.
+    expected := make(map[string]manifest.Entry, len(entries))
+    for _, e := range entries {
+        if cfg.RunID != "" && e.RunID != cfg.RunID {
+            continue
+        }
+        expected[e.Key] = e
+    }
+
+    var l listing
+    var err error
+    if cfg.InventoryManifest != "" {
+        l, err = readInventory(cfg, s3Client)
+    } else {
+        l, err = listBucket(cfg, s3Client)
+    }
+    if err != nil {
+        return Result{}, err
+    }
+
+    result := Result{
+        Source:          l.Source,
+        SnapshotTime:    l.SnapshotTime,
+        ManifestObjects: len(expected),
+        BucketObjects:   len(l.Objects),
+    }
+
+    var absent []string
+    var wrongSize []SizeMismatch
+    for key, e := range expected {
+        size, ok := l.Objects[key]
+        switch {
+        case !ok && e.UploadedAt.After(l.SnapshotTime):
+            result.NotYetListed++
+        case !ok:
+            absent = append(absent, key)
+        case size != e.Size:
+            wrongSize = append(wrongSize, SizeMismatch{Key: key, ExpectedSize: e.Size, ActualSize: size})
+        }
+    }
+    for key := range l.Objects {
+        if _, ok := expected[key]; !ok {
+            result.Extra = append(result.Extra, key)
+        }
+    }
+
+    // Re-check discrepancies against the objects themselves.
+    if n := len(absent) + len(wrongSize); n > 0 {
+        fmt.Printf("Checking %d discrepancies with HEAD...\n", n)
+    }
+    var mu sync.Mutex
+    forEachKey(absent, func(key string) {
+        _, found, err := headSize(cfg, s3Client, key)
+        mu.Lock()
+        defer mu.Unlock()
+        if err != nil {
+            fmt.Printf("Error checking %s: %v\n", key, err)
+        }
+        if found {
+            result.Unlisted = append(result.Unlisted, key)
+        } else {
+            result.Missing = append(result.Missing, key)
+        }
+    })
+    mismatches := make(map[string]SizeMismatch, len(wrongSize))
+    keys := make([]string, len(wrongSize))
+    for i, m := range wrongSize {
+        mismatches[m.Key] = m
+        keys[i] = m.Key
+    }
+    forEachKey(keys, func(key string) {
+        size, found, err := headSize(cfg, s3Client, key)
+        m := mismatches[key]
+        mu.Lock()
+        defer mu.Unlock()
+        if err != nil {
+            fmt.Printf("Error checking %s: %v\n", key, err)
+        }
+        if found && size == m.ExpectedSize {
+            result.StaleSizes = append(result.StaleSizes, m)
+            return
+        }
+        if found {
+            m.ActualSize = size
+        }
+        result.SizeMismatches = append(result.SizeMismatches, m)
+    })
+
+    sort.Strings(result.Missing)
+    sort.Strings(result.Unlisted)
+    sort.Strings(result.Extra)
+    sort.Slice(result.SizeMismatches, func(i, j int) bool { return result.SizeMismatches[i].Key < result.SizeMismatches[j].Key })
+    sort.Slice(result.StaleSizes, func(i, j int) bool { return result.StaleSizes[i].Key < result.StaleSizes[j].Key })
+
+    return result, nil
+}
+
+// listPrefix returns the prefix of the objects to verify: those of RunID if set, otherwise
+// everything under the S3 folder.
+func listPrefix(cfg *config.Config) string {
+    if cfg.RunID != "" {
+        return cfg.RunPrefix(cfg.RunID) + "/"
+    }
+    return cfg.S3Folder
+}
+
+// listBucket lists every object under the configured S3 folder with paged LIST requests.
+// The snapshot time is the start of the listing.
+func listBucket(cfg *config.Config, s3Client *s3.S3) (listing, error) {
+    l := listing{Source: "list", SnapshotTime: time.Now(), Objects: make(map[string]int64)}
+    task := progress.Begin("Listing bucket", "objects", 0)
+    defer task.Done()
+
+    input := &s3.ListObjectsV2Input{
+        Bucket: aws.String(cfg.BucketName),
+        Prefix: aws.String(listPrefix(cfg)),
+    }
+    for {
+        // Each page request gets its own LIST deadline.
+        ctx, cancel := cfg.OperationContext(config.OperationList)
+        page, err := s3Client.ListObjectsV2WithContext(ctx, input)
+        cancel()
+        if err != nil {
+            return listing{}, fmt.Errorf("error listing bucket %s: %w", cfg.BucketName, err)
+        }
+
+        for _, obj := range page.Contents {
+            l.Objects[aws.StringValue(obj.Key)] = aws.Int64Value(obj.Size)
+        }
+        task.Add(int64(len(page.Contents)))
+
+        if !aws.BoolValue(page.IsTruncated) {
+            break
+        }
+        input.ContinuationToken = page.NextContinuationToken
+    }
+    return l, nil
+}
+
+// headSize returns the size of an object and whether it exists. A missing object is not an error.
+func headSize(cfg *config.Config, s3Client *s3.S3, key string) (int64, bool, error) {
+    ctx, cancel := cfg.OperationContext(config.OperationHead)
+    defer cancel()
+
+    out, err := s3Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+        Bucket: aws.String(cfg.BucketName),
+        Key:    aws.String(key),
+    })
+    if err != nil {
+        if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == 404 {
+            return 0, false, nil
+        }
+        return 0, false, err
+    }
+    return aws.Int64Value(out.ContentLength), true, nil
+}
+
+// forEachKey calls fn for every key with at most headConcurrency calls running at once.
+func forEachKey(keys []string, fn func(key string)) {
+    sem := make(chan struct{}, headConcurrency)
+    var wg sync.WaitGroup
+    for _, key := range keys {
+        wg.Add(1)
+        sem <- struct{}{}
+        go func(k string) {
+            defer wg.Done()
+            defer func() { <-sem }()
+            fn(k)
+        }(key)
+    }
+    wg.Wait()
+}
+
+// PrintReport prints a summary of the reconciliation with a sample of each discrepancy.
+func PrintReport(result Result) {
+    fmt.Println("\nVerification Report:")
+    fmt.Println("====================")
+    fmt.Printf("Listing Source: %s\n", result.Source)
+    fmt.Printf("Listing Snapshot: %s (age %v)\n", result.SnapshotTime.Format(time.RFC3339), time.Since(result.SnapshotTime).Round(time.Second))
+    fmt.Printf("Objects in Manifest: %d\n", result.ManifestObjects)
+    fmt.Printf("Objects in Listing: %d\n", result.BucketObjects)
+    fmt.Printf("Uploaded After Snapshot: %d\n", result.NotYetListed)
+    fmt.Printf("Listing Completeness: %.4f%%\n", result.Completeness()*100)
+    fmt.Printf("Missing: %d\n", len(result.Missing))
+    fmt.Printf("Unlisted (present but not listed): %d\n", len(result.Unlisted))
+    fmt.Printf("Extra: %d\n", len(result.Extra))
+    fmt.Printf("Size Mismatches: %d\n", len(result.SizeMismatches))
+    fmt.Printf("Stale Listed Sizes: %d\n", len(result.StaleSizes))
+
+    printKeys("Missing objects", result.Missing)
+    printKeys("Unlisted objects", result.Unlisted)
+    printKeys("Extra objects", result.Extra)
+    printMismatches("Size mismatches", result.SizeMismatches)
+    printMismatches("Stale listed sizes", result.StaleSizes)
+
+    if result.OK() {
+        fmt.Println("\nBucket contents match the manifest.")
+    }
+    fmt.Println("====================")
+}
+
+// printKeys prints up to maxListedExamples keys under a heading.
+func printKeys(title string, keys []string) {
+    if len(keys) == 0 {
+        return
+    }
+    fmt.Printf("\n%s:\n", title)
+    for i, key := range keys {
+        if i == maxListedExamples {
+            fmt.Printf("  ... and %d more\n", len(keys)-maxListedExamples)
+            break
+        }
+        fmt.Printf("  %s\n", key)
+    }
+}
+
+// printMismatches prints up to maxListedExamples size mismatches under a heading.
+func printMismatches(title string, mismatches []SizeMismatch) {
+    if len(mismatches) == 0 {
+        return
+    }
+    fmt.Printf("\n%s:\n", title)
+    for i, m := range mismatches {
+        if i == maxListedExamples {
+            fmt.Printf("  ... and %d more\n", len(mismatches)-maxListedExamples)
+            break
+        }
+        fmt.Printf("  %s (expected %d, got %d)\n", m.Key, m.ExpectedSize, m.ActualSize)
+    }
+}
@@ -0,0 +1,352 @@
+// versions/versions.go
+package versions
+
+import (
+    "bytes"
+    "encoding/csv"
+    "fmt"
+    "io"
+    "math/rand"
+    "os"
+    "path"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/aws/aws-sdk-go/aws"
+    "github.com/aws/aws-sdk-go/service/s3"
+
+    "scale_s3_benchmark/cleanup"
+    "scale_s3_benchmark/config"
+    "scale_s3_benchmark/internal/stats"
+    "scale_s3_benchmark/progress"
+)
+
+// Operations measured for every number of versions per key, in the order they run.
+var Operations = []string{config.OperationPut, config.OperationList, config.OperationGet, config.OperationDelete}
+
+// OperationResult holds the requests of one operation in one key group.
+type OperationResult struct {
+    Requests  int64
+    Errors    int64
+    Elapsed   time.Duration
+    Latencies stats.Latencies // Times of the successful requests.
+}
+
+// Average returns the average time of a successful request.
+func (o *OperationResult) Average() time.Duration {
+    return o.Latencies.Average()
+}
+
+// Percentile returns the p-th fraction (0-1) of the request times.
+func (o *OperationResult) Percentile(p float64) time.Duration {
+    return o.Latencies.Percentile(p)
+}
+
+// OpsPerSecond returns the successful requests per second.
+func (o *OperationResult) OpsPerSecond() float64 {
+    if o.Elapsed <= 0 {
+        return 0
+    }
+    return float64(o.Latencies.Count) / o.Elapsed.Seconds()
+}
+
+// GroupResult is the outcome of the key group with one number of versions per key.
+type GroupResult struct {
+    Versions       int   // Versions written per key.
+    ListedVersions int64 // Versions returned by the successful ListObjectVersions requests.
+    Operations     map[string]*OperationResult
+}
+
+// VersionsPerListing returns the average versions returned by a ListObjectVersions request.
+func (g GroupResult) VersionsPerListing() float64 {
+    if n := g.Operations[config.OperationList].Latencies.Count; n > 0 {
+        return float64(g.ListedVersions) / float64(n)
+    }
+    return 0
+}
+
+// Result is the outcome of a version-explosion run.
+type Result struct {
+    RunID        string
+    Prefix       string
+    Groups       []GroupResult
+    Deleted      int64
+    DeleteFailed int64
+    DeleteError  error
+}
+
+// Failures returns the failed requests and deletes over all groups.
+func (r Result) Failures() int64 {
+    failures := r.DeleteFailed
+    for _, g := range r.Groups {
+        for _, op := range g.Operations {
+            failures += op.Errors
+        }
+    }
+    if r.DeleteError != nil {
+        failures++
+    }
+    return failures
+}
+
+// Run writes versionKeys keys with each number of versionCounts versions on a versioned bucket,
+// then times ListObjectVersions of single keys, GET of the latest version and DELETE of the
+// first version written of each key. The group with n versions per key is written below
+// <versionPrefix>/<runID>/VERSIONS/<n>/.
+func Run(cfg *config.Config, s3Clients []*s3.S3) (Result, error) {
+    status, err := s3Clients[0].GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(cfg.BucketName)})
+    if err != nil {
+        return Result{}, fmt.Errorf("error getting the versioning state of bucket %s: %w", cfg.BucketName, err)
+    }
+    if aws.StringValue(status.Status) != s3.BucketVersioningStatusEnabled {
+        return Result{}, fmt.Errorf("versioning is not enabled on bucket %s; enable it or create the bucket with createBucket and bucketVersioning", cfg.BucketName)
+    }
+
+    runID := cfg.NewRunID()
+    result := Result{RunID: runID, Prefix: path.Join(cfg.VersionPrefix, runID, "VERSIONS")}
+
+    payload := make([]byte, cfg.VersionObjectSize)
+    rand.Read(payload)
+
+    keys := cfg.VersionKeys
+    for _, versions := range cfg.VersionCounts {
+        root := path.Join(result.Prefix, strconv.Itoa(versions))
+        key := func(i int) string { return path.Join(root, fmt.Sprintf("%06d", i%keys)) }
+        g := GroupResult{Versions: versions, Operations: make(map[string]*OperationResult)}
+        fmt.Printf("\n%d versions per key: %d keys, %d versions below %s/\n", versions, keys, keys*versions, root)
+
+        // The versions are written round by round over the keys, so the first version recorded
+        // for a key is its oldest as long as versionConcurrency does not exceed versionKeys.
+        var mu sync.Mutex
+        versionIDs := make([][]string, keys)
+        g.Operations[config.OperationPut] = measure(cfg, s3Clients, fmt.Sprintf("PUT with %d versions", versions), keys*versions,
+            func(client *s3.S3, i int) error {
+                ctx, cancel := cfg.OperationContext(config.OperationPut)
+                defer cancel()
+                output, err := client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+                    Bucket:        aws.String(cfg.BucketName),
+                    Key:           aws.String(key(i)),
+                    Body:          bytes.NewReader(payload),
+                    ContentLength: aws.Int64(int64(len(payload))),
+                })
+                if err != nil {
+                    return err
+                }
+                if output.VersionId == nil {
+                    return fmt.Errorf("no version ID returned for %s", key(i))
+                }
+                mu.Lock()
+                versionIDs[i%keys] = append(versionIDs[i%keys], aws.StringValue(output.VersionId))
+                mu.Unlock()
+                return nil
+            })
+
+        g.Operations[config.OperationList] = measure(cfg, s3Clients, fmt.Sprintf("LIST with %d versions", versions), cfg.VersionRequests,
+            func(client *s3.S3, i int) error {
+                input := &s3.ListObjectVersionsInput{
+                    Bucket: aws.String(cfg.BucketName),
+                    Prefix: aws.String(key(i)),
+                }
+                var listed int64
+                for {
+                    ctx, cancel := cfg.OperationContext(config.OperationList)
+                    page, err := client.ListObjectVersionsWithContext(ctx, input)
+                    cancel()
+                    if err != nil {
+                        return err
+                    }
+                    listed += int64(len(page.Versions))
+                    if !aws.BoolValue(page.IsTruncated) {
+                        break
+                    }
+                    input.KeyMarker, input.VersionIdMarker = page.NextKeyMarker, page.NextVersionIdMarker
+                }
+                atomic.AddInt64(&g.ListedVersions, listed)
+                return nil
+            })
+
+        g.Operations[config.OperationGet] = measure(cfg, s3Clients, fmt.Sprintf("GET with %d versions", versions), cfg.VersionRequests,
+            func(client *s3.S3, i int) error {
+                ctx, cancel := cfg.OperationContext(config.OperationGet)
+                defer cancel()
+                output, err := client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+                    Bucket: aws.String(cfg.BucketName),
+                    Key:    aws.String(key(i)),
+                })
+                if err != nil {
+                    return err
+                }
+                defer output.Body.Close()
+                _, err = io.Copy(io.Discard, output.Body)
+                return err
+            })
+
+        g.Operations[config.OperationDelete] = measure(cfg, s3Clients, fmt.Sprintf("DELETE with %d versions", versions), keys,
+            func(client *s3.S3, i int) error {
+                if len(versionIDs[i]) == 0 {
+                    return fmt.Errorf("no version of %s was written", key(i))
+                }
+                ctx, cancel := cfg.OperationContext(config.OperationDelete)
+                defer cancel()
+                _, err := client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+                    Bucket:    aws.String(cfg.BucketName),
+                    Key:       aws.String(key(i)),
+                    VersionId: aws.String(versionIDs[i][0]),
+                })
+                return err
+            })
+
+        result.Groups = append(result.Groups, g)
+    }
+
+    if cfg.VersionDeleteObjects {
+        fmt.Println("\nDeleting the object versions of the run...")
+        versionCfg := *cfg
+        versionCfg.S3Folder = cfg.VersionPrefix
+        deleted, err := cleanup.DeleteRunVersions(&versionCfg, s3Clients[0], runID)
+        result.Deleted, result.DeleteFailed, result.DeleteError = deleted.Deleted, deleted.Failed, err
+    }
+    return result, nil
+}
+
+// measure sends count requests with request, versionConcurrency at a time, and times each one.
+func measure(cfg *config.Config, s3Clients []*s3.S3, name string, count int, request func(client *s3.S3, i int) error) *OperationResult {
+    result := &OperationResult{}
+    task := progress.Begin(name, "requests", int64(count))
+    defer task.Done()
+
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    var next, printedErrors int64
+    start := time.Now()
+    for w := 0; w < cfg.VersionConcurrency; w++ {
+        wg.Add(1)
+        go func(w int) {
+            defer wg.Done()
+            client := s3Clients[w%len(s3Clients)]
+            for {
+                i := int(atomic.AddInt64(&next, 1) - 1)
+                if i >= count {
+                    return
+                }
+
+                requestStart := time.Now()
+                err := request(client, i)
+                duration := time.Since(requestStart)
+
+                mu.Lock()
+                result.Requests++
+                if err != nil {
+                    result.Errors++
+                } else {
+                    result.Latencies.Add(duration)
+                }
+                mu.Unlock()
+
+                if err != nil {
+                    task.Fail(1)
+                    if atomic.AddInt64(&printedErrors, 1) <= stats.MaxPrintedErrors {
+                        progress.Printf("Error in %s: %v\n", name, err)
+                    }
+                    continue
+                }
+                task.Add(1)
+            }
+        }(w)
+    }
+    wg.Wait()
+    result.Elapsed = time.Since(start)
+
+    if printedErrors > stats.MaxPrintedErrors {
+        fmt.Printf("%d further errors were not printed.\n", printedErrors-stats.MaxPrintedErrors)
+    }
+    return result
+}
+
+// PrintReport prints the latency and rate of every operation per number of versions per key
+// and how the median latency with the most versions compares with the fewest.
+func PrintReport(r Result) {
+    fmt.Println("\nVersion Explosion Report:")
+    fmt.Println("=========================")
+    fmt.Printf("Run ID: %s\n", r.RunID)
+    fmt.Printf("Prefix: %s/\n", r.Prefix)
+
+    for _, op := range Operations {
+        fmt.Printf("\n%s Latency:\n", op)
+        fmt.Printf("%10s %10s %8s %10s %12s %12s %12s\n", "Versions", "Requests", "Errors", "Ops/sec", "Avg", "P50", "P99")
+        for _, g := range r.Groups {
+            o := g.Operations[op]
+            fmt.Printf("%10d %10d %8d %10.1f %12v %12v %12v\n", g.Versions, o.Requests, o.Errors, o.OpsPerSecond(),
+                o.Average().Round(time.Microsecond), o.Percentile(0.50).Round(time.Microsecond), o.Percentile(0.99).Round(time.Microsecond))
+        }
+        if op == config.OperationList {
+            for _, g := range r.Groups {
+                if listed := g.VersionsPerListing(); listed < float64(g.Versions) {
+                    fmt.Printf("Warning: listings with %d versions per key returned %.1f versions on average.\n", g.Versions, listed)
+                }
+            }
+        }
+    }
+
+    if len(r.Groups) > 1 {
+        fewest, most := r.Groups[0], r.Groups[0]
+        for _, g := range r.Groups {
+            if g.Versions < fewest.Versions {
+                fewest = g
+            }
+            if g.Versions > most.Versions {
+                most = g
+            }
+        }
+        fmt.Printf("\nMedian latency with %d versions per key relative to %d:", most.Versions, fewest.Versions)
+        for _, op := range Operations {
+            if base := fewest.Operations[op].Percentile(0.50); base > 0 {
+                fmt.Printf(" %s x%.2f", op, float64(most.Operations[op].Percentile(0.50))/float64(base))
+            }
+        }
+        fmt.Println()
+    }
+
+    if r.Deleted > 0 || r.DeleteFailed > 0 || r.DeleteError != nil {
+        fmt.Printf("\nVersions Deleted: %d\n", r.Deleted)
+        fmt.Printf("Versions Not Deleted: %d\n", r.DeleteFailed)
+        if r.DeleteError != nil {
+            fmt.Printf("Delete Error: %v\n", r.DeleteError)
+        }
+    }
+    fmt.Println("=========================")
+}
+
+// WriteCSV writes one row per number of versions per key and operation.
+func WriteCSV(filePath string, r Result) error {
+    file, err := os.Create(filePath)
+    if err != nil {
+        return fmt.Errorf("error creating versions report %s: %w", filePath, err)
+    }
+    defer file.Close()
+
+    writer := csv.NewWriter(file)
+    writer.Write([]string{"Versions", "Operation", "Requests", "Errors", "OpsPerSec", "AvgMs", "P50Ms", "P99Ms"})
+    for _, g := range r.Groups {
+        for _, op := range Operations {
+            o := g.Operations[op]
+            writer.Write([]string{
+                strconv.Itoa(g.Versions),
+                op,
+                strconv.FormatInt(o.Requests, 10),
+                strconv.FormatInt(o.Errors, 10),
+                fmt.Sprintf("%.1f", o.OpsPerSecond()),
+                stats.Milliseconds(o.Average()),
+                stats.Milliseconds(o.Percentile(0.50)),
+                stats.Milliseconds(o.Percentile(0.99)),
+            })
+        }
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        return fmt.Errorf("error writing versions report %s: %w", filePath, err)
+    }
+    return nil
+}
@@ -2,17 +2,50 @@
 package main
 
 import (
-//    "encoding/json"
+    "crypto/subtle"
+    "embed"
+    "encoding/json"
+    "errors"
     "fmt"
     "html/template"
+    "io/fs"
+    "net"
     "net/http"
+    "os"
+    "scale_s3_benchmark/config"
     "scale_s3_benchmark/monitor"
+    "strconv"
+    "strings"
     "time"
 )
 
+// embeddedAssets holds the dashboard templates and static files built into the binary.
+//
+//go:embed templates static
+var embeddedAssets embed.FS
+
+// webAssets is the file system the web server reads templates and static files from.
+var webAssets fs.FS = embeddedAssets
+
+// overlayFS serves files from dir when they exist there and from base otherwise,
+// so single templates or static files can be customized without copying the rest.
+type overlayFS struct {
+    dir  fs.FS
+    base fs.FS
+}
+
+// Open implements fs.FS.
+func (o overlayFS) Open(name string) (fs.File, error) {
+    f, err := o.dir.Open(name)
+    if errors.Is(err, fs.ErrNotExist) {
+        return o.base.Open(name)
+    }
+    return f, err
+}
+
 // dashboardHandler handles the main dashboard route and renders the dashboard template.
 func dashboardHandler(w http.ResponseWriter, r *http.Request) {
-    tmpl, err := template.ParseFiles("templates/dashboard.html")
+    tmpl, err := template.ParseFS(webAssets, "templates/dashboard.html")
     if err != nil {
         http.Error(w, err.Error(), http.StatusInternalServerError)
         return
@@ -42,12 +75,17 @@ func statsHandler(w http.ResponseWriter, r *http.Request) {
     w.Write(jsonData)
 }
 
-// sseHandler implements Server-Sent Events for real-time updates.
+// sseHandler implements Server-Sent Events for real-time updates. Upload statistics are sent
+// as unnamed messages and per-endpoint statistics as "endpoints" events. Run lifecycle events
+// are sent as they happen, named after their type (phase_started, folder_completed, ...).
 func sseHandler(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "text/event-stream")
     w.Header().Set("Cache-Control", "no-cache")
     w.Header().Set("Connection", "keep-alive")
 
+    events, unsubscribe := monitor.Subscribe()
+    defer unsubscribe()
+
     ticker := time.NewTicker(5 * time.Second)
     defer ticker.Stop()
 
@@ -55,12 +93,24 @@ func sseHandler(w http.ResponseWriter, r *http.Request) {
         select {
         case <-r.Context().Done():
             return
+        case e := <-events:
+            eventData, err := json.Marshal(e)
+            if err != nil {
+                continue
+            }
+            fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, eventData)
+            if f, ok := w.(http.Flusher); ok {
+                f.Flush()
+            }
         case <-ticker.C:
             jsonData, err := monitor.ToJSON()
             if err != nil {
                 continue
             }
             fmt.Fprintf(w, "data: %s\n\n", jsonData)
+            if endpointData, err := json.Marshal(monitor.GetEndpointLive()); err == nil {
+                fmt.Fprintf(w, "event: endpoints\ndata: %s\n\n", endpointData)
+            }
             if f, ok := w.(http.Flusher); ok {
                 f.Flush()
             }
@@ -68,8 +118,60 @@ func sseHandler(w http.ResponseWriter, r *http.Request) {
     }
 }
 
+// requireAuth wraps a handler so every request must carry the configured bearer token
+// or basic-auth credentials. With neither configured the handler is returned unchanged.
+func requireAuth(cfg *config.Config, next http.Handler) http.Handler {
+    if cfg.WebAuthToken == "" && cfg.WebAuthUsername == "" {
+        return next
+    }
+
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if cfg.WebAuthToken != "" {
+            if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") &&
+                subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(cfg.WebAuthToken)) == 1 {
+                next.ServeHTTP(w, r)
+                return
+            }
+        }
+        if cfg.WebAuthUsername != "" {
+            if user, pass, ok := r.BasicAuth(); ok &&
+                subtle.ConstantTimeCompare([]byte(user), []byte(cfg.WebAuthUsername)) == 1 &&
+                subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.WebAuthPassword)) == 1 {
+                next.ServeHTTP(w, r)
+                return
+            }
+            w.Header().Set("WWW-Authenticate", `Basic realm="s3-benchmark"`)
+        }
+        http.Error(w, "unauthorized", http.StatusUnauthorized)
+    })
+}
+
+// webPortAttempts is the number of consecutive ports tried when the configured port is in use.
+const webPortAttempts = 10
+
+// listenWeb opens the web server listener on the configured address, moving on to the
+// next ports when the configured one is already in use.
+func listenWeb(cfg *config.Config) (net.Listener, error) {
+    var lastErr error
+    for port := cfg.WebPort; port < cfg.WebPort+webPortAttempts; port++ {
+        listener, err := net.Listen("tcp", net.JoinHostPort(cfg.WebListenAddress, strconv.Itoa(port)))
+        if err == nil {
+            if port != cfg.WebPort {
+                fmt.Printf("Port %d is in use, web server moved to port %d\n", cfg.WebPort, port)
+            }
+            return listener, nil
+        }
+        lastErr = err
+    }
+    return nil, fmt.Errorf("no free port in %d-%d: %w", cfg.WebPort, cfg.WebPort+webPortAttempts-1, lastErr)
+}
+
 // startWebServer initializes the HTTP server with the necessary routes and starts it.
-func startWebServer() {
+func startWebServer(cfg *config.Config) error {
+    if cfg.WebAssetsDir != "" {
+        webAssets = overlayFS{dir: os.DirFS(cfg.WebAssetsDir), base: embeddedAssets}
+    }
+
     // Route for the dashboard.
     http.HandleFunc("/", dashboardHandler)
 
@@ -79,16 +181,40 @@ func startWebServer() {
     // Route for Server-Sent Events.
     http.HandleFunc("/events", sseHandler)
 
+    // Routes for controlling runs remotely.
+    registerControlRoutes()
+
+    // Routes for browsing past runs.
+    registerHistoryRoutes(cfg)
+
     // Serve static files (CSS, JS, etc.).
-    fs := http.FileServer(http.Dir("static"))
-    http.Handle("/static/", http.StripPrefix("/static/", fs))
+    staticFiles, err := fs.Sub(webAssets, "static")
+    if err != nil {
+        return fmt.Errorf("error loading static files: %w", err)
+    }
+    http.Handle("/static/", http.StripPrefix("/static/", http.FileServerFS(staticFiles)))
+
+    handler := requireAuth(cfg, http.DefaultServeMux)
+
+    listener, err := listenWeb(cfg)
+    if err != nil {
+        return fmt.Errorf("error starting web server: %w", err)
+    }
 
     // Start the server in a separate goroutine.
     go func() {
-        fmt.Println("Web server started on port 8080")
-        if err := http.ListenAndServe(":8080", nil); err != nil {
-            panic(err)
+        server := &http.Server{Handler: handler}
+        if cfg.WebTLSCertFile != "" {
+            fmt.Printf("Web server started on https://%s\n", listener.Addr())
+            err = server.ServeTLS(listener, cfg.WebTLSCertFile, cfg.WebTLSKeyFile)
+        } else {
+            fmt.Printf("Web server started on http://%s\n", listener.Addr())
+            err = server.Serve(listener)
+        }
+        if err != nil {
+            fmt.Printf("Web server stopped: %v\n", err)
         }
     }()
-}
 
+    return nil
+}